@@ -0,0 +1,88 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	cerrors "github.com/drausin/libri/libri/common/errors"
+	api "github.com/elixirhealth/directory/pkg/directoryapi"
+	bserver "github.com/elixirhealth/service-base/pkg/server"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+const (
+	directoryFlag     = "directory"
+	thresholdFlag     = "threshold"
+	limitFlag         = "limit"
+	dedupeTimeoutFlag = "dedupeTimeout"
+)
+
+var dedupeCmd = &cobra.Command{
+	Use:   "dedupe",
+	Short: "list probable duplicate patients for review",
+	Run: func(cmd *cobra.Command, args []string) {
+		if err := dedupe(os.Stdout); err != nil {
+			log.Fatal(err)
+		}
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(dedupeCmd)
+
+	dedupeCmd.Flags().String(directoryFlag,
+		fmt.Sprintf("localhost:%d", bserver.DefaultServerPort),
+		"address of the directory")
+	dedupeCmd.Flags().Float32(thresholdFlag, api.DefaultDuplicateThreshold,
+		"minimum score in [0, 1] of listed duplicate pairs")
+	dedupeCmd.Flags().Uint(limitFlag, api.DefaultDuplicatesLimit,
+		"maximum number of duplicate pairs to list")
+	dedupeCmd.Flags().Uint(dedupeTimeoutFlag, 30,
+		"timeout (secs) of the find duplicates request")
+
+	// bind viper flags
+	viper.SetEnvPrefix(envVarPrefix) // look for env vars with prefix
+	viper.AutomaticEnv()             // read in environment variables that match
+	cerrors.MaybePanic(viper.BindPFlags(dedupeCmd.Flags()))
+}
+
+// dedupe finds probable duplicate patients in the directory and writes the pairs to w, one per
+// line, from highest score to lowest.
+func dedupe(w io.Writer) error {
+	timeout := time.Duration(viper.GetInt(dedupeTimeoutFlag) * 1e9)
+	conn, err := bserver.NewInsecureDialer().Dial(viper.GetString(directoryFlag))
+	if err != nil {
+		return err
+	}
+	defer func() { cerrors.MaybePanic(conn.Close()) }()
+	client := api.NewDirectoryClient(conn)
+
+	rq := &api.FindDuplicatesRequest{
+		Threshold: float32(viper.GetFloat64(thresholdFlag)),
+		Limit:     uint32(viper.GetInt(limitFlag)),
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	rp, err := client.FindDuplicates(ctx, rq)
+	if err != nil {
+		return err
+	}
+	return writeDuplicatePairs(w, rp.Pairs)
+}
+
+func writeDuplicatePairs(w io.Writer, pairs []*api.DuplicatePair) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "SCORE\tNAME SIM\tBIRTHDATE MATCH\tENTITY ID 1\tNAME 1\tENTITY ID 2\tNAME 2")
+	for _, p := range pairs {
+		fmt.Fprintf(tw, "%.3f\t%.3f\t%t\t%s\t%s\t%s\t%s\n", p.Score, p.NameSimilarity,
+			p.BirthdateMatch, p.Entity1.EntityId, p.Entity1.Name(), p.Entity2.EntityId,
+			p.Entity2.Name())
+	}
+	return tw.Flush()
+}
@@ -0,0 +1,61 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+
+	api "github.com/elixirhealth/directory/pkg/directoryapi"
+	"github.com/elixirhealth/directory/pkg/server"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap/zapcore"
+)
+
+func TestDedupe(t *testing.T) {
+	config := server.NewDefaultConfig()
+	config.LogLevel = zapcore.DebugLevel
+	config.ServerPort = 10202
+	config.MetricsPort = 10203
+
+	up := make(chan *server.Directory, 1)
+	wg1 := new(sync.WaitGroup)
+	wg1.Add(1)
+	go func(wg2 *sync.WaitGroup) {
+		defer wg2.Done()
+		err := server.Start(config, up)
+		assert.Nil(t, err)
+	}(wg1)
+
+	x := <-up
+	birthdate := &api.Date{Year: 1980, Month: 1, Day: 1}
+	for _, lastName := range []string{"Smith", "Smyth", "Doe"} {
+		rq := &api.PutEntityRequest{
+			Entity: api.NewPatient("", &api.Patient{
+				LastName:  lastName,
+				FirstName: "John",
+				Birthdate: birthdate,
+			}),
+		}
+		_, err := x.PutEntity(context.Background(), rq)
+		assert.Nil(t, err)
+	}
+	viper.Set(directoryFlag, fmt.Sprintf("localhost:%d", config.ServerPort))
+	viper.Set(thresholdFlag, 0.7)
+	viper.Set(limitFlag, 10)
+	viper.Set(dedupeTimeoutFlag, 3)
+
+	out := new(bytes.Buffer)
+	err := dedupe(out)
+	assert.Nil(t, err)
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	assert.Equal(t, 2, len(lines))
+	assert.Contains(t, lines[1], "John Smith")
+	assert.Contains(t, lines[1], "John Smyth")
+
+	x.StopServer()
+	wg1.Wait()
+}
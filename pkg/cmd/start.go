@@ -8,6 +8,7 @@ import (
 	cerrors "github.com/drausin/libri/libri/common/errors"
 	"github.com/drausin/libri/libri/common/logging"
 	"github.com/elixirhealth/directory/pkg/server"
+	"github.com/elixirhealth/directory/pkg/server/storage"
 	bserver "github.com/elixirhealth/service-base/pkg/server"
 	bstorage "github.com/elixirhealth/service-base/pkg/server/storage"
 	"github.com/spf13/cobra"
@@ -16,13 +17,19 @@ import (
 )
 
 const (
-	serverPortFlag      = "serverPort"
-	metricsPortFlag     = "metricsPort"
-	profilerPortFlag    = "profilerPort"
-	profileFlag         = "profile"
-	dbURLFlag           = "dbURL"
-	storageMemoryFlag   = "storageMemory"
-	storagePostgresFlag = "storagePostgres"
+	serverPortFlag        = "serverPort"
+	metricsPortFlag       = "metricsPort"
+	profilerPortFlag      = "profilerPort"
+	profileFlag           = "profile"
+	dbURLFlag             = "dbURL"
+	storageMemoryFlag     = "storageMemory"
+	storagePostgresFlag   = "storagePostgres"
+	failPartialSearchFlag = "failPartialSearch"
+	nicknamesFileFlag     = "nicknamesFile"
+	combinationFlag       = "searchCombination"
+	searcherWeightsFlag   = "searcherWeights"
+	acpRateLimitFlag      = "autocompleteRateLimit"
+	acpBurstFlag          = "autocompleteBurst"
 )
 
 var (
@@ -62,6 +69,18 @@ func init() {
 	startCmd.Flags().Bool(storagePostgresFlag, false,
 		"use Postgres DB storage")
 	startCmd.Flags().String(dbURLFlag, "", "Postgres DB URL")
+	startCmd.Flags().Bool(failPartialSearchFlag, server.DefaultFailPartialSearch,
+		"fail searches when some searchers time out instead of returning partial results")
+	startCmd.Flags().String(nicknamesFileFlag, "",
+		"CSV file of given-name synonym groups (e.g., Robert,Bob,Bobby) to add to the bundled ones")
+	startCmd.Flags().String(combinationFlag, storage.L2.String(),
+		"how search results' weighted searcher similarities combine: L2, SUM, or MAX")
+	startCmd.Flags().String(searcherWeightsFlag, "",
+		"searcher similarity weights (e.g., PatientEntityID=0.25,PatientName=2), with 1 the default")
+	startCmd.Flags().Float64(acpRateLimitFlag, server.DefaultAutocompleteRateLimit,
+		"max sustained autocomplete requests per second, with 0 not limiting them")
+	startCmd.Flags().Uint(acpBurstFlag, server.DefaultAutocompleteBurst,
+		"max autocomplete requests allowed at once above the sustained rate")
 
 	// bind viper flags
 	viper.SetEnvPrefix(envVarPrefix) // look for env vars with "DIRECTORY_" prefix
@@ -82,7 +101,18 @@ func getDirectoryConfig() (*server.Config, error) {
 		WithProfile(viper.GetBool(profileFlag))
 
 	c.Storage.Type = storageType
-	c.WithDBUrl(viper.GetString(dbURLFlag))
+	if c.Storage.Ranking, err = getRanking(); err != nil {
+		return nil, err
+	}
+	c.WithDBUrl(viper.GetString(dbURLFlag)).
+		WithFailPartialSearch(viper.GetBool(failPartialSearchFlag)).
+		WithAutocompleteRateLimit(viper.GetFloat64(acpRateLimitFlag),
+			uint(viper.GetInt(acpBurstFlag)))
+	if nicknamesFile := viper.GetString(nicknamesFileFlag); nicknamesFile != "" {
+		if err := loadNicknames(c.Storage.Nicknames, nicknamesFile); err != nil {
+			return nil, err
+		}
+	}
 
 	lg := logging.NewDevLogger(c.LogLevel)
 	lg.Info("successfully parsed config", zap.Object("config", c))
@@ -90,6 +120,30 @@ func getDirectoryConfig() (*server.Config, error) {
 	return c, nil
 }
 
+func loadNicknames(nicknames storage.Nicknames, filepath string) error {
+	f, err := os.Open(filepath)
+	if err != nil {
+		return err
+	}
+	defer func() { cerrors.MaybePanic(f.Close()) }()
+	return nicknames.Load(f)
+}
+
+func getRanking() (*storage.Ranking, error) {
+	combination, err := storage.ParseCombination(viper.GetString(combinationFlag))
+	if err != nil {
+		return nil, err
+	}
+	weights, err := storage.ParseSearcherWeights(viper.GetString(searcherWeightsFlag))
+	if err != nil {
+		return nil, err
+	}
+	return &storage.Ranking{
+		Combination:     combination,
+		SearcherWeights: weights,
+	}, nil
+}
+
 func getStorageType() (bstorage.Type, error) {
 	if viper.GetBool(storageMemoryFlag) && viper.GetBool(storagePostgresFlag) {
 		return bstorage.Unspecified, errMultipleStorageTypes
@@ -1,9 +1,13 @@
 package cmd
 
 import (
+	"io/ioutil"
+	"os"
 	"testing"
 
+	"github.com/elixirhealth/directory/pkg/server/storage"
 	bstorage "github.com/elixirhealth/service-base/pkg/server/storage"
+	"github.com/pkg/errors"
 	"github.com/spf13/viper"
 	"github.com/stretchr/testify/assert"
 	"go.uber.org/zap/zapcore"
@@ -18,6 +22,11 @@ func TestGetDirectoryConfig(t *testing.T) {
 	dbURL := "some URL"
 	storageMemory := false
 	storagePostgres := true
+	failPartialSearch := true
+	combination := "max"
+	searcherWeights := "PatientEntityID=0.25"
+	acpRateLimit := 12.5
+	acpBurst := uint(5)
 
 	viper.Set(serverPortFlag, serverPort)
 	viper.Set(metricsPortFlag, metricsPort)
@@ -27,6 +36,11 @@ func TestGetDirectoryConfig(t *testing.T) {
 	viper.Set(dbURLFlag, dbURL)
 	viper.Set(storageMemoryFlag, storageMemory)
 	viper.Set(storagePostgresFlag, storagePostgres)
+	viper.Set(failPartialSearchFlag, failPartialSearch)
+	viper.Set(combinationFlag, combination)
+	viper.Set(searcherWeightsFlag, searcherWeights)
+	viper.Set(acpRateLimitFlag, acpRateLimit)
+	viper.Set(acpBurstFlag, acpBurst)
 
 	c, err := getDirectoryConfig()
 	assert.Nil(t, err)
@@ -37,6 +51,64 @@ func TestGetDirectoryConfig(t *testing.T) {
 	assert.Equal(t, profile, c.Profile)
 	assert.Equal(t, dbURL, c.DBUrl)
 	assert.Equal(t, bstorage.Postgres, c.Storage.Type)
+	assert.Equal(t, failPartialSearch, c.FailPartialSearch)
+	assert.Equal(t, acpRateLimit, c.AutocompleteRateLimit)
+	assert.Equal(t, acpBurst, c.AutocompleteBurst)
+	assert.Contains(t, c.Storage.Nicknames["BOB"], "ROBERT")
+	assert.Equal(t, &storage.Ranking{
+		Combination:     storage.Max,
+		SearcherWeights: storage.SearcherWeights{"PatientEntityID": 0.25},
+	}, c.Storage.Ranking)
+}
+
+func TestGetDirectoryConfig_nicknamesFile(t *testing.T) {
+	f, err := ioutil.TempFile("", "nicknames")
+	assert.Nil(t, err)
+	defer func() { assert.Nil(t, os.Remove(f.Name())) }()
+	_, err = f.WriteString("# local variants\nGuillermo,Memo\n")
+	assert.Nil(t, err)
+	assert.Nil(t, f.Close())
+
+	viper.Set(storageMemoryFlag, true)
+	viper.Set(storagePostgresFlag, false)
+	viper.Set(nicknamesFileFlag, f.Name())
+	defer viper.Set(nicknamesFileFlag, "")
+	c, err := getDirectoryConfig()
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"MEMO"}, c.Storage.Nicknames["GUILLERMO"])
+	assert.Contains(t, c.Storage.Nicknames["BOB"], "ROBERT")
+
+	viper.Set(nicknamesFileFlag, "/nonexistent/nicknames.csv")
+	c, err = getDirectoryConfig()
+	assert.NotNil(t, err)
+	assert.Nil(t, c)
+}
+
+func TestGetRanking(t *testing.T) {
+	viper.Set(combinationFlag, "L2")
+	viper.Set(searcherWeightsFlag, "")
+	r, err := getRanking()
+	assert.Nil(t, err)
+	assert.Equal(t, storage.NewDefaultRanking(), r)
+
+	viper.Set(combinationFlag, "mean")
+	r, err = getRanking()
+	assert.NotNil(t, err)
+	assert.Nil(t, r)
+
+	viper.Set(combinationFlag, "sum")
+	viper.Set(searcherWeightsFlag, "PatientName")
+	r, err = getRanking()
+	assert.NotNil(t, err)
+	assert.Nil(t, r)
+
+	viper.Set(searcherWeightsFlag, "EntityID=0.25")
+	r, err = getRanking()
+	assert.Equal(t, storage.ErrUnknownSearcher, errors.Cause(err))
+	assert.Nil(t, r)
+
+	viper.Set(combinationFlag, storage.L2.String())
+	viper.Set(searcherWeightsFlag, "")
 }
 
 func TestGetCacheStorageType(t *testing.T) {
@@ -2,7 +2,9 @@ package directoryapi
 
 import (
 	"fmt"
+	"strings"
 
+	"github.com/golang/protobuf/proto"
 	"github.com/pkg/errors"
 )
 
@@ -13,11 +15,76 @@ const (
 	// MaxSearchQueryLen is the maximum length for an entity search query.
 	MaxSearchQueryLen = 32
 
+	// MaxRawSearchQueryLen is the maximum length for an entity search query including its
+	// field-scoped terms.
+	MaxRawSearchQueryLen = 128
+
 	// MinSearchLimit is the minimum size for an entity search limit.
 	MinSearchLimit = 1
 
 	// MaxSearchLimit is the maximum size for an entity search limit.
 	MaxSearchLimit = 8
+
+	// MaxSearchDepth is the maximum number of ranked results, across all pages, that a search
+	// can page through.
+	MaxSearchDepth = 256
+
+	// MaxBatchSize is the maximum number of entities or entity IDs in a batch request.
+	MaxBatchSize = 64
+
+	// DefaultListPageSize is the page size used when a list request does not specify one.
+	DefaultListPageSize = 32
+
+	// MaxListPageSize is the maximum page size for a list request.
+	MaxListPageSize = 256
+
+	// DefaultDuplicateThreshold is the minimum duplicate pair score used when a find duplicates
+	// request does not specify one.
+	DefaultDuplicateThreshold = 0.8
+
+	// DefaultDuplicatesLimit is the limit used when a find duplicates request does not specify
+	// one.
+	DefaultDuplicatesLimit = 100
+
+	// MaxDuplicatesLimit is the maximum limit for a find duplicates request.
+	MaxDuplicatesLimit = 1000
+
+	// DefaultMatchLimit is the limit used when a match patient request does not specify one.
+	DefaultMatchLimit = 8
+
+	// MaxMatchLimit is the maximum limit for a match patient request.
+	MaxMatchLimit = 64
+
+	// Match is the classification of a candidate whose match weight is at least the match
+	// threshold.
+	Match = "MATCH"
+
+	// PossibleMatch is the classification of a candidate whose match weight is at least the
+	// possible match threshold but below the match threshold.
+	PossibleMatch = "POSSIBLE_MATCH"
+
+	// NonMatch is the classification of a candidate whose match weight is below the possible
+	// match threshold.
+	NonMatch = "NON_MATCH"
+
+	// MinAutocompletePrefixLen is the minimum length for an autocomplete prefix, after folding.
+	MinAutocompletePrefixLen = 2
+
+	// MaxAutocompletePrefixLen is the maximum length for an autocomplete prefix, after folding.
+	MaxAutocompletePrefixLen = 32
+
+	// DefaultAutocompleteLimit is the limit used when an autocomplete request does not specify
+	// one.
+	DefaultAutocompleteLimit = 8
+
+	// MaxAutocompleteLimit is the maximum limit for an autocomplete request.
+	MaxAutocompleteLimit = 16
+
+	// PatientType is the entity type of patient entities.
+	PatientType = "PATIENT"
+
+	// OfficeType is the entity type of office entities.
+	OfficeType = "OFFICE"
 )
 
 var (
@@ -27,6 +94,12 @@ var (
 	// ErrGetMissingEntityID denotes when a get request is missing the entity ID.
 	ErrGetMissingEntityID = errors.New("get request missing entity ID")
 
+	// ErrDeleteMissingEntityID denotes when a delete request is missing the entity ID.
+	ErrDeleteMissingEntityID = errors.New("delete request missing entity ID")
+
+	// ErrGetHistoryMissingEntityID denotes when a get history request is missing the entity ID.
+	ErrGetHistoryMissingEntityID = errors.New("get history request missing entity ID")
+
 	// ErrMissingTypeAttributes denotes when an entity is missing the expected type_attributes
 	// field.
 	ErrMissingTypeAttributes = errors.New("entity missing type_attributes")
@@ -53,6 +126,18 @@ var (
 	ErrSearchQueryTooLong = fmt.Errorf("search query longer than max length %d",
 		MaxSearchQueryLen)
 
+	// ErrRawSearchQueryTooLong identifies when a search query string, including its field-scoped
+	// terms, is longer than the maximum length.
+	ErrRawSearchQueryTooLong = fmt.Errorf("search query longer than max raw length %d",
+		MaxRawSearchQueryLen)
+
+	// ErrSearchUnknownEntityType identifies when a search filter has an unknown entity type.
+	ErrSearchUnknownEntityType = errors.New("search filter has unknown entity type")
+
+	// ErrSearchInvalidBirthdateRange identifies when a search filter's birthdate range starts
+	// after it ends.
+	ErrSearchInvalidBirthdateRange = errors.New("search filter birthdate range starts after end")
+
 	// ErrSearchLimitTooSmall identifies when a search limit is smaller than the minimum value.
 	ErrSearchLimitTooSmall = fmt.Errorf("search limit smaller than min length %d",
 		MinSearchLimit)
@@ -61,9 +146,120 @@ var (
 	ErrSearchLimitTooLarge = fmt.Errorf("search limit larger than max length %d",
 		MaxSearchLimit)
 
+	// ErrEmptyBatch identifies when a batch request has no entities or entity IDs.
+	ErrEmptyBatch = errors.New("batch request is empty")
+
+	// ErrBatchTooLarge identifies when a batch request has more than the maximum number of
+	// entities or entity IDs.
+	ErrBatchTooLarge = fmt.Errorf("batch request larger than max size %d", MaxBatchSize)
+
+	// ErrDupBatchEntityID identifies when a batch put request has more than one entity with
+	// the same entity ID.
+	ErrDupBatchEntityID = errors.New("batch request has duplicate entity ID")
+
+	// ErrListUnknownEntityType identifies when a list request has an unknown entity type.
+	ErrListUnknownEntityType = errors.New("list request has unknown entity type")
+
+	// ErrListPageSizeTooLarge identifies when a list request page size is larger than the
+	// maximum value.
+	ErrListPageSizeTooLarge = fmt.Errorf("list page size larger than max size %d",
+		MaxListPageSize)
+
+	// ErrPatchMissingEntity denotes when a patch request is missing the entity.
+	ErrPatchMissingEntity = errors.New("patch request missing entity")
+
+	// ErrPatchMissingEntityID denotes when a patch request is missing the entity ID.
+	ErrPatchMissingEntityID = errors.New("patch request missing entity ID")
+
+	// ErrPatchMissingUpdateMask denotes when a patch request has no fields to update.
+	ErrPatchMissingUpdateMask = errors.New("patch request missing update mask")
+
+	// ErrPatchInvalidPath denotes when a patch update mask path is not a field of the entity's
+	// type.
+	ErrPatchInvalidPath = errors.New("patch update mask path invalid for entity type")
+
+	// ErrPatchTypeMismatch denotes when a patch has a different type than the entity it patches.
+	ErrPatchTypeMismatch = errors.New("patch type does not match entity type")
+
+	// ErrMergeMissingSurvivorID denotes when a merge request is missing the survivor entity ID.
+	ErrMergeMissingSurvivorID = errors.New("merge request missing survivor entity ID")
+
+	// ErrMergeMissingMergedID denotes when a merge request is missing the merged entity ID.
+	ErrMergeMissingMergedID = errors.New("merge request missing merged entity ID")
+
+	// ErrMergeSameEntity denotes when a merge request has the same survivor and merged entity
+	// IDs.
+	ErrMergeSameEntity = errors.New("merge request survivor and merged entity IDs are the same")
+
+	// ErrDuplicateThresholdOutOfRange denotes when a find duplicates request threshold is not
+	// in [0, 1].
+	ErrDuplicateThresholdOutOfRange = errors.New("duplicate threshold not in [0, 1]")
+
+	// ErrDuplicatesLimitTooLarge denotes when a find duplicates request limit is larger than
+	// the maximum value.
+	ErrDuplicatesLimitTooLarge = fmt.Errorf("duplicates limit larger than max limit %d",
+		MaxDuplicatesLimit)
+
+	// ErrMatchMissingPatient denotes when a match request is missing the patient.
+	ErrMatchMissingPatient = errors.New("match request missing patient")
+
+	// ErrMatchPatientEmpty denotes when a match request patient has none of the fields used to
+	// find candidates.
+	ErrMatchPatientEmpty = errors.New("match patient missing names and birthdate")
+
+	// ErrMatchLimitTooLarge denotes when a match request limit is larger than the maximum value.
+	ErrMatchLimitTooLarge = fmt.Errorf("match limit larger than max limit %d", MaxMatchLimit)
+
+	// ErrRevertMissingEntityID denotes when a revert request is missing the entity ID.
+	ErrRevertMissingEntityID = errors.New("revert request missing entity ID")
+
+	// ErrRevertMissingToVersion denotes when a revert request is missing the version to revert
+	// to.
+	ErrRevertMissingToVersion = errors.New("revert request missing version to revert to")
+
+	// ErrRevertMissingRevertedBy denotes when a revert request is missing who is reverting.
+	ErrRevertMissingRevertedBy = errors.New("revert request missing reverted by")
+
+	// ErrRevertMissingReason denotes when a revert request is missing the reason for reverting.
+	ErrRevertMissingReason = errors.New("revert request missing reason")
+
+	// ErrAutocompletePrefixTooShort denotes when an autocomplete prefix, after folding, is
+	// shorter than the minimum length.
+	ErrAutocompletePrefixTooShort = fmt.Errorf("autocomplete prefix shorter than min length %d",
+		MinAutocompletePrefixLen)
+
+	// ErrAutocompletePrefixTooLong denotes when an autocomplete prefix, after folding, is longer
+	// than the maximum length.
+	ErrAutocompletePrefixTooLong = fmt.Errorf("autocomplete prefix longer than max length %d",
+		MaxAutocompletePrefixLen)
+
+	// ErrAutocompleteLimitTooLarge denotes when an autocomplete request limit is larger than the
+	// maximum value.
+	ErrAutocompleteLimitTooLarge = fmt.Errorf("autocomplete limit larger than max limit %d",
+		MaxAutocompleteLimit)
+
+	// ErrAutocompleteUnknownEntityType denotes when an autocomplete request has an entity type
+	// that is neither PATIENT nor OFFICE.
+	ErrAutocompleteUnknownEntityType = errors.New("autocomplete request has unknown entity type")
+
 	errUnknownEntityType = errors.New("unknown entity type")
 )
 
+// patientPatchers and officePatchers copy the field at each update mask path from a patch to an
+// entity of the corresponding type
+var (
+	patientPatchers = map[string]func(dst, src *Patient){
+		"patient.last_name":   func(dst, src *Patient) { dst.LastName = src.LastName },
+		"patient.first_name":  func(dst, src *Patient) { dst.FirstName = src.FirstName },
+		"patient.middle_name": func(dst, src *Patient) { dst.MiddleName = src.MiddleName },
+		"patient.suffix":      func(dst, src *Patient) { dst.Suffix = src.Suffix },
+		"patient.birthdate":   func(dst, src *Patient) { dst.Birthdate = src.Birthdate },
+	}
+	officePatchers = map[string]func(dst, src *Office){
+		"office.name": func(dst, src *Office) { dst.Name = src.Name },
+	}
+)
+
 // ValidatePutEntityRequest checks that the PutEntityRequest has the required fields populated.
 func ValidatePutEntityRequest(rq *PutEntityRequest) error {
 	if rq.Entity == nil {
@@ -80,6 +276,227 @@ func ValidateGetEntityRequest(rq *GetEntityRequest) error {
 	return nil
 }
 
+// ValidateDeleteEntityRequest checks that the DeleteEntityRequest has the required fields
+// populated.
+func ValidateDeleteEntityRequest(rq *DeleteEntityRequest) error {
+	if rq.EntityId == "" {
+		return ErrDeleteMissingEntityID
+	}
+	return nil
+}
+
+// ValidateGetEntityHistoryRequest checks that the GetEntityHistoryRequest has the required
+// fields populated.
+func ValidateGetEntityHistoryRequest(rq *GetEntityHistoryRequest) error {
+	if rq.EntityId == "" {
+		return ErrGetHistoryMissingEntityID
+	}
+	return nil
+}
+
+// ValidatePutEntitiesRequest checks that the PutEntitiesRequest has a valid batch of entities.
+func ValidatePutEntitiesRequest(rq *PutEntitiesRequest) error {
+	return ValidateEntityBatch(rq.Entities)
+}
+
+// ValidateGetEntitiesRequest checks that the GetEntitiesRequest has a valid batch of entity IDs.
+func ValidateGetEntitiesRequest(rq *GetEntitiesRequest) error {
+	return ValidateEntityIDBatch(rq.EntityIds)
+}
+
+// ValidateEntityBatch checks that the batch of entities is within the required size and that no
+// two entities have the same entity ID. It does not validate the individual entities.
+func ValidateEntityBatch(es []*Entity) error {
+	if err := validateBatchSize(len(es)); err != nil {
+		return err
+	}
+	entityIDs := make(map[string]struct{})
+	for _, e := range es {
+		if e == nil || e.EntityId == "" {
+			continue
+		}
+		if _, in := entityIDs[e.EntityId]; in {
+			return ErrDupBatchEntityID
+		}
+		entityIDs[e.EntityId] = struct{}{}
+	}
+	return nil
+}
+
+// ValidateEntityIDBatch checks that the batch of entity IDs is within the required size. It does
+// not validate the individual entity IDs.
+func ValidateEntityIDBatch(entityIDs []string) error {
+	return validateBatchSize(len(entityIDs))
+}
+
+// ValidateListEntitiesRequest checks that the ListEntitiesRequest has a known entity type and a
+// page size within the required range.
+func ValidateListEntitiesRequest(rq *ListEntitiesRequest) error {
+	if rq.EntityType != PatientType && rq.EntityType != OfficeType {
+		return ErrListUnknownEntityType
+	}
+	if rq.PageSize > MaxListPageSize {
+		return ErrListPageSizeTooLarge
+	}
+	return nil
+}
+
+// ValidatePatchEntityRequest checks that the PatchEntityRequest has the required fields populated
+// and that its update mask paths are fields of the entity's type.
+func ValidatePatchEntityRequest(rq *PatchEntityRequest) error {
+	if rq.Entity == nil {
+		return ErrPatchMissingEntity
+	}
+	if rq.Entity.EntityId == "" {
+		return ErrPatchMissingEntityID
+	}
+	if rq.UpdateMask == nil || len(rq.UpdateMask.Paths) == 0 {
+		return ErrPatchMissingUpdateMask
+	}
+	return ValidatePatchPaths(rq.Entity, rq.UpdateMask.Paths)
+}
+
+// ValidateMergeEntitiesRequest checks that the MergeEntitiesRequest has distinct survivor and
+// merged entity IDs.
+func ValidateMergeEntitiesRequest(rq *MergeEntitiesRequest) error {
+	if rq.SurvivorId == "" {
+		return ErrMergeMissingSurvivorID
+	}
+	if rq.MergedId == "" {
+		return ErrMergeMissingMergedID
+	}
+	if rq.SurvivorId == rq.MergedId {
+		return ErrMergeSameEntity
+	}
+	return nil
+}
+
+// ValidateRevertEntityRequest checks that the RevertEntityRequest has the required fields
+// populated.
+func ValidateRevertEntityRequest(rq *RevertEntityRequest) error {
+	if rq.EntityId == "" {
+		return ErrRevertMissingEntityID
+	}
+	if rq.ToVersion == 0 {
+		return ErrRevertMissingToVersion
+	}
+	if rq.RevertedBy == "" {
+		return ErrRevertMissingRevertedBy
+	}
+	if rq.Reason == "" {
+		return ErrRevertMissingReason
+	}
+	return nil
+}
+
+// ValidateFindDuplicatesRequest checks that the FindDuplicatesRequest threshold and limit are
+// within their allowed ranges.
+func ValidateFindDuplicatesRequest(rq *FindDuplicatesRequest) error {
+	if rq.Threshold < 0 || rq.Threshold > 1 {
+		return ErrDuplicateThresholdOutOfRange
+	}
+	if rq.Limit > MaxDuplicatesLimit {
+		return ErrDuplicatesLimitTooLarge
+	}
+	return nil
+}
+
+// ValidateMatchPatientRequest checks that the MatchPatientRequest has a patient with a name or
+// birthdate to find candidates by and a limit within the allowed range.
+func ValidateMatchPatientRequest(rq *MatchPatientRequest) error {
+	if rq.Patient == nil {
+		return ErrMatchMissingPatient
+	}
+	if rq.Patient.LastName == "" && rq.Patient.FirstName == "" && rq.Patient.Birthdate == nil {
+		return ErrMatchPatientEmpty
+	}
+	if rq.Limit > MaxMatchLimit {
+		return ErrMatchLimitTooLarge
+	}
+	return nil
+}
+
+// ValidateAutocompleteRequest checks that the AutocompleteRequest fields have values within the
+// required ranges/sizes. The prefix length is that of the folded prefix, which is what is matched.
+func ValidateAutocompleteRequest(rq *AutocompleteRequest) error {
+	prefix := FoldName(rq.Prefix)
+	if len(prefix) < MinAutocompletePrefixLen {
+		return ErrAutocompletePrefixTooShort
+	}
+	if len(prefix) > MaxAutocompletePrefixLen {
+		return ErrAutocompletePrefixTooLong
+	}
+	if rq.Limit > MaxAutocompleteLimit {
+		return ErrAutocompleteLimitTooLarge
+	}
+	for _, et := range rq.EntityTypes {
+		if et != PatientType && et != OfficeType {
+			return ErrAutocompleteUnknownEntityType
+		}
+	}
+	return nil
+}
+
+// ValidatePatchPaths checks that the update mask paths are fields of the patch entity's type.
+func ValidatePatchPaths(patch *Entity, paths []string) error {
+	if len(paths) == 0 {
+		return ErrPatchMissingUpdateMask
+	}
+	switch ta := patch.TypeAttributes.(type) {
+	case *Entity_Patient:
+		if ta.Patient == nil {
+			return ErrMissingTypeAttributes
+		}
+		for _, path := range paths {
+			if _, in := patientPatchers[path]; !in {
+				return ErrPatchInvalidPath
+			}
+		}
+	case *Entity_Office:
+		if ta.Office == nil {
+			return ErrMissingTypeAttributes
+		}
+		for _, path := range paths {
+			if _, in := officePatchers[path]; !in {
+				return ErrPatchInvalidPath
+			}
+		}
+	default:
+		return ErrMissingTypeAttributes
+	}
+	return nil
+}
+
+// PatchEntity returns a copy of the entity with the fields at the given update mask paths set to
+// their values in the patch. It does not validate the patched entity.
+func PatchEntity(e *Entity, patch *Entity, paths []string) (*Entity, error) {
+	if err := ValidatePatchPaths(patch, paths); err != nil {
+		return nil, err
+	}
+	patched := proto.Clone(e).(*Entity)
+	switch ta := patched.TypeAttributes.(type) {
+	case *Entity_Patient:
+		src, ok := patch.TypeAttributes.(*Entity_Patient)
+		if !ok {
+			return nil, ErrPatchTypeMismatch
+		}
+		for _, path := range paths {
+			patientPatchers[path](ta.Patient, src.Patient)
+		}
+	case *Entity_Office:
+		src, ok := patch.TypeAttributes.(*Entity_Office)
+		if !ok {
+			return nil, ErrPatchTypeMismatch
+		}
+		for _, path := range paths {
+			officePatchers[path](ta.Office, src.Office)
+		}
+	default:
+		panic(errUnknownEntityType)
+	}
+	return patched, nil
+}
+
 // ValidateEntity validates that the entity has the expected fields populated given its type. It
 // does not validate that the EntityId is present or of any particular form.
 func ValidateEntity(e *Entity) error {
@@ -96,14 +513,47 @@ func ValidateEntity(e *Entity) error {
 }
 
 // ValidateSearchEntityRequest checks that the SearchEntityRequest fields have values within the
-// required ranges/sizes.
+// required ranges/sizes. The query is parsed for field-scoped terms, whose filters are validated
+// along with the request's.
 func ValidateSearchEntityRequest(rq *SearchEntityRequest) error {
-	return ValidateSearchQuery(rq.Query, rq.Limit)
+	if len(rq.Query) > MaxRawSearchQueryLen {
+		return ErrRawSearchQueryTooLong
+	}
+	q, err := ParseSearchQuery(rq.Query)
+	if err != nil {
+		return err
+	}
+	filters := q.MergeFilters(rq.AllFilters())
+	if err := ValidateSearchQuery(q.Text, filters, rq.Limit); err != nil {
+		return err
+	}
+	return ValidateSearchFilters(filters)
+}
+
+// ValidateSearchFilters checks that the (possibly nil) SearchFilters have known entity types and
+// a non-empty birthdate range.
+func ValidateSearchFilters(f *SearchFilters) error {
+	if f == nil {
+		return nil
+	}
+	for _, et := range f.EntityTypes {
+		if et != PatientType && et != OfficeType {
+			return ErrSearchUnknownEntityType
+		}
+	}
+	if f.BirthdateFrom != nil && f.BirthdateTo != nil &&
+		f.BirthdateFrom.ISO8601() > f.BirthdateTo.ISO8601() {
+		return ErrSearchInvalidBirthdateRange
+	}
+	return nil
 }
 
 // ValidateSearchQuery checks that the query and limit have values within the required ranges/sizes.
-func ValidateSearchQuery(query string, limit uint32) error {
-	if len(query) < MinSearchQueryLen {
+// The minimum length applies to the query together with the values of the (possibly nil) filters
+// that field-scoped terms set, so a short query narrowed by them is still valid.
+func ValidateSearchQuery(query string, filters *SearchFilters, limit uint32) error {
+	if query == "" || len(PatientNameQuery(query, filters))+fieldValuesLen(filters) <
+		MinSearchQueryLen {
 		return ErrSearchQueryTooShort
 	}
 	if len(query) > MaxSearchQueryLen {
@@ -118,6 +568,16 @@ func ValidateSearchQuery(query string, limit uint32) error {
 	return nil
 }
 
+func validateBatchSize(size int) error {
+	if size == 0 {
+		return ErrEmptyBatch
+	}
+	if size > MaxBatchSize {
+		return ErrBatchTooLarge
+	}
+	return nil
+}
+
 func validatePatient(p *Patient) error {
 	if p.LastName == "" {
 		return ErrPatientMissingLastName
@@ -142,9 +602,9 @@ func validateOffice(p *Office) error {
 func (m *Entity) Type() string {
 	switch m.TypeAttributes.(type) {
 	case *Entity_Patient:
-		return "PATIENT"
+		return PatientType
 	case *Entity_Office:
-		return "OFFICE"
+		return OfficeType
 	default:
 		panic(errUnknownEntityType)
 	}
@@ -162,11 +622,99 @@ func (m *Entity) Name() string {
 	}
 }
 
+// AllFilters returns the (possibly nil) filters of the request with its entity types, if any, in
+// the place of theirs.
+func (m *SearchEntityRequest) AllFilters() *SearchFilters {
+	if len(m.EntityTypes) == 0 {
+		return m.Filters
+	}
+	filters := &SearchFilters{}
+	if m.Filters != nil {
+		filters = proto.Clone(m.Filters).(*SearchFilters)
+	}
+	filters.EntityTypes = m.EntityTypes
+	return filters
+}
+
+// AllowsType returns whether the filters allow entities of the given type, which they do not if
+// the type is not among their entity types or if they filter on fields of other types.
+func (m *SearchFilters) AllowsType(entityType string) bool {
+	if m == nil {
+		return true
+	}
+	if len(m.EntityTypes) > 0 {
+		allowed := false
+		for _, et := range m.EntityTypes {
+			allowed = allowed || et == entityType
+		}
+		if !allowed {
+			return false
+		}
+	}
+	switch entityType {
+	case PatientType:
+		return m.OfficeName == ""
+	case OfficeType:
+		return m.Birthdate == nil && m.BirthdateFrom == nil && m.BirthdateTo == nil &&
+			m.LastNamePrefix == "" && m.FirstNamePrefix == "" && m.Suffix == ""
+	}
+	return false
+}
+
+// Matches returns whether the entity satisfies the (possibly nil) filters.
+func (m *SearchFilters) Matches(e *Entity) bool {
+	if !m.AllowsType(e.Type()) {
+		return false
+	}
+	if m == nil {
+		return true
+	}
+	switch ta := e.TypeAttributes.(type) {
+	case *Entity_Patient:
+		p := ta.Patient
+		if !m.matchesBirthdate(p.Birthdate) {
+			return false
+		}
+		if !strings.HasPrefix(FoldName(p.LastName), FoldName(m.LastNamePrefix)) {
+			return false
+		}
+		if !strings.HasPrefix(FoldName(p.FirstName), FoldName(m.FirstNamePrefix)) {
+			return false
+		}
+		return m.Suffix == "" || strings.EqualFold(p.Suffix, m.Suffix)
+	case *Entity_Office:
+		return m.OfficeName == "" || FoldName(ta.Office.Name) == FoldName(m.OfficeName)
+	}
+	return false
+}
+
+func (m *SearchFilters) matchesBirthdate(birthdate *Date) bool {
+	if m.Birthdate == nil && m.BirthdateFrom == nil && m.BirthdateTo == nil {
+		return true
+	}
+	if birthdate == nil {
+		return false
+	}
+	iso := birthdate.ISO8601()
+	return (m.Birthdate == nil || iso == m.Birthdate.ISO8601()) &&
+		(m.BirthdateFrom == nil || iso >= m.BirthdateFrom.ISO8601()) &&
+		(m.BirthdateTo == nil || iso <= m.BirthdateTo.ISO8601())
+}
+
 // ISO8601 returns the YYYY-MM-DD ISO 8601 date string.
 func (m *Date) ISO8601() string {
 	return fmt.Sprintf("%04d-%02d-%02d", m.Year, m.Month, m.Day)
 }
 
+// Transposed returns the date with its day and month swapped, as when it was written in the
+// other of month-first and day-first order, or nil if that is the same or not a valid date.
+func (m *Date) Transposed() *Date {
+	if m.Day == m.Month || m.Day > 12 {
+		return nil
+	}
+	return &Date{Year: m.Year, Month: m.Day, Day: m.Month}
+}
+
 // NewPatient returns an *Entity with the given entityID and wrapping the given *Patient.
 func NewPatient(entityID string, p *Patient) *Entity {
 	return &Entity{
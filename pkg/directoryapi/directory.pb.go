@@ -13,8 +13,41 @@ It has these top-level messages:
 	GetEntityRequest
 	GetEntityResponse
 	SearchEntityRequest
+	SearchFilters
 	SearchEntityResponse
+	SearchResult
+	SearchExplanation
+	SearcherMatch
+	DeleteEntityRequest
+	DeleteEntityResponse
+	GetEntityHistoryRequest
+	GetEntityHistoryResponse
+	PutEntitiesRequest
+	PutEntitiesResponse
+	PutEntityResult
+	GetEntitiesRequest
+	GetEntitiesResponse
+	GetEntityResult
+	ListEntitiesRequest
+	ListEntitiesResponse
+	PatchEntityRequest
+	PatchEntityResponse
+	MergeEntitiesRequest
+	MergeEntitiesResponse
+	RevertEntityRequest
+	RevertEntityResponse
+	FindDuplicatesRequest
+	FindDuplicatesResponse
+	DuplicatePair
+	MatchPatientRequest
+	MatchPatientResponse
+	PatientMatch
+	AutocompleteRequest
+	AutocompleteResponse
+	Suggestion
+	Revert
 	Entity
+	EntityVersion
 	Patient
 	Office
 	Date
@@ -24,6 +57,8 @@ package directoryapi
 import proto "github.com/golang/protobuf/proto"
 import fmt "fmt"
 import math "math"
+import google_protobuf "google.golang.org/genproto/protobuf/field_mask"
+import google_protobuf1 "github.com/golang/protobuf/ptypes/timestamp"
 
 import (
 	context "golang.org/x/net/context"
@@ -43,6 +78,10 @@ const _ = proto.ProtoPackageIsVersion2 // please upgrade the proto package
 
 type PutEntityRequest struct {
 	Entity *Entity `protobuf:"bytes,1,opt,name=entity" json:"entity,omitempty"`
+	// allow_create_with_id creates the entity with its given entity ID if no entity with that ID
+	// exists, e.g., when importing legacy entities whose IDs must be preserved; otherwise, putting
+	// an entity with an unknown entity ID fails
+	AllowCreateWithId bool `protobuf:"varint,2,opt,name=allow_create_with_id,json=allowCreateWithId" json:"allow_create_with_id,omitempty"`
 }
 
 func (m *PutEntityRequest) Reset()                    { *m = PutEntityRequest{} }
@@ -57,106 +96,1130 @@ func (m *PutEntityRequest) GetEntity() *Entity {
 	return nil
 }
 
+func (m *PutEntityRequest) GetAllowCreateWithId() bool {
+	if m != nil {
+		return m.AllowCreateWithId
+	}
+	return false
+}
+
 type PutEntityResponse struct {
 	EntityId string `protobuf:"bytes,1,opt,name=entity_id,json=entityId" json:"entity_id,omitempty"`
+	// version is the version of the stored entity
+	Version uint64 `protobuf:"varint,2,opt,name=version" json:"version,omitempty"`
+}
+
+func (m *PutEntityResponse) Reset()                    { *m = PutEntityResponse{} }
+func (m *PutEntityResponse) String() string            { return proto.CompactTextString(m) }
+func (*PutEntityResponse) ProtoMessage()               {}
+func (*PutEntityResponse) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{1} }
+
+func (m *PutEntityResponse) GetEntityId() string {
+	if m != nil {
+		return m.EntityId
+	}
+	return ""
+}
+
+func (m *PutEntityResponse) GetVersion() uint64 {
+	if m != nil {
+		return m.Version
+	}
+	return 0
+}
+
+type GetEntityRequest struct {
+	EntityId string `protobuf:"bytes,1,opt,name=entity_id,json=entityId" json:"entity_id,omitempty"`
+	// as_of optionally requests the entity as it was at a past time rather than its current
+	// version
+	AsOf *google_protobuf1.Timestamp `protobuf:"bytes,2,opt,name=as_of,json=asOf" json:"as_of,omitempty"`
+}
+
+func (m *GetEntityRequest) Reset()                    { *m = GetEntityRequest{} }
+func (m *GetEntityRequest) String() string            { return proto.CompactTextString(m) }
+func (*GetEntityRequest) ProtoMessage()               {}
+func (*GetEntityRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{2} }
+
+func (m *GetEntityRequest) GetEntityId() string {
+	if m != nil {
+		return m.EntityId
+	}
+	return ""
+}
+
+func (m *GetEntityRequest) GetAsOf() *google_protobuf1.Timestamp {
+	if m != nil {
+		return m.AsOf
+	}
+	return nil
+}
+
+type GetEntityResponse struct {
+	Entity *Entity `protobuf:"bytes,1,opt,name=entity" json:"entity,omitempty"`
+	// merged_into_id is the entity ID of the returned entity when the requested entity was
+	// merged into it, and is empty otherwise
+	MergedIntoId string `protobuf:"bytes,2,opt,name=merged_into_id,json=mergedIntoId" json:"merged_into_id,omitempty"`
+}
+
+func (m *GetEntityResponse) Reset()                    { *m = GetEntityResponse{} }
+func (m *GetEntityResponse) String() string            { return proto.CompactTextString(m) }
+func (*GetEntityResponse) ProtoMessage()               {}
+func (*GetEntityResponse) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{3} }
+
+func (m *GetEntityResponse) GetEntity() *Entity {
+	if m != nil {
+		return m.Entity
+	}
+	return nil
+}
+
+func (m *GetEntityResponse) GetMergedIntoId() string {
+	if m != nil {
+		return m.MergedIntoId
+	}
+	return ""
+}
+
+type SearchEntityRequest struct {
+	// query is free text to match, optionally with field-scoped terms like last:smith,
+	// first:jo, dob:1980-02-03, type:patient, suffix:jr, or office:"Main St Clinic" that
+	// restrict the results like the corresponding filters. A date in the free text, like
+	// 03/14/1975, boosts patients born on it or on it with its day and month transposed. Given
+	// names in the free text also match their nicknames and other synonyms, e.g., bob jones
+	// matches Robert Jones. Names are matched ignoring accents and punctuation, so munoz obrien
+	// matches Muñoz O'Brien.
+	Query string `protobuf:"bytes,1,opt,name=query" json:"query,omitempty"`
+	Limit uint32 `protobuf:"varint,2,opt,name=limit" json:"limit,omitempty"`
+	// as_of optionally requests searching the entities as they were at a past time rather than
+	// their current versions
+	AsOf *google_protobuf1.Timestamp `protobuf:"bytes,3,opt,name=as_of,json=asOf" json:"as_of,omitempty"`
+	// page_token is the next_page_token of a previous response for the same query to continue
+	// down the ranked results from, with empty denoting the top. The results are ranked as of
+	// when the top page was searched, so writes in between don't shift them.
+	PageToken string `protobuf:"bytes,4,opt,name=page_token,json=pageToken" json:"page_token,omitempty"`
+	// explain requests an explanation of how each result was ranked
+	Explain bool `protobuf:"varint,5,opt,name=explain" json:"explain,omitempty"`
+	// filters optionally restrict the results matching the query to those with the given field
+	// values
+	Filters *SearchFilters `protobuf:"bytes,6,opt,name=filters" json:"filters,omitempty"`
+	// entity_types restricts results to entities of the given types (e.g., PATIENT or OFFICE),
+	// with empty allowing all. Only the searchers for entities of these types are run. When
+	// given, they take the place of the entity types of the filters.
+	EntityTypes []string `protobuf:"bytes,7,rep,name=entity_types,json=entityTypes" json:"entity_types,omitempty"`
+}
+
+func (m *SearchEntityRequest) Reset()                    { *m = SearchEntityRequest{} }
+func (m *SearchEntityRequest) String() string            { return proto.CompactTextString(m) }
+func (*SearchEntityRequest) ProtoMessage()               {}
+func (*SearchEntityRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{4} }
+
+func (m *SearchEntityRequest) GetQuery() string {
+	if m != nil {
+		return m.Query
+	}
+	return ""
+}
+
+func (m *SearchEntityRequest) GetLimit() uint32 {
+	if m != nil {
+		return m.Limit
+	}
+	return 0
+}
+
+func (m *SearchEntityRequest) GetAsOf() *google_protobuf1.Timestamp {
+	if m != nil {
+		return m.AsOf
+	}
+	return nil
+}
+
+func (m *SearchEntityRequest) GetPageToken() string {
+	if m != nil {
+		return m.PageToken
+	}
+	return ""
+}
+
+func (m *SearchEntityRequest) GetExplain() bool {
+	if m != nil {
+		return m.Explain
+	}
+	return false
+}
+
+func (m *SearchEntityRequest) GetFilters() *SearchFilters {
+	if m != nil {
+		return m.Filters
+	}
+	return nil
+}
+
+func (m *SearchEntityRequest) GetEntityTypes() []string {
+	if m != nil {
+		return m.EntityTypes
+	}
+	return nil
+}
+
+type SearchFilters struct {
+	// entity_types restricts results to entities of the given types (e.g., PATIENT or OFFICE),
+	// with empty allowing all. Only the searchers for entities of these types are run.
+	EntityTypes []string `protobuf:"bytes,1,rep,name=entity_types,json=entityTypes" json:"entity_types,omitempty"`
+	// birthdate restricts results to patients born on the given date
+	Birthdate *Date `protobuf:"bytes,2,opt,name=birthdate" json:"birthdate,omitempty"`
+	// birthdate_from and birthdate_to restrict results to patients born on or after and on or
+	// before the given dates, respectively
+	BirthdateFrom *Date `protobuf:"bytes,3,opt,name=birthdate_from,json=birthdateFrom" json:"birthdate_from,omitempty"`
+	BirthdateTo   *Date `protobuf:"bytes,4,opt,name=birthdate_to,json=birthdateTo" json:"birthdate_to,omitempty"`
+	// last_name_prefix restricts results to patients whose last names start with the given
+	// prefix, ignoring case
+	LastNamePrefix string `protobuf:"bytes,5,opt,name=last_name_prefix,json=lastNamePrefix" json:"last_name_prefix,omitempty"`
+	// suffix restricts results to patients with the given suffix, ignoring case
+	Suffix string `protobuf:"bytes,6,opt,name=suffix" json:"suffix,omitempty"`
+	// office_name restricts results to offices with the given name, ignoring case
+	OfficeName string `protobuf:"bytes,7,opt,name=office_name,json=officeName" json:"office_name,omitempty"`
+	// first_name_prefix restricts results to patients whose first names start with the given
+	// prefix, ignoring case
+	FirstNamePrefix string `protobuf:"bytes,8,opt,name=first_name_prefix,json=firstNamePrefix" json:"first_name_prefix,omitempty"`
+}
+
+func (m *SearchFilters) Reset()                    { *m = SearchFilters{} }
+func (m *SearchFilters) String() string            { return proto.CompactTextString(m) }
+func (*SearchFilters) ProtoMessage()               {}
+func (*SearchFilters) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{5} }
+
+func (m *SearchFilters) GetEntityTypes() []string {
+	if m != nil {
+		return m.EntityTypes
+	}
+	return nil
+}
+
+func (m *SearchFilters) GetBirthdate() *Date {
+	if m != nil {
+		return m.Birthdate
+	}
+	return nil
+}
+
+func (m *SearchFilters) GetBirthdateFrom() *Date {
+	if m != nil {
+		return m.BirthdateFrom
+	}
+	return nil
+}
+
+func (m *SearchFilters) GetBirthdateTo() *Date {
+	if m != nil {
+		return m.BirthdateTo
+	}
+	return nil
+}
+
+func (m *SearchFilters) GetLastNamePrefix() string {
+	if m != nil {
+		return m.LastNamePrefix
+	}
+	return ""
+}
+
+func (m *SearchFilters) GetSuffix() string {
+	if m != nil {
+		return m.Suffix
+	}
+	return ""
+}
+
+func (m *SearchFilters) GetOfficeName() string {
+	if m != nil {
+		return m.OfficeName
+	}
+	return ""
+}
+
+func (m *SearchFilters) GetFirstNamePrefix() string {
+	if m != nil {
+		return m.FirstNamePrefix
+	}
+	return ""
+}
+
+type SearchEntityResponse struct {
+	// entities are the entities of the results, in the same order.
+	// Deprecated: read the results instead.
+	Entities []*Entity `protobuf:"bytes,1,rep,name=entities" json:"entities,omitempty"`
+	// results are the matching entities with their similarities, ordered most similar to least
+	Results []*SearchResult `protobuf:"bytes,3,rep,name=results" json:"results,omitempty"`
+	// next_page_token continues down the ranked results after this page, and is empty when
+	// there are no more results
+	NextPageToken string `protobuf:"bytes,2,opt,name=next_page_token,json=nextPageToken" json:"next_page_token,omitempty"`
+	// timed_out_searchers are the searchers that timed out before returning all their results
+	TimedOutSearchers []string `protobuf:"bytes,4,rep,name=timed_out_searchers,json=timedOutSearchers" json:"timed_out_searchers,omitempty"`
+	// partial indicates when some searchers timed out, so the results may be missing matches
+	Partial bool `protobuf:"varint,5,opt,name=partial" json:"partial,omitempty"`
+}
+
+func (m *SearchEntityResponse) Reset()                    { *m = SearchEntityResponse{} }
+func (m *SearchEntityResponse) String() string            { return proto.CompactTextString(m) }
+func (*SearchEntityResponse) ProtoMessage()               {}
+func (*SearchEntityResponse) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{6} }
+
+func (m *SearchEntityResponse) GetEntities() []*Entity {
+	if m != nil {
+		return m.Entities
+	}
+	return nil
+}
+
+func (m *SearchEntityResponse) GetResults() []*SearchResult {
+	if m != nil {
+		return m.Results
+	}
+	return nil
+}
+
+func (m *SearchEntityResponse) GetNextPageToken() string {
+	if m != nil {
+		return m.NextPageToken
+	}
+	return ""
+}
+
+func (m *SearchEntityResponse) GetTimedOutSearchers() []string {
+	if m != nil {
+		return m.TimedOutSearchers
+	}
+	return nil
+}
+
+func (m *SearchEntityResponse) GetPartial() bool {
+	if m != nil {
+		return m.Partial
+	}
+	return false
+}
+
+type SearchResult struct {
+	Entity *Entity `protobuf:"bytes,1,opt,name=entity" json:"entity,omitempty"`
+	// similarity is the combined similarity of the entity to the query over all searchers
+	Similarity float32 `protobuf:"fixed32,2,opt,name=similarity" json:"similarity,omitempty"`
+	// searcher_similarities are the [0, 1] similarities of the entity to the query for each
+	// searcher of its type, keyed by searcher name
+	SearcherSimilarities map[string]float32 `protobuf:"bytes,3,rep,name=searcher_similarities,json=searcherSimilarities" json:"searcher_similarities,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"fixed32,2,opt,name=value"`
+	// explanation is how the result was ranked, and is only populated when requested
+	Explanation *SearchExplanation `protobuf:"bytes,4,opt,name=explanation" json:"explanation,omitempty"`
+}
+
+func (m *SearchResult) Reset()                    { *m = SearchResult{} }
+func (m *SearchResult) String() string            { return proto.CompactTextString(m) }
+func (*SearchResult) ProtoMessage()               {}
+func (*SearchResult) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{7} }
+
+func (m *SearchResult) GetEntity() *Entity {
+	if m != nil {
+		return m.Entity
+	}
+	return nil
+}
+
+func (m *SearchResult) GetSimilarity() float32 {
+	if m != nil {
+		return m.Similarity
+	}
+	return 0
+}
+
+func (m *SearchResult) GetSearcherSimilarities() map[string]float32 {
+	if m != nil {
+		return m.SearcherSimilarities
+	}
+	return nil
+}
+
+func (m *SearchResult) GetExplanation() *SearchExplanation {
+	if m != nil {
+		return m.Explanation
+	}
+	return nil
+}
+
+type SearchExplanation struct {
+	// matches are the searchers that matched the entity, ordered by searcher name
+	Matches []*SearcherMatch `protobuf:"bytes,1,rep,name=matches" json:"matches,omitempty"`
+	// combination shows how the matches' similarities combine into the overall similarity
+	Combination string `protobuf:"bytes,2,opt,name=combination" json:"combination,omitempty"`
+}
+
+func (m *SearchExplanation) Reset()                    { *m = SearchExplanation{} }
+func (m *SearchExplanation) String() string            { return proto.CompactTextString(m) }
+func (*SearchExplanation) ProtoMessage()               {}
+func (*SearchExplanation) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{8} }
+
+func (m *SearchExplanation) GetMatches() []*SearcherMatch {
+	if m != nil {
+		return m.Matches
+	}
+	return nil
+}
+
+func (m *SearchExplanation) GetCombination() string {
+	if m != nil {
+		return m.Combination
+	}
+	return ""
+}
+
+type SearcherMatch struct {
+	Searcher string `protobuf:"bytes,1,opt,name=searcher" json:"searcher,omitempty"`
+	// query is the query after the searcher preprocessed it
+	Query string `protobuf:"bytes,2,opt,name=query" json:"query,omitempty"`
+	// similarity is the raw [0, 1] similarity of the entity to the query for the searcher
+	Similarity float32 `protobuf:"fixed32,3,opt,name=similarity" json:"similarity,omitempty"`
+}
+
+func (m *SearcherMatch) Reset()                    { *m = SearcherMatch{} }
+func (m *SearcherMatch) String() string            { return proto.CompactTextString(m) }
+func (*SearcherMatch) ProtoMessage()               {}
+func (*SearcherMatch) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{9} }
+
+func (m *SearcherMatch) GetSearcher() string {
+	if m != nil {
+		return m.Searcher
+	}
+	return ""
+}
+
+func (m *SearcherMatch) GetQuery() string {
+	if m != nil {
+		return m.Query
+	}
+	return ""
+}
+
+func (m *SearcherMatch) GetSimilarity() float32 {
+	if m != nil {
+		return m.Similarity
+	}
+	return 0
+}
+
+type DeleteEntityRequest struct {
+	EntityId string `protobuf:"bytes,1,opt,name=entity_id,json=entityId" json:"entity_id,omitempty"`
+}
+
+func (m *DeleteEntityRequest) Reset()                    { *m = DeleteEntityRequest{} }
+func (m *DeleteEntityRequest) String() string            { return proto.CompactTextString(m) }
+func (*DeleteEntityRequest) ProtoMessage()               {}
+func (*DeleteEntityRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{10} }
+
+func (m *DeleteEntityRequest) GetEntityId() string {
+	if m != nil {
+		return m.EntityId
+	}
+	return ""
+}
+
+type DeleteEntityResponse struct {
+}
+
+func (m *DeleteEntityResponse) Reset()                    { *m = DeleteEntityResponse{} }
+func (m *DeleteEntityResponse) String() string            { return proto.CompactTextString(m) }
+func (*DeleteEntityResponse) ProtoMessage()               {}
+func (*DeleteEntityResponse) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{11} }
+
+type GetEntityHistoryRequest struct {
+	EntityId string `protobuf:"bytes,1,opt,name=entity_id,json=entityId" json:"entity_id,omitempty"`
+}
+
+func (m *GetEntityHistoryRequest) Reset()                    { *m = GetEntityHistoryRequest{} }
+func (m *GetEntityHistoryRequest) String() string            { return proto.CompactTextString(m) }
+func (*GetEntityHistoryRequest) ProtoMessage()               {}
+func (*GetEntityHistoryRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{12} }
+
+func (m *GetEntityHistoryRequest) GetEntityId() string {
+	if m != nil {
+		return m.EntityId
+	}
+	return ""
+}
+
+type GetEntityHistoryResponse struct {
+	// versions of the entity, from oldest to newest
+	Versions []*EntityVersion `protobuf:"bytes,1,rep,name=versions" json:"versions,omitempty"`
+}
+
+func (m *GetEntityHistoryResponse) Reset()                    { *m = GetEntityHistoryResponse{} }
+func (m *GetEntityHistoryResponse) String() string            { return proto.CompactTextString(m) }
+func (*GetEntityHistoryResponse) ProtoMessage()               {}
+func (*GetEntityHistoryResponse) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{13} }
+
+func (m *GetEntityHistoryResponse) GetVersions() []*EntityVersion {
+	if m != nil {
+		return m.Versions
+	}
+	return nil
+}
+
+type PutEntitiesRequest struct {
+	Entities []*Entity `protobuf:"bytes,1,rep,name=entities" json:"entities,omitempty"`
+	// allow_create_with_id creates each entity with its given entity ID if no entity with that
+	// ID exists, as in PutEntityRequest
+	AllowCreateWithId bool `protobuf:"varint,2,opt,name=allow_create_with_id,json=allowCreateWithId" json:"allow_create_with_id,omitempty"`
+}
+
+func (m *PutEntitiesRequest) Reset()                    { *m = PutEntitiesRequest{} }
+func (m *PutEntitiesRequest) String() string            { return proto.CompactTextString(m) }
+func (*PutEntitiesRequest) ProtoMessage()               {}
+func (*PutEntitiesRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{14} }
+
+func (m *PutEntitiesRequest) GetEntities() []*Entity {
+	if m != nil {
+		return m.Entities
+	}
+	return nil
+}
+
+func (m *PutEntitiesRequest) GetAllowCreateWithId() bool {
+	if m != nil {
+		return m.AllowCreateWithId
+	}
+	return false
+}
+
+type PutEntitiesResponse struct {
+	// results for each entity, in the same order as the request entities
+	Results []*PutEntityResult `protobuf:"bytes,1,rep,name=results" json:"results,omitempty"`
+}
+
+func (m *PutEntitiesResponse) Reset()                    { *m = PutEntitiesResponse{} }
+func (m *PutEntitiesResponse) String() string            { return proto.CompactTextString(m) }
+func (*PutEntitiesResponse) ProtoMessage()               {}
+func (*PutEntitiesResponse) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{15} }
+
+func (m *PutEntitiesResponse) GetResults() []*PutEntityResult {
+	if m != nil {
+		return m.Results
+	}
+	return nil
+}
+
+type PutEntityResult struct {
+	EntityId string `protobuf:"bytes,1,opt,name=entity_id,json=entityId" json:"entity_id,omitempty"`
+	// error describes why the entity could not be put, and is empty on success
+	Error string `protobuf:"bytes,2,opt,name=error" json:"error,omitempty"`
+	// version is the version of the stored entity, and is zero on error
+	Version uint64 `protobuf:"varint,3,opt,name=version" json:"version,omitempty"`
+}
+
+func (m *PutEntityResult) Reset()                    { *m = PutEntityResult{} }
+func (m *PutEntityResult) String() string            { return proto.CompactTextString(m) }
+func (*PutEntityResult) ProtoMessage()               {}
+func (*PutEntityResult) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{16} }
+
+func (m *PutEntityResult) GetEntityId() string {
+	if m != nil {
+		return m.EntityId
+	}
+	return ""
+}
+
+func (m *PutEntityResult) GetError() string {
+	if m != nil {
+		return m.Error
+	}
+	return ""
+}
+
+func (m *PutEntityResult) GetVersion() uint64 {
+	if m != nil {
+		return m.Version
+	}
+	return 0
+}
+
+type GetEntitiesRequest struct {
+	EntityIds []string `protobuf:"bytes,1,rep,name=entity_ids,json=entityIds" json:"entity_ids,omitempty"`
+}
+
+func (m *GetEntitiesRequest) Reset()                    { *m = GetEntitiesRequest{} }
+func (m *GetEntitiesRequest) String() string            { return proto.CompactTextString(m) }
+func (*GetEntitiesRequest) ProtoMessage()               {}
+func (*GetEntitiesRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{17} }
+
+func (m *GetEntitiesRequest) GetEntityIds() []string {
+	if m != nil {
+		return m.EntityIds
+	}
+	return nil
+}
+
+type GetEntitiesResponse struct {
+	// results for each entity ID, in the same order as the request entity IDs
+	Results []*GetEntityResult `protobuf:"bytes,1,rep,name=results" json:"results,omitempty"`
+}
+
+func (m *GetEntitiesResponse) Reset()                    { *m = GetEntitiesResponse{} }
+func (m *GetEntitiesResponse) String() string            { return proto.CompactTextString(m) }
+func (*GetEntitiesResponse) ProtoMessage()               {}
+func (*GetEntitiesResponse) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{18} }
+
+func (m *GetEntitiesResponse) GetResults() []*GetEntityResult {
+	if m != nil {
+		return m.Results
+	}
+	return nil
+}
+
+type GetEntityResult struct {
+	Entity *Entity `protobuf:"bytes,1,opt,name=entity" json:"entity,omitempty"`
+	// error describes why the entity could not be gotten, and is empty on success
+	Error string `protobuf:"bytes,2,opt,name=error" json:"error,omitempty"`
+}
+
+func (m *GetEntityResult) Reset()                    { *m = GetEntityResult{} }
+func (m *GetEntityResult) String() string            { return proto.CompactTextString(m) }
+func (*GetEntityResult) ProtoMessage()               {}
+func (*GetEntityResult) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{19} }
+
+func (m *GetEntityResult) GetEntity() *Entity {
+	if m != nil {
+		return m.Entity
+	}
+	return nil
+}
+
+func (m *GetEntityResult) GetError() string {
+	if m != nil {
+		return m.Error
+	}
+	return ""
+}
+
+type ListEntitiesRequest struct {
+	// entity_type is the type of entities to list, either PATIENT or OFFICE
+	EntityType string `protobuf:"bytes,1,opt,name=entity_type,json=entityType" json:"entity_type,omitempty"`
+	// page_size is the max number of entities in each page, with zero denoting the default
+	PageSize uint32 `protobuf:"varint,2,opt,name=page_size,json=pageSize" json:"page_size,omitempty"`
+	// page_token is the next_page_token of a previous page to start listing from, with empty
+	// denoting the start
+	PageToken string `protobuf:"bytes,3,opt,name=page_token,json=pageToken" json:"page_token,omitempty"`
+}
+
+func (m *ListEntitiesRequest) Reset()                    { *m = ListEntitiesRequest{} }
+func (m *ListEntitiesRequest) String() string            { return proto.CompactTextString(m) }
+func (*ListEntitiesRequest) ProtoMessage()               {}
+func (*ListEntitiesRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{20} }
+
+func (m *ListEntitiesRequest) GetEntityType() string {
+	if m != nil {
+		return m.EntityType
+	}
+	return ""
+}
+
+func (m *ListEntitiesRequest) GetPageSize() uint32 {
+	if m != nil {
+		return m.PageSize
+	}
+	return 0
+}
+
+func (m *ListEntitiesRequest) GetPageToken() string {
+	if m != nil {
+		return m.PageToken
+	}
+	return ""
+}
+
+type ListEntitiesResponse struct {
+	Entities []*Entity `protobuf:"bytes,1,rep,name=entities" json:"entities,omitempty"`
+	// next_page_token starts listing from the page after this one, and is empty on the last
+	// page
+	NextPageToken string `protobuf:"bytes,2,opt,name=next_page_token,json=nextPageToken" json:"next_page_token,omitempty"`
+}
+
+func (m *ListEntitiesResponse) Reset()                    { *m = ListEntitiesResponse{} }
+func (m *ListEntitiesResponse) String() string            { return proto.CompactTextString(m) }
+func (*ListEntitiesResponse) ProtoMessage()               {}
+func (*ListEntitiesResponse) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{21} }
+
+func (m *ListEntitiesResponse) GetEntities() []*Entity {
+	if m != nil {
+		return m.Entities
+	}
+	return nil
 }
 
-func (m *PutEntityResponse) Reset()                    { *m = PutEntityResponse{} }
-func (m *PutEntityResponse) String() string            { return proto.CompactTextString(m) }
-func (*PutEntityResponse) ProtoMessage()               {}
-func (*PutEntityResponse) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{1} }
+func (m *ListEntitiesResponse) GetNextPageToken() string {
+	if m != nil {
+		return m.NextPageToken
+	}
+	return ""
+}
 
-func (m *PutEntityResponse) GetEntityId() string {
+type PatchEntityRequest struct {
+	// entity has the entity ID of the entity to patch, the new values of the fields to update,
+	// and optionally the version of the entity the patch is based on
+	Entity *Entity `protobuf:"bytes,1,opt,name=entity" json:"entity,omitempty"`
+	// update_mask lists the fields to update, e.g., "patient.last_name" or "office.name"
+	UpdateMask *google_protobuf.FieldMask `protobuf:"bytes,2,opt,name=update_mask,json=updateMask" json:"update_mask,omitempty"`
+}
+
+func (m *PatchEntityRequest) Reset()                    { *m = PatchEntityRequest{} }
+func (m *PatchEntityRequest) String() string            { return proto.CompactTextString(m) }
+func (*PatchEntityRequest) ProtoMessage()               {}
+func (*PatchEntityRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{22} }
+
+func (m *PatchEntityRequest) GetEntity() *Entity {
 	if m != nil {
-		return m.EntityId
+		return m.Entity
+	}
+	return nil
+}
+
+func (m *PatchEntityRequest) GetUpdateMask() *google_protobuf.FieldMask {
+	if m != nil {
+		return m.UpdateMask
+	}
+	return nil
+}
+
+type PatchEntityResponse struct {
+	// entity is the patched entity
+	Entity *Entity `protobuf:"bytes,1,opt,name=entity" json:"entity,omitempty"`
+}
+
+func (m *PatchEntityResponse) Reset()                    { *m = PatchEntityResponse{} }
+func (m *PatchEntityResponse) String() string            { return proto.CompactTextString(m) }
+func (*PatchEntityResponse) ProtoMessage()               {}
+func (*PatchEntityResponse) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{23} }
+
+func (m *PatchEntityResponse) GetEntity() *Entity {
+	if m != nil {
+		return m.Entity
+	}
+	return nil
+}
+
+type MergeEntitiesRequest struct {
+	// survivor_id is the entity ID of the entity that remains
+	SurvivorId string `protobuf:"bytes,1,opt,name=survivor_id,json=survivorId" json:"survivor_id,omitempty"`
+	// merged_id is the entity ID of the duplicate entity of the same type to merge into the
+	// survivor
+	MergedId string `protobuf:"bytes,2,opt,name=merged_id,json=mergedId" json:"merged_id,omitempty"`
+}
+
+func (m *MergeEntitiesRequest) Reset()                    { *m = MergeEntitiesRequest{} }
+func (m *MergeEntitiesRequest) String() string            { return proto.CompactTextString(m) }
+func (*MergeEntitiesRequest) ProtoMessage()               {}
+func (*MergeEntitiesRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{24} }
+
+func (m *MergeEntitiesRequest) GetSurvivorId() string {
+	if m != nil {
+		return m.SurvivorId
 	}
 	return ""
 }
 
-type GetEntityRequest struct {
+func (m *MergeEntitiesRequest) GetMergedId() string {
+	if m != nil {
+		return m.MergedId
+	}
+	return ""
+}
+
+type MergeEntitiesResponse struct {
+	// survivor is the current version of the surviving entity
+	Survivor *Entity `protobuf:"bytes,1,opt,name=survivor" json:"survivor,omitempty"`
+}
+
+func (m *MergeEntitiesResponse) Reset()                    { *m = MergeEntitiesResponse{} }
+func (m *MergeEntitiesResponse) String() string            { return proto.CompactTextString(m) }
+func (*MergeEntitiesResponse) ProtoMessage()               {}
+func (*MergeEntitiesResponse) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{25} }
+
+func (m *MergeEntitiesResponse) GetSurvivor() *Entity {
+	if m != nil {
+		return m.Survivor
+	}
+	return nil
+}
+
+type RevertEntityRequest struct {
 	EntityId string `protobuf:"bytes,1,opt,name=entity_id,json=entityId" json:"entity_id,omitempty"`
+	// to_version is the earlier version of the entity to revert to
+	ToVersion uint64 `protobuf:"varint,2,opt,name=to_version,json=toVersion" json:"to_version,omitempty"`
+	// reverted_by identifies who is reverting the entity
+	RevertedBy string `protobuf:"bytes,3,opt,name=reverted_by,json=revertedBy" json:"reverted_by,omitempty"`
+	// reason describes why the entity is being reverted
+	Reason string `protobuf:"bytes,4,opt,name=reason" json:"reason,omitempty"`
 }
 
-func (m *GetEntityRequest) Reset()                    { *m = GetEntityRequest{} }
-func (m *GetEntityRequest) String() string            { return proto.CompactTextString(m) }
-func (*GetEntityRequest) ProtoMessage()               {}
-func (*GetEntityRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{2} }
+func (m *RevertEntityRequest) Reset()                    { *m = RevertEntityRequest{} }
+func (m *RevertEntityRequest) String() string            { return proto.CompactTextString(m) }
+func (*RevertEntityRequest) ProtoMessage()               {}
+func (*RevertEntityRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{26} }
 
-func (m *GetEntityRequest) GetEntityId() string {
+func (m *RevertEntityRequest) GetEntityId() string {
 	if m != nil {
 		return m.EntityId
 	}
 	return ""
 }
 
-type GetEntityResponse struct {
+func (m *RevertEntityRequest) GetToVersion() uint64 {
+	if m != nil {
+		return m.ToVersion
+	}
+	return 0
+}
+
+func (m *RevertEntityRequest) GetRevertedBy() string {
+	if m != nil {
+		return m.RevertedBy
+	}
+	return ""
+}
+
+func (m *RevertEntityRequest) GetReason() string {
+	if m != nil {
+		return m.Reason
+	}
+	return ""
+}
+
+type RevertEntityResponse struct {
+	// entity is the new current version of the entity
 	Entity *Entity `protobuf:"bytes,1,opt,name=entity" json:"entity,omitempty"`
 }
 
-func (m *GetEntityResponse) Reset()                    { *m = GetEntityResponse{} }
-func (m *GetEntityResponse) String() string            { return proto.CompactTextString(m) }
-func (*GetEntityResponse) ProtoMessage()               {}
-func (*GetEntityResponse) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{3} }
+func (m *RevertEntityResponse) Reset()                    { *m = RevertEntityResponse{} }
+func (m *RevertEntityResponse) String() string            { return proto.CompactTextString(m) }
+func (*RevertEntityResponse) ProtoMessage()               {}
+func (*RevertEntityResponse) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{27} }
 
-func (m *GetEntityResponse) GetEntity() *Entity {
+func (m *RevertEntityResponse) GetEntity() *Entity {
 	if m != nil {
 		return m.Entity
 	}
 	return nil
 }
 
-type SearchEntityRequest struct {
-	Query string `protobuf:"bytes,1,opt,name=query" json:"query,omitempty"`
+type FindDuplicatesRequest struct {
+	// threshold is the minimum score in [0, 1] of the pairs to find, and is the default if zero
+	Threshold float32 `protobuf:"fixed32,1,opt,name=threshold" json:"threshold,omitempty"`
+	// limit is the maximum number of pairs to find, and is the default if zero
 	Limit uint32 `protobuf:"varint,2,opt,name=limit" json:"limit,omitempty"`
 }
 
-func (m *SearchEntityRequest) Reset()                    { *m = SearchEntityRequest{} }
-func (m *SearchEntityRequest) String() string            { return proto.CompactTextString(m) }
-func (*SearchEntityRequest) ProtoMessage()               {}
-func (*SearchEntityRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{4} }
+func (m *FindDuplicatesRequest) Reset()                    { *m = FindDuplicatesRequest{} }
+func (m *FindDuplicatesRequest) String() string            { return proto.CompactTextString(m) }
+func (*FindDuplicatesRequest) ProtoMessage()               {}
+func (*FindDuplicatesRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{28} }
 
-func (m *SearchEntityRequest) GetQuery() string {
+func (m *FindDuplicatesRequest) GetThreshold() float32 {
 	if m != nil {
-		return m.Query
+		return m.Threshold
+	}
+	return 0
+}
+
+func (m *FindDuplicatesRequest) GetLimit() uint32 {
+	if m != nil {
+		return m.Limit
+	}
+	return 0
+}
+
+type FindDuplicatesResponse struct {
+	// pairs are the probable duplicates, ordered by score from highest to lowest
+	Pairs []*DuplicatePair `protobuf:"bytes,1,rep,name=pairs" json:"pairs,omitempty"`
+}
+
+func (m *FindDuplicatesResponse) Reset()                    { *m = FindDuplicatesResponse{} }
+func (m *FindDuplicatesResponse) String() string            { return proto.CompactTextString(m) }
+func (*FindDuplicatesResponse) ProtoMessage()               {}
+func (*FindDuplicatesResponse) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{29} }
+
+func (m *FindDuplicatesResponse) GetPairs() []*DuplicatePair {
+	if m != nil {
+		return m.Pairs
+	}
+	return nil
+}
+
+type DuplicatePair struct {
+	// entity1 and entity2 are the patients, ordered by entity ID
+	Entity1 *Entity `protobuf:"bytes,1,opt,name=entity1" json:"entity1,omitempty"`
+	Entity2 *Entity `protobuf:"bytes,2,opt,name=entity2" json:"entity2,omitempty"`
+	// score is the mean of the name similarity and the birthdate agreement, where agreeing
+	// birthdates count as 1 and disagreeing ones as 0
+	Score float32 `protobuf:"fixed32,3,opt,name=score" json:"score,omitempty"`
+	// name_similarity is the trigram similarity of the patients' names
+	NameSimilarity float32 `protobuf:"fixed32,4,opt,name=name_similarity,json=nameSimilarity" json:"name_similarity,omitempty"`
+	// birthdate_match is whether the patients' birthdates agree
+	BirthdateMatch bool `protobuf:"varint,5,opt,name=birthdate_match,json=birthdateMatch" json:"birthdate_match,omitempty"`
+}
+
+func (m *DuplicatePair) Reset()                    { *m = DuplicatePair{} }
+func (m *DuplicatePair) String() string            { return proto.CompactTextString(m) }
+func (*DuplicatePair) ProtoMessage()               {}
+func (*DuplicatePair) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{30} }
+
+func (m *DuplicatePair) GetEntity1() *Entity {
+	if m != nil {
+		return m.Entity1
+	}
+	return nil
+}
+
+func (m *DuplicatePair) GetEntity2() *Entity {
+	if m != nil {
+		return m.Entity2
+	}
+	return nil
+}
+
+func (m *DuplicatePair) GetScore() float32 {
+	if m != nil {
+		return m.Score
+	}
+	return 0
+}
+
+func (m *DuplicatePair) GetNameSimilarity() float32 {
+	if m != nil {
+		return m.NameSimilarity
+	}
+	return 0
+}
+
+func (m *DuplicatePair) GetBirthdateMatch() bool {
+	if m != nil {
+		return m.BirthdateMatch
+	}
+	return false
+}
+
+type MatchPatientRequest struct {
+	// patient is the incoming patient to find matches for
+	Patient *Patient `protobuf:"bytes,1,opt,name=patient" json:"patient,omitempty"`
+	// limit is the maximum number of candidates to return, and is the default if zero
+	Limit uint32 `protobuf:"varint,2,opt,name=limit" json:"limit,omitempty"`
+}
+
+func (m *MatchPatientRequest) Reset()                    { *m = MatchPatientRequest{} }
+func (m *MatchPatientRequest) String() string            { return proto.CompactTextString(m) }
+func (*MatchPatientRequest) ProtoMessage()               {}
+func (*MatchPatientRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{31} }
+
+func (m *MatchPatientRequest) GetPatient() *Patient {
+	if m != nil {
+		return m.Patient
+	}
+	return nil
+}
+
+func (m *MatchPatientRequest) GetLimit() uint32 {
+	if m != nil {
+		return m.Limit
+	}
+	return 0
+}
+
+type MatchPatientResponse struct {
+	// candidates are the existing patients, ordered by match weight from highest to lowest
+	Candidates []*PatientMatch `protobuf:"bytes,1,rep,name=candidates" json:"candidates,omitempty"`
+}
+
+func (m *MatchPatientResponse) Reset()                    { *m = MatchPatientResponse{} }
+func (m *MatchPatientResponse) String() string            { return proto.CompactTextString(m) }
+func (*MatchPatientResponse) ProtoMessage()               {}
+func (*MatchPatientResponse) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{32} }
+
+func (m *MatchPatientResponse) GetCandidates() []*PatientMatch {
+	if m != nil {
+		return m.Candidates
+	}
+	return nil
+}
+
+type PatientMatch struct {
+	Entity *Entity `protobuf:"bytes,1,opt,name=entity" json:"entity,omitempty"`
+	// weight is the sum of the agreement or disagreement weights of the compared fields, where
+	// fields missing from either patient count as zero
+	Weight float64 `protobuf:"fixed64,2,opt,name=weight" json:"weight,omitempty"`
+	// classification is MATCH, POSSIBLE_MATCH, or NON_MATCH, given the weight and the configured
+	// thresholds
+	Classification string `protobuf:"bytes,3,opt,name=classification" json:"classification,omitempty"`
+}
+
+func (m *PatientMatch) Reset()                    { *m = PatientMatch{} }
+func (m *PatientMatch) String() string            { return proto.CompactTextString(m) }
+func (*PatientMatch) ProtoMessage()               {}
+func (*PatientMatch) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{33} }
+
+func (m *PatientMatch) GetEntity() *Entity {
+	if m != nil {
+		return m.Entity
+	}
+	return nil
+}
+
+func (m *PatientMatch) GetWeight() float64 {
+	if m != nil {
+		return m.Weight
+	}
+	return 0
+}
+
+func (m *PatientMatch) GetClassification() string {
+	if m != nil {
+		return m.Classification
 	}
 	return ""
 }
 
-func (m *SearchEntityRequest) GetLimit() uint32 {
+type AutocompleteRequest struct {
+	// prefix is the start of the last name followed by the first name or of the first name
+	// followed by the last name of the patients to suggest, or of the name of the offices to
+	// suggest, ignoring case, accents, and punctuation
+	Prefix string `protobuf:"bytes,1,opt,name=prefix" json:"prefix,omitempty"`
+	// limit is the maximum number of suggestions, and is the default if zero
+	Limit uint32 `protobuf:"varint,2,opt,name=limit" json:"limit,omitempty"`
+	// entity_types restricts suggestions to entities of the given types (e.g., PATIENT or
+	// OFFICE), with empty allowing all
+	EntityTypes []string `protobuf:"bytes,3,rep,name=entity_types,json=entityTypes" json:"entity_types,omitempty"`
+}
+
+func (m *AutocompleteRequest) Reset()                    { *m = AutocompleteRequest{} }
+func (m *AutocompleteRequest) String() string            { return proto.CompactTextString(m) }
+func (*AutocompleteRequest) ProtoMessage()               {}
+func (*AutocompleteRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{34} }
+
+func (m *AutocompleteRequest) GetPrefix() string {
+	if m != nil {
+		return m.Prefix
+	}
+	return ""
+}
+
+func (m *AutocompleteRequest) GetLimit() uint32 {
 	if m != nil {
 		return m.Limit
 	}
 	return 0
 }
 
-type SearchEntityResponse struct {
-	Entities []*Entity `protobuf:"bytes,1,rep,name=entities" json:"entities,omitempty"`
+func (m *AutocompleteRequest) GetEntityTypes() []string {
+	if m != nil {
+		return m.EntityTypes
+	}
+	return nil
 }
 
-func (m *SearchEntityResponse) Reset()                    { *m = SearchEntityResponse{} }
-func (m *SearchEntityResponse) String() string            { return proto.CompactTextString(m) }
-func (*SearchEntityResponse) ProtoMessage()               {}
-func (*SearchEntityResponse) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{5} }
+type AutocompleteResponse struct {
+	// suggestions are ordered by name, with patients' names starting with their last names
+	Suggestions []*Suggestion `protobuf:"bytes,1,rep,name=suggestions" json:"suggestions,omitempty"`
+}
 
-func (m *SearchEntityResponse) GetEntities() []*Entity {
+func (m *AutocompleteResponse) Reset()                    { *m = AutocompleteResponse{} }
+func (m *AutocompleteResponse) String() string            { return proto.CompactTextString(m) }
+func (*AutocompleteResponse) ProtoMessage()               {}
+func (*AutocompleteResponse) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{35} }
+
+func (m *AutocompleteResponse) GetSuggestions() []*Suggestion {
 	if m != nil {
-		return m.Entities
+		return m.Suggestions
 	}
 	return nil
 }
 
+type Suggestion struct {
+	EntityId string `protobuf:"bytes,1,opt,name=entity_id,json=entityId" json:"entity_id,omitempty"`
+	// entity_type is the type of the entity, either PATIENT or OFFICE
+	EntityType string `protobuf:"bytes,2,opt,name=entity_type,json=entityType" json:"entity_type,omitempty"`
+	// display_name is the name of the entity to display, e.g., First Last for a patient
+	DisplayName string `protobuf:"bytes,3,opt,name=display_name,json=displayName" json:"display_name,omitempty"`
+}
+
+func (m *Suggestion) Reset()                    { *m = Suggestion{} }
+func (m *Suggestion) String() string            { return proto.CompactTextString(m) }
+func (*Suggestion) ProtoMessage()               {}
+func (*Suggestion) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{36} }
+
+func (m *Suggestion) GetEntityId() string {
+	if m != nil {
+		return m.EntityId
+	}
+	return ""
+}
+
+func (m *Suggestion) GetEntityType() string {
+	if m != nil {
+		return m.EntityType
+	}
+	return ""
+}
+
+func (m *Suggestion) GetDisplayName() string {
+	if m != nil {
+		return m.DisplayName
+	}
+	return ""
+}
+
+type Revert struct {
+	// to_version is the earlier version of the entity that was reverted to
+	ToVersion uint64 `protobuf:"varint,1,opt,name=to_version,json=toVersion" json:"to_version,omitempty"`
+	// reverted_by identifies who reverted the entity
+	RevertedBy string `protobuf:"bytes,2,opt,name=reverted_by,json=revertedBy" json:"reverted_by,omitempty"`
+	// reason describes why the entity was reverted
+	Reason string `protobuf:"bytes,3,opt,name=reason" json:"reason,omitempty"`
+}
+
+func (m *Revert) Reset()                    { *m = Revert{} }
+func (m *Revert) String() string            { return proto.CompactTextString(m) }
+func (*Revert) ProtoMessage()               {}
+func (*Revert) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{37} }
+
+func (m *Revert) GetToVersion() uint64 {
+	if m != nil {
+		return m.ToVersion
+	}
+	return 0
+}
+
+func (m *Revert) GetRevertedBy() string {
+	if m != nil {
+		return m.RevertedBy
+	}
+	return ""
+}
+
+func (m *Revert) GetReason() string {
+	if m != nil {
+		return m.Reason
+	}
+	return ""
+}
+
 type Entity struct {
 	EntityId string `protobuf:"bytes,1,opt,name=entity_id,json=entityId" json:"entity_id,omitempty"`
 	// Types that are valid to be assigned to TypeAttributes:
 	//	*Entity_Patient
 	//	*Entity_Office
 	TypeAttributes isEntity_TypeAttributes `protobuf_oneof:"type_attributes"`
+	// version starts at 1 when the entity is created and increments with each update. An update
+	// with a non-zero version is rejected if it does not match the stored version.
+	Version uint64 `protobuf:"varint,4,opt,name=version" json:"version,omitempty"`
 }
 
 func (m *Entity) Reset()                    { *m = Entity{} }
 func (m *Entity) String() string            { return proto.CompactTextString(m) }
 func (*Entity) ProtoMessage()               {}
-func (*Entity) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{6} }
+func (*Entity) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{38} }
 
 type isEntity_TypeAttributes interface {
 	isEntity_TypeAttributes()
@@ -200,6 +1263,13 @@ func (m *Entity) GetOffice() *Office {
 	return nil
 }
 
+func (m *Entity) GetVersion() uint64 {
+	if m != nil {
+		return m.Version
+	}
+	return 0
+}
+
 // XXX_OneofFuncs is for the internal use of the proto package.
 func (*Entity) XXX_OneofFuncs() (func(msg proto.Message, b *proto.Buffer) error, func(msg proto.Message, tag, wire int, b *proto.Buffer) (bool, error), func(msg proto.Message) (n int), []interface{}) {
 	return _Entity_OneofMarshaler, _Entity_OneofUnmarshaler, _Entity_OneofSizer, []interface{}{
@@ -271,7 +1341,62 @@ func _Entity_OneofSizer(msg proto.Message) (n int) {
 	default:
 		panic(fmt.Sprintf("proto: unexpected type %T in oneof", x))
 	}
-	return n
+	return n
+}
+
+type EntityVersion struct {
+	Entity *Entity `protobuf:"bytes,1,opt,name=entity" json:"entity,omitempty"`
+	// valid_from is when this version was stored
+	ValidFrom *google_protobuf1.Timestamp `protobuf:"bytes,2,opt,name=valid_from,json=validFrom" json:"valid_from,omitempty"`
+	// valid_to is when this version was superseded or deleted, and is absent for the current
+	// version
+	ValidTo *google_protobuf1.Timestamp `protobuf:"bytes,3,opt,name=valid_to,json=validTo" json:"valid_to,omitempty"`
+	// merged_into_id is the entity ID of the entity this one was merged into when this version
+	// was closed by a merge, and is empty otherwise
+	MergedIntoId string `protobuf:"bytes,4,opt,name=merged_into_id,json=mergedIntoId" json:"merged_into_id,omitempty"`
+	// revert describes the revert that stored this version, and is absent for versions stored
+	// otherwise
+	Revert *Revert `protobuf:"bytes,5,opt,name=revert" json:"revert,omitempty"`
+}
+
+func (m *EntityVersion) Reset()                    { *m = EntityVersion{} }
+func (m *EntityVersion) String() string            { return proto.CompactTextString(m) }
+func (*EntityVersion) ProtoMessage()               {}
+func (*EntityVersion) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{39} }
+
+func (m *EntityVersion) GetEntity() *Entity {
+	if m != nil {
+		return m.Entity
+	}
+	return nil
+}
+
+func (m *EntityVersion) GetValidFrom() *google_protobuf1.Timestamp {
+	if m != nil {
+		return m.ValidFrom
+	}
+	return nil
+}
+
+func (m *EntityVersion) GetValidTo() *google_protobuf1.Timestamp {
+	if m != nil {
+		return m.ValidTo
+	}
+	return nil
+}
+
+func (m *EntityVersion) GetMergedIntoId() string {
+	if m != nil {
+		return m.MergedIntoId
+	}
+	return ""
+}
+
+func (m *EntityVersion) GetRevert() *Revert {
+	if m != nil {
+		return m.Revert
+	}
+	return nil
 }
 
 type Patient struct {
@@ -285,7 +1410,7 @@ type Patient struct {
 func (m *Patient) Reset()                    { *m = Patient{} }
 func (m *Patient) String() string            { return proto.CompactTextString(m) }
 func (*Patient) ProtoMessage()               {}
-func (*Patient) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{7} }
+func (*Patient) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{40} }
 
 func (m *Patient) GetLastName() string {
 	if m != nil {
@@ -329,7 +1454,7 @@ type Office struct {
 func (m *Office) Reset()                    { *m = Office{} }
 func (m *Office) String() string            { return proto.CompactTextString(m) }
 func (*Office) ProtoMessage()               {}
-func (*Office) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{8} }
+func (*Office) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{41} }
 
 func (m *Office) GetName() string {
 	if m != nil {
@@ -347,7 +1472,7 @@ type Date struct {
 func (m *Date) Reset()                    { *m = Date{} }
 func (m *Date) String() string            { return proto.CompactTextString(m) }
 func (*Date) ProtoMessage()               {}
-func (*Date) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{9} }
+func (*Date) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{42} }
 
 func (m *Date) GetYear() uint32 {
 	if m != nil {
@@ -376,8 +1501,41 @@ func init() {
 	proto.RegisterType((*GetEntityRequest)(nil), "directoryapi.GetEntityRequest")
 	proto.RegisterType((*GetEntityResponse)(nil), "directoryapi.GetEntityResponse")
 	proto.RegisterType((*SearchEntityRequest)(nil), "directoryapi.SearchEntityRequest")
+	proto.RegisterType((*SearchFilters)(nil), "directoryapi.SearchFilters")
 	proto.RegisterType((*SearchEntityResponse)(nil), "directoryapi.SearchEntityResponse")
+	proto.RegisterType((*SearchResult)(nil), "directoryapi.SearchResult")
+	proto.RegisterType((*SearchExplanation)(nil), "directoryapi.SearchExplanation")
+	proto.RegisterType((*SearcherMatch)(nil), "directoryapi.SearcherMatch")
+	proto.RegisterType((*DeleteEntityRequest)(nil), "directoryapi.DeleteEntityRequest")
+	proto.RegisterType((*DeleteEntityResponse)(nil), "directoryapi.DeleteEntityResponse")
+	proto.RegisterType((*GetEntityHistoryRequest)(nil), "directoryapi.GetEntityHistoryRequest")
+	proto.RegisterType((*GetEntityHistoryResponse)(nil), "directoryapi.GetEntityHistoryResponse")
+	proto.RegisterType((*PutEntitiesRequest)(nil), "directoryapi.PutEntitiesRequest")
+	proto.RegisterType((*PutEntitiesResponse)(nil), "directoryapi.PutEntitiesResponse")
+	proto.RegisterType((*PutEntityResult)(nil), "directoryapi.PutEntityResult")
+	proto.RegisterType((*GetEntitiesRequest)(nil), "directoryapi.GetEntitiesRequest")
+	proto.RegisterType((*GetEntitiesResponse)(nil), "directoryapi.GetEntitiesResponse")
+	proto.RegisterType((*GetEntityResult)(nil), "directoryapi.GetEntityResult")
+	proto.RegisterType((*ListEntitiesRequest)(nil), "directoryapi.ListEntitiesRequest")
+	proto.RegisterType((*ListEntitiesResponse)(nil), "directoryapi.ListEntitiesResponse")
+	proto.RegisterType((*PatchEntityRequest)(nil), "directoryapi.PatchEntityRequest")
+	proto.RegisterType((*PatchEntityResponse)(nil), "directoryapi.PatchEntityResponse")
+	proto.RegisterType((*MergeEntitiesRequest)(nil), "directoryapi.MergeEntitiesRequest")
+	proto.RegisterType((*MergeEntitiesResponse)(nil), "directoryapi.MergeEntitiesResponse")
+	proto.RegisterType((*RevertEntityRequest)(nil), "directoryapi.RevertEntityRequest")
+	proto.RegisterType((*RevertEntityResponse)(nil), "directoryapi.RevertEntityResponse")
+	proto.RegisterType((*FindDuplicatesRequest)(nil), "directoryapi.FindDuplicatesRequest")
+	proto.RegisterType((*FindDuplicatesResponse)(nil), "directoryapi.FindDuplicatesResponse")
+	proto.RegisterType((*DuplicatePair)(nil), "directoryapi.DuplicatePair")
+	proto.RegisterType((*MatchPatientRequest)(nil), "directoryapi.MatchPatientRequest")
+	proto.RegisterType((*MatchPatientResponse)(nil), "directoryapi.MatchPatientResponse")
+	proto.RegisterType((*PatientMatch)(nil), "directoryapi.PatientMatch")
+	proto.RegisterType((*AutocompleteRequest)(nil), "directoryapi.AutocompleteRequest")
+	proto.RegisterType((*AutocompleteResponse)(nil), "directoryapi.AutocompleteResponse")
+	proto.RegisterType((*Suggestion)(nil), "directoryapi.Suggestion")
+	proto.RegisterType((*Revert)(nil), "directoryapi.Revert")
 	proto.RegisterType((*Entity)(nil), "directoryapi.Entity")
+	proto.RegisterType((*EntityVersion)(nil), "directoryapi.EntityVersion")
 	proto.RegisterType((*Patient)(nil), "directoryapi.Patient")
 	proto.RegisterType((*Office)(nil), "directoryapi.Office")
 	proto.RegisterType((*Date)(nil), "directoryapi.Date")
@@ -397,6 +1555,17 @@ type DirectoryClient interface {
 	PutEntity(ctx context.Context, in *PutEntityRequest, opts ...grpc.CallOption) (*PutEntityResponse, error)
 	GetEntity(ctx context.Context, in *GetEntityRequest, opts ...grpc.CallOption) (*GetEntityResponse, error)
 	SearchEntity(ctx context.Context, in *SearchEntityRequest, opts ...grpc.CallOption) (*SearchEntityResponse, error)
+	DeleteEntity(ctx context.Context, in *DeleteEntityRequest, opts ...grpc.CallOption) (*DeleteEntityResponse, error)
+	GetEntityHistory(ctx context.Context, in *GetEntityHistoryRequest, opts ...grpc.CallOption) (*GetEntityHistoryResponse, error)
+	PutEntities(ctx context.Context, in *PutEntitiesRequest, opts ...grpc.CallOption) (*PutEntitiesResponse, error)
+	GetEntities(ctx context.Context, in *GetEntitiesRequest, opts ...grpc.CallOption) (*GetEntitiesResponse, error)
+	ListEntities(ctx context.Context, in *ListEntitiesRequest, opts ...grpc.CallOption) (Directory_ListEntitiesClient, error)
+	PatchEntity(ctx context.Context, in *PatchEntityRequest, opts ...grpc.CallOption) (*PatchEntityResponse, error)
+	MergeEntities(ctx context.Context, in *MergeEntitiesRequest, opts ...grpc.CallOption) (*MergeEntitiesResponse, error)
+	RevertEntity(ctx context.Context, in *RevertEntityRequest, opts ...grpc.CallOption) (*RevertEntityResponse, error)
+	FindDuplicates(ctx context.Context, in *FindDuplicatesRequest, opts ...grpc.CallOption) (*FindDuplicatesResponse, error)
+	MatchPatient(ctx context.Context, in *MatchPatientRequest, opts ...grpc.CallOption) (*MatchPatientResponse, error)
+	Autocomplete(ctx context.Context, in *AutocompleteRequest, opts ...grpc.CallOption) (*AutocompleteResponse, error)
 }
 
 type directoryClient struct {
@@ -434,12 +1603,145 @@ func (c *directoryClient) SearchEntity(ctx context.Context, in *SearchEntityRequ
 	return out, nil
 }
 
+func (c *directoryClient) DeleteEntity(ctx context.Context, in *DeleteEntityRequest, opts ...grpc.CallOption) (*DeleteEntityResponse, error) {
+	out := new(DeleteEntityResponse)
+	err := grpc.Invoke(ctx, "/directoryapi.Directory/DeleteEntity", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *directoryClient) GetEntityHistory(ctx context.Context, in *GetEntityHistoryRequest, opts ...grpc.CallOption) (*GetEntityHistoryResponse, error) {
+	out := new(GetEntityHistoryResponse)
+	err := grpc.Invoke(ctx, "/directoryapi.Directory/GetEntityHistory", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *directoryClient) PutEntities(ctx context.Context, in *PutEntitiesRequest, opts ...grpc.CallOption) (*PutEntitiesResponse, error) {
+	out := new(PutEntitiesResponse)
+	err := grpc.Invoke(ctx, "/directoryapi.Directory/PutEntities", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *directoryClient) GetEntities(ctx context.Context, in *GetEntitiesRequest, opts ...grpc.CallOption) (*GetEntitiesResponse, error) {
+	out := new(GetEntitiesResponse)
+	err := grpc.Invoke(ctx, "/directoryapi.Directory/GetEntities", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *directoryClient) ListEntities(ctx context.Context, in *ListEntitiesRequest, opts ...grpc.CallOption) (Directory_ListEntitiesClient, error) {
+	stream, err := grpc.NewClientStream(ctx, &_Directory_serviceDesc.Streams[0], c.cc, "/directoryapi.Directory/ListEntities", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &directoryListEntitiesClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type Directory_ListEntitiesClient interface {
+	Recv() (*ListEntitiesResponse, error)
+	grpc.ClientStream
+}
+
+type directoryListEntitiesClient struct {
+	grpc.ClientStream
+}
+
+func (x *directoryListEntitiesClient) Recv() (*ListEntitiesResponse, error) {
+	m := new(ListEntitiesResponse)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *directoryClient) PatchEntity(ctx context.Context, in *PatchEntityRequest, opts ...grpc.CallOption) (*PatchEntityResponse, error) {
+	out := new(PatchEntityResponse)
+	err := grpc.Invoke(ctx, "/directoryapi.Directory/PatchEntity", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *directoryClient) MergeEntities(ctx context.Context, in *MergeEntitiesRequest, opts ...grpc.CallOption) (*MergeEntitiesResponse, error) {
+	out := new(MergeEntitiesResponse)
+	err := grpc.Invoke(ctx, "/directoryapi.Directory/MergeEntities", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *directoryClient) RevertEntity(ctx context.Context, in *RevertEntityRequest, opts ...grpc.CallOption) (*RevertEntityResponse, error) {
+	out := new(RevertEntityResponse)
+	err := grpc.Invoke(ctx, "/directoryapi.Directory/RevertEntity", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *directoryClient) FindDuplicates(ctx context.Context, in *FindDuplicatesRequest, opts ...grpc.CallOption) (*FindDuplicatesResponse, error) {
+	out := new(FindDuplicatesResponse)
+	err := grpc.Invoke(ctx, "/directoryapi.Directory/FindDuplicates", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *directoryClient) MatchPatient(ctx context.Context, in *MatchPatientRequest, opts ...grpc.CallOption) (*MatchPatientResponse, error) {
+	out := new(MatchPatientResponse)
+	err := grpc.Invoke(ctx, "/directoryapi.Directory/MatchPatient", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *directoryClient) Autocomplete(ctx context.Context, in *AutocompleteRequest, opts ...grpc.CallOption) (*AutocompleteResponse, error) {
+	out := new(AutocompleteResponse)
+	err := grpc.Invoke(ctx, "/directoryapi.Directory/Autocomplete", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 // Server API for Directory service
 
 type DirectoryServer interface {
 	PutEntity(context.Context, *PutEntityRequest) (*PutEntityResponse, error)
 	GetEntity(context.Context, *GetEntityRequest) (*GetEntityResponse, error)
 	SearchEntity(context.Context, *SearchEntityRequest) (*SearchEntityResponse, error)
+	DeleteEntity(context.Context, *DeleteEntityRequest) (*DeleteEntityResponse, error)
+	GetEntityHistory(context.Context, *GetEntityHistoryRequest) (*GetEntityHistoryResponse, error)
+	PutEntities(context.Context, *PutEntitiesRequest) (*PutEntitiesResponse, error)
+	GetEntities(context.Context, *GetEntitiesRequest) (*GetEntitiesResponse, error)
+	ListEntities(*ListEntitiesRequest, Directory_ListEntitiesServer) error
+	PatchEntity(context.Context, *PatchEntityRequest) (*PatchEntityResponse, error)
+	MergeEntities(context.Context, *MergeEntitiesRequest) (*MergeEntitiesResponse, error)
+	RevertEntity(context.Context, *RevertEntityRequest) (*RevertEntityResponse, error)
+	FindDuplicates(context.Context, *FindDuplicatesRequest) (*FindDuplicatesResponse, error)
+	MatchPatient(context.Context, *MatchPatientRequest) (*MatchPatientResponse, error)
+	Autocomplete(context.Context, *AutocompleteRequest) (*AutocompleteResponse, error)
 }
 
 func RegisterDirectoryServer(s *grpc.Server, srv DirectoryServer) {
@@ -500,6 +1802,207 @@ func _Directory_SearchEntity_Handler(srv interface{}, ctx context.Context, dec f
 	return interceptor(ctx, in, info, handler)
 }
 
+func _Directory_DeleteEntity_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(DeleteEntityRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(DirectoryServer).DeleteEntity(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/directoryapi.Directory/DeleteEntity",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(DirectoryServer).DeleteEntity(ctx, req.(*DeleteEntityRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Directory_GetEntityHistory_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetEntityHistoryRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(DirectoryServer).GetEntityHistory(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/directoryapi.Directory/GetEntityHistory",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(DirectoryServer).GetEntityHistory(ctx, req.(*GetEntityHistoryRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Directory_PutEntities_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(PutEntitiesRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(DirectoryServer).PutEntities(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/directoryapi.Directory/PutEntities",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(DirectoryServer).PutEntities(ctx, req.(*PutEntitiesRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Directory_GetEntities_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetEntitiesRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(DirectoryServer).GetEntities(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/directoryapi.Directory/GetEntities",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(DirectoryServer).GetEntities(ctx, req.(*GetEntitiesRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Directory_ListEntities_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(ListEntitiesRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(DirectoryServer).ListEntities(m, &directoryListEntitiesServer{stream})
+}
+
+type Directory_ListEntitiesServer interface {
+	Send(*ListEntitiesResponse) error
+	grpc.ServerStream
+}
+
+type directoryListEntitiesServer struct {
+	grpc.ServerStream
+}
+
+func (x *directoryListEntitiesServer) Send(m *ListEntitiesResponse) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func _Directory_PatchEntity_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(PatchEntityRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(DirectoryServer).PatchEntity(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/directoryapi.Directory/PatchEntity",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(DirectoryServer).PatchEntity(ctx, req.(*PatchEntityRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Directory_MergeEntities_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(MergeEntitiesRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(DirectoryServer).MergeEntities(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/directoryapi.Directory/MergeEntities",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(DirectoryServer).MergeEntities(ctx, req.(*MergeEntitiesRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Directory_RevertEntity_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(RevertEntityRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(DirectoryServer).RevertEntity(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/directoryapi.Directory/RevertEntity",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(DirectoryServer).RevertEntity(ctx, req.(*RevertEntityRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Directory_FindDuplicates_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(FindDuplicatesRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(DirectoryServer).FindDuplicates(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/directoryapi.Directory/FindDuplicates",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(DirectoryServer).FindDuplicates(ctx, req.(*FindDuplicatesRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Directory_MatchPatient_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(MatchPatientRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(DirectoryServer).MatchPatient(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/directoryapi.Directory/MatchPatient",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(DirectoryServer).MatchPatient(ctx, req.(*MatchPatientRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Directory_Autocomplete_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(AutocompleteRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(DirectoryServer).Autocomplete(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/directoryapi.Directory/Autocomplete",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(DirectoryServer).Autocomplete(ctx, req.(*AutocompleteRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
 var _Directory_serviceDesc = grpc.ServiceDesc{
 	ServiceName: "directoryapi.Directory",
 	HandlerType: (*DirectoryServer)(nil),
@@ -516,44 +2019,180 @@ var _Directory_serviceDesc = grpc.ServiceDesc{
 			MethodName: "SearchEntity",
 			Handler:    _Directory_SearchEntity_Handler,
 		},
+		{
+			MethodName: "DeleteEntity",
+			Handler:    _Directory_DeleteEntity_Handler,
+		},
+		{
+			MethodName: "GetEntityHistory",
+			Handler:    _Directory_GetEntityHistory_Handler,
+		},
+		{
+			MethodName: "PutEntities",
+			Handler:    _Directory_PutEntities_Handler,
+		},
+		{
+			MethodName: "GetEntities",
+			Handler:    _Directory_GetEntities_Handler,
+		},
+		{
+			MethodName: "PatchEntity",
+			Handler:    _Directory_PatchEntity_Handler,
+		},
+		{
+			MethodName: "MergeEntities",
+			Handler:    _Directory_MergeEntities_Handler,
+		},
+		{
+			MethodName: "RevertEntity",
+			Handler:    _Directory_RevertEntity_Handler,
+		},
+		{
+			MethodName: "FindDuplicates",
+			Handler:    _Directory_FindDuplicates_Handler,
+		},
+		{
+			MethodName: "MatchPatient",
+			Handler:    _Directory_MatchPatient_Handler,
+		},
+		{
+			MethodName: "Autocomplete",
+			Handler:    _Directory_Autocomplete_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "ListEntities",
+			Handler:       _Directory_ListEntities_Handler,
+			ServerStreams: true,
+		},
 	},
-	Streams:  []grpc.StreamDesc{},
 	Metadata: "pkg/directoryapi/directory.proto",
 }
 
 func init() { proto.RegisterFile("pkg/directoryapi/directory.proto", fileDescriptor0) }
 
 var fileDescriptor0 = []byte{
-	// 483 bytes of a gzipped FileDescriptorProto
-	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0x94, 0x54, 0xdd, 0x6e, 0xd3, 0x4c,
-	0x10, 0x8d, 0x93, 0xd4, 0xfd, 0x3c, 0x69, 0xf4, 0x25, 0x4b, 0x40, 0x56, 0xf9, 0x69, 0xd8, 0xab,
-	0x5e, 0xa0, 0x34, 0x84, 0x17, 0xa0, 0x55, 0x11, 0xe1, 0xa6, 0x54, 0xe6, 0x82, 0xcb, 0x68, 0x13,
-	0x8f, 0xc9, 0x8a, 0xf8, 0xa7, 0xeb, 0xb1, 0x84, 0xef, 0x79, 0x0b, 0x5e, 0x82, 0x47, 0x44, 0xde,
-	0xb5, 0x53, 0xdb, 0xb4, 0x91, 0xb8, 0xdb, 0x99, 0x73, 0xe6, 0xec, 0x19, 0xef, 0x8c, 0x61, 0x9a,
-	0x7c, 0xff, 0x76, 0xe1, 0x4b, 0x85, 0x1b, 0x8a, 0x55, 0x2e, 0x12, 0x79, 0x1f, 0xcc, 0x12, 0x15,
-	0x53, 0xcc, 0x4e, 0xea, 0x28, 0x7f, 0x0f, 0xa3, 0xdb, 0x8c, 0x3e, 0x44, 0x24, 0x29, 0xf7, 0xf0,
-	0x2e, 0xc3, 0x94, 0xd8, 0x1b, 0xb0, 0x51, 0x27, 0x5c, 0x6b, 0x6a, 0x9d, 0x0f, 0x16, 0x93, 0x59,
-	0xbd, 0x64, 0x56, 0x92, 0x4b, 0x0e, 0x9f, 0xc3, 0xb8, 0xa6, 0x90, 0x26, 0x71, 0x94, 0x22, 0x7b,
-	0x0e, 0x8e, 0x81, 0x57, 0xd2, 0xd7, 0x2a, 0x8e, 0xf7, 0x9f, 0x49, 0x7c, 0xf2, 0xf9, 0x05, 0x8c,
-	0x3e, 0x62, 0xeb, 0xce, 0x83, 0x05, 0x97, 0x30, 0xae, 0x15, 0x94, 0x57, 0xfc, 0x9b, 0xcb, 0x4b,
-	0x78, 0xf2, 0x05, 0x85, 0xda, 0x6c, 0x9b, 0xd7, 0x4e, 0xe0, 0xe8, 0x2e, 0x43, 0x95, 0x97, 0x57,
-	0x9a, 0xa0, 0xc8, 0xee, 0x64, 0x28, 0xc9, 0xed, 0x4e, 0xad, 0xf3, 0xa1, 0x67, 0x02, 0xbe, 0x84,
-	0x49, 0x53, 0xa2, 0x34, 0x32, 0x07, 0xe3, 0x54, 0x62, 0xea, 0x5a, 0xd3, 0xde, 0xa3, 0x56, 0xf6,
-	0x2c, 0xfe, 0xcb, 0x02, 0xdb, 0x24, 0x0f, 0xf6, 0xcd, 0xde, 0xc2, 0x71, 0x22, 0x48, 0x62, 0x64,
-	0x9c, 0x0c, 0x16, 0x4f, 0x9b, 0xc2, 0xb7, 0x06, 0x5c, 0x76, 0xbc, 0x8a, 0xc7, 0x66, 0x60, 0xc7,
-	0x41, 0x20, 0x37, 0xe8, 0xf6, 0x1e, 0xfa, 0x2a, 0x9f, 0x35, 0xb6, 0xec, 0x78, 0x25, 0xeb, 0x6a,
-	0x0c, 0xff, 0x53, 0x9e, 0xe0, 0x4a, 0x10, 0x29, 0xb9, 0xce, 0x08, 0x53, 0xfe, 0xdb, 0x82, 0xe3,
-	0x52, 0xb9, 0xb0, 0xb7, 0x13, 0x29, 0xad, 0x22, 0x11, 0x62, 0x65, 0xaf, 0x48, 0xdc, 0x88, 0x10,
-	0xd9, 0x4b, 0x80, 0x40, 0xaa, 0x0a, 0xed, 0x6a, 0xd4, 0xd1, 0x19, 0x0d, 0x9f, 0xc1, 0x20, 0x94,
-	0xbe, 0xbf, 0x43, 0x83, 0xf7, 0x34, 0x0e, 0x26, 0xa5, 0x09, 0xcf, 0xc0, 0x4e, 0xb3, 0x20, 0x90,
-	0x3f, 0xdc, 0xbe, 0xc6, 0xca, 0x88, 0xcd, 0xc1, 0x59, 0x4b, 0x45, 0x5b, 0x5f, 0x10, 0xba, 0x47,
-	0xba, 0x0d, 0xd6, 0x6c, 0xe3, 0x5a, 0x10, 0x7a, 0xf7, 0x24, 0xfe, 0x02, 0x6c, 0xd3, 0x19, 0x63,
-	0xd0, 0xaf, 0x79, 0xd5, 0x67, 0x7e, 0x05, 0xfd, 0xa2, 0xa0, 0xc0, 0x72, 0x14, 0x4a, 0x63, 0x43,
-	0x4f, 0x9f, 0x8b, 0xa7, 0x0e, 0xe3, 0x88, 0xb6, 0xd5, 0x53, 0xeb, 0x80, 0x8d, 0xa0, 0xe7, 0x8b,
-	0x5c, 0x5b, 0x1e, 0x7a, 0xc5, 0x71, 0xf1, 0xb3, 0x0b, 0xce, 0x75, 0x65, 0x81, 0xdd, 0x80, 0xb3,
-	0x9f, 0x79, 0xf6, 0xaa, 0xf5, 0x28, 0xad, 0x75, 0x3a, 0x3d, 0x7b, 0x14, 0x37, 0x03, 0xc4, 0x3b,
-	0x85, 0xde, 0x7e, 0xc0, 0xdb, 0x7a, 0xed, 0x55, 0x69, 0xeb, 0xfd, 0xb5, 0x19, 0xbc, 0xc3, 0xbe,
-	0xc2, 0x49, 0x7d, 0x54, 0xd9, 0xeb, 0x66, 0xc9, 0x03, 0x9b, 0x70, 0xca, 0x0f, 0x51, 0x2a, 0xe1,
-	0xb5, 0xad, 0xff, 0x21, 0xef, 0xfe, 0x04, 0x00, 0x00, 0xff, 0xff, 0xda, 0xa3, 0x57, 0x07, 0x67,
-	0x04, 0x00, 0x00,
+	// 1933 bytes of a gzipped FileDescriptorProto
+	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0xa4, 0x58, 0x5f, 0x73, 0x1b, 0x49,
+	0x11, 0x8f, 0xfe, 0x58, 0xb6, 0x5a, 0x96, 0xff, 0x8c, 0x15, 0x23, 0xf6, 0x2e, 0x67, 0x7b, 0x38,
+	0x42, 0x8a, 0xa2, 0xe4, 0xc4, 0x97, 0x03, 0xee, 0x78, 0xba, 0x9c, 0x13, 0xc5, 0x1c, 0x49, 0x5c,
+	0x6b, 0xc3, 0x15, 0x14, 0xa0, 0x1b, 0x6b, 0x47, 0xd2, 0x94, 0x57, 0x5a, 0x65, 0x77, 0xe4, 0x44,
+	0x57, 0x54, 0xf1, 0x40, 0xf1, 0xc2, 0xa7, 0xe1, 0x85, 0x4f, 0xc2, 0x03, 0x9f, 0xe0, 0xbe, 0x01,
+	0x1f, 0x80, 0x9a, 0x7f, 0xbb, 0xb3, 0xab, 0xd5, 0xda, 0x3a, 0xde, 0x34, 0x3d, 0xbf, 0xe9, 0xe9,
+	0xee, 0xe9, 0xed, 0xfe, 0xb5, 0xe0, 0x70, 0x7a, 0x3d, 0x3c, 0xf6, 0x58, 0x48, 0xfb, 0x3c, 0x08,
+	0xe7, 0x64, 0xca, 0x92, 0x45, 0x67, 0x1a, 0x06, 0x3c, 0x40, 0x9b, 0xf6, 0xae, 0x73, 0x38, 0x0c,
+	0x82, 0xa1, 0x4f, 0x8f, 0xe5, 0xde, 0xd5, 0x6c, 0x70, 0x3c, 0x60, 0xd4, 0xf7, 0x7a, 0x63, 0x12,
+	0x5d, 0x2b, 0xbc, 0x73, 0x90, 0x45, 0x70, 0x36, 0xa6, 0x11, 0x27, 0xe3, 0xa9, 0x02, 0xe0, 0xb7,
+	0xb0, 0x73, 0x3e, 0xe3, 0xcf, 0x27, 0x9c, 0xf1, 0xb9, 0x4b, 0xdf, 0xce, 0x68, 0xc4, 0xd1, 0xcf,
+	0xa0, 0x46, 0xa5, 0xa0, 0x5d, 0x3a, 0x2c, 0x3d, 0x6a, 0x9c, 0xb4, 0x3a, 0xf6, 0xad, 0x1d, 0x0d,
+	0xd6, 0x18, 0x74, 0x0c, 0x2d, 0xe2, 0xfb, 0xc1, 0xbb, 0x5e, 0x3f, 0xa4, 0x84, 0xd3, 0xde, 0x3b,
+	0xc6, 0x47, 0x3d, 0xe6, 0xb5, 0xcb, 0x87, 0xa5, 0x47, 0x1b, 0xee, 0xae, 0xdc, 0xfb, 0x52, 0x6e,
+	0x7d, 0xcd, 0xf8, 0xe8, 0xcc, 0xc3, 0xbf, 0x86, 0x5d, 0xeb, 0xca, 0x68, 0x1a, 0x4c, 0x22, 0x8a,
+	0x3e, 0x80, 0xba, 0xd2, 0x27, 0x8e, 0x8a, 0x6b, 0xeb, 0xee, 0x86, 0x12, 0x9c, 0x79, 0xa8, 0x0d,
+	0xeb, 0x37, 0x34, 0x8c, 0x58, 0x30, 0x91, 0x5a, 0xab, 0xae, 0x59, 0xe2, 0x6f, 0x60, 0xa7, 0x4b,
+	0x33, 0xe6, 0x17, 0xaa, 0x3a, 0x86, 0x35, 0x12, 0xf5, 0x82, 0x81, 0x54, 0xd4, 0x38, 0x71, 0x3a,
+	0x2a, 0x40, 0x1d, 0x13, 0xa0, 0xce, 0xa5, 0x09, 0x90, 0x5b, 0x25, 0xd1, 0x9b, 0x01, 0x1e, 0xc2,
+	0x6e, 0x97, 0x66, 0xad, 0x5d, 0x2d, 0x42, 0x1f, 0xc3, 0xd6, 0x98, 0x86, 0x43, 0xea, 0xf5, 0xd8,
+	0x84, 0x07, 0x26, 0x36, 0x75, 0x77, 0x53, 0x49, 0xcf, 0x26, 0x3c, 0x38, 0xf3, 0xf0, 0xdf, 0xcb,
+	0xb0, 0x77, 0x41, 0x49, 0xd8, 0x1f, 0xa5, 0xdd, 0x69, 0xc1, 0xda, 0xdb, 0x19, 0x0d, 0xe7, 0xda,
+	0x15, 0xb5, 0x10, 0x52, 0x9f, 0x8d, 0x19, 0x97, 0xaa, 0x9a, 0xae, 0x5a, 0x24, 0xde, 0x55, 0xee,
+	0xe6, 0x1d, 0x7a, 0x00, 0x30, 0x25, 0x43, 0xda, 0xe3, 0xc1, 0x35, 0x9d, 0xb4, 0xab, 0xf2, 0x86,
+	0xba, 0x90, 0x5c, 0x0a, 0x81, 0x08, 0x3c, 0x7d, 0x3f, 0xf5, 0x09, 0x9b, 0xb4, 0xd7, 0xe4, 0x73,
+	0x9a, 0x25, 0xfa, 0x14, 0xd6, 0x07, 0xcc, 0xe7, 0x34, 0x8c, 0xda, 0x35, 0x79, 0xd7, 0x07, 0xe9,
+	0x10, 0x28, 0x4f, 0x5e, 0x28, 0x88, 0x6b, 0xb0, 0xe8, 0x08, 0x36, 0xf5, 0xdb, 0xf0, 0xf9, 0x94,
+	0x46, 0xed, 0xf5, 0xc3, 0xca, 0xa3, 0xba, 0xdb, 0x50, 0xb2, 0x4b, 0x21, 0xc2, 0xdf, 0x95, 0xa1,
+	0x99, 0x3a, 0xbd, 0x70, 0xa8, 0xb4, 0x70, 0x08, 0x3d, 0x86, 0xfa, 0x15, 0x0b, 0xf9, 0xc8, 0x23,
+	0x9c, 0xea, 0xa7, 0x45, 0x69, 0x83, 0x4e, 0x09, 0xa7, 0x6e, 0x02, 0x42, 0x9f, 0xc1, 0x56, 0xbc,
+	0xe8, 0x0d, 0xc2, 0x60, 0xdc, 0xae, 0x2c, 0x3d, 0xd6, 0x8c, 0x91, 0x2f, 0xc2, 0x60, 0x8c, 0x3e,
+	0x85, 0xcd, 0xe4, 0x28, 0x0f, 0xda, 0xd5, 0xa5, 0x07, 0x1b, 0x31, 0xee, 0x32, 0x40, 0x8f, 0x60,
+	0xc7, 0x27, 0x11, 0xef, 0x4d, 0xc8, 0x98, 0xf6, 0xa6, 0x21, 0x1d, 0xb0, 0xf7, 0x32, 0xaa, 0x75,
+	0x77, 0x4b, 0xc8, 0x5f, 0x93, 0x31, 0x3d, 0x97, 0x52, 0xb4, 0x0f, 0xb5, 0x68, 0x36, 0x10, 0xfb,
+	0x35, 0xb9, 0xaf, 0x57, 0xe8, 0x00, 0x1a, 0xc1, 0x60, 0xc0, 0xfa, 0x54, 0xea, 0x68, 0xaf, 0xcb,
+	0x4d, 0x50, 0x22, 0x71, 0x1c, 0xfd, 0x14, 0x76, 0x07, 0x2c, 0xcc, 0xdc, 0xb1, 0x21, 0x61, 0xdb,
+	0x72, 0x23, 0xb9, 0x04, 0xff, 0xb7, 0x04, 0xad, 0x74, 0xbe, 0xe9, 0xe4, 0x7e, 0x0a, 0xea, 0x73,
+	0x61, 0x3a, 0xd4, 0x4b, 0xd2, 0xfb, 0x59, 0xb9, 0x5d, 0x72, 0x63, 0x24, 0x7a, 0x0a, 0xeb, 0x21,
+	0x8d, 0x66, 0x3e, 0x8f, 0xda, 0x15, 0x79, 0xc8, 0xc9, 0x4b, 0x08, 0x57, 0x42, 0x5c, 0x03, 0x45,
+	0x0f, 0x61, 0x7b, 0x42, 0xdf, 0xf3, 0x9e, 0x95, 0x84, 0xea, 0xdb, 0x68, 0x0a, 0xf1, 0x79, 0x9c,
+	0x88, 0x1d, 0xd8, 0x13, 0x95, 0xcb, 0xeb, 0x05, 0x33, 0xde, 0x8b, 0xa4, 0x2a, 0x91, 0x7a, 0x55,
+	0x99, 0x09, 0xbb, 0x72, 0xeb, 0xcd, 0x8c, 0x5f, 0x98, 0x0d, 0x91, 0xb8, 0x53, 0x12, 0x72, 0x46,
+	0x7c, 0x93, 0xb8, 0x7a, 0x89, 0xff, 0x5d, 0x86, 0x4d, 0xdb, 0x96, 0x15, 0xbf, 0xe5, 0x8f, 0x00,
+	0x22, 0x36, 0x66, 0x3e, 0x09, 0xc5, 0x09, 0x61, 0x6b, 0xd9, 0xb5, 0x24, 0x88, 0xc1, 0x7d, 0x63,
+	0x5e, 0x2f, 0x16, 0x33, 0x6a, 0x82, 0xf2, 0x74, 0x79, 0x50, 0x3a, 0xc6, 0xfa, 0x0b, 0xeb, 0xd8,
+	0xf3, 0x09, 0x0f, 0xe7, 0x6e, 0x2b, 0xca, 0xd9, 0x42, 0x5f, 0x40, 0x43, 0x7e, 0x8d, 0x13, 0xc2,
+	0x45, 0x65, 0x54, 0x59, 0x78, 0x90, 0x77, 0xc1, 0xf3, 0x04, 0xe6, 0xda, 0x67, 0x9c, 0x2e, 0xfc,
+	0x70, 0xe9, 0xad, 0x68, 0x07, 0x2a, 0xd7, 0xd4, 0x94, 0x1d, 0xf1, 0x53, 0x14, 0x9d, 0x1b, 0xe2,
+	0xcf, 0xa8, 0xf6, 0x5b, 0x2d, 0x3e, 0x2f, 0xff, 0xb2, 0x84, 0x7d, 0xd8, 0x5d, 0xb8, 0x4a, 0xd4,
+	0x88, 0x31, 0xe1, 0xfd, 0x51, 0x9c, 0x47, 0xb9, 0x35, 0x82, 0x86, 0xaf, 0x04, 0xc8, 0x35, 0x58,
+	0x74, 0x08, 0x8d, 0x7e, 0x30, 0xbe, 0x62, 0xda, 0x2f, 0x95, 0x0f, 0xb6, 0x08, 0x13, 0x68, 0xa6,
+	0xce, 0x22, 0x07, 0x36, 0x4c, 0x88, 0x4c, 0xc5, 0x37, 0xeb, 0xa4, 0x7e, 0x96, 0xed, 0xfa, 0x99,
+	0x7e, 0xc7, 0x4a, 0xf6, 0x1d, 0xf1, 0x09, 0xec, 0x9d, 0x52, 0x9f, 0x72, 0x7a, 0xf7, 0xde, 0x82,
+	0xf7, 0xa1, 0x95, 0x3e, 0xa3, 0x3e, 0x28, 0xfc, 0x73, 0xf8, 0x41, 0xdc, 0x42, 0x5e, 0xb2, 0x48,
+	0xb8, 0x7f, 0x27, 0x7d, 0x17, 0xd0, 0x5e, 0x3c, 0xa7, 0x3f, 0xd2, 0x5f, 0xc0, 0x86, 0xee, 0x81,
+	0x4b, 0x82, 0xab, 0x8e, 0xfd, 0x4e, 0x61, 0xdc, 0x18, 0x8c, 0xdf, 0x01, 0x32, 0xdd, 0x97, 0xd1,
+	0xc8, 0xd8, 0xf1, 0xf8, 0x6e, 0xdf, 0xbc, 0xf5, 0xbd, 0xaf, 0xdc, 0xf6, 0x5f, 0xc3, 0x5e, 0xea,
+	0xe2, 0xd8, 0x91, 0xb8, 0x6e, 0xa8, 0x8b, 0x1f, 0xa4, 0x2f, 0xb6, 0xa9, 0x82, 0x5d, 0x3a, 0xf0,
+	0x9f, 0x61, 0x3b, 0xb3, 0x57, 0xdc, 0xf9, 0x5b, 0xb0, 0x46, 0xc3, 0x30, 0x08, 0x4d, 0x1e, 0xc8,
+	0x85, 0x4d, 0x2d, 0x2a, 0x69, 0x6a, 0xf1, 0x09, 0xa0, 0x2e, 0xb5, 0xec, 0x55, 0x81, 0x7a, 0x00,
+	0x10, 0x5f, 0x61, 0x3a, 0x51, 0xdd, 0xdc, 0x11, 0x09, 0x27, 0xbb, 0x74, 0x75, 0x27, 0xbb, 0x74,
+	0x89, 0x93, 0xbf, 0x85, 0xed, 0xcc, 0xde, 0x8a, 0xf5, 0x2a, 0xd7, 0x6b, 0x1c, 0xc2, 0xde, 0x6f,
+	0x58, 0xb4, 0xe0, 0xdc, 0x01, 0x34, 0xac, 0x46, 0xab, 0x23, 0x08, 0x49, 0x9f, 0x15, 0x01, 0x96,
+	0x95, 0x3a, 0x62, 0xdf, 0x52, 0xcd, 0x3c, 0x36, 0x84, 0xe0, 0x82, 0x7d, 0x4b, 0x33, 0x5c, 0xa2,
+	0x92, 0xe1, 0x12, 0x78, 0x0a, 0xad, 0xf4, 0x9d, 0x3a, 0x36, 0xab, 0xa7, 0xde, 0x1d, 0x9b, 0x06,
+	0xfe, 0x2b, 0xa0, 0x73, 0x51, 0x1e, 0xfe, 0x1f, 0x76, 0xfb, 0x2b, 0x68, 0xcc, 0xa6, 0xb2, 0xd1,
+	0x0b, 0x56, 0xbd, 0x94, 0x35, 0xbe, 0x10, 0xc4, 0xfb, 0x15, 0x89, 0xae, 0x5d, 0x50, 0x70, 0xf1,
+	0x1b, 0x7f, 0x09, 0x7b, 0x29, 0x03, 0xbe, 0x0f, 0x7b, 0xc4, 0x97, 0xd0, 0x7a, 0x25, 0x78, 0x62,
+	0xce, 0x63, 0x45, 0xb3, 0xf0, 0x86, 0xdd, 0x04, 0x61, 0x92, 0xee, 0x60, 0x44, 0x67, 0x9e, 0x78,
+	0x2c, 0x43, 0x3b, 0x0d, 0xe3, 0xdc, 0xd0, 0x8c, 0xd3, 0xc3, 0x67, 0x70, 0x3f, 0xa3, 0x35, 0x79,
+	0x0e, 0xa3, 0xa3, 0xd0, 0xbc, 0x18, 0x85, 0xff, 0x51, 0x82, 0x3d, 0x97, 0xde, 0xd0, 0x70, 0x15,
+	0x1e, 0xfe, 0x00, 0x80, 0x07, 0xbd, 0x34, 0xab, 0xaf, 0xf3, 0x40, 0xd7, 0x2b, 0xe1, 0x5c, 0x28,
+	0x55, 0x52, 0xaf, 0x77, 0x35, 0xd7, 0xc9, 0x04, 0x46, 0xf4, 0x6c, 0x2e, 0x28, 0x52, 0x48, 0x49,
+	0x14, 0x18, 0xd2, 0xaa, 0x57, 0xf8, 0x14, 0x5a, 0x69, 0x5b, 0xbe, 0x57, 0xcc, 0xbf, 0x82, 0xfb,
+	0x2f, 0xd8, 0xc4, 0x3b, 0x9d, 0x4d, 0x7d, 0xd6, 0x27, 0x3c, 0x09, 0xfa, 0x87, 0x50, 0xe7, 0xa3,
+	0x90, 0x46, 0xa3, 0xc0, 0x57, 0x3e, 0x95, 0xdd, 0x44, 0x90, 0x4f, 0xca, 0xf1, 0x57, 0xb0, 0x9f,
+	0x55, 0xa6, 0x8d, 0x7a, 0x02, 0x6b, 0x53, 0xc2, 0xc2, 0x25, 0x15, 0x3c, 0x3e, 0x70, 0x4e, 0x58,
+	0xe8, 0x2a, 0x24, 0xfe, 0x4f, 0x09, 0x9a, 0xa9, 0x0d, 0xd4, 0x81, 0x75, 0x65, 0xf5, 0x93, 0x42,
+	0xd7, 0x0c, 0x28, 0xc1, 0x9f, 0xb4, 0xcb, 0xb7, 0xe3, 0x4f, 0x84, 0x53, 0x51, 0x3f, 0x08, 0xa9,
+	0x6e, 0x92, 0x6a, 0x81, 0x7e, 0x02, 0xdb, 0x92, 0x63, 0x5a, 0x4d, 0xb4, 0x2a, 0xf7, 0xb7, 0x84,
+	0x38, 0x26, 0x13, 0x73, 0x01, 0x4c, 0xc8, 0xb2, 0x6c, 0xf1, 0x9a, 0x91, 0x25, 0xf4, 0x5b, 0xf6,
+	0x70, 0xfc, 0x47, 0xd8, 0x93, 0x3f, 0xce, 0x09, 0x67, 0x74, 0xc2, 0x4d, 0xc4, 0x8f, 0x05, 0x93,
+	0x93, 0x12, 0xed, 0xde, 0xfd, 0x4c, 0x7f, 0xd0, 0x70, 0x83, 0x5a, 0xf2, 0x08, 0x2e, 0xb4, 0xd2,
+	0xda, 0xf5, 0x13, 0x7c, 0x0e, 0xd0, 0x27, 0x13, 0x8f, 0x09, 0x3b, 0xcc, 0x3b, 0x38, 0xb9, 0x37,
+	0xc8, 0xe3, 0xae, 0x85, 0xc6, 0x7f, 0x81, 0x4d, 0x7b, 0x6f, 0xc5, 0xca, 0xb2, 0x0f, 0xb5, 0x77,
+	0x94, 0x0d, 0x47, 0xca, 0xd0, 0x92, 0xab, 0x57, 0xe8, 0x21, 0x6c, 0xf5, 0x7d, 0x12, 0x45, 0x6c,
+	0x20, 0xde, 0xd8, 0x34, 0xa6, 0xba, 0x9b, 0x91, 0xe2, 0x01, 0xec, 0x7d, 0x31, 0xe3, 0x41, 0x3f,
+	0x18, 0x4f, 0x7d, 0xca, 0xa9, 0x89, 0xd7, 0x3e, 0xd4, 0x34, 0xef, 0x57, 0x9f, 0x9c, 0x5e, 0x2d,
+	0x19, 0x18, 0xb3, 0xa3, 0x55, 0x65, 0x71, 0x1e, 0x73, 0xa1, 0x95, 0xbe, 0x27, 0x8e, 0x5c, 0x23,
+	0x9a, 0x0d, 0x87, 0x34, 0xe2, 0x16, 0x09, 0x69, 0x67, 0x18, 0x5e, 0x0c, 0x70, 0x6d, 0x30, 0x1e,
+	0x03, 0x24, 0x5b, 0xc5, 0x85, 0x22, 0xd3, 0x93, 0xca, 0x0b, 0x3d, 0xe9, 0x08, 0x36, 0x3d, 0x16,
+	0x4d, 0x7d, 0x32, 0x57, 0x53, 0x91, 0x8a, 0x56, 0x43, 0xcb, 0xc4, 0xc0, 0x83, 0xbf, 0x81, 0x9a,
+	0x2a, 0x0a, 0x99, 0xb2, 0x53, 0xba, 0xa5, 0xec, 0x94, 0x0b, 0xca, 0x4e, 0x25, 0x55, 0x76, 0xfe,
+	0x55, 0x82, 0x9a, 0x7a, 0xdf, 0x62, 0x6f, 0x9e, 0x24, 0xd9, 0x5c, 0x2e, 0xc8, 0xe6, 0x97, 0xf7,
+	0x92, 0x7c, 0xee, 0x40, 0x4d, 0x4d, 0x78, 0xed, 0x4a, 0x5e, 0x56, 0xbd, 0x91, 0x7b, 0x2f, 0xef,
+	0xb9, 0x1a, 0x65, 0x33, 0x9a, 0x6a, 0x8a, 0xd1, 0x3c, 0xdb, 0x85, 0x6d, 0x11, 0xc3, 0x1e, 0xe1,
+	0x3c, 0x64, 0x57, 0x33, 0x91, 0xc2, 0x7f, 0x2b, 0x43, 0x33, 0xc5, 0x14, 0x57, 0x4c, 0xe2, 0xcf,
+	0x00, 0x6e, 0x88, 0xcf, 0x3c, 0x35, 0x41, 0xdf, 0xfe, 0x9f, 0x4a, 0x5d, 0xa2, 0xf5, 0x14, 0xbd,
+	0xa1, 0x8e, 0xf2, 0xe0, 0x0e, 0x7f, 0x57, 0xac, 0x4b, 0xec, 0x65, 0x90, 0xf3, 0x67, 0x4a, 0x75,
+	0xf1, 0xcf, 0x14, 0xe1, 0x85, 0x7a, 0xb5, 0xf6, 0x5a, 0x9e, 0x17, 0x2a, 0x1b, 0x5c, 0x8d, 0xc1,
+	0xff, 0x2c, 0xc1, 0xba, 0x8e, 0xbc, 0x78, 0xbe, 0x78, 0x4a, 0x37, 0xcf, 0x67, 0xc6, 0x73, 0x91,
+	0x3e, 0xc9, 0x7c, 0xad, 0xd3, 0xa3, 0x1e, 0x0f, 0xd6, 0x22, 0x7d, 0xc6, 0xcc, 0xf3, 0x7c, 0x6a,
+	0x67, 0x22, 0x28, 0x91, 0x04, 0x24, 0x83, 0x7d, 0x35, 0x35, 0xd8, 0xa7, 0xfe, 0xbe, 0x58, 0xbb,
+	0xc3, 0xdf, 0x17, 0xf8, 0x43, 0xa8, 0xa9, 0x97, 0x47, 0x08, 0xaa, 0x96, 0xad, 0xf2, 0x37, 0x7e,
+	0x06, 0x55, 0x71, 0x40, 0xec, 0xcd, 0x29, 0x51, 0x8d, 0xbc, 0xe9, 0xca, 0xdf, 0xa2, 0x10, 0x8c,
+	0x83, 0x09, 0x1f, 0x99, 0x42, 0x20, 0x17, 0x62, 0xd8, 0xf3, 0x88, 0x6a, 0xb4, 0x4d, 0x57, 0xfc,
+	0x3c, 0xf9, 0xae, 0x0e, 0xf5, 0x53, 0x63, 0x02, 0x7a, 0x0d, 0xf5, 0x98, 0x6d, 0xa3, 0x8f, 0x96,
+	0x52, 0x74, 0x59, 0x83, 0x9c, 0x83, 0xa5, 0xfb, 0x7a, 0x22, 0xba, 0x27, 0xf4, 0x75, 0xe9, 0x12,
+	0x7d, 0x5d, 0x5a, 0xac, 0x6f, 0xe1, 0xff, 0x38, 0x7c, 0x0f, 0x7d, 0x6d, 0xa6, 0x7a, 0xad, 0xf2,
+	0x28, 0x77, 0x0e, 0x4e, 0x69, 0xc5, 0x45, 0x10, 0x5b, 0xb1, 0x3d, 0xd4, 0x65, 0x15, 0xe7, 0x0c,
+	0x89, 0x0e, 0x2e, 0x82, 0xc4, 0x8a, 0xfb, 0xb0, 0x93, 0x9d, 0xee, 0xd0, 0x8f, 0x97, 0x38, 0x9a,
+	0x9e, 0x1a, 0x9d, 0x87, 0xb7, 0xc1, 0xe2, 0x4b, 0x2e, 0xa1, 0x61, 0x0d, 0x5d, 0xe8, 0x30, 0xff,
+	0x61, 0x12, 0x56, 0xe9, 0x1c, 0x15, 0x20, 0x6c, 0xad, 0x5d, 0xba, 0x54, 0x6b, 0x97, 0xde, 0xa6,
+	0x35, 0x67, 0x44, 0xc2, 0xf7, 0xd0, 0xef, 0x61, 0xd3, 0x1e, 0x10, 0xb2, 0x91, 0xce, 0x19, 0x58,
+	0x1c, 0x5c, 0x04, 0x31, 0x8a, 0x1f, 0x97, 0x64, 0x18, 0x12, 0x22, 0xbe, 0x10, 0x86, 0x85, 0x21,
+	0xc1, 0x39, 0x2a, 0x40, 0xc4, 0x06, 0xff, 0x01, 0x9a, 0x29, 0x0e, 0x8d, 0x32, 0xe6, 0xe4, 0xd1,
+	0x76, 0xe7, 0x47, 0x85, 0x18, 0x3b, 0xed, 0x6c, 0x1e, 0x9b, 0x0d, 0x46, 0x0e, 0xdf, 0x76, 0x70,
+	0x11, 0x24, 0x56, 0xfc, 0x27, 0xd8, 0x4a, 0xb3, 0x51, 0x94, 0xb1, 0x28, 0x97, 0xf8, 0x3a, 0x1f,
+	0x17, 0x83, 0x6c, 0xbb, 0x6d, 0x9e, 0x95, 0xb5, 0x3b, 0x87, 0xe1, 0x39, 0xb8, 0x08, 0x62, 0x2b,
+	0xb6, 0x69, 0x48, 0x56, 0x71, 0x0e, 0x15, 0x72, 0x70, 0x11, 0xc4, 0x28, 0xbe, 0xaa, 0xc9, 0x6e,
+	0xf3, 0xc9, 0xff, 0x06, 0x00, 0x57, 0x75, 0x2c, 0x9d, 0x7d, 0x19, 0x00, 0x00,
 }
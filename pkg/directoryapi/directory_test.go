@@ -4,7 +4,9 @@ import (
 	"strings"
 	"testing"
 
+	"github.com/pkg/errors"
 	"github.com/stretchr/testify/assert"
+	"google.golang.org/genproto/protobuf/field_mask"
 )
 
 func TestEntity_Type(t *testing.T) {
@@ -79,6 +81,449 @@ func TestValidateGetEntityRequest(t *testing.T) {
 	}
 }
 
+func TestValidateDeleteEntityRequest(t *testing.T) {
+	cases := map[string]struct {
+		rq       *DeleteEntityRequest
+		expected error
+	}{
+		"ok": {
+			rq:       &DeleteEntityRequest{EntityId: "some entity ID"},
+			expected: nil,
+		},
+		"missing entity ID": {
+			rq:       &DeleteEntityRequest{},
+			expected: ErrDeleteMissingEntityID,
+		},
+	}
+
+	for desc, c := range cases {
+		err := ValidateDeleteEntityRequest(c.rq)
+		assert.Equal(t, c.expected, err, desc)
+	}
+}
+
+func TestValidateGetEntityHistoryRequest(t *testing.T) {
+	cases := map[string]struct {
+		rq       *GetEntityHistoryRequest
+		expected error
+	}{
+		"ok": {
+			rq:       &GetEntityHistoryRequest{EntityId: "some entity ID"},
+			expected: nil,
+		},
+		"missing entity ID": {
+			rq:       &GetEntityHistoryRequest{},
+			expected: ErrGetHistoryMissingEntityID,
+		},
+	}
+
+	for desc, c := range cases {
+		err := ValidateGetEntityHistoryRequest(c.rq)
+		assert.Equal(t, c.expected, err, desc)
+	}
+}
+
+func TestValidatePutEntitiesRequest(t *testing.T) {
+	cases := map[string]struct {
+		rq       *PutEntitiesRequest
+		expected error
+	}{
+		"ok": {
+			rq: &PutEntitiesRequest{Entities: []*Entity{
+				NewTestPatient(1, true),
+				NewTestPatient(2, false),
+				NewTestPatient(3, false),
+			}},
+			expected: nil,
+		},
+		"empty": {
+			rq:       &PutEntitiesRequest{},
+			expected: ErrEmptyBatch,
+		},
+		"too large": {
+			rq:       &PutEntitiesRequest{Entities: make([]*Entity, MaxBatchSize+1)},
+			expected: ErrBatchTooLarge,
+		},
+		"duplicate entity ID": {
+			rq: &PutEntitiesRequest{Entities: []*Entity{
+				NewTestPatient(1, true),
+				NewTestOffice(1, true),
+			}},
+			expected: ErrDupBatchEntityID,
+		},
+	}
+
+	for desc, c := range cases {
+		err := ValidatePutEntitiesRequest(c.rq)
+		assert.Equal(t, c.expected, err, desc)
+	}
+}
+
+func TestValidateGetEntitiesRequest(t *testing.T) {
+	cases := map[string]struct {
+		rq       *GetEntitiesRequest
+		expected error
+	}{
+		"ok": {
+			rq:       &GetEntitiesRequest{EntityIds: []string{"entity 1", "entity 2"}},
+			expected: nil,
+		},
+		"empty": {
+			rq:       &GetEntitiesRequest{},
+			expected: ErrEmptyBatch,
+		},
+		"too large": {
+			rq:       &GetEntitiesRequest{EntityIds: make([]string, MaxBatchSize+1)},
+			expected: ErrBatchTooLarge,
+		},
+	}
+
+	for desc, c := range cases {
+		err := ValidateGetEntitiesRequest(c.rq)
+		assert.Equal(t, c.expected, err, desc)
+	}
+}
+
+func TestValidateListEntitiesRequest(t *testing.T) {
+	cases := map[string]struct {
+		rq       *ListEntitiesRequest
+		expected error
+	}{
+		"ok patient": {
+			rq:       &ListEntitiesRequest{EntityType: PatientType},
+			expected: nil,
+		},
+		"ok office": {
+			rq: &ListEntitiesRequest{
+				EntityType: OfficeType,
+				PageSize:   MaxListPageSize,
+				PageToken:  "some page token",
+			},
+			expected: nil,
+		},
+		"unknown entity type": {
+			rq:       &ListEntitiesRequest{EntityType: "some type"},
+			expected: ErrListUnknownEntityType,
+		},
+		"page size too large": {
+			rq: &ListEntitiesRequest{
+				EntityType: PatientType,
+				PageSize:   MaxListPageSize + 1,
+			},
+			expected: ErrListPageSizeTooLarge,
+		},
+	}
+
+	for desc, c := range cases {
+		err := ValidateListEntitiesRequest(c.rq)
+		assert.Equal(t, c.expected, err, desc)
+	}
+}
+
+func TestValidatePatchEntityRequest(t *testing.T) {
+	patch := NewPatient("some entity ID", &Patient{LastName: "Last Name"})
+	cases := map[string]struct {
+		rq       *PatchEntityRequest
+		expected error
+	}{
+		"ok": {
+			rq: &PatchEntityRequest{
+				Entity:     patch,
+				UpdateMask: &field_mask.FieldMask{Paths: []string{"patient.last_name"}},
+			},
+			expected: nil,
+		},
+		"missing entity": {
+			rq: &PatchEntityRequest{
+				UpdateMask: &field_mask.FieldMask{Paths: []string{"patient.last_name"}},
+			},
+			expected: ErrPatchMissingEntity,
+		},
+		"missing entity ID": {
+			rq: &PatchEntityRequest{
+				Entity:     NewPatient("", &Patient{LastName: "Last Name"}),
+				UpdateMask: &field_mask.FieldMask{Paths: []string{"patient.last_name"}},
+			},
+			expected: ErrPatchMissingEntityID,
+		},
+		"missing update mask": {
+			rq:       &PatchEntityRequest{Entity: patch},
+			expected: ErrPatchMissingUpdateMask,
+		},
+		"empty update mask": {
+			rq: &PatchEntityRequest{
+				Entity:     patch,
+				UpdateMask: &field_mask.FieldMask{},
+			},
+			expected: ErrPatchMissingUpdateMask,
+		},
+		"path for other type": {
+			rq: &PatchEntityRequest{
+				Entity:     patch,
+				UpdateMask: &field_mask.FieldMask{Paths: []string{"office.name"}},
+			},
+			expected: ErrPatchInvalidPath,
+		},
+		"missing type attributes": {
+			rq: &PatchEntityRequest{
+				Entity:     &Entity{EntityId: "some entity ID"},
+				UpdateMask: &field_mask.FieldMask{Paths: []string{"office.name"}},
+			},
+			expected: ErrMissingTypeAttributes,
+		},
+	}
+
+	for desc, c := range cases {
+		err := ValidatePatchEntityRequest(c.rq)
+		assert.Equal(t, c.expected, err, desc)
+	}
+}
+
+func TestValidateMergeEntitiesRequest(t *testing.T) {
+	cases := map[string]struct {
+		rq       *MergeEntitiesRequest
+		expected error
+	}{
+		"ok": {
+			rq:       &MergeEntitiesRequest{SurvivorId: "entity 1", MergedId: "entity 2"},
+			expected: nil,
+		},
+		"missing survivor ID": {
+			rq:       &MergeEntitiesRequest{MergedId: "entity 2"},
+			expected: ErrMergeMissingSurvivorID,
+		},
+		"missing merged ID": {
+			rq:       &MergeEntitiesRequest{SurvivorId: "entity 1"},
+			expected: ErrMergeMissingMergedID,
+		},
+		"same entity": {
+			rq:       &MergeEntitiesRequest{SurvivorId: "entity 1", MergedId: "entity 1"},
+			expected: ErrMergeSameEntity,
+		},
+	}
+
+	for desc, c := range cases {
+		err := ValidateMergeEntitiesRequest(c.rq)
+		assert.Equal(t, c.expected, err, desc)
+	}
+}
+
+func TestValidateRevertEntityRequest(t *testing.T) {
+	cases := map[string]struct {
+		rq       *RevertEntityRequest
+		expected error
+	}{
+		"ok": {
+			rq: &RevertEntityRequest{
+				EntityId:   "entity 1",
+				ToVersion:  1,
+				RevertedBy: "some registrar",
+				Reason:     "some reason",
+			},
+			expected: nil,
+		},
+		"missing entity ID": {
+			rq: &RevertEntityRequest{
+				ToVersion:  1,
+				RevertedBy: "some registrar",
+				Reason:     "some reason",
+			},
+			expected: ErrRevertMissingEntityID,
+		},
+		"missing to version": {
+			rq: &RevertEntityRequest{
+				EntityId:   "entity 1",
+				RevertedBy: "some registrar",
+				Reason:     "some reason",
+			},
+			expected: ErrRevertMissingToVersion,
+		},
+		"missing reverted by": {
+			rq: &RevertEntityRequest{
+				EntityId:  "entity 1",
+				ToVersion: 1,
+				Reason:    "some reason",
+			},
+			expected: ErrRevertMissingRevertedBy,
+		},
+		"missing reason": {
+			rq: &RevertEntityRequest{
+				EntityId:   "entity 1",
+				ToVersion:  1,
+				RevertedBy: "some registrar",
+			},
+			expected: ErrRevertMissingReason,
+		},
+	}
+
+	for desc, c := range cases {
+		err := ValidateRevertEntityRequest(c.rq)
+		assert.Equal(t, c.expected, err, desc)
+	}
+}
+
+func TestValidateFindDuplicatesRequest(t *testing.T) {
+	cases := map[string]struct {
+		rq       *FindDuplicatesRequest
+		expected error
+	}{
+		"ok": {
+			rq:       &FindDuplicatesRequest{Threshold: 0.9, Limit: 10},
+			expected: nil,
+		},
+		"defaults": {
+			rq:       &FindDuplicatesRequest{},
+			expected: nil,
+		},
+		"threshold too small": {
+			rq:       &FindDuplicatesRequest{Threshold: -0.1},
+			expected: ErrDuplicateThresholdOutOfRange,
+		},
+		"threshold too large": {
+			rq:       &FindDuplicatesRequest{Threshold: 1.1},
+			expected: ErrDuplicateThresholdOutOfRange,
+		},
+		"limit too large": {
+			rq:       &FindDuplicatesRequest{Limit: MaxDuplicatesLimit + 1},
+			expected: ErrDuplicatesLimitTooLarge,
+		},
+	}
+
+	for desc, c := range cases {
+		err := ValidateFindDuplicatesRequest(c.rq)
+		assert.Equal(t, c.expected, err, desc)
+	}
+}
+
+func TestValidateMatchPatientRequest(t *testing.T) {
+	cases := map[string]struct {
+		rq       *MatchPatientRequest
+		expected error
+	}{
+		"ok": {
+			rq: &MatchPatientRequest{
+				Patient: NewTestPatient(1, false).GetPatient(),
+				Limit:   4,
+			},
+			expected: nil,
+		},
+		"birthdate only": {
+			rq: &MatchPatientRequest{
+				Patient: &Patient{Birthdate: &Date{Year: 2006, Month: 1, Day: 2}},
+			},
+			expected: nil,
+		},
+		"missing patient": {
+			rq:       &MatchPatientRequest{},
+			expected: ErrMatchMissingPatient,
+		},
+		"empty patient": {
+			rq:       &MatchPatientRequest{Patient: &Patient{MiddleName: "Middle Name"}},
+			expected: ErrMatchPatientEmpty,
+		},
+		"limit too large": {
+			rq: &MatchPatientRequest{
+				Patient: NewTestPatient(1, false).GetPatient(),
+				Limit:   MaxMatchLimit + 1,
+			},
+			expected: ErrMatchLimitTooLarge,
+		},
+	}
+
+	for desc, c := range cases {
+		err := ValidateMatchPatientRequest(c.rq)
+		assert.Equal(t, c.expected, err, desc)
+	}
+}
+
+func TestValidateAutocompleteRequest(t *testing.T) {
+	cases := map[string]struct {
+		rq       *AutocompleteRequest
+		expected error
+	}{
+		"ok": {
+			rq:       &AutocompleteRequest{Prefix: "ga", Limit: 4},
+			expected: nil,
+		},
+		"entity types": {
+			rq: &AutocompleteRequest{
+				Prefix:      "Garcia, Jo",
+				EntityTypes: []string{PatientType, OfficeType},
+			},
+			expected: nil,
+		},
+		"prefix too short": {
+			rq:       &AutocompleteRequest{Prefix: "g"},
+			expected: ErrAutocompletePrefixTooShort,
+		},
+		"folded prefix too short": {
+			rq:       &AutocompleteRequest{Prefix: "O'"},
+			expected: ErrAutocompletePrefixTooShort,
+		},
+		"prefix too long": {
+			rq:       &AutocompleteRequest{Prefix: strings.Repeat("a", MaxAutocompletePrefixLen+1)},
+			expected: ErrAutocompletePrefixTooLong,
+		},
+		"limit too large": {
+			rq:       &AutocompleteRequest{Prefix: "ga", Limit: MaxAutocompleteLimit + 1},
+			expected: ErrAutocompleteLimitTooLarge,
+		},
+		"unknown entity type": {
+			rq:       &AutocompleteRequest{Prefix: "ga", EntityTypes: []string{"OTHER"}},
+			expected: ErrAutocompleteUnknownEntityType,
+		},
+	}
+
+	for desc, c := range cases {
+		err := ValidateAutocompleteRequest(c.rq)
+		assert.Equal(t, c.expected, err, desc)
+	}
+}
+
+func TestPatchEntity(t *testing.T) {
+	e := NewTestPatient(1, true)
+	patch := NewPatient(e.EntityId, &Patient{LastName: "New Last Name", Suffix: "Jr"})
+
+	patched, err := PatchEntity(e, patch, []string{"patient.last_name"})
+	assert.Nil(t, err)
+	assert.Equal(t, "New Last Name", patched.TypeAttributes.(*Entity_Patient).Patient.LastName)
+	assert.Empty(t, patched.TypeAttributes.(*Entity_Patient).Patient.Suffix)
+	assert.Equal(t, e.EntityId, patched.EntityId)
+
+	// original entity is unchanged
+	assert.Equal(t, NewTestPatient(1, true), e)
+
+	// unpatched fields keep their original values
+	ep := e.TypeAttributes.(*Entity_Patient).Patient
+	pp := patched.TypeAttributes.(*Entity_Patient).Patient
+	assert.Equal(t, ep.FirstName, pp.FirstName)
+	assert.Equal(t, ep.Birthdate, pp.Birthdate)
+
+	// office
+	f := NewTestOffice(1, true)
+	patched, err = PatchEntity(f, NewOffice(f.EntityId, &Office{Name: "New Name"}),
+		[]string{"office.name"})
+	assert.Nil(t, err)
+	assert.Equal(t, "New Name", patched.TypeAttributes.(*Entity_Office).Office.Name)
+}
+
+func TestPatchEntity_err(t *testing.T) {
+	e := NewTestPatient(1, true)
+
+	patched, err := PatchEntity(e, NewOffice(e.EntityId, &Office{Name: "New Name"}),
+		[]string{"office.name"})
+	assert.Equal(t, ErrPatchTypeMismatch, err)
+	assert.Nil(t, patched)
+
+	patched, err = PatchEntity(e, NewPatient(e.EntityId, &Patient{}), []string{"patient.age"})
+	assert.Equal(t, ErrPatchInvalidPath, err)
+	assert.Nil(t, patched)
+
+	patched, err = PatchEntity(e, NewPatient(e.EntityId, &Patient{}), nil)
+	assert.Equal(t, ErrPatchMissingUpdateMask, err)
+	assert.Nil(t, patched)
+}
+
 func TestValidateEntity(t *testing.T) {
 	cases := map[string]struct {
 		e        *Entity
@@ -165,13 +610,216 @@ func TestValidateSearchEntityRequest(t *testing.T) {
 			},
 			expected: ErrSearchLimitTooLarge,
 		},
+		"ok filters": {
+			rq: &SearchEntityRequest{
+				Query:   strings.Repeat("A", 4),
+				Limit:   1,
+				Filters: &SearchFilters{EntityTypes: []string{PatientType}},
+			},
+			expected: nil,
+		},
+		"unknown filter entity type": {
+			rq: &SearchEntityRequest{
+				Query:   strings.Repeat("A", 4),
+				Limit:   1,
+				Filters: &SearchFilters{EntityTypes: []string{"SOME TYPE"}},
+			},
+			expected: ErrSearchUnknownEntityType,
+		},
+		"ok entity types": {
+			rq: &SearchEntityRequest{
+				Query:       strings.Repeat("A", 4),
+				Limit:       1,
+				EntityTypes: []string{OfficeType},
+			},
+			expected: nil,
+		},
+		"unknown entity type": {
+			rq: &SearchEntityRequest{
+				Query:       strings.Repeat("A", 4),
+				Limit:       1,
+				EntityTypes: []string{"SOME TYPE"},
+			},
+			expected: ErrSearchUnknownEntityType,
+		},
+		"invalid filter birthdate range": {
+			rq: &SearchEntityRequest{
+				Query: strings.Repeat("A", 4),
+				Limit: 1,
+				Filters: &SearchFilters{
+					BirthdateFrom: &Date{Year: 2006, Month: 1, Day: 2},
+					BirthdateTo:   &Date{Year: 2006, Month: 1, Day: 1},
+				},
+			},
+			expected: ErrSearchInvalidBirthdateRange,
+		},
+		"ok prefixed query": {
+			rq: &SearchEntityRequest{
+				Query: "last:smith first:jo dob:1980-02-03 type:patient",
+				Limit: 1,
+			},
+			expected: nil,
+		},
+		"raw query too long": {
+			rq: &SearchEntityRequest{
+				Query: "last:" + strings.Repeat("A", 124),
+				Limit: 1,
+			},
+			expected: ErrRawSearchQueryTooLong,
+		},
+		"short text with field terms": {
+			rq: &SearchEntityRequest{
+				Query: "dob:1980-02-03 li",
+				Limit: 1,
+			},
+			expected: nil,
+		},
+		"short names": {
+			rq: &SearchEntityRequest{
+				Query: "last:li first:ann",
+				Limit: 1,
+			},
+			expected: nil,
+		},
+		"prefixed query text too short": {
+			rq: &SearchEntityRequest{
+				Query: "last:smi",
+				Limit: 1,
+			},
+			expected: ErrSearchQueryTooShort,
+		},
+		"unknown query field": {
+			rq: &SearchEntityRequest{
+				Query: "smith city:boston",
+				Limit: 1,
+			},
+			expected: ErrSearchUnknownField,
+		},
 	}
 	for desc, c := range cases {
 		err := ValidateSearchEntityRequest(c.rq)
-		assert.Equal(t, c.expected, err, desc)
+		assert.Equal(t, c.expected, errors.Cause(err), desc)
 	}
 }
 
+func TestSearchEntityRequest_AllFilters(t *testing.T) {
+	rq := &SearchEntityRequest{}
+	assert.Nil(t, rq.AllFilters())
+
+	filters := &SearchFilters{EntityTypes: []string{PatientType}, LastNamePrefix: "Last"}
+	rq = &SearchEntityRequest{Filters: filters}
+	assert.Equal(t, filters, rq.AllFilters())
+
+	rq = &SearchEntityRequest{EntityTypes: []string{OfficeType}}
+	assert.Equal(t, &SearchFilters{EntityTypes: []string{OfficeType}}, rq.AllFilters())
+
+	rq = &SearchEntityRequest{Filters: filters, EntityTypes: []string{OfficeType}}
+	assert.Equal(t, &SearchFilters{
+		EntityTypes:    []string{OfficeType},
+		LastNamePrefix: "Last",
+	}, rq.AllFilters())
+	assert.Equal(t, []string{PatientType}, filters.EntityTypes)
+}
+
+func TestSearchFilters_AllowsType(t *testing.T) {
+	var f *SearchFilters
+	assert.True(t, f.AllowsType(PatientType))
+	assert.True(t, f.AllowsType(OfficeType))
+
+	f = &SearchFilters{EntityTypes: []string{OfficeType}}
+	assert.False(t, f.AllowsType(PatientType))
+	assert.True(t, f.AllowsType(OfficeType))
+
+	f = &SearchFilters{LastNamePrefix: "Last"}
+	assert.True(t, f.AllowsType(PatientType))
+	assert.False(t, f.AllowsType(OfficeType))
+
+	f = &SearchFilters{FirstNamePrefix: "First"}
+	assert.True(t, f.AllowsType(PatientType))
+	assert.False(t, f.AllowsType(OfficeType))
+
+	f = &SearchFilters{OfficeName: "Office Name 1"}
+	assert.False(t, f.AllowsType(PatientType))
+	assert.True(t, f.AllowsType(OfficeType))
+}
+
+func TestSearchFilters_Matches(t *testing.T) {
+	p, f := NewTestPatient(1, true), NewTestOffice(1, true)
+	cases := map[string]struct {
+		filters *SearchFilters
+		patient bool
+		office  bool
+	}{
+		"nil": {
+			filters: nil,
+			patient: true,
+			office:  true,
+		},
+		"entity type": {
+			filters: &SearchFilters{EntityTypes: []string{PatientType}},
+			patient: true,
+		},
+		"exact birthdate": {
+			filters: &SearchFilters{Birthdate: &Date{Year: 2006, Month: 1, Day: 2}},
+			patient: true,
+		},
+		"other birthdate": {
+			filters: &SearchFilters{Birthdate: &Date{Year: 2006, Month: 1, Day: 3}},
+		},
+		"birthdate range": {
+			filters: &SearchFilters{
+				BirthdateFrom: &Date{Year: 2006, Month: 1, Day: 2},
+				BirthdateTo:   &Date{Year: 2006, Month: 2, Day: 1},
+			},
+			patient: true,
+		},
+		"birthdate range after": {
+			filters: &SearchFilters{BirthdateFrom: &Date{Year: 2006, Month: 1, Day: 3}},
+		},
+		"last name prefix": {
+			filters: &SearchFilters{LastNamePrefix: "last"},
+			patient: true,
+		},
+		"other last name prefix": {
+			filters: &SearchFilters{LastNamePrefix: "Name"},
+		},
+		"first name prefix": {
+			filters: &SearchFilters{FirstNamePrefix: "first"},
+			patient: true,
+		},
+		"other first name prefix": {
+			filters: &SearchFilters{FirstNamePrefix: "Last"},
+		},
+		"suffix": {
+			filters: &SearchFilters{Suffix: "Jr"},
+		},
+		"office name": {
+			filters: &SearchFilters{OfficeName: "office name 1"},
+			office:  true,
+		},
+		"office name with punctuation": {
+			filters: &SearchFilters{OfficeName: "office-name, 1"},
+			office:  true,
+		},
+	}
+	for desc, c := range cases {
+		assert.Equal(t, c.patient, c.filters.Matches(p), desc)
+		assert.Equal(t, c.office, c.filters.Matches(f), desc)
+	}
+
+	// accents and punctuation are ignored
+	p = NewPatient("", &Patient{LastName: "Muñoz-O'Brien", FirstName: "Zoë"})
+	assert.True(t, (&SearchFilters{LastNamePrefix: "munoz obr"}).Matches(p))
+	assert.True(t, (&SearchFilters{FirstNamePrefix: "zoe"}).Matches(p))
+}
+
+func TestDate_Transposed(t *testing.T) {
+	d := &Date{Year: 1975, Month: 3, Day: 4}
+	assert.Equal(t, &Date{Year: 1975, Month: 4, Day: 3}, d.Transposed())
+	assert.Nil(t, (&Date{Year: 1975, Month: 3, Day: 14}).Transposed())
+	assert.Nil(t, (&Date{Year: 1975, Month: 3, Day: 3}).Transposed())
+}
+
 func TestDate_ISO8601(t *testing.T) {
 	cases := []struct {
 		d        *Date
@@ -0,0 +1,46 @@
+package directoryapi
+
+import (
+	"strings"
+	"unicode"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+// foldedLetters are the upper-cased letters without a decomposition into a base letter and
+// diacritics that Postgres unaccent nevertheless folds, along with what they fold to.
+var foldedLetters = strings.NewReplacer(
+	"ß", "SS",
+	"ẞ", "SS",
+	"Æ", "AE",
+	"Œ", "OE",
+	"Ø", "O",
+	"Ł", "L",
+	"Đ", "D",
+	"Ð", "D",
+	"Ħ", "H",
+	"Þ", "TH",
+)
+
+// FoldName returns the upper-cased name in NFKD normal form with its diacritics and punctuation
+// stripped, e.g., MUNOZ for Muñoz and OBRIEN for O'Brien, so names are compared regardless of
+// accents or punctuation. Dashes separate words, as spaces do, and consecutive spaces are
+// collapsed. The Postgres name search columns hold names folded by it, which the migration adding
+// them approximates with unaccent until their rows are refolded.
+func FoldName(name string) string {
+	decomposed := norm.NFKD.String(name)
+	stripped := strings.Map(func(r rune) rune {
+		switch {
+		case unicode.Is(unicode.Mn, r):
+			return -1
+		case unicode.Is(unicode.Pd, r), unicode.IsSpace(r):
+			return ' '
+		case unicode.IsLetter(r), unicode.IsDigit(r):
+			return r
+		default:
+			return -1
+		}
+	}, decomposed)
+	upper := foldedLetters.Replace(strings.ToUpper(stripped))
+	return strings.Join(strings.Fields(upper), " ")
+}
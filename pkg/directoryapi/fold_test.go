@@ -0,0 +1,34 @@
+package directoryapi
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFoldName(t *testing.T) {
+	cases := map[string]string{
+		"Muñoz":              "MUNOZ",
+		"Zoë":                "ZOE",
+		"O'Brien":            "OBRIEN",
+		"O’Brien":            "OBRIEN",
+		"Smith-Jones":        "SMITH JONES",
+		"  st.  john ":       "ST JOHN",
+		"Ångström":           "ANGSTROM",
+		"Łukasz Straße":      "LUKASZ STRASSE",
+		"Søren Kierkegaard":  "SOREN KIERKEGAARD",
+		"ﬁnn":                "FINN",
+		"Nguyễn":             "NGUYEN",
+		"Jr.":                "JR",
+		"":                   "",
+		"'-'":                "",
+		"François-René":      "FRANCOIS RENE",
+		"Dr. Æsop's Clinic":  "DR AESOPS CLINIC",
+		"Clinic #2":          "CLINIC 2",
+		"Å":                 "A",
+		"already FOLDED 123": "ALREADY FOLDED 123",
+	}
+	for name, expected := range cases {
+		assert.Equal(t, expected, FoldName(name), name)
+	}
+}
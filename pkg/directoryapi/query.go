@@ -0,0 +1,279 @@
+package directoryapi
+
+import (
+	"fmt"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/pkg/errors"
+)
+
+const (
+	searchFieldSep   = ":"
+	searchQuote      = '"'
+	birthdateLayout  = "2006-01-02"
+	typeSearchField  = "type"
+	lastSearchField  = "last"
+	firstSearchField = "first"
+)
+
+var (
+	// ErrSearchUnknownField identifies when a search query term has a field prefix that is
+	// not one of the known search fields.
+	ErrSearchUnknownField = errors.New("search term has unknown field")
+
+	// ErrSearchTermMissingValue identifies when a search query term has a field prefix but no
+	// value.
+	ErrSearchTermMissingValue = errors.New("search term missing value")
+
+	// ErrSearchRepeatedField identifies when a search query has more than one term for a field
+	// that takes a single value.
+	ErrSearchRepeatedField = errors.New("search field repeated")
+
+	// ErrSearchInvalidBirthdate identifies when a search query birthdate term is not a
+	// YYYY-MM-DD date.
+	ErrSearchInvalidBirthdate = errors.New("search birthdate not YYYY-MM-DD")
+
+	// ErrSearchUnterminatedQuote identifies when a search query has an opening quote without a
+	// closing one.
+	ErrSearchUnterminatedQuote = errors.New("search query has unterminated quote")
+
+	// ErrSearchQueryMissingText identifies when a search query has only filtering terms and so
+	// no text for the searchers to match.
+	ErrSearchQueryMissingText = errors.New("search query has no text to match")
+)
+
+// queryDateLayouts are the layouts of the dates recognized in free-text search queries, in order
+// of preference, so month-first dates are preferred to day-first ones
+var queryDateLayouts = []string{
+	birthdateLayout,
+	"1/2/2006",
+	"1-2-2006",
+	"1.2.2006",
+	"2/1/2006",
+	"2-1-2006",
+	"2.1.2006",
+}
+
+// searchFields set the filter for each known search field prefix from a term's value
+var searchFields = map[string]func(f *SearchFilters, value string) error{
+	typeSearchField: func(f *SearchFilters, value string) error {
+		entityType := strings.ToUpper(value)
+		if entityType != PatientType && entityType != OfficeType {
+			return ErrSearchUnknownEntityType
+		}
+		f.EntityTypes = append(f.EntityTypes, entityType)
+		return nil
+	},
+	"dob": func(f *SearchFilters, value string) error {
+		if f.Birthdate != nil {
+			return ErrSearchRepeatedField
+		}
+		birthdate, err := time.Parse(birthdateLayout, value)
+		if err != nil {
+			return ErrSearchInvalidBirthdate
+		}
+		f.Birthdate = newDate(birthdate)
+		return nil
+	},
+	lastSearchField:  setOnce(func(f *SearchFilters) *string { return &f.LastNamePrefix }),
+	firstSearchField: setOnce(func(f *SearchFilters) *string { return &f.FirstNamePrefix }),
+	"suffix":         setOnce(func(f *SearchFilters) *string { return &f.Suffix }),
+	"office":         setOnce(func(f *SearchFilters) *string { return &f.OfficeName }),
+}
+
+// SearchQuery is a search query parsed into the text for the searchers to match and the filters
+// given by its field-scoped terms.
+type SearchQuery struct {
+	// Text is the free text of the query's unprefixed terms or, if it has none, the text of its
+	// name terms.
+	Text string
+
+	// Filters are the filters set by the query's field-scoped terms, which is nil if it has none.
+	Filters *SearchFilters
+}
+
+// ParseSearchQuery parses a query like `last:smith first:jo dob:1980-02-03 type:patient` into
+// its text and filters. Values with spaces may be double-quoted, like `office:"Main St Clinic"`.
+// A query without any field-scoped terms is parsed into just its text, as for a plain query.
+func ParseSearchQuery(query string) (*SearchQuery, error) {
+	terms, err := splitSearchTerms(query)
+	if err != nil {
+		return nil, err
+	}
+	var filters *SearchFilters
+	var freeTerms []string
+	for _, term := range terms {
+		i := strings.Index(term, searchFieldSep)
+		if i <= 0 || strings.HasPrefix(term, string(searchQuote)) {
+			freeTerms = append(freeTerms, strings.Trim(term, string(searchQuote)))
+			continue
+		}
+		field := strings.ToLower(term[:i])
+		setFilter, in := searchFields[field]
+		if !in {
+			return nil, errors.Wrap(ErrSearchUnknownField, fmt.Sprintf("term %q", term))
+		}
+		value := strings.Trim(term[i+1:], string(searchQuote))
+		if value == "" {
+			return nil, errors.Wrap(ErrSearchTermMissingValue, fmt.Sprintf("term %q", term))
+		}
+		if filters == nil {
+			filters = &SearchFilters{}
+		}
+		if err := setFilter(filters, value); err != nil {
+			return nil, errors.Wrap(err, fmt.Sprintf("term %q", term))
+		}
+	}
+	text := searchText(freeTerms, filters)
+	if text == "" && filters != nil {
+		return nil, ErrSearchQueryMissingText
+	}
+	return &SearchQuery{Text: text, Filters: filters}, nil
+}
+
+// MergeFilters returns the given (possibly nil) filters with the fields set by the query's
+// field-scoped terms overriding theirs.
+func (q *SearchQuery) MergeFilters(filters *SearchFilters) *SearchFilters {
+	if q.Filters == nil {
+		return filters
+	}
+	if filters == nil {
+		return q.Filters
+	}
+	merged := proto.Clone(filters).(*SearchFilters)
+	if len(q.Filters.EntityTypes) > 0 {
+		merged.EntityTypes = q.Filters.EntityTypes
+	}
+	if q.Filters.Birthdate != nil {
+		merged.Birthdate = q.Filters.Birthdate
+	}
+	for _, field := range []struct{ dst, src *string }{
+		{&merged.LastNamePrefix, &q.Filters.LastNamePrefix},
+		{&merged.FirstNamePrefix, &q.Filters.FirstNamePrefix},
+		{&merged.Suffix, &q.Filters.Suffix},
+		{&merged.OfficeName, &q.Filters.OfficeName},
+	} {
+		if *field.src != "" {
+			*field.dst = *field.src
+		}
+	}
+	return merged
+}
+
+// SplitQueryBirthdate splits the first date in the free-text query, like the date in
+// "garcia 03/14/1975", from the rest of it, returning the remaining text and the date. The query
+// is returned unchanged with a nil date if it has none. Dates may be YYYY-MM-DD or month-first
+// M/D/YYYY (or with - or . separators), falling back to day-first if not a valid month-first date.
+func SplitQueryBirthdate(query string) (string, *Date) {
+	terms := strings.Fields(query)
+	for i, term := range terms {
+		if d := parseQueryDate(term); d != nil {
+			rest := append(terms[:i:i], terms[i+1:]...)
+			return strings.Join(rest, " "), d
+		}
+	}
+	return query, nil
+}
+
+func parseQueryDate(term string) *Date {
+	for _, layout := range queryDateLayouts {
+		if t, err := time.Parse(layout, term); err == nil {
+			return newDate(t)
+		}
+	}
+	return nil
+}
+
+func newDate(t time.Time) *Date {
+	return &Date{Year: uint32(t.Year()), Month: uint32(t.Month()), Day: uint32(t.Day())}
+}
+
+// PatientNameQuery returns the query for the patient name searchers, which is the query preceded
+// by the last and first name prefixes of the (possibly nil) filters, so the names in `last:` and
+// `first:` terms are searched for as well as filtered on. The query is returned unchanged if it is
+// just those names, as when parsed from a query without other text.
+func PatientNameQuery(query string, filters *SearchFilters) string {
+	names := filterNames(filters)
+	if names == "" || query == names {
+		return query
+	}
+	return strings.TrimSpace(names + " " + query)
+}
+
+// searchText returns the text for the searchers to match, which is the free text if there is
+// any, or else the patient name or office name from the filters
+func searchText(freeTerms []string, filters *SearchFilters) string {
+	if len(freeTerms) > 0 || filters == nil {
+		return strings.Join(freeTerms, " ")
+	}
+	if names := filterNames(filters); names != "" {
+		return names
+	}
+	return filters.OfficeName
+}
+
+// filterNames returns the last and then first name prefixes of the (possibly nil) filters, like the
+// patient name searchers search last then first name
+func filterNames(filters *SearchFilters) string {
+	if filters == nil {
+		return ""
+	}
+	return strings.TrimSpace(filters.LastNamePrefix + " " + filters.FirstNamePrefix)
+}
+
+// fieldValuesLen returns the total length of the values of the (possibly nil) filters set by
+// field-scoped terms other than the names and entity types
+func fieldValuesLen(filters *SearchFilters) int {
+	if filters == nil {
+		return 0
+	}
+	n := len(filters.Suffix) + len(filters.OfficeName)
+	if filters.Birthdate != nil {
+		n += len(birthdateLayout)
+	}
+	return n
+}
+
+// splitSearchTerms splits the query into its whitespace-separated terms, keeping the whitespace
+// within double quotes
+func splitSearchTerms(query string) ([]string, error) {
+	var terms []string
+	var term strings.Builder
+	inQuote := false
+	for _, r := range query {
+		switch {
+		case r == searchQuote:
+			inQuote = !inQuote
+			term.WriteRune(r)
+		case unicode.IsSpace(r) && !inQuote:
+			if term.Len() > 0 {
+				terms = append(terms, term.String())
+				term.Reset()
+			}
+		default:
+			term.WriteRune(r)
+		}
+	}
+	if inQuote {
+		return nil, ErrSearchUnterminatedQuote
+	}
+	if term.Len() > 0 {
+		terms = append(terms, term.String())
+	}
+	return terms, nil
+}
+
+// setOnce returns a search field filter setter for the string filter field, which may only be set
+// once
+func setOnce(field func(f *SearchFilters) *string) func(f *SearchFilters, value string) error {
+	return func(f *SearchFilters, value string) error {
+		if *field(f) != "" {
+			return ErrSearchRepeatedField
+		}
+		*field(f) = value
+		return nil
+	}
+}
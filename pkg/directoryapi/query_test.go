@@ -0,0 +1,192 @@
+package directoryapi
+
+import (
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseSearchQuery_ok(t *testing.T) {
+	cases := map[string]struct {
+		query    string
+		expected *SearchQuery
+	}{
+		"free text": {
+			query:    "john smith",
+			expected: &SearchQuery{Text: "john smith"},
+		},
+		"extra whitespace": {
+			query:    "  john \t smith ",
+			expected: &SearchQuery{Text: "john smith"},
+		},
+		"name terms": {
+			query: "last:smith first:jo dob:1980-02-03 type:patient",
+			expected: &SearchQuery{
+				Text: "smith jo",
+				Filters: &SearchFilters{
+					EntityTypes:     []string{PatientType},
+					Birthdate:       &Date{Year: 1980, Month: 2, Day: 3},
+					LastNamePrefix:  "smith",
+					FirstNamePrefix: "jo",
+				},
+			},
+		},
+		"free text and terms": {
+			query: "Smith SUFFIX:jr",
+			expected: &SearchQuery{
+				Text:    "Smith",
+				Filters: &SearchFilters{Suffix: "jr"},
+			},
+		},
+		"quoted office": {
+			query: `office:"Main St Clinic" type:office type:patient`,
+			expected: &SearchQuery{
+				Text: "Main St Clinic",
+				Filters: &SearchFilters{
+					EntityTypes: []string{OfficeType, PatientType},
+					OfficeName:  "Main St Clinic",
+				},
+			},
+		},
+		"quoted free text": {
+			query:    `"http://example.com" clinic`,
+			expected: &SearchQuery{Text: "http://example.com clinic"},
+		},
+		"leading colon": {
+			query:    ":smith",
+			expected: &SearchQuery{Text: ":smith"},
+		},
+	}
+	for desc, c := range cases {
+		q, err := ParseSearchQuery(c.query)
+		assert.Nil(t, err, desc)
+		assert.Equal(t, c.expected, q, desc)
+	}
+}
+
+func TestParseSearchQuery_err(t *testing.T) {
+	cases := map[string]struct {
+		query    string
+		expected error
+	}{
+		"unknown field": {
+			query:    "smith city:boston",
+			expected: ErrSearchUnknownField,
+		},
+		"missing value": {
+			query:    "smith last:",
+			expected: ErrSearchTermMissingValue,
+		},
+		"repeated field": {
+			query:    "last:smith last:jones",
+			expected: ErrSearchRepeatedField,
+		},
+		"repeated birthdate": {
+			query:    "smith dob:1980-02-03 dob:1980-02-04",
+			expected: ErrSearchRepeatedField,
+		},
+		"unknown entity type": {
+			query:    "smith type:clinic",
+			expected: ErrSearchUnknownEntityType,
+		},
+		"invalid birthdate": {
+			query:    "smith dob:02/03/1980",
+			expected: ErrSearchInvalidBirthdate,
+		},
+		"unterminated quote": {
+			query:    `office:"Main St Clinic`,
+			expected: ErrSearchUnterminatedQuote,
+		},
+		"missing text": {
+			query:    "dob:1980-02-03 type:patient",
+			expected: ErrSearchQueryMissingText,
+		},
+	}
+	for desc, c := range cases {
+		q, err := ParseSearchQuery(c.query)
+		assert.Equal(t, c.expected, errors.Cause(err), desc)
+		assert.Nil(t, q, desc)
+	}
+}
+
+func TestSearchQuery_MergeFilters(t *testing.T) {
+	q := &SearchQuery{Text: "smith"}
+	base := &SearchFilters{LastNamePrefix: "jones"}
+	assert.Nil(t, q.MergeFilters(nil))
+	assert.Equal(t, base, q.MergeFilters(base))
+
+	q.Filters = &SearchFilters{
+		EntityTypes:    []string{PatientType},
+		LastNamePrefix: "smith",
+	}
+	assert.Equal(t, q.Filters, q.MergeFilters(nil))
+
+	base = &SearchFilters{
+		EntityTypes:    []string{OfficeType},
+		LastNamePrefix: "jones",
+		Suffix:         "jr",
+	}
+	expected := &SearchFilters{
+		EntityTypes:    []string{PatientType},
+		LastNamePrefix: "smith",
+		Suffix:         "jr",
+	}
+	assert.Equal(t, expected, q.MergeFilters(base))
+	assert.Equal(t, "jones", base.LastNamePrefix)
+}
+
+func TestPatientNameQuery(t *testing.T) {
+	assert.Equal(t, "jo", PatientNameQuery("jo", nil))
+	assert.Equal(t, "jo", PatientNameQuery("jo", &SearchFilters{Suffix: "Jr"}))
+	assert.Equal(t, "smith jo", PatientNameQuery("jo", &SearchFilters{LastNamePrefix: "smith"}))
+	assert.Equal(t, "smith ann 1980-02-03", PatientNameQuery("1980-02-03",
+		&SearchFilters{LastNamePrefix: "smith", FirstNamePrefix: "ann"}))
+
+	// text parsed from just the names isn't repeated
+	q, err := ParseSearchQuery("last:smith first:ann")
+	assert.Nil(t, err)
+	assert.Equal(t, "smith ann", PatientNameQuery(q.Text, q.Filters))
+}
+
+func TestSplitQueryBirthdate(t *testing.T) {
+	cases := map[string]struct {
+		query     string
+		text      string
+		birthdate *Date
+	}{
+		"no date": {
+			query: "garcia  maria",
+			text:  "garcia  maria",
+		},
+		"month first": {
+			query:     "garcia 03/14/1975",
+			text:      "garcia",
+			birthdate: &Date{Year: 1975, Month: 3, Day: 14},
+		},
+		"day first": {
+			query:     "14.3.1975 garcia maria",
+			text:      "garcia maria",
+			birthdate: &Date{Year: 1975, Month: 3, Day: 14},
+		},
+		"ISO 8601": {
+			query:     "garcia 1975-03-14 maria",
+			text:      "garcia maria",
+			birthdate: &Date{Year: 1975, Month: 3, Day: 14},
+		},
+		"first date only": {
+			query:     "3-4-1975 3-5-1975",
+			text:      "3-5-1975",
+			birthdate: &Date{Year: 1975, Month: 3, Day: 4},
+		},
+		"invalid date": {
+			query: "garcia 02/30/1975",
+			text:  "garcia 02/30/1975",
+		},
+	}
+	for desc, c := range cases {
+		text, birthdate := SplitQueryBirthdate(c.query)
+		assert.Equal(t, c.text, text, desc)
+		assert.Equal(t, c.birthdate, birthdate, desc)
+	}
+}
@@ -7,11 +7,32 @@ import (
 	"go.uber.org/zap/zapcore"
 )
 
+const (
+	// DefaultFailPartialSearch is the default setting for whether to fail searches when some
+	// searchers time out rather than returning partial results.
+	DefaultFailPartialSearch = false
+
+	// DefaultAutocompleteRateLimit is the default maximum sustained rate of Autocomplete requests
+	// per second.
+	DefaultAutocompleteRateLimit = 100
+
+	// DefaultAutocompleteBurst is the default maximum number of Autocomplete requests allowed at
+	// once above the sustained rate.
+	DefaultAutocompleteBurst = 20
+)
+
 // Config is the config for a Directory instance.
 type Config struct {
 	*server.BaseConfig
-	Storage *storage.Parameters
-	DBUrl   string
+	Storage           *storage.Parameters
+	DBUrl             string
+	FailPartialSearch bool
+
+	// AutocompleteRateLimit is the maximum sustained rate of Autocomplete requests per second,
+	// with zero not limiting them, and AutocompleteBurst is the maximum number of requests
+	// allowed at once above it.
+	AutocompleteRateLimit float64
+	AutocompleteBurst     uint
 }
 
 // NewDefaultConfig create a new config instance with default values.
@@ -20,7 +41,9 @@ func NewDefaultConfig() *Config {
 		BaseConfig: server.NewDefaultBaseConfig(),
 	}
 	return config.
-		WithDefaultStorage()
+		WithDefaultStorage().
+		WithFailPartialSearch(DefaultFailPartialSearch).
+		WithAutocompleteRateLimit(DefaultAutocompleteRateLimit, DefaultAutocompleteBurst)
 }
 
 // MarshalLogObject writes the config to the given object encoder.
@@ -30,6 +53,9 @@ func (c *Config) MarshalLogObject(oe zapcore.ObjectEncoder) error {
 	err = oe.AddObject(logStorage, c.Storage)
 	errors.MaybePanic(err) // should never happen
 	oe.AddString(logDBUrl, c.DBUrl)
+	oe.AddBool(logFailPartialSearch, c.FailPartialSearch)
+	oe.AddFloat64(logAcpRateLimit, c.AutocompleteRateLimit)
+	oe.AddUint(logAcpBurst, c.AutocompleteBurst)
 	return nil
 }
 
@@ -53,3 +79,18 @@ func (c *Config) WithDBUrl(dbURL string) *Config {
 	c.DBUrl = dbURL
 	return c
 }
+
+// WithFailPartialSearch sets whether to fail searches when some searchers time out rather than
+// returning partial results.
+func (c *Config) WithFailPartialSearch(fail bool) *Config {
+	c.FailPartialSearch = fail
+	return c
+}
+
+// WithAutocompleteRateLimit sets the maximum sustained rate of Autocomplete requests per second,
+// with zero not limiting them, and the maximum number of requests allowed at once above it.
+func (c *Config) WithAutocompleteRateLimit(rate float64, burst uint) *Config {
+	c.AutocompleteRateLimit = rate
+	c.AutocompleteBurst = burst
+	return c
+}
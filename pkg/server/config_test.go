@@ -12,6 +12,9 @@ func TestNewDefaultConfig(t *testing.T) {
 	c := NewDefaultConfig()
 	assert.NotNil(t, c)
 	assert.NotEmpty(t, c.Storage)
+	assert.Equal(t, DefaultFailPartialSearch, c.FailPartialSearch)
+	assert.Equal(t, float64(DefaultAutocompleteRateLimit), c.AutocompleteRateLimit)
+	assert.Equal(t, uint(DefaultAutocompleteBurst), c.AutocompleteBurst)
 }
 
 func TestConfig_WithStorage(t *testing.T) {
@@ -32,3 +35,16 @@ func TestConfig_WithDBUrl(t *testing.T) {
 	c1.WithDBUrl(dbURL)
 	assert.Equal(t, dbURL, c1.DBUrl)
 }
+
+func TestConfig_WithFailPartialSearch(t *testing.T) {
+	c1 := &Config{}
+	c1.WithFailPartialSearch(true)
+	assert.True(t, c1.FailPartialSearch)
+}
+
+func TestConfig_WithAutocompleteRateLimit(t *testing.T) {
+	c1 := &Config{}
+	c1.WithAutocompleteRateLimit(2.5, 4)
+	assert.Equal(t, 2.5, c1.AutocompleteRateLimit)
+	assert.Equal(t, uint(4), c1.AutocompleteBurst)
+}
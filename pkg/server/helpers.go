@@ -2,18 +2,30 @@ package server
 
 import (
 	"errors"
+	"time"
 
+	api "github.com/elixirhealth/directory/pkg/directoryapi"
 	"github.com/elixirhealth/directory/pkg/server/storage"
 	"github.com/elixirhealth/directory/pkg/server/storage/id"
 	memstorage "github.com/elixirhealth/directory/pkg/server/storage/memory"
 	pgstorage "github.com/elixirhealth/directory/pkg/server/storage/postgres"
 	bstorage "github.com/elixirhealth/service-base/pkg/server/storage"
+	"github.com/golang/protobuf/ptypes"
+	"github.com/golang/protobuf/ptypes/timestamp"
 	"go.uber.org/zap"
 )
 
 var (
 	// ErrInvalidStorageType indicates when a storage type is not expected.
 	ErrInvalidStorageType = errors.New("invalid storage type")
+
+	// ErrPartialSearch indicates when some searchers timed out and the config requires failing
+	// the search rather than returning partial results.
+	ErrPartialSearch = errors.New("search timed out before all searchers finished")
+
+	// ErrAutocompleteRateLimited indicates when an Autocomplete request exceeds the configured
+	// rate limit.
+	ErrAutocompleteRateLimited = errors.New("autocomplete rate limit exceeded")
 )
 
 func getStorer(config *Config, logger *zap.Logger) (storage.Storer, error) {
@@ -27,3 +39,29 @@ func getStorer(config *Config, logger *zap.Logger) (storage.Storer, error) {
 		return nil, ErrInvalidStorageType
 	}
 }
+
+// getAsOfTime converts an optional as-of timestamp into a time, where the zero time denotes the
+// timestamp's absence
+func getAsOfTime(asOf *timestamp.Timestamp) (time.Time, error) {
+	if asOf == nil {
+		return time.Time{}, nil
+	}
+	return ptypes.Timestamp(asOf)
+}
+
+// resultEntities returns the entities of the search results, in the same order
+func resultEntities(results []*api.SearchResult) []*api.Entity {
+	es := make([]*api.Entity, len(results))
+	for i, r := range results {
+		es[i] = r.Entity
+	}
+	return es
+}
+
+// errorString returns the error's message, or an empty string if the error is nil
+func errorString(err error) string {
+	if err == nil {
+		return ""
+	}
+	return err.Error()
+}
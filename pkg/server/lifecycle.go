@@ -3,9 +3,11 @@ package server
 import (
 	"github.com/drausin/libri/libri/common/errors"
 	api "github.com/elixirhealth/directory/pkg/directoryapi"
+	"github.com/elixirhealth/directory/pkg/server/storage/postgres"
 	"github.com/elixirhealth/directory/pkg/server/storage/postgres/migrations"
 	bstorage "github.com/elixirhealth/service-base/pkg/server/storage"
 	"github.com/mattes/migrate/source/go-bindata"
+	"go.uber.org/zap"
 	"google.golang.org/grpc"
 )
 
@@ -41,5 +43,14 @@ func (d *Directory) maybeMigrateDB() error {
 		bindata.Resource(migrations.AssetNames(), migrations.Asset),
 		&bstorage.ZapLogger{Logger: d.Logger},
 	)
-	return m.Up()
+	if err := m.Up(); err != nil {
+		return err
+	}
+	go func() {
+		// refolding doesn't block serving, since the migration already folded the names
+		if err := postgres.RefoldNameSearchCols(d.config.DBUrl, d.Logger); err != nil {
+			d.Logger.Error("failed to refold name search columns", zap.Error(err))
+		}
+	}()
+	return nil
 }
@@ -2,19 +2,54 @@ package server
 
 import (
 	api "github.com/elixirhealth/directory/pkg/directoryapi"
+	"github.com/elixirhealth/directory/pkg/server/storage"
+	"github.com/golang/protobuf/ptypes"
+	"github.com/golang/protobuf/ptypes/timestamp"
 	"go.uber.org/zap"
 	"go.uber.org/zap/zapcore"
 )
 
 const (
-	logEntityID   = "entity_id"
-	logNewEntity  = "new_entity"
-	logEntityType = "entity_type"
-	logStorage    = "storage"
-	logDBUrl      = "db_url"
-	logQuery      = "query"
-	logLimit      = "limit"
-	logNFound     = "n_found"
+	logEntityID          = "entity_id"
+	logNewEntity         = "new_entity"
+	logEntityType        = "entity_type"
+	logStorage           = "storage"
+	logDBUrl             = "db_url"
+	logQuery             = "query"
+	logLimit             = "limit"
+	logNFound            = "n_found"
+	logNVersions         = "n_versions"
+	logAsOf              = "as_of"
+	logNEntities         = "n_entities"
+	logNErrors           = "n_errors"
+	logPageSize          = "page_size"
+	logPageToken         = "page_token"
+	logNPages            = "n_pages"
+	logHasNextPage       = "has_next_page"
+	logExplain           = "explain"
+	logFilters           = "filters"
+	logPartial           = "partial"
+	logTimedOutSearchers = "timed_out_searchers"
+	logFailPartialSearch = "fail_partial_search"
+	logPaths             = "paths"
+	logVersion           = "version"
+	logAllowCreateWithID = "allow_create_with_id"
+	logMergedIntoID      = "merged_into_id"
+	logSurvivorID        = "survivor_id"
+	logMergedID          = "merged_id"
+	logToVersion         = "to_version"
+	logRevertedBy        = "reverted_by"
+	logReason            = "reason"
+	logThreshold         = "threshold"
+	logNPairs            = "n_pairs"
+	logNCandidates       = "n_candidates"
+	logTopWeight         = "top_weight"
+	logTopClassification = "top_classification"
+	logPrefix            = "prefix"
+	logEntityTypes       = "entity_types"
+	logNSuggestions      = "n_suggestions"
+	logAcpRateLimit      = "autocomplete_rate_limit"
+	logAcpBurst          = "autocomplete_burst"
 )
 
 func logPutEntityRq(rq *api.PutEntityRequest) []zapcore.Field {
@@ -25,6 +60,14 @@ func logPutEntityRq(rq *api.PutEntityRequest) []zapcore.Field {
 		zap.String(logEntityID, rq.Entity.EntityId),
 		zap.Bool(logNewEntity, rq.Entity.EntityId == ""),
 		zap.String(logEntityType, rq.Entity.Type()),
+		zap.Bool(logAllowCreateWithID, rq.AllowCreateWithId),
+	}
+}
+
+func logGetEntityRq(rq *api.GetEntityRequest) []zapcore.Field {
+	return []zapcore.Field{
+		zap.String(logEntityID, rq.EntityId),
+		logAsOfField(rq.AsOf),
 	}
 }
 
@@ -32,12 +75,25 @@ func logSearchEntityRq(rq *api.SearchEntityRequest) []zapcore.Field {
 	return []zapcore.Field{
 		zap.String(logQuery, rq.Query),
 		zap.Uint32(logLimit, rq.Limit),
+		logAsOfField(rq.AsOf),
+		zap.String(logPageToken, rq.PageToken),
+		zap.Bool(logExplain, rq.Explain),
+		logFiltersField(rq.Filters),
+		zap.Strings(logEntityTypes, rq.EntityTypes),
+	}
+}
+
+func logFiltersField(filters *api.SearchFilters) zapcore.Field {
+	if filters == nil {
+		return zap.Skip()
 	}
+	return zap.Stringer(logFilters, filters)
 }
 
 func logPutEntityRp(rq *api.PutEntityRequest, rp *api.PutEntityResponse, new bool) []zapcore.Field {
 	return []zapcore.Field{
 		zap.String(logEntityID, rp.EntityId),
+		zap.Uint64(logVersion, rp.Version),
 		zap.Bool(logNewEntity, new),
 		zap.String(logEntityType, rq.Entity.Type()),
 	}
@@ -47,6 +103,7 @@ func logGetEntityRp(rp *api.GetEntityResponse) []zapcore.Field {
 	return []zapcore.Field{
 		zap.String(logEntityID, rp.Entity.EntityId),
 		zap.String(logEntityType, rp.Entity.Type()),
+		zap.String(logMergedIntoID, rp.MergedIntoId),
 	}
 }
 
@@ -54,6 +111,149 @@ func logSearchEntityRp(rq *api.SearchEntityRequest, rp *api.SearchEntityResponse
 	return []zapcore.Field{
 		zap.String(logQuery, rq.Query),
 		zap.Uint32(logLimit, rq.Limit),
-		zap.Int(logNFound, len(rp.Entities)),
+		zap.Int(logNFound, len(rp.Results)),
+		zap.Bool(logHasNextPage, rp.NextPageToken != ""),
+		zap.Bool(logPartial, rp.Partial),
+	}
+}
+
+func logPartialSearch(rq *api.SearchEntityRequest, page *storage.SearchPage) []zapcore.Field {
+	return []zapcore.Field{
+		zap.String(logQuery, rq.Query),
+		zap.Strings(logTimedOutSearchers, page.TimedOutSearchers),
+	}
+}
+
+func logGetEntityHistoryRp(
+	rq *api.GetEntityHistoryRequest, rp *api.GetEntityHistoryResponse,
+) []zapcore.Field {
+	return []zapcore.Field{
+		zap.String(logEntityID, rq.EntityId),
+		zap.Int(logNVersions, len(rp.Versions)),
+	}
+}
+
+func logPutEntitiesRp(rp *api.PutEntitiesResponse) []zapcore.Field {
+	nErrors := 0
+	for _, r := range rp.Results {
+		if r.Error != "" {
+			nErrors++
+		}
+	}
+	return []zapcore.Field{
+		zap.Int(logNEntities, len(rp.Results)),
+		zap.Int(logNErrors, nErrors),
+	}
+}
+
+func logGetEntitiesRp(rp *api.GetEntitiesResponse) []zapcore.Field {
+	nErrors := 0
+	for _, r := range rp.Results {
+		if r.Error != "" {
+			nErrors++
+		}
+	}
+	return []zapcore.Field{
+		zap.Int(logNEntities, len(rp.Results)),
+		zap.Int(logNErrors, nErrors),
+	}
+}
+
+func logListEntitiesRq(rq *api.ListEntitiesRequest) []zapcore.Field {
+	return []zapcore.Field{
+		zap.String(logEntityType, rq.EntityType),
+		zap.Uint32(logPageSize, rq.PageSize),
+		zap.String(logPageToken, rq.PageToken),
+	}
+}
+
+func logListEntitiesRp(rq *api.ListEntitiesRequest, nPages, nEntities int) []zapcore.Field {
+	return []zapcore.Field{
+		zap.String(logEntityType, rq.EntityType),
+		zap.Int(logNPages, nPages),
+		zap.Int(logNEntities, nEntities),
+	}
+}
+
+func logAsOfField(asOf *timestamp.Timestamp) zapcore.Field {
+	if asOf == nil {
+		return zap.Skip()
+	}
+	return zap.String(logAsOf, ptypes.TimestampString(asOf))
+}
+
+func logPatchEntityRq(rq *api.PatchEntityRequest) []zapcore.Field {
+	if rq.Entity == nil || rq.UpdateMask == nil {
+		return []zapcore.Field{}
+	}
+	return []zapcore.Field{
+		zap.String(logEntityID, rq.Entity.EntityId),
+		zap.Strings(logPaths, rq.UpdateMask.Paths),
+	}
+}
+
+func logRevertEntityRq(rq *api.RevertEntityRequest) []zapcore.Field {
+	return []zapcore.Field{
+		zap.String(logEntityID, rq.EntityId),
+		zap.Uint64(logToVersion, rq.ToVersion),
+		zap.String(logRevertedBy, rq.RevertedBy),
+		zap.String(logReason, rq.Reason),
+	}
+}
+
+func logRevertEntityRp(rq *api.RevertEntityRequest, rp *api.RevertEntityResponse) []zapcore.Field {
+	return append(logRevertEntityRq(rq), zap.Uint64(logVersion, rp.Entity.Version))
+}
+
+func logMergeEntitiesRq(rq *api.MergeEntitiesRequest) []zapcore.Field {
+	return []zapcore.Field{
+		zap.String(logSurvivorID, rq.SurvivorId),
+		zap.String(logMergedID, rq.MergedId),
+	}
+}
+
+func logFindDuplicatesRq(rq *api.FindDuplicatesRequest) []zapcore.Field {
+	return []zapcore.Field{
+		zap.Float32(logThreshold, rq.Threshold),
+		zap.Uint32(logLimit, rq.Limit),
+	}
+}
+
+func logFindDuplicatesRp(
+	rq *api.FindDuplicatesRequest, rp *api.FindDuplicatesResponse,
+) []zapcore.Field {
+	return append(logFindDuplicatesRq(rq), zap.Int(logNPairs, len(rp.Pairs)))
+}
+
+func logMatchPatientRq(rq *api.MatchPatientRequest) []zapcore.Field {
+	return []zapcore.Field{
+		zap.Uint32(logLimit, rq.Limit),
 	}
 }
+
+func logMatchPatientRp(
+	rq *api.MatchPatientRequest, rp *api.MatchPatientResponse,
+) []zapcore.Field {
+	fields := append(logMatchPatientRq(rq), zap.Int(logNCandidates, len(rp.Candidates)))
+	if len(rp.Candidates) > 0 {
+		fields = append(fields,
+			zap.Float64(logTopWeight, rp.Candidates[0].Weight),
+			zap.String(logTopClassification, rp.Candidates[0].Classification),
+		)
+	}
+	return fields
+}
+
+func logAutocompleteRq(rq *api.AutocompleteRequest) []zapcore.Field {
+	return []zapcore.Field{
+		zap.String(logPrefix, rq.Prefix),
+		zap.Uint32(logLimit, rq.Limit),
+		zap.Strings(logEntityTypes, rq.EntityTypes),
+	}
+}
+
+func logAutocompleteRp(
+	rq *api.AutocompleteRequest, rp *api.AutocompleteResponse,
+) []zapcore.Field {
+	return append(logAutocompleteRq(rq), zap.Int(logNSuggestions, len(rp.Suggestions)))
+}
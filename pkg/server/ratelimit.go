@@ -0,0 +1,55 @@
+package server
+
+import (
+	"sync"
+	"time"
+)
+
+// rateLimiter is a token bucket that allows bursts of up to burst requests and otherwise up to
+// rate requests per second. A nil *rateLimiter allows every request.
+type rateLimiter struct {
+	rate   float64
+	burst  float64
+	tokens float64
+	last   time.Time
+	now    func() time.Time
+	mu     sync.Mutex
+}
+
+// newRateLimiter returns a new *rateLimiter with a full bucket, or nil if the rate is not
+// positive, so requests are not limited.
+func newRateLimiter(rate float64, burst uint) *rateLimiter {
+	if rate <= 0 {
+		return nil
+	}
+	if burst == 0 {
+		burst = 1
+	}
+	return &rateLimiter{
+		rate:   rate,
+		burst:  float64(burst),
+		tokens: float64(burst),
+		last:   time.Now(),
+		now:    time.Now,
+	}
+}
+
+// allow returns whether a request may proceed now, taking a token from the bucket if so.
+func (rl *rateLimiter) allow() bool {
+	if rl == nil {
+		return true
+	}
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+	now := rl.now()
+	rl.tokens += now.Sub(rl.last).Seconds() * rl.rate
+	if rl.tokens > rl.burst {
+		rl.tokens = rl.burst
+	}
+	rl.last = now
+	if rl.tokens < 1 {
+		return false
+	}
+	rl.tokens--
+	return true
+}
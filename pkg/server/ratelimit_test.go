@@ -0,0 +1,43 @@
+package server
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRateLimiter_allow(t *testing.T) {
+	now := time.Date(2006, 1, 2, 15, 4, 5, 0, time.UTC)
+	rl := newRateLimiter(2, 3)
+	rl.last, rl.now = now, func() time.Time { return now }
+
+	// burst drains the bucket
+	for i := 0; i < 3; i++ {
+		assert.True(t, rl.allow(), i)
+	}
+	assert.False(t, rl.allow())
+
+	// refills at the rate
+	now = now.Add(500 * time.Millisecond)
+	assert.True(t, rl.allow())
+	assert.False(t, rl.allow())
+
+	// refills no more than the burst
+	now = now.Add(time.Minute)
+	for i := 0; i < 3; i++ {
+		assert.True(t, rl.allow(), i)
+	}
+	assert.False(t, rl.allow())
+}
+
+func TestNewRateLimiter(t *testing.T) {
+	assert.Nil(t, newRateLimiter(0, 10))
+	var rl *rateLimiter
+	assert.True(t, rl.allow())
+
+	rl = newRateLimiter(1, 0)
+	assert.Equal(t, float64(1), rl.burst)
+	assert.True(t, rl.allow())
+	assert.False(t, rl.allow())
+}
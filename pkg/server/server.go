@@ -13,6 +13,8 @@ type Directory struct {
 	*server.BaseServer
 	config *Config
 	storer storage.Storer
+
+	autocompleteLimiter *rateLimiter
 }
 
 // newDirectory creates a new DirectoryServer from the given config.
@@ -26,6 +28,8 @@ func newDirectory(config *Config) (*Directory, error) {
 		BaseServer: baseServer,
 		config:     config,
 		storer:     storer,
+		autocompleteLimiter: newRateLimiter(config.AutocompleteRateLimit,
+			config.AutocompleteBurst),
 	}, nil
 }
 
@@ -38,11 +42,11 @@ func (d *Directory) PutEntity(
 		return nil, err
 	}
 	newEntity := rq.Entity.EntityId == ""
-	entityID, err := d.storer.PutEntity(rq.Entity)
+	entityID, err := d.storer.PutEntity(rq.Entity, rq.AllowCreateWithId)
 	if err != nil {
 		return nil, err
 	}
-	rp := &api.PutEntityResponse{EntityId: entityID}
+	rp := &api.PutEntityResponse{EntityId: entityID, Version: rq.Entity.Version}
 	d.Logger.Info("put entity", logPutEntityRp(rq, rp, newEntity)...)
 	return rp, nil
 }
@@ -51,15 +55,22 @@ func (d *Directory) PutEntity(
 func (d *Directory) GetEntity(
 	ctx context.Context, rq *api.GetEntityRequest,
 ) (*api.GetEntityResponse, error) {
-	d.Logger.Debug("received GetEntity request", zap.String(logEntityID, rq.EntityId))
+	d.Logger.Debug("received GetEntity request", logGetEntityRq(rq)...)
 	if err := api.ValidateGetEntityRequest(rq); err != nil {
 		return nil, err
 	}
-	e, err := d.storer.GetEntity(rq.EntityId)
+	asOf, err := getAsOfTime(rq.AsOf)
+	if err != nil {
+		return nil, err
+	}
+	e, err := d.storer.GetEntity(rq.EntityId, asOf)
 	if err != nil {
 		return nil, err
 	}
 	rp := &api.GetEntityResponse{Entity: e}
+	if e.EntityId != rq.EntityId {
+		rp.MergedIntoId = e.EntityId
+	}
 	d.Logger.Info("got entity", logGetEntityRp(rp)...)
 	return rp, nil
 }
@@ -72,12 +83,31 @@ func (d *Directory) SearchEntity(
 	if err := api.ValidateSearchEntityRequest(rq); err != nil {
 		return nil, err
 	}
-	es, err := d.storer.SearchEntity(rq.Query, uint(rq.Limit))
+	asOf, err := getAsOfTime(rq.AsOf)
+	if err != nil {
+		return nil, err
+	}
+	q, err := api.ParseSearchQuery(rq.Query)
+	if err != nil {
+		return nil, err
+	}
+	page, err := d.storer.SearchEntity(q.Text, q.MergeFilters(rq.AllFilters()), uint(rq.Limit), asOf,
+		rq.PageToken, rq.Explain)
 	if err != nil {
 		return nil, err
 	}
-	rp := &api.SearchEntityResponse{Entities: es}
-	if len(rp.Entities) == 0 {
+	if page.Partial() && d.config.FailPartialSearch {
+		d.Logger.Error("failing partial search", logPartialSearch(rq, page)...)
+		return nil, ErrPartialSearch
+	}
+	rp := &api.SearchEntityResponse{
+		Entities:          resultEntities(page.Results),
+		Results:           page.Results,
+		NextPageToken:     page.NextPageToken,
+		TimedOutSearchers: page.TimedOutSearchers,
+		Partial:           page.Partial(),
+	}
+	if len(rp.Results) == 0 {
 		d.Logger.Info("found no entities", logSearchEntityRp(rq, rp)...)
 	} else {
 		d.Logger.Info("found entities", logSearchEntityRp(rq, rp)...)
@@ -85,3 +115,242 @@ func (d *Directory) SearchEntity(
 	return rp, nil
 
 }
+
+// DeleteEntity marks an existing entity as deleted.
+func (d *Directory) DeleteEntity(
+	ctx context.Context, rq *api.DeleteEntityRequest,
+) (*api.DeleteEntityResponse, error) {
+	d.Logger.Debug("received DeleteEntity request", zap.String(logEntityID, rq.EntityId))
+	if err := api.ValidateDeleteEntityRequest(rq); err != nil {
+		return nil, err
+	}
+	if err := d.storer.DeleteEntity(rq.EntityId); err != nil {
+		return nil, err
+	}
+	d.Logger.Info("deleted entity", zap.String(logEntityID, rq.EntityId))
+	return &api.DeleteEntityResponse{}, nil
+}
+
+// GetEntityHistory returns every version of an existing entity.
+func (d *Directory) GetEntityHistory(
+	ctx context.Context, rq *api.GetEntityHistoryRequest,
+) (*api.GetEntityHistoryResponse, error) {
+	d.Logger.Debug("received GetEntityHistory request", zap.String(logEntityID, rq.EntityId))
+	if err := api.ValidateGetEntityHistoryRequest(rq); err != nil {
+		return nil, err
+	}
+	versions, err := d.storer.GetEntityHistory(rq.EntityId)
+	if err != nil {
+		return nil, err
+	}
+	rp := &api.GetEntityHistoryResponse{Versions: versions}
+	d.Logger.Info("got entity history", logGetEntityHistoryRp(rq, rp)...)
+	return rp, nil
+}
+
+// PutEntities creates new and/or updates existing entities in a single batch.
+func (d *Directory) PutEntities(
+	ctx context.Context, rq *api.PutEntitiesRequest,
+) (*api.PutEntitiesResponse, error) {
+	d.Logger.Debug("received PutEntities request", zap.Int(logNEntities, len(rq.Entities)))
+	if err := api.ValidatePutEntitiesRequest(rq); err != nil {
+		return nil, err
+	}
+	prs, err := d.storer.PutEntities(rq.Entities, rq.AllowCreateWithId)
+	if err != nil {
+		return nil, err
+	}
+	rp := &api.PutEntitiesResponse{Results: make([]*api.PutEntityResult, len(prs))}
+	for i, pr := range prs {
+		rp.Results[i] = &api.PutEntityResult{
+			EntityId: pr.EntityID,
+			Version:  pr.Version,
+			Error:    errorString(pr.Err),
+		}
+	}
+	d.Logger.Info("put entities", logPutEntitiesRp(rp)...)
+	return rp, nil
+}
+
+// GetEntities returns existing entities in a single batch.
+func (d *Directory) GetEntities(
+	ctx context.Context, rq *api.GetEntitiesRequest,
+) (*api.GetEntitiesResponse, error) {
+	d.Logger.Debug("received GetEntities request", zap.Int(logNEntities, len(rq.EntityIds)))
+	if err := api.ValidateGetEntitiesRequest(rq); err != nil {
+		return nil, err
+	}
+	grs, err := d.storer.GetEntities(rq.EntityIds)
+	if err != nil {
+		return nil, err
+	}
+	rp := &api.GetEntitiesResponse{Results: make([]*api.GetEntityResult, len(grs))}
+	for i, gr := range grs {
+		rp.Results[i] = &api.GetEntityResult{
+			Entity: gr.Entity,
+			Error:  errorString(gr.Err),
+		}
+	}
+	d.Logger.Info("got entities", logGetEntitiesRp(rp)...)
+	return rp, nil
+}
+
+// ListEntities streams pages of existing entities of a given type.
+func (d *Directory) ListEntities(
+	rq *api.ListEntitiesRequest, stream api.Directory_ListEntitiesServer,
+) error {
+	d.Logger.Debug("received ListEntities request", logListEntitiesRq(rq)...)
+	if err := api.ValidateListEntitiesRequest(rq); err != nil {
+		return err
+	}
+	et, err := storage.GetEntityTypeFromName(rq.EntityType)
+	if err != nil {
+		return err
+	}
+	pageToken, nPages, nEntities := rq.PageToken, 0, 0
+	for {
+		es, nextPageToken, err := d.storer.ListEntities(et, pageToken, uint(rq.PageSize))
+		if err != nil {
+			return err
+		}
+		rp := &api.ListEntitiesResponse{Entities: es, NextPageToken: nextPageToken}
+		if err := stream.Send(rp); err != nil {
+			return err
+		}
+		nPages++
+		nEntities += len(es)
+		if nextPageToken == "" {
+			break
+		}
+		pageToken = nextPageToken
+	}
+	d.Logger.Info("listed entities", logListEntitiesRp(rq, nPages, nEntities)...)
+	return nil
+}
+
+// PatchEntity updates just the given fields of an existing entity.
+func (d *Directory) PatchEntity(
+	ctx context.Context, rq *api.PatchEntityRequest,
+) (*api.PatchEntityResponse, error) {
+	d.Logger.Debug("received PatchEntity request", logPatchEntityRq(rq)...)
+	if err := api.ValidatePatchEntityRequest(rq); err != nil {
+		return nil, err
+	}
+	patched, err := d.storer.PatchEntity(rq.Entity, rq.UpdateMask.Paths)
+	if err != nil {
+		return nil, err
+	}
+	rp := &api.PatchEntityResponse{Entity: patched}
+	d.Logger.Info("patched entity", logPatchEntityRq(rq)...)
+	return rp, nil
+}
+
+// MergeEntities merges a duplicate entity into the surviving entity.
+func (d *Directory) MergeEntities(
+	ctx context.Context, rq *api.MergeEntitiesRequest,
+) (*api.MergeEntitiesResponse, error) {
+	d.Logger.Debug("received MergeEntities request", logMergeEntitiesRq(rq)...)
+	if err := api.ValidateMergeEntitiesRequest(rq); err != nil {
+		return nil, err
+	}
+	survivor, err := d.storer.MergeEntities(rq.SurvivorId, rq.MergedId)
+	if err != nil {
+		return nil, err
+	}
+	rp := &api.MergeEntitiesResponse{Survivor: survivor}
+	d.Logger.Info("merged entities", logMergeEntitiesRq(rq)...)
+	return rp, nil
+}
+
+// RevertEntity reverts an existing entity to one of its earlier versions.
+func (d *Directory) RevertEntity(
+	ctx context.Context, rq *api.RevertEntityRequest,
+) (*api.RevertEntityResponse, error) {
+	d.Logger.Debug("received RevertEntity request", logRevertEntityRq(rq)...)
+	if err := api.ValidateRevertEntityRequest(rq); err != nil {
+		return nil, err
+	}
+	revert := &api.Revert{
+		ToVersion:  rq.ToVersion,
+		RevertedBy: rq.RevertedBy,
+		Reason:     rq.Reason,
+	}
+	reverted, err := d.storer.RevertEntity(rq.EntityId, revert)
+	if err != nil {
+		return nil, err
+	}
+	rp := &api.RevertEntityResponse{Entity: reverted}
+	d.Logger.Info("reverted entity", logRevertEntityRp(rq, rp)...)
+	return rp, nil
+}
+
+// FindDuplicates finds pairs of current patients that are probably the same person, for human
+// review.
+func (d *Directory) FindDuplicates(
+	ctx context.Context, rq *api.FindDuplicatesRequest,
+) (*api.FindDuplicatesResponse, error) {
+	d.Logger.Debug("received FindDuplicates request", logFindDuplicatesRq(rq)...)
+	if err := api.ValidateFindDuplicatesRequest(rq); err != nil {
+		return nil, err
+	}
+	threshold, limit := rq.Threshold, rq.Limit
+	if threshold == 0 {
+		threshold = api.DefaultDuplicateThreshold
+	}
+	if limit == 0 {
+		limit = api.DefaultDuplicatesLimit
+	}
+	pairs, err := d.storer.FindDuplicates(threshold, uint(limit))
+	if err != nil {
+		return nil, err
+	}
+	rp := &api.FindDuplicatesResponse{Pairs: pairs}
+	d.Logger.Info("found duplicates", logFindDuplicatesRp(rq, rp)...)
+	return rp, nil
+}
+
+// MatchPatient finds the existing patients that are candidate matches for a given patient,
+// weighted and classified as matches, possible matches, or non-matches.
+func (d *Directory) MatchPatient(
+	ctx context.Context, rq *api.MatchPatientRequest,
+) (*api.MatchPatientResponse, error) {
+	d.Logger.Debug("received MatchPatient request", logMatchPatientRq(rq)...)
+	if err := api.ValidateMatchPatientRequest(rq); err != nil {
+		return nil, err
+	}
+	limit := rq.Limit
+	if limit == 0 {
+		limit = api.DefaultMatchLimit
+	}
+	ms, err := d.storer.MatchPatient(rq.Patient, uint(limit))
+	if err != nil {
+		return nil, err
+	}
+	rp := &api.MatchPatientResponse{Candidates: ms}
+	d.Logger.Info("matched patient", logMatchPatientRp(rq, rp)...)
+	return rp, nil
+}
+
+// Autocomplete suggests the entities whose names start with a prefix.
+func (d *Directory) Autocomplete(
+	ctx context.Context, rq *api.AutocompleteRequest,
+) (*api.AutocompleteResponse, error) {
+	d.Logger.Debug("received Autocomplete request", logAutocompleteRq(rq)...)
+	if !d.autocompleteLimiter.allow() {
+		return nil, ErrAutocompleteRateLimited
+	}
+	if err := api.ValidateAutocompleteRequest(rq); err != nil {
+		return nil, err
+	}
+	limit := rq.Limit
+	if limit == 0 {
+		limit = api.DefaultAutocompleteLimit
+	}
+	suggestions, err := d.storer.Autocomplete(rq.Prefix, rq.EntityTypes, uint(limit))
+	if err != nil {
+		return nil, err
+	}
+	rp := &api.AutocompleteResponse{Suggestions: suggestions}
+	d.Logger.Debug("autocompleted", logAutocompleteRp(rq, rp)...)
+	return rp, nil
+}
@@ -3,13 +3,18 @@ package server
 import (
 	"context"
 	"testing"
+	"time"
 
 	api "github.com/elixirhealth/directory/pkg/directoryapi"
 	"github.com/elixirhealth/directory/pkg/server/storage"
 	"github.com/elixirhealth/service-base/pkg/server"
 	bstorage "github.com/elixirhealth/service-base/pkg/server/storage"
+	"github.com/golang/protobuf/ptypes"
+	"github.com/golang/protobuf/ptypes/timestamp"
 	"github.com/pkg/errors"
 	"github.com/stretchr/testify/assert"
+	"google.golang.org/genproto/protobuf/field_mask"
+	"google.golang.org/grpc"
 )
 
 var okEntity = api.NewTestPatient(0, false)
@@ -40,15 +45,17 @@ func TestDirectory_PutEntity_ok(t *testing.T) {
 		BaseServer: server.NewBaseServer(server.NewDefaultBaseConfig()),
 		storer: &fixedStorer{
 			putEntityID: "some entity ID",
+			putVersion:  2,
 		},
 	}
 	rq := &api.PutEntityRequest{
-		Entity: okEntity,
+		Entity: api.NewTestPatient(1, true),
 	}
 
 	rp, err := d.PutEntity(context.Background(), rq)
 	assert.Nil(t, err)
 	assert.NotEmpty(t, rp.EntityId)
+	assert.Equal(t, uint64(2), rp.Version)
 }
 
 func TestDirectory_PutEntity_err(t *testing.T) {
@@ -87,16 +94,27 @@ func TestDirectory_GetEntity_ok(t *testing.T) {
 	d := &Directory{
 		BaseServer: server.NewBaseServer(server.NewDefaultBaseConfig()),
 		storer: &fixedStorer{
-			getEntity: okEntity,
+			getEntity: api.NewTestPatient(0, true),
 		},
 	}
 	rq := &api.GetEntityRequest{
-		EntityId: "some entity ID",
+		EntityId: "entity 0",
+		AsOf:     ptypes.TimestampNow(),
 	}
 
 	rp, err := d.GetEntity(context.Background(), rq)
 	assert.Nil(t, err)
-	assert.Equal(t, okEntity, rp.Entity)
+	assert.Equal(t, api.NewTestPatient(0, true), rp.Entity)
+	assert.Empty(t, rp.MergedIntoId)
+
+	// entity merged into the gotten survivor
+	survivor := api.NewTestPatient(1, true)
+	d.storer = &fixedStorer{getEntity: survivor}
+	rq.EntityId = "some merged entity ID"
+	rp, err = d.GetEntity(context.Background(), rq)
+	assert.Nil(t, err)
+	assert.Equal(t, survivor, rp.Entity)
+	assert.Equal(t, survivor.EntityId, rp.MergedIntoId)
 }
 
 func TestDirectory_GetEntity_err(t *testing.T) {
@@ -111,6 +129,15 @@ func TestDirectory_GetEntity_err(t *testing.T) {
 			},
 			rq: &api.GetEntityRequest{},
 		},
+		"invalid as of": {
+			d: &Directory{
+				BaseServer: baseServer,
+			},
+			rq: &api.GetEntityRequest{
+				EntityId: "some entity ID",
+				AsOf:     &timestamp.Timestamp{Nanos: -1},
+			},
+		},
 		"storer Get error": {
 			d: &Directory{
 				BaseServer: baseServer,
@@ -134,10 +161,15 @@ func TestDirectory_GetEntity_err(t *testing.T) {
 func TestDirectory_SearchEntity_ok(t *testing.T) {
 	d := &Directory{
 		BaseServer: server.NewBaseServer(server.NewDefaultBaseConfig()),
+		config:     NewDefaultConfig(),
 		storer: &fixedStorer{
-			searchEntities: []*api.Entity{
-				api.NewTestPatient(0, true),
-				api.NewTestPatient(1, true),
+			searchPage: &storage.SearchPage{
+				Results: []*api.SearchResult{
+					{Entity: api.NewTestPatient(0, true), Similarity: 0.5},
+					{Entity: api.NewTestPatient(1, true), Similarity: 0.25},
+				},
+				NextPageToken:     "some next page token",
+				TimedOutSearchers: []string{"some searcher"},
 			},
 		},
 	}
@@ -148,7 +180,48 @@ func TestDirectory_SearchEntity_ok(t *testing.T) {
 
 	rp, err := d.SearchEntity(context.Background(), rq)
 	assert.Nil(t, err)
-	assert.Equal(t, 2, len(rp.Entities))
+	assert.Equal(t, 2, len(rp.Results))
+	assert.Equal(t, float32(0.5), rp.Results[0].Similarity)
+	assert.Equal(t, []*api.Entity{rp.Results[0].Entity, rp.Results[1].Entity}, rp.Entities)
+	assert.Equal(t, "some next page token", rp.NextPageToken)
+	assert.Equal(t, []string{"some searcher"}, rp.TimedOutSearchers)
+	assert.True(t, rp.Partial)
+}
+
+func TestDirectory_SearchEntity_prefixedQuery(t *testing.T) {
+	storer := &fixedStorer{searchPage: &storage.SearchPage{}}
+	d := &Directory{
+		BaseServer: server.NewBaseServer(server.NewDefaultBaseConfig()),
+		config:     NewDefaultConfig(),
+		storer:     storer,
+	}
+	rq := &api.SearchEntityRequest{
+		Query:   "last:smith first:jo dob:1980-02-03",
+		Limit:   8,
+		Filters: &api.SearchFilters{EntityTypes: []string{api.PatientType}},
+	}
+
+	rp, err := d.SearchEntity(context.Background(), rq)
+	assert.Nil(t, err)
+	assert.NotNil(t, rp)
+	assert.Equal(t, "smith jo", storer.searchQuery)
+	assert.Equal(t, &api.SearchFilters{
+		EntityTypes:     []string{api.PatientType},
+		Birthdate:       &api.Date{Year: 1980, Month: 2, Day: 3},
+		LastNamePrefix:  "smith",
+		FirstNamePrefix: "jo",
+	}, storer.searchFilters)
+
+	rq = &api.SearchEntityRequest{
+		Query:       "main st",
+		Limit:       8,
+		EntityTypes: []string{api.OfficeType},
+	}
+	rp, err = d.SearchEntity(context.Background(), rq)
+	assert.Nil(t, err)
+	assert.NotNil(t, rp)
+	assert.Equal(t, &api.SearchFilters{EntityTypes: []string{api.OfficeType}},
+		storer.searchFilters)
 }
 
 func TestDirectory_SearchEntity_err(t *testing.T) {
@@ -163,6 +236,31 @@ func TestDirectory_SearchEntity_err(t *testing.T) {
 			},
 			rq: &api.SearchEntityRequest{},
 		},
+		"invalid as of": {
+			d: &Directory{
+				BaseServer: baseServer,
+			},
+			rq: &api.SearchEntityRequest{
+				Query: "some query",
+				Limit: 8,
+				AsOf:  &timestamp.Timestamp{Nanos: -1},
+			},
+		},
+		"partial search": {
+			d: &Directory{
+				BaseServer: baseServer,
+				config:     NewDefaultConfig().WithFailPartialSearch(true),
+				storer: &fixedStorer{
+					searchPage: &storage.SearchPage{
+						TimedOutSearchers: []string{"some searcher"},
+					},
+				},
+			},
+			rq: &api.SearchEntityRequest{
+				Query: "some query",
+				Limit: 8,
+			},
+		},
 		"storer Search error": {
 			d: &Directory{
 				BaseServer: baseServer,
@@ -183,28 +281,800 @@ func TestDirectory_SearchEntity_err(t *testing.T) {
 	}
 }
 
+func TestDirectory_DeleteEntity_ok(t *testing.T) {
+	d := &Directory{
+		BaseServer: server.NewBaseServer(server.NewDefaultBaseConfig()),
+		storer:     &fixedStorer{},
+	}
+	rq := &api.DeleteEntityRequest{
+		EntityId: "some entity ID",
+	}
+
+	rp, err := d.DeleteEntity(context.Background(), rq)
+	assert.Nil(t, err)
+	assert.NotNil(t, rp)
+}
+
+func TestDirectory_DeleteEntity_err(t *testing.T) {
+	baseServer := server.NewBaseServer(server.NewDefaultBaseConfig())
+	cases := map[string]struct {
+		d  *Directory
+		rq *api.DeleteEntityRequest
+	}{
+		"invalid request": {
+			d: &Directory{
+				BaseServer: baseServer,
+			},
+			rq: &api.DeleteEntityRequest{},
+		},
+		"storer Delete error": {
+			d: &Directory{
+				BaseServer: baseServer,
+				storer: &fixedStorer{
+					deleteErr: errors.New("some Delete error"),
+				},
+			},
+			rq: &api.DeleteEntityRequest{
+				EntityId: "some entity ID",
+			},
+		},
+	}
+
+	for desc, c := range cases {
+		rp, err := c.d.DeleteEntity(context.Background(), c.rq)
+		assert.NotNil(t, err, desc)
+		assert.Nil(t, rp, desc)
+	}
+}
+
+func TestDirectory_GetEntityHistory_ok(t *testing.T) {
+	d := &Directory{
+		BaseServer: server.NewBaseServer(server.NewDefaultBaseConfig()),
+		storer: &fixedStorer{
+			getHistory: []*api.EntityVersion{
+				{Entity: okEntity},
+				{Entity: okEntity},
+			},
+		},
+	}
+	rq := &api.GetEntityHistoryRequest{
+		EntityId: "some entity ID",
+	}
+
+	rp, err := d.GetEntityHistory(context.Background(), rq)
+	assert.Nil(t, err)
+	assert.Equal(t, 2, len(rp.Versions))
+}
+
+func TestDirectory_GetEntityHistory_err(t *testing.T) {
+	baseServer := server.NewBaseServer(server.NewDefaultBaseConfig())
+	cases := map[string]struct {
+		d  *Directory
+		rq *api.GetEntityHistoryRequest
+	}{
+		"invalid request": {
+			d: &Directory{
+				BaseServer: baseServer,
+			},
+			rq: &api.GetEntityHistoryRequest{},
+		},
+		"storer GetHistory error": {
+			d: &Directory{
+				BaseServer: baseServer,
+				storer: &fixedStorer{
+					getHistoryErr: errors.New("some GetHistory error"),
+				},
+			},
+			rq: &api.GetEntityHistoryRequest{
+				EntityId: "some entity ID",
+			},
+		},
+	}
+
+	for desc, c := range cases {
+		rp, err := c.d.GetEntityHistory(context.Background(), c.rq)
+		assert.NotNil(t, err, desc)
+		assert.Nil(t, rp, desc)
+	}
+}
+
+func TestDirectory_PutEntities_ok(t *testing.T) {
+	d := &Directory{
+		BaseServer: server.NewBaseServer(server.NewDefaultBaseConfig()),
+		storer: &fixedStorer{
+			putResults: []*storage.PutResult{
+				{EntityID: "some entity ID", Version: 1},
+				{Err: errors.New("some Put error")},
+			},
+		},
+	}
+	rq := &api.PutEntitiesRequest{
+		Entities: []*api.Entity{
+			api.NewTestPatient(1, false),
+			api.NewTestPatient(2, false),
+		},
+	}
+
+	rp, err := d.PutEntities(context.Background(), rq)
+	assert.Nil(t, err)
+	assert.Equal(t, 2, len(rp.Results))
+	assert.Equal(t, "some entity ID", rp.Results[0].EntityId)
+	assert.Equal(t, uint64(1), rp.Results[0].Version)
+	assert.Empty(t, rp.Results[0].Error)
+	assert.Empty(t, rp.Results[1].EntityId)
+	assert.Equal(t, "some Put error", rp.Results[1].Error)
+}
+
+func TestDirectory_PutEntities_err(t *testing.T) {
+	baseServer := server.NewBaseServer(server.NewDefaultBaseConfig())
+	cases := map[string]struct {
+		d  *Directory
+		rq *api.PutEntitiesRequest
+	}{
+		"invalid request": {
+			d: &Directory{
+				BaseServer: baseServer,
+			},
+			rq: &api.PutEntitiesRequest{},
+		},
+		"storer Puts error": {
+			d: &Directory{
+				BaseServer: baseServer,
+				storer: &fixedStorer{
+					putsErr: errors.New("some Puts error"),
+				},
+			},
+			rq: &api.PutEntitiesRequest{
+				Entities: []*api.Entity{okEntity},
+			},
+		},
+	}
+
+	for desc, c := range cases {
+		rp, err := c.d.PutEntities(context.Background(), c.rq)
+		assert.NotNil(t, err, desc)
+		assert.Nil(t, rp, desc)
+	}
+}
+
+func TestDirectory_GetEntities_ok(t *testing.T) {
+	d := &Directory{
+		BaseServer: server.NewBaseServer(server.NewDefaultBaseConfig()),
+		storer: &fixedStorer{
+			getResults: []*storage.GetResult{
+				{Entity: okEntity},
+				{Err: errors.New("some Get error")},
+			},
+		},
+	}
+	rq := &api.GetEntitiesRequest{
+		EntityIds: []string{"some entity ID", "another entity ID"},
+	}
+
+	rp, err := d.GetEntities(context.Background(), rq)
+	assert.Nil(t, err)
+	assert.Equal(t, 2, len(rp.Results))
+	assert.Equal(t, okEntity, rp.Results[0].Entity)
+	assert.Empty(t, rp.Results[0].Error)
+	assert.Nil(t, rp.Results[1].Entity)
+	assert.Equal(t, "some Get error", rp.Results[1].Error)
+}
+
+func TestDirectory_GetEntities_err(t *testing.T) {
+	baseServer := server.NewBaseServer(server.NewDefaultBaseConfig())
+	cases := map[string]struct {
+		d  *Directory
+		rq *api.GetEntitiesRequest
+	}{
+		"invalid request": {
+			d: &Directory{
+				BaseServer: baseServer,
+			},
+			rq: &api.GetEntitiesRequest{},
+		},
+		"storer Gets error": {
+			d: &Directory{
+				BaseServer: baseServer,
+				storer: &fixedStorer{
+					getsErr: errors.New("some Gets error"),
+				},
+			},
+			rq: &api.GetEntitiesRequest{
+				EntityIds: []string{"some entity ID"},
+			},
+		},
+	}
+
+	for desc, c := range cases {
+		rp, err := c.d.GetEntities(context.Background(), c.rq)
+		assert.NotNil(t, err, desc)
+		assert.Nil(t, rp, desc)
+	}
+}
+
+func TestDirectory_ListEntities_ok(t *testing.T) {
+	d := &Directory{
+		BaseServer: server.NewBaseServer(server.NewDefaultBaseConfig()),
+		storer: &fixedStorer{
+			listPages: map[string]*fixedListPage{
+				"": {
+					es: []*api.Entity{
+						api.NewTestPatient(1, true),
+						api.NewTestPatient(2, true),
+					},
+					nextPageToken: "page 2",
+				},
+				"page 2": {
+					es: []*api.Entity{
+						api.NewTestPatient(3, true),
+					},
+				},
+			},
+		},
+	}
+	rq := &api.ListEntitiesRequest{
+		EntityType: api.PatientType,
+		PageSize:   2,
+	}
+	stream := &fixedListEntitiesServer{}
+
+	err := d.ListEntities(rq, stream)
+	assert.Nil(t, err)
+	assert.Equal(t, 2, len(stream.sent))
+	assert.Equal(t, 2, len(stream.sent[0].Entities))
+	assert.Equal(t, "page 2", stream.sent[0].NextPageToken)
+	assert.Equal(t, 1, len(stream.sent[1].Entities))
+	assert.Empty(t, stream.sent[1].NextPageToken)
+}
+
+func TestDirectory_ListEntities_err(t *testing.T) {
+	baseServer := server.NewBaseServer(server.NewDefaultBaseConfig())
+	okRq := &api.ListEntitiesRequest{EntityType: api.OfficeType}
+	okPages := map[string]*fixedListPage{
+		"": {es: []*api.Entity{api.NewTestOffice(1, true)}},
+	}
+	cases := map[string]struct {
+		d      *Directory
+		rq     *api.ListEntitiesRequest
+		stream *fixedListEntitiesServer
+	}{
+		"invalid request": {
+			d: &Directory{
+				BaseServer: baseServer,
+			},
+			rq:     &api.ListEntitiesRequest{},
+			stream: &fixedListEntitiesServer{},
+		},
+		"storer List error": {
+			d: &Directory{
+				BaseServer: baseServer,
+				storer: &fixedStorer{
+					listErr: errors.New("some List error"),
+				},
+			},
+			rq:     okRq,
+			stream: &fixedListEntitiesServer{},
+		},
+		"stream Send error": {
+			d: &Directory{
+				BaseServer: baseServer,
+				storer:     &fixedStorer{listPages: okPages},
+			},
+			rq:     okRq,
+			stream: &fixedListEntitiesServer{sendErr: errors.New("some Send error")},
+		},
+	}
+
+	for desc, c := range cases {
+		err := c.d.ListEntities(c.rq, c.stream)
+		assert.NotNil(t, err, desc)
+		assert.Empty(t, c.stream.sent, desc)
+	}
+}
+
+func TestDirectory_PatchEntity_ok(t *testing.T) {
+	d := &Directory{
+		BaseServer: server.NewBaseServer(server.NewDefaultBaseConfig()),
+		storer: &fixedStorer{
+			patchEntity: okEntity,
+		},
+	}
+	rq := &api.PatchEntityRequest{
+		Entity:     api.NewPatient("some entity ID", &api.Patient{LastName: "Last Name"}),
+		UpdateMask: &field_mask.FieldMask{Paths: []string{"patient.last_name"}},
+	}
+
+	rp, err := d.PatchEntity(context.Background(), rq)
+	assert.Nil(t, err)
+	assert.Equal(t, okEntity, rp.Entity)
+}
+
+func TestDirectory_PatchEntity_err(t *testing.T) {
+	baseServer := server.NewBaseServer(server.NewDefaultBaseConfig())
+	cases := map[string]struct {
+		d  *Directory
+		rq *api.PatchEntityRequest
+	}{
+		"invalid request": {
+			d: &Directory{
+				BaseServer: baseServer,
+			},
+			rq: &api.PatchEntityRequest{},
+		},
+		"storer Patch error": {
+			d: &Directory{
+				BaseServer: baseServer,
+				storer: &fixedStorer{
+					patchErr: errors.New("some Patch error"),
+				},
+			},
+			rq: &api.PatchEntityRequest{
+				Entity: api.NewPatient("some entity ID",
+					&api.Patient{LastName: "Last Name"}),
+				UpdateMask: &field_mask.FieldMask{Paths: []string{"patient.last_name"}},
+			},
+		},
+	}
+
+	for desc, c := range cases {
+		rp, err := c.d.PatchEntity(context.Background(), c.rq)
+		assert.NotNil(t, err, desc)
+		assert.Nil(t, rp, desc)
+	}
+}
+
+func TestDirectory_RevertEntity_ok(t *testing.T) {
+	d := &Directory{
+		BaseServer: server.NewBaseServer(server.NewDefaultBaseConfig()),
+		storer: &fixedStorer{
+			revertEntity: okEntity,
+		},
+	}
+	rq := &api.RevertEntityRequest{
+		EntityId:   "some entity ID",
+		ToVersion:  1,
+		RevertedBy: "some registrar",
+		Reason:     "some reason",
+	}
+
+	rp, err := d.RevertEntity(context.Background(), rq)
+	assert.Nil(t, err)
+	assert.Equal(t, okEntity, rp.Entity)
+}
+
+func TestDirectory_RevertEntity_err(t *testing.T) {
+	baseServer := server.NewBaseServer(server.NewDefaultBaseConfig())
+	cases := map[string]struct {
+		d  *Directory
+		rq *api.RevertEntityRequest
+	}{
+		"invalid request": {
+			d: &Directory{
+				BaseServer: baseServer,
+			},
+			rq: &api.RevertEntityRequest{},
+		},
+		"storer Revert error": {
+			d: &Directory{
+				BaseServer: baseServer,
+				storer: &fixedStorer{
+					revertErr: errors.New("some Revert error"),
+				},
+			},
+			rq: &api.RevertEntityRequest{
+				EntityId:   "some entity ID",
+				ToVersion:  1,
+				RevertedBy: "some registrar",
+				Reason:     "some reason",
+			},
+		},
+	}
+
+	for desc, c := range cases {
+		rp, err := c.d.RevertEntity(context.Background(), c.rq)
+		assert.NotNil(t, err, desc)
+		assert.Nil(t, rp, desc)
+	}
+}
+
+func TestDirectory_MergeEntities_ok(t *testing.T) {
+	d := &Directory{
+		BaseServer: server.NewBaseServer(server.NewDefaultBaseConfig()),
+		storer: &fixedStorer{
+			mergeSurvivor: okEntity,
+		},
+	}
+	rq := &api.MergeEntitiesRequest{
+		SurvivorId: "some entity ID",
+		MergedId:   "some other entity ID",
+	}
+
+	rp, err := d.MergeEntities(context.Background(), rq)
+	assert.Nil(t, err)
+	assert.Equal(t, okEntity, rp.Survivor)
+}
+
+func TestDirectory_MergeEntities_err(t *testing.T) {
+	baseServer := server.NewBaseServer(server.NewDefaultBaseConfig())
+	cases := map[string]struct {
+		d  *Directory
+		rq *api.MergeEntitiesRequest
+	}{
+		"invalid request": {
+			d: &Directory{
+				BaseServer: baseServer,
+			},
+			rq: &api.MergeEntitiesRequest{},
+		},
+		"storer Merge error": {
+			d: &Directory{
+				BaseServer: baseServer,
+				storer: &fixedStorer{
+					mergeErr: errors.New("some Merge error"),
+				},
+			},
+			rq: &api.MergeEntitiesRequest{
+				SurvivorId: "some entity ID",
+				MergedId:   "some other entity ID",
+			},
+		},
+	}
+
+	for desc, c := range cases {
+		rp, err := c.d.MergeEntities(context.Background(), c.rq)
+		assert.NotNil(t, err, desc)
+		assert.Nil(t, rp, desc)
+	}
+}
+
+func TestDirectory_FindDuplicates_ok(t *testing.T) {
+	pairs := []*api.DuplicatePair{
+		storage.NewDuplicatePair(api.NewTestPatient(1, true), api.NewTestPatient(2, true),
+			0.9, true),
+	}
+	cases := map[string]struct {
+		rq                *api.FindDuplicatesRequest
+		expectedThreshold float32
+		expectedLimit     uint
+	}{
+		"defaults": {
+			rq:                &api.FindDuplicatesRequest{},
+			expectedThreshold: api.DefaultDuplicateThreshold,
+			expectedLimit:     api.DefaultDuplicatesLimit,
+		},
+		"given": {
+			rq:                &api.FindDuplicatesRequest{Threshold: 0.5, Limit: 10},
+			expectedThreshold: 0.5,
+			expectedLimit:     10,
+		},
+	}
+	for desc, c := range cases {
+		st := &fixedStorer{duplicates: pairs}
+		d := &Directory{
+			BaseServer: server.NewBaseServer(server.NewDefaultBaseConfig()),
+			storer:     st,
+		}
+		rp, err := d.FindDuplicates(context.Background(), c.rq)
+		assert.Nil(t, err, desc)
+		assert.Equal(t, pairs, rp.Pairs, desc)
+		assert.Equal(t, c.expectedThreshold, st.dupThreshold, desc)
+		assert.Equal(t, c.expectedLimit, st.dupLimit, desc)
+	}
+}
+
+func TestDirectory_FindDuplicates_err(t *testing.T) {
+	baseServer := server.NewBaseServer(server.NewDefaultBaseConfig())
+	cases := map[string]struct {
+		d  *Directory
+		rq *api.FindDuplicatesRequest
+	}{
+		"invalid request": {
+			d: &Directory{
+				BaseServer: baseServer,
+			},
+			rq: &api.FindDuplicatesRequest{Threshold: 2},
+		},
+		"storer FindDuplicates error": {
+			d: &Directory{
+				BaseServer: baseServer,
+				storer: &fixedStorer{
+					duplicatesErr: errors.New("some FindDuplicates error"),
+				},
+			},
+			rq: &api.FindDuplicatesRequest{},
+		},
+	}
+
+	for desc, c := range cases {
+		rp, err := c.d.FindDuplicates(context.Background(), c.rq)
+		assert.NotNil(t, err, desc)
+		assert.Nil(t, rp, desc)
+	}
+}
+
+func TestDirectory_MatchPatient_ok(t *testing.T) {
+	ms := []*api.PatientMatch{
+		{Entity: api.NewTestPatient(1, true), Weight: 20, Classification: api.Match},
+	}
+	cases := map[string]struct {
+		rq            *api.MatchPatientRequest
+		expectedLimit uint
+	}{
+		"default limit": {
+			rq: &api.MatchPatientRequest{
+				Patient: api.NewTestPatient(1, false).GetPatient(),
+			},
+			expectedLimit: api.DefaultMatchLimit,
+		},
+		"given limit": {
+			rq: &api.MatchPatientRequest{
+				Patient: api.NewTestPatient(1, false).GetPatient(),
+				Limit:   2,
+			},
+			expectedLimit: 2,
+		},
+	}
+	for desc, c := range cases {
+		st := &fixedStorer{matches: ms}
+		d := &Directory{
+			BaseServer: server.NewBaseServer(server.NewDefaultBaseConfig()),
+			storer:     st,
+		}
+		rp, err := d.MatchPatient(context.Background(), c.rq)
+		assert.Nil(t, err, desc)
+		assert.Equal(t, ms, rp.Candidates, desc)
+		assert.Equal(t, c.expectedLimit, st.matchLimit, desc)
+	}
+}
+
+func TestDirectory_MatchPatient_err(t *testing.T) {
+	baseServer := server.NewBaseServer(server.NewDefaultBaseConfig())
+	cases := map[string]struct {
+		d  *Directory
+		rq *api.MatchPatientRequest
+	}{
+		"invalid request": {
+			d: &Directory{
+				BaseServer: baseServer,
+			},
+			rq: &api.MatchPatientRequest{},
+		},
+		"storer MatchPatient error": {
+			d: &Directory{
+				BaseServer: baseServer,
+				storer: &fixedStorer{
+					matchErr: errors.New("some MatchPatient error"),
+				},
+			},
+			rq: &api.MatchPatientRequest{
+				Patient: api.NewTestPatient(1, false).GetPatient(),
+			},
+		},
+	}
+
+	for desc, c := range cases {
+		rp, err := c.d.MatchPatient(context.Background(), c.rq)
+		assert.NotNil(t, err, desc)
+		assert.Nil(t, rp, desc)
+	}
+}
+
+func TestDirectory_Autocomplete_ok(t *testing.T) {
+	suggestions := []*api.Suggestion{
+		{EntityId: "P1", EntityType: api.PatientType, DisplayName: "José Muñoz"},
+	}
+	cases := map[string]struct {
+		rq            *api.AutocompleteRequest
+		expectedLimit uint
+	}{
+		"default limit": {
+			rq:            &api.AutocompleteRequest{Prefix: "mu"},
+			expectedLimit: api.DefaultAutocompleteLimit,
+		},
+		"given limit": {
+			rq:            &api.AutocompleteRequest{Prefix: "mu", Limit: 2},
+			expectedLimit: 2,
+		},
+	}
+	for desc, c := range cases {
+		st := &fixedStorer{suggestions: suggestions}
+		d := &Directory{
+			BaseServer:          server.NewBaseServer(server.NewDefaultBaseConfig()),
+			storer:              st,
+			autocompleteLimiter: newRateLimiter(DefaultAutocompleteRateLimit, 1),
+		}
+		rp, err := d.Autocomplete(context.Background(), c.rq)
+		assert.Nil(t, err, desc)
+		assert.Equal(t, suggestions, rp.Suggestions, desc)
+		assert.Equal(t, c.expectedLimit, st.acpLimit, desc)
+	}
+}
+
+func TestDirectory_Autocomplete_err(t *testing.T) {
+	baseServer := server.NewBaseServer(server.NewDefaultBaseConfig())
+	drained := newRateLimiter(1, 1)
+	assert.True(t, drained.allow())
+	cases := map[string]struct {
+		d        *Directory
+		rq       *api.AutocompleteRequest
+		expected error
+	}{
+		"rate limited": {
+			d: &Directory{
+				BaseServer:          baseServer,
+				storer:              &fixedStorer{},
+				autocompleteLimiter: drained,
+			},
+			rq:       &api.AutocompleteRequest{Prefix: "mu"},
+			expected: ErrAutocompleteRateLimited,
+		},
+		"invalid request": {
+			d: &Directory{
+				BaseServer: baseServer,
+			},
+			rq:       &api.AutocompleteRequest{Prefix: "m"},
+			expected: api.ErrAutocompletePrefixTooShort,
+		},
+		"storer Autocomplete error": {
+			d: &Directory{
+				BaseServer: baseServer,
+				storer: &fixedStorer{
+					acpErr: errors.New("some Autocomplete error"),
+				},
+			},
+			rq: &api.AutocompleteRequest{Prefix: "mu"},
+		},
+	}
+
+	for desc, c := range cases {
+		rp, err := c.d.Autocomplete(context.Background(), c.rq)
+		assert.NotNil(t, err, desc)
+		if c.expected != nil {
+			assert.Equal(t, c.expected, err, desc)
+		}
+		assert.Nil(t, rp, desc)
+	}
+}
+
 type fixedStorer struct {
-	putEntityID    string
-	putErr         error
-	getEntity      *api.Entity
-	getErr         error
-	searchEntities []*api.Entity
-	searchErr      error
-	closeErr       error
+	putEntityID   string
+	putVersion    uint64
+	putErr        error
+	getEntity     *api.Entity
+	getErr        error
+	searchPage    *storage.SearchPage
+	searchErr     error
+	searchQuery   string
+	searchFilters *api.SearchFilters
+	deleteErr     error
+	getHistory    []*api.EntityVersion
+	getHistoryErr error
+	putResults    []*storage.PutResult
+	putsErr       error
+	getResults    []*storage.GetResult
+	getsErr       error
+	listPages     map[string]*fixedListPage
+	listErr       error
+	patchEntity   *api.Entity
+	patchErr      error
+	mergeSurvivor *api.Entity
+	mergeErr      error
+	revertEntity  *api.Entity
+	revertErr     error
+	duplicates    []*api.DuplicatePair
+	duplicatesErr error
+	dupThreshold  float32
+	dupLimit      uint
+	matches       []*api.PatientMatch
+	matchErr      error
+	matchLimit    uint
+	suggestions   []*api.Suggestion
+	acpErr        error
+	acpLimit      uint
+	closeErr      error
 }
 
-func (f *fixedStorer) PutEntity(e *api.Entity) (string, error) {
+func (f *fixedStorer) PutEntity(e *api.Entity, allowCreate bool) (string, error) {
+	if f.putErr == nil {
+		e.Version = f.putVersion
+	}
 	return f.putEntityID, f.putErr
 }
 
-func (f *fixedStorer) GetEntity(entityID string) (*api.Entity, error) {
+func (f *fixedStorer) GetEntity(entityID string, asOf time.Time) (*api.Entity, error) {
 	return f.getEntity, f.getErr
 }
 
-func (f *fixedStorer) SearchEntity(query string, limit uint) ([]*api.Entity, error) {
-	return f.searchEntities, f.searchErr
+func (f *fixedStorer) SearchEntity(
+	query string,
+	filters *api.SearchFilters,
+	limit uint,
+	asOf time.Time,
+	pageToken string,
+	explain bool,
+) (*storage.SearchPage, error) {
+	f.searchQuery, f.searchFilters = query, filters
+	return f.searchPage, f.searchErr
+}
+
+func (f *fixedStorer) DeleteEntity(entityID string) error {
+	return f.deleteErr
+}
+
+func (f *fixedStorer) GetEntityHistory(entityID string) ([]*api.EntityVersion, error) {
+	return f.getHistory, f.getHistoryErr
+}
+
+func (f *fixedStorer) PutEntities(
+	es []*api.Entity, allowCreate bool,
+) ([]*storage.PutResult, error) {
+	return f.putResults, f.putsErr
+}
+
+func (f *fixedStorer) GetEntities(entityIDs []string) ([]*storage.GetResult, error) {
+	return f.getResults, f.getsErr
+}
+
+func (f *fixedStorer) ListEntities(
+	et storage.EntityType, pageToken string, pageSize uint,
+) ([]*api.Entity, string, error) {
+	if f.listErr != nil {
+		return nil, "", f.listErr
+	}
+	page := f.listPages[pageToken]
+	return page.es, page.nextPageToken, nil
+}
+
+func (f *fixedStorer) PatchEntity(patch *api.Entity, paths []string) (*api.Entity, error) {
+	return f.patchEntity, f.patchErr
+}
+
+func (f *fixedStorer) MergeEntities(survivorID, mergedID string) (*api.Entity, error) {
+	return f.mergeSurvivor, f.mergeErr
+}
+
+func (f *fixedStorer) RevertEntity(entityID string, revert *api.Revert) (*api.Entity, error) {
+	return f.revertEntity, f.revertErr
+}
+
+func (f *fixedStorer) FindDuplicates(
+	threshold float32, limit uint,
+) ([]*api.DuplicatePair, error) {
+	f.dupThreshold, f.dupLimit = threshold, limit
+	return f.duplicates, f.duplicatesErr
+}
+
+func (f *fixedStorer) MatchPatient(p *api.Patient, limit uint) ([]*api.PatientMatch, error) {
+	f.matchLimit = limit
+	return f.matches, f.matchErr
+}
+
+func (f *fixedStorer) Autocomplete(
+	prefix string, entityTypes []string, limit uint,
+) ([]*api.Suggestion, error) {
+	f.acpLimit = limit
+	return f.suggestions, f.acpErr
 }
 
 func (f *fixedStorer) Close() error {
 	return f.closeErr
 }
+
+type fixedListPage struct {
+	es            []*api.Entity
+	nextPageToken string
+}
+
+type fixedListEntitiesServer struct {
+	grpc.ServerStream
+	sent    []*api.ListEntitiesResponse
+	sendErr error
+}
+
+func (f *fixedListEntitiesServer) Send(rp *api.ListEntitiesResponse) error {
+	if f.sendErr != nil {
+		return f.sendErr
+	}
+	f.sent = append(f.sent, rp)
+	return nil
+}
@@ -0,0 +1,89 @@
+package storage
+
+import (
+	"sort"
+	"strings"
+
+	api "github.com/elixirhealth/directory/pkg/directoryapi"
+)
+
+// AutocompleteEntityTypes returns the EntityTypes with the given api entity type names, or all
+// EntityTypes if there are none.
+func AutocompleteEntityTypes(names []string) ([]EntityType, error) {
+	if len(names) == 0 {
+		ets := make([]EntityType, NEntityTypes)
+		for i := range ets {
+			ets[i] = EntityType(i)
+		}
+		return ets, nil
+	}
+	ets := make([]EntityType, 0, len(names))
+	seen := make(map[EntityType]struct{})
+	for _, name := range names {
+		et, err := GetEntityTypeFromName(name)
+		if err != nil {
+			return nil, err
+		}
+		if _, in := seen[et]; !in {
+			seen[et] = struct{}{}
+			ets = append(ets, et)
+		}
+	}
+	return ets, nil
+}
+
+// AutocompleteKeys returns the folded names of the entity that autocomplete prefixes are matched
+// against: for a patient, its last name followed by its first name and its first name followed by
+// its last name, and for an office, its name. Suggestions are ordered by the first key.
+func AutocompleteKeys(e *api.Entity) []string {
+	switch ta := e.TypeAttributes.(type) {
+	case *api.Entity_Patient:
+		last, first := api.FoldName(ta.Patient.LastName), api.FoldName(ta.Patient.FirstName)
+		return []string{last + " " + first, first + " " + last}
+	case *api.Entity_Office:
+		return []string{api.FoldName(ta.Office.Name)}
+	default:
+		panic(ErrUnknownEntityType)
+	}
+}
+
+// MatchesAutocompletePrefix returns whether any of the autocomplete keys of the entity start with
+// the folded prefix.
+func MatchesAutocompletePrefix(e *api.Entity, foldedPrefix string) bool {
+	for _, key := range AutocompleteKeys(e) {
+		if strings.HasPrefix(key, foldedPrefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// TopSuggestions returns the suggestions for the first limit entities ordered by their first
+// autocomplete key and then by entity ID.
+func TopSuggestions(es []*api.Entity, limit uint) []*api.Suggestion {
+	keys := make(map[string]string, len(es))
+	for _, e := range es {
+		keys[e.EntityId] = AutocompleteKeys(e)[0]
+	}
+	sorted := make([]*api.Entity, len(es))
+	copy(sorted, es)
+	sort.Slice(sorted, func(i, j int) bool {
+		ki, kj := keys[sorted[i].EntityId], keys[sorted[j].EntityId]
+		if ki != kj {
+			return ki < kj
+		}
+		return sorted[i].EntityId < sorted[j].EntityId
+	})
+	if uint(len(sorted)) > limit {
+		sorted = sorted[:limit]
+	}
+	suggestions := make([]*api.Suggestion, len(sorted))
+	for i, e := range sorted {
+		suggestions[i] = &api.Suggestion{
+			EntityId:    e.EntityId,
+			EntityType:  e.Type(),
+			DisplayName: e.Name(),
+		}
+	}
+	return suggestions
+}
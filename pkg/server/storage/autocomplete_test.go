@@ -0,0 +1,63 @@
+package storage
+
+import (
+	"testing"
+
+	api "github.com/elixirhealth/directory/pkg/directoryapi"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAutocompleteEntityTypes(t *testing.T) {
+	ets, err := AutocompleteEntityTypes(nil)
+	assert.Nil(t, err)
+	assert.Equal(t, []EntityType{Patient, Office}, ets)
+
+	ets, err = AutocompleteEntityTypes([]string{api.OfficeType, api.OfficeType})
+	assert.Nil(t, err)
+	assert.Equal(t, []EntityType{Office}, ets)
+
+	ets, err = AutocompleteEntityTypes([]string{"OTHER"})
+	assert.Equal(t, ErrUnknownEntityType, err)
+	assert.Nil(t, ets)
+}
+
+func TestAutocompleteKeys(t *testing.T) {
+	p := api.NewPatient("", &api.Patient{LastName: "Muñoz-García", FirstName: "José"})
+	assert.Equal(t, []string{"MUNOZ GARCIA JOSE", "JOSE MUNOZ GARCIA"}, AutocompleteKeys(p))
+
+	f := api.NewOffice("", &api.Office{Name: "St. Mary's Clinic"})
+	assert.Equal(t, []string{"ST MARYS CLINIC"}, AutocompleteKeys(f))
+}
+
+func TestMatchesAutocompletePrefix(t *testing.T) {
+	p := api.NewPatient("", &api.Patient{LastName: "Muñoz", FirstName: "José"})
+	assert.True(t, MatchesAutocompletePrefix(p, "MU"))
+	assert.True(t, MatchesAutocompletePrefix(p, "MUNOZ J"))
+	assert.True(t, MatchesAutocompletePrefix(p, "JOSE M"))
+	assert.False(t, MatchesAutocompletePrefix(p, "OZ"))
+	assert.False(t, MatchesAutocompletePrefix(p, "MUNOZ M"))
+}
+
+func TestTopSuggestions(t *testing.T) {
+	es := []*api.Entity{
+		api.NewPatient("P2", &api.Patient{LastName: "Smith", FirstName: "Alice"}),
+		api.NewOffice("F1", &api.Office{Name: "Smith Clinic"}),
+		api.NewPatient("P1", &api.Patient{LastName: "Smith", FirstName: "Alice"}),
+		api.NewPatient("P3", &api.Patient{LastName: "Smith", FirstName: "Adam"}),
+	}
+	expected := []*api.Suggestion{
+		{EntityId: "P3", EntityType: api.PatientType, DisplayName: "Adam Smith"},
+		{EntityId: "P1", EntityType: api.PatientType, DisplayName: "Alice Smith"},
+		{EntityId: "P2", EntityType: api.PatientType, DisplayName: "Alice Smith"},
+	}
+	assert.Equal(t, expected, TopSuggestions(es, 3))
+	assert.Equal(t, "P2", es[0].EntityId) // not sorted in place
+
+	suggestions := TopSuggestions(es, 8)
+	assert.Len(t, suggestions, 4)
+	assert.Equal(t, &api.Suggestion{
+		EntityId:    "F1",
+		EntityType:  api.OfficeType,
+		DisplayName: "Smith Clinic",
+	}, suggestions[3])
+}
@@ -0,0 +1,51 @@
+package storage
+
+import (
+	"sort"
+
+	api "github.com/elixirhealth/directory/pkg/directoryapi"
+)
+
+// DuplicateCandidateSimilarity is the minimum name similarity for a pair of patients to be
+// considered as candidate duplicates, which matches the default pg_trgm similarity threshold.
+const DuplicateCandidateSimilarity = 0.3
+
+// DuplicateScore returns the score of a candidate duplicate pair of patients, which is the mean of
+// their name similarity and their birthdate agreement.
+func DuplicateScore(nameSim float32, birthdateMatch bool) float32 {
+	if birthdateMatch {
+		return (nameSim + 1) / 2
+	}
+	return nameSim / 2
+}
+
+// NewDuplicatePair creates an *api.DuplicatePair for the two patients, ordering them by entity ID
+// and scoring them from their name similarity and birthdate agreement.
+func NewDuplicatePair(
+	e1, e2 *api.Entity, nameSim float32, birthdateMatch bool,
+) *api.DuplicatePair {
+	if e2.EntityId < e1.EntityId {
+		e1, e2 = e2, e1
+	}
+	return &api.DuplicatePair{
+		Entity1:        e1,
+		Entity2:        e2,
+		Score:          DuplicateScore(nameSim, birthdateMatch),
+		NameSimilarity: nameSim,
+		BirthdateMatch: birthdateMatch,
+	}
+}
+
+// SortDuplicatePairs sorts the pairs by score from highest to lowest, breaking ties by the
+// entity IDs of the pairs.
+func SortDuplicatePairs(pairs []*api.DuplicatePair) {
+	sort.Slice(pairs, func(i, j int) bool {
+		if pairs[i].Score != pairs[j].Score {
+			return pairs[i].Score > pairs[j].Score
+		}
+		if pairs[i].Entity1.EntityId != pairs[j].Entity1.EntityId {
+			return pairs[i].Entity1.EntityId < pairs[j].Entity1.EntityId
+		}
+		return pairs[i].Entity2.EntityId < pairs[j].Entity2.EntityId
+	})
+}
@@ -0,0 +1,38 @@
+package storage
+
+import (
+	"testing"
+
+	api "github.com/elixirhealth/directory/pkg/directoryapi"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDuplicateScore(t *testing.T) {
+	assert.Equal(t, float32(1), DuplicateScore(1, true))
+	assert.Equal(t, float32(0.75), DuplicateScore(0.5, true))
+	assert.Equal(t, float32(0.5), DuplicateScore(1, false))
+	assert.Equal(t, float32(0), DuplicateScore(0, false))
+}
+
+func TestNewDuplicatePair(t *testing.T) {
+	e1, e2 := api.NewTestPatient(1, true), api.NewTestPatient(2, true)
+
+	p := NewDuplicatePair(e2, e1, 0.5, true)
+	assert.Equal(t, e1, p.Entity1)
+	assert.Equal(t, e2, p.Entity2)
+	assert.Equal(t, float32(0.75), p.Score)
+	assert.Equal(t, float32(0.5), p.NameSimilarity)
+	assert.True(t, p.BirthdateMatch)
+}
+
+func TestSortDuplicatePairs(t *testing.T) {
+	e1, e2, e3 := api.NewTestPatient(1, true), api.NewTestPatient(2, true),
+		api.NewTestPatient(3, true)
+	p12 := NewDuplicatePair(e1, e2, 0.6, true)
+	p13 := NewDuplicatePair(e1, e3, 0.6, true)
+	p23 := NewDuplicatePair(e2, e3, 0.9, true)
+	pairs := []*api.DuplicatePair{p13, p12, p23}
+
+	SortDuplicatePairs(pairs)
+	assert.Equal(t, []*api.DuplicatePair{p23, p12, p13}, pairs)
+}
@@ -66,3 +66,15 @@ func GetEntityTypeFromID(entityID string) EntityType {
 	}
 	panic(ErrUnknownEntityType)
 }
+
+// GetEntityTypeFromName returns the EntityType with the given api entity type name (e.g.,
+// api.PatientType).
+func GetEntityTypeFromName(name string) (EntityType, error) {
+	for i := 0; i < NEntityTypes; i++ {
+		et := EntityType(i)
+		if strings.ToUpper(et.String()) == name {
+			return et, nil
+		}
+	}
+	return 0, ErrUnknownEntityType
+}
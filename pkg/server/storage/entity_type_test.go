@@ -42,3 +42,20 @@ func TestGetEntityTypeFromID(t *testing.T) {
 		assert.Equal(t, et, GetEntityTypeFromID(id))
 	}
 }
+
+func TestGetEntityTypeFromName(t *testing.T) {
+	cases := map[EntityType]string{
+		Patient: api.PatientType,
+		Office:  api.OfficeType,
+	}
+	assert.Equal(t, NEntityTypes, len(cases))
+	for et, name := range cases {
+		et2, err := GetEntityTypeFromName(name)
+		assert.Nil(t, err)
+		assert.Equal(t, et, et2)
+	}
+
+	et, err := GetEntityTypeFromName("some type")
+	assert.Equal(t, ErrUnknownEntityType, err)
+	assert.Zero(t, et)
+}
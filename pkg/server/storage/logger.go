@@ -6,6 +6,16 @@ const (
 	logStorageType     = "storage_type"
 	logPutQueryTimeout = "put_query_timeout"
 	logGetQueryTimeout = "get_query_timeout"
+	logDelQueryTimeout = "delete_query_timeout"
+	logLstQueryTimeout = "list_query_timeout"
+	logDdpQueryTimeout = "dedupe_query_timeout"
+	logMchQueryTimeout = "match_query_timeout"
+	logAcpQueryTimeout = "autocomplete_query_timeout"
+	logMatchThreshold  = "match_threshold"
+	logPossibleMatch   = "possible_match_threshold"
+	logNNicknames      = "n_nicknames"
+	logCombination     = "similarity_combination"
+	logSearcherWeights = "searcher_weights"
 	logEntityID        = "entity_id"
 	logSimilarities    = "similarities"
 	logSimilarity      = "similarity"
@@ -16,5 +26,14 @@ func (p *Parameters) MarshalLogObject(oe zapcore.ObjectEncoder) error {
 	oe.AddString(logStorageType, p.Type.String())
 	oe.AddDuration(logPutQueryTimeout, p.PutQueryTimeout)
 	oe.AddDuration(logGetQueryTimeout, p.GetQueryTimeout)
-	return nil
+	oe.AddDuration(logDelQueryTimeout, p.DeleteQueryTimeout)
+	oe.AddDuration(logLstQueryTimeout, p.ListQueryTimeout)
+	oe.AddDuration(logDdpQueryTimeout, p.DedupeQueryTimeout)
+	oe.AddDuration(logMchQueryTimeout, p.MatchQueryTimeout)
+	oe.AddDuration(logAcpQueryTimeout, p.AutocompleteQueryTimeout)
+	oe.AddFloat64(logMatchThreshold, p.MatchThreshold)
+	oe.AddFloat64(logPossibleMatch, p.PossibleMatchThreshold)
+	oe.AddInt(logNNicknames, len(p.Nicknames))
+	oe.AddString(logCombination, p.Ranking.Combination.String())
+	return oe.AddObject(logSearcherWeights, p.Ranking.SearcherWeights)
 }
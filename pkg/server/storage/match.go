@@ -0,0 +1,149 @@
+package storage
+
+import (
+	"math"
+	"sort"
+	"strings"
+
+	api "github.com/elixirhealth/directory/pkg/directoryapi"
+	"github.com/golang/protobuf/proto"
+)
+
+const (
+	// DefaultMatchThreshold is the default minimum match weight of a candidate classified as a
+	// match.
+	DefaultMatchThreshold = 15.0
+
+	// DefaultPossibleMatchThreshold is the default minimum match weight of a candidate
+	// classified as a possible match.
+	DefaultPossibleMatchThreshold = 5.0
+
+	// MaxMatchCandidates is the maximum number of candidates a Storer weights when matching a
+	// patient.
+	MaxMatchCandidates = 256
+)
+
+// FieldWeights are the Fellegi-Sunter weights a compared field adds to a match weight when it
+// agrees or disagrees.
+type FieldWeights struct {
+	Agreement    float64
+	Disagreement float64
+}
+
+// NewFieldWeights returns the FieldWeights for a field with the given m-probability, that the
+// field agrees between records of the same patient, and u-probability, that it agrees between
+// records of different patients.
+func NewFieldWeights(m, u float64) *FieldWeights {
+	return &FieldWeights{
+		Agreement:    math.Log2(m / u),
+		Disagreement: math.Log2((1 - m) / (1 - u)),
+	}
+}
+
+func (fw *FieldWeights) weight(a agreement) float64 {
+	switch a {
+	case agrees:
+		return fw.Agreement
+	case disagrees:
+		return fw.Disagreement
+	default:
+		return 0
+	}
+}
+
+// MatchWeights are the FieldWeights of each compared patient field.
+type MatchWeights struct {
+	LastName   *FieldWeights
+	FirstName  *FieldWeights
+	MiddleName *FieldWeights
+	Suffix     *FieldWeights
+	Birthdate  *FieldWeights
+}
+
+// NewDefaultMatchWeights returns a *MatchWeights object with default values.
+func NewDefaultMatchWeights() *MatchWeights {
+	return &MatchWeights{
+		LastName:   NewFieldWeights(0.95, 0.01),
+		FirstName:  NewFieldWeights(0.9, 0.02),
+		MiddleName: NewFieldWeights(0.8, 0.05),
+		Suffix:     NewFieldWeights(0.9, 0.5),
+		Birthdate:  NewFieldWeights(0.97, 0.0003),
+	}
+}
+
+// Weight returns the match weight of the two patients, summing the weights of each of their
+// fields. Names agree when equal ignoring case and surrounding whitespace, and fields missing from
+// either patient add zero.
+func (mw *MatchWeights) Weight(p1, p2 *api.Patient) float64 {
+	return mw.LastName.weight(compareNames(p1.LastName, p2.LastName)) +
+		mw.FirstName.weight(compareNames(p1.FirstName, p2.FirstName)) +
+		mw.MiddleName.weight(compareNames(p1.MiddleName, p2.MiddleName)) +
+		mw.Suffix.weight(compareNames(p1.Suffix, p2.Suffix)) +
+		mw.Birthdate.weight(compareDates(p1.Birthdate, p2.Birthdate))
+}
+
+// ClassifyMatch returns the classification of a candidate with the given match weight.
+func ClassifyMatch(weight float64, params *Parameters) string {
+	if weight >= params.MatchThreshold {
+		return api.Match
+	}
+	if weight >= params.PossibleMatchThreshold {
+		return api.PossibleMatch
+	}
+	return api.NonMatch
+}
+
+// RankPatientMatches weights and classifies each candidate patient entity against the given
+// patient, returning at most limit of them ordered from highest weight to lowest.
+func RankPatientMatches(
+	p *api.Patient, candidates []*api.Entity, limit uint, params *Parameters,
+) []*api.PatientMatch {
+	ms := make([]*api.PatientMatch, len(candidates))
+	for i, e := range candidates {
+		weight := params.MatchWeights.Weight(p, e.GetPatient())
+		ms[i] = &api.PatientMatch{
+			Entity:         e,
+			Weight:         weight,
+			Classification: ClassifyMatch(weight, params),
+		}
+	}
+	sort.Slice(ms, func(i, j int) bool {
+		if ms[i].Weight != ms[j].Weight {
+			return ms[i].Weight > ms[j].Weight
+		}
+		return ms[i].Entity.EntityId < ms[j].Entity.EntityId
+	})
+	if uint(len(ms)) > limit {
+		ms = ms[:limit]
+	}
+	return ms
+}
+
+type agreement int
+
+const (
+	missing agreement = iota
+	agrees
+	disagrees
+)
+
+func compareNames(n1, n2 string) agreement {
+	n1, n2 = strings.TrimSpace(n1), strings.TrimSpace(n2)
+	if n1 == "" || n2 == "" {
+		return missing
+	}
+	if strings.EqualFold(n1, n2) {
+		return agrees
+	}
+	return disagrees
+}
+
+func compareDates(d1, d2 *api.Date) agreement {
+	if d1 == nil || d2 == nil {
+		return missing
+	}
+	if proto.Equal(d1, d2) {
+		return agrees
+	}
+	return disagrees
+}
@@ -0,0 +1,73 @@
+package storage
+
+import (
+	"math"
+	"testing"
+
+	api "github.com/elixirhealth/directory/pkg/directoryapi"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewFieldWeights(t *testing.T) {
+	fw := NewFieldWeights(0.8, 0.2)
+	assert.InDelta(t, 2, fw.Agreement, 1e-9)
+	assert.InDelta(t, -2, fw.Disagreement, 1e-9)
+}
+
+func TestMatchWeights_Weight(t *testing.T) {
+	mw := NewDefaultMatchWeights()
+	p := api.NewTestPatient(1, false).GetPatient()
+	allNames := mw.LastName.Agreement + mw.FirstName.Agreement + mw.MiddleName.Agreement
+
+	// suffixes are both missing
+	assert.Equal(t, allNames+mw.Birthdate.Agreement, mw.Weight(p, p))
+
+	// names agree ignoring case and surrounding whitespace
+	other := &api.Patient{
+		LastName:   " " + p.LastName,
+		FirstName:  "FIRST NAME 1",
+		MiddleName: p.MiddleName,
+		Suffix:     "Jr",
+		Birthdate:  &api.Date{Year: 2006, Month: 1, Day: 3},
+	}
+	assert.Equal(t, allNames+mw.Birthdate.Disagreement, mw.Weight(p, other))
+
+	other = &api.Patient{LastName: "Other Last Name"}
+	assert.Equal(t, mw.LastName.Disagreement, mw.Weight(p, other))
+}
+
+func TestClassifyMatch(t *testing.T) {
+	params := NewDefaultParameters()
+	assert.Equal(t, api.Match, ClassifyMatch(params.MatchThreshold, params))
+	assert.Equal(t, api.PossibleMatch, ClassifyMatch(params.MatchThreshold-1, params))
+	assert.Equal(t, api.PossibleMatch, ClassifyMatch(params.PossibleMatchThreshold, params))
+	assert.Equal(t, api.NonMatch, ClassifyMatch(params.PossibleMatchThreshold-1, params))
+	assert.Equal(t, api.NonMatch, ClassifyMatch(math.Inf(-1), params))
+}
+
+func TestRankPatientMatches(t *testing.T) {
+	params := NewDefaultParameters()
+	p := api.NewTestPatient(1, false).GetPatient()
+	same := api.NewTestPatient(1, true)
+	same.EntityId = "entity 3"
+	sameName := api.NewTestPatient(1, true)
+	sameName.GetPatient().Birthdate = &api.Date{Year: 2006, Month: 1, Day: 3}
+	other := api.NewTestPatient(2, true)
+	candidates := []*api.Entity{other, sameName, same}
+
+	ms := RankPatientMatches(p, candidates, 8, params)
+	assert.Equal(t, 3, len(ms))
+	assert.Equal(t, same, ms[0].Entity)
+	assert.Equal(t, api.Match, ms[0].Classification)
+	assert.Equal(t, sameName, ms[1].Entity)
+	assert.Equal(t, api.PossibleMatch, ms[1].Classification)
+	assert.Equal(t, other, ms[2].Entity)
+	assert.Equal(t, api.NonMatch, ms[2].Classification)
+	for i := 1; i < len(ms); i++ {
+		assert.True(t, ms[i-1].Weight >= ms[i].Weight)
+	}
+
+	ms = RankPatientMatches(p, candidates, 1, params)
+	assert.Equal(t, 1, len(ms))
+	assert.Equal(t, same, ms[0].Entity)
+}
@@ -1,18 +1,34 @@
 package memory
 
 import (
+	api "github.com/elixirhealth/directory/pkg/directoryapi"
 	"github.com/elixirhealth/directory/pkg/server/storage"
 	"go.uber.org/zap"
 	"go.uber.org/zap/zapcore"
 )
 
 const (
-	logQuery    = "query"
-	logEntityID = "entity_id"
-	logInsert   = "insert"
-	logUpdate   = "update"
-	logLimit    = "limit"
-	logResults  = "results"
+	logQuery       = "query"
+	logEntityID    = "entity_id"
+	logInsert      = "insert"
+	logUpdate      = "update"
+	logLimit       = "limit"
+	logResults     = "results"
+	logNVersions   = "n_versions"
+	logEntityType  = "entity_type"
+	logPageToken   = "page_token"
+	logNEntities   = "n_entities"
+	logPaths       = "paths"
+	logSurvivorID  = "survivor_id"
+	logMergedID    = "merged_id"
+	logToVersion   = "to_version"
+	logRevertedBy  = "reverted_by"
+	logThreshold   = "threshold"
+	logNPairs      = "n_pairs"
+	logNCandidates = "n_candidates"
+	logNMatches    = "n_matches"
+	logPrefix      = "prefix"
+	logNSuggested  = "n_suggested"
 )
 
 func logPutResult(entityID string, insert bool) []zapcore.Field {
@@ -30,3 +46,74 @@ func logSearchRanked(query string, limit uint, ess storage.EntitySims) []zapcore
 		zap.Array(logResults, ess),
 	}
 }
+
+func logHistoryResult(entityID string, history []*api.EntityVersion) []zapcore.Field {
+	return []zapcore.Field{
+		zap.String(logEntityID, entityID),
+		zap.Int(logNVersions, len(history)),
+	}
+}
+
+func logListResult(et storage.EntityType, pageToken string, page []*api.Entity) []zapcore.Field {
+	return []zapcore.Field{
+		zap.Stringer(logEntityType, et),
+		zap.String(logPageToken, pageToken),
+		zap.Int(logNEntities, len(page)),
+	}
+}
+
+func logPatchResult(entityID string, paths []string) []zapcore.Field {
+	return []zapcore.Field{
+		zap.String(logEntityID, entityID),
+		zap.Strings(logPaths, paths),
+	}
+}
+
+func logRevertResult(entityID string, revert *api.Revert) []zapcore.Field {
+	return []zapcore.Field{
+		zap.String(logEntityID, entityID),
+		zap.Uint64(logToVersion, revert.ToVersion),
+		zap.String(logRevertedBy, revert.RevertedBy),
+	}
+}
+
+func logMergeResult(survivorID, mergedID string) []zapcore.Field {
+	return []zapcore.Field{
+		zap.String(logSurvivorID, survivorID),
+		zap.String(logMergedID, mergedID),
+	}
+}
+
+func logDuplicatesResult(
+	threshold float32, limit uint, pairs []*api.DuplicatePair,
+) []zapcore.Field {
+	return []zapcore.Field{
+		zap.Float32(logThreshold, threshold),
+		zap.Uint(logLimit, limit),
+		zap.Int(logNPairs, len(pairs)),
+	}
+}
+
+func logMatchResult(limit uint, nCandidates int, ms []*api.PatientMatch) []zapcore.Field {
+	nMatches := 0
+	for _, m := range ms {
+		if m.Classification == api.Match {
+			nMatches++
+		}
+	}
+	return []zapcore.Field{
+		zap.Uint(logLimit, limit),
+		zap.Int(logNCandidates, nCandidates),
+		zap.Int(logNMatches, nMatches),
+	}
+}
+
+func logAutocompleteResult(
+	prefix string, limit uint, suggestions []*api.Suggestion,
+) []zapcore.Field {
+	return []zapcore.Field{
+		zap.String(logPrefix, prefix),
+		zap.Uint(logLimit, limit),
+		zap.Int(logNSuggested, len(suggestions)),
+	}
+}
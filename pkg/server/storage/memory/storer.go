@@ -5,17 +5,22 @@ import (
 	"sort"
 	"strings"
 	"sync"
+	"time"
+	"unicode"
 
 	api "github.com/elixirhealth/directory/pkg/directoryapi"
 	"github.com/elixirhealth/directory/pkg/server/storage"
 	"github.com/elixirhealth/directory/pkg/server/storage/id"
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/ptypes"
+	"github.com/golang/protobuf/ptypes/timestamp"
 	"go.uber.org/zap"
 )
 
 type storer struct {
 	params *storage.Parameters
 	idGen  id.Generator
-	stored map[string]*api.Entity
+	stored map[string][]*api.EntityVersion
 	mu     sync.Mutex
 	logger *zap.Logger
 }
@@ -26,66 +31,102 @@ func New(idGen id.Generator, params *storage.Parameters, logger *zap.Logger) sto
 	return &storer{
 		params: params,
 		idGen:  idGen,
-		stored: make(map[string]*api.Entity),
+		stored: make(map[string][]*api.EntityVersion),
 		logger: logger,
 	}
 }
 
-func (s *storer) PutEntity(e *api.Entity) (string, error) {
-	insert := true
-	if e.EntityId != "" {
-		insert = false
-		if err := s.idGen.Check(e.EntityId); err != nil {
-			return "", err
-		}
-	}
-	if err := api.ValidateEntity(e); err != nil {
-		return "", err
-	}
-	if _, err := storage.MaybeAddEntityID(e, s.idGen); err != nil {
+func (s *storer) PutEntity(e *api.Entity, allowCreate bool) (string, error) {
+	insert, err := s.prepPut(e)
+	if err != nil {
 		return "", err
 	}
+	now := ptypes.TimestampNow()
 	s.mu.Lock()
-	if _, in := s.stored[e.EntityId]; in && insert {
-		return "", storage.ErrDupGenEntityID
+	defer s.mu.Unlock()
+	if err := s.put(e, insert || s.createsWithID(e, allowCreate), now); err != nil {
+		return "", err
 	}
-	s.stored[e.EntityId] = e
-	s.mu.Unlock()
-	s.logger.Debug("successfully stored entity", logPutResult(e.EntityId, insert)...)
 	return e.EntityId, nil
 }
 
-func (s *storer) GetEntity(entityID string) (*api.Entity, error) {
+func (s *storer) GetEntity(entityID string, asOf time.Time) (*api.Entity, error) {
 	if err := s.idGen.Check(entityID); err != nil {
 		return nil, err
 	}
 	s.mu.Lock()
-	e, in := s.stored[entityID]
+	v, err := s.getAsOfRedirected(entityID, asOf)
 	s.mu.Unlock()
-	if !in {
-		return nil, storage.ErrMissingEntity
+	if err != nil {
+		return nil, err
 	}
 	s.logger.Debug("successfully found entity", zap.String(logEntityID, entityID))
-	return e, nil
+	return cloneEntity(v.Entity), nil
 }
 
-func (s *storer) SearchEntity(query string, limit uint) ([]*api.Entity, error) {
-	if err := api.ValidateSearchQuery(query, uint32(limit)); err != nil {
+func (s *storer) SearchEntity(
+	query string,
+	filters *api.SearchFilters,
+	limit uint,
+	asOf time.Time,
+	pageToken string,
+	explain bool,
+) (*storage.SearchPage, error) {
+	if err := api.ValidateSearchQuery(query, filters, uint32(limit)); err != nil {
+		return nil, err
+	}
+	if err := api.ValidateSearchFilters(filters); err != nil {
+		return nil, err
+	}
+	pageKey := storage.SearchPageKey(query, filters)
+	offset, snapshot, err := storage.DecodeSearchPageToken(pageKey, pageToken, asOf)
+	if err != nil {
 		return nil, err
 	}
+	if pageToken != "" {
+		// later pages are ranked as of the first, so writes in between don't shift them
+		asOf = snapshot
+	}
+
+	// rank enough results for this page plus one more to know if there is a next page
+	depth := offset + limit + 1
 	s.mu.Lock()
 	ess := &storage.EntitySims{}
 	heap.Init(ess)
 
+	// the date in the query, if any, is matched by the birthdate searcher instead of the others,
+	// and the patient name searchers also match the names filtered on
+	text, birthdate := api.SplitQueryBirthdate(query)
+	nameText, _ := api.SplitQueryBirthdate(api.PatientNameQuery(query, filters))
+
 	// just loop through all entities once
-	for _, e := range s.stored {
-		if matches, searcher, sim := checkMatchesQuery(e, query); matches {
-			es := storage.NewEntitySim(e)
+	for _, versions := range s.stored {
+		v, err := getAsOf(versions, asOf)
+		if err != nil {
+			// skip entities deleted or not yet created
+			continue
+		}
+		e := v.Entity
+		if !filters.Matches(e) {
+			continue
+		}
+		es := storage.NewEntitySim(e, s.params.Ranking)
+		matches, searcher, sim := checkMatchesQuery(e, text, nameText, s.params.Nicknames)
+		if matches {
 			es.Add(searcher, sim)
-			if ess.Len() < int(limit) || es.Similarity() > ess.Peak().Similarity() {
+		}
+		// the birthdate only boosts entities matched by the other searchers when the query
+		// has text besides the date
+		if birthdate != nil && (nameText == "" || len(es.Similarities) > 0) {
+			if matches, sim := checkMatchesBirthdate(e, birthdate); matches {
+				es.Add(storage.PatientBirthdateSearcher, sim)
+			}
+		}
+		if len(es.Similarities) > 0 {
+			if ess.Len() < int(depth) || ess.Peak().Less(es) {
 				heap.Push(ess, es)
 			}
-			if ess.Len() > int(limit) {
+			if ess.Len() > int(depth) {
 				heap.Pop(ess)
 			}
 		}
@@ -93,42 +134,606 @@ func (s *storer) SearchEntity(query string, limit uint) ([]*api.Entity, error) {
 	s.mu.Unlock()
 
 	sort.Sort(sort.Reverse(ess)) // sort descending
-	result := make([]*api.Entity, 0, limit)
-	s.logger.Debug("ranked search results", logSearchRanked(query, limit, *ess)...)
+	ranked := make([]*api.SearchResult, 0, depth)
+	s.logger.Debug("ranked search results", logSearchRanked(query, depth, *ess)...)
+	searcherQueries := getSearcherQueries(query, filters, s.params.Nicknames)
 	for _, es := range *ess {
-		result = append(result, es.E)
+		r := es.SearchResult()
+		r.Entity = cloneEntity(r.Entity)
+		if explain {
+			r.Explanation = es.Explain(searcherQueries)
+		}
+		ranked = append(ranked, r)
+	}
+	results, nextPageToken := storage.SplitSearchPage(ranked, pageKey, snapshot, offset, limit)
+	return &storage.SearchPage{Results: results, NextPageToken: nextPageToken}, nil
+}
+
+func (s *storer) DeleteEntity(entityID string) error {
+	if err := s.idGen.Check(entityID); err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	current, err := getCurrent(s.stored[entityID])
+	if err != nil {
+		return err
+	}
+	current.ValidTo = ptypes.TimestampNow()
+	s.logger.Debug("successfully deleted entity", zap.String(logEntityID, entityID))
+	return nil
+}
+
+func (s *storer) GetEntityHistory(entityID string) ([]*api.EntityVersion, error) {
+	if err := s.idGen.Check(entityID); err != nil {
+		return nil, err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	versions, in := s.stored[entityID]
+	if !in {
+		return nil, storage.ErrMissingEntity
+	}
+	history := make([]*api.EntityVersion, len(versions))
+	for i, v := range versions {
+		history[i] = &api.EntityVersion{
+			Entity:       cloneEntity(v.Entity),
+			ValidFrom:    v.ValidFrom,
+			ValidTo:      v.ValidTo,
+			MergedIntoId: v.MergedIntoId,
+			Revert:       v.Revert,
+		}
+	}
+	s.logger.Debug("successfully found entity history", logHistoryResult(entityID, history)...)
+	return history, nil
+}
+
+func (s *storer) PutEntities(es []*api.Entity, allowCreate bool) ([]*storage.PutResult, error) {
+	if err := api.ValidateEntityBatch(es); err != nil {
+		return nil, err
+	}
+	results := make([]*storage.PutResult, len(es))
+	inserts := make([]bool, len(es))
+	for i, e := range es {
+		insert, err := s.prepPut(e)
+		results[i] = &storage.PutResult{Err: err}
+		inserts[i] = insert
+	}
+
+	// store all the valid entities under the same lock and with the same time
+	now := ptypes.TimestampNow()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i, e := range es {
+		if results[i].Err != nil {
+			continue
+		}
+		insert := inserts[i] || s.createsWithID(e, allowCreate)
+		if results[i].Err = s.put(e, insert, now); results[i].Err == nil {
+			results[i].EntityID = e.EntityId
+			results[i].Version = e.Version
+		}
+	}
+	return results, nil
+}
+
+func (s *storer) GetEntities(entityIDs []string) ([]*storage.GetResult, error) {
+	if err := api.ValidateEntityIDBatch(entityIDs); err != nil {
+		return nil, err
+	}
+	results := make([]*storage.GetResult, len(entityIDs))
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i, entityID := range entityIDs {
+		if err := s.idGen.Check(entityID); err != nil {
+			results[i] = &storage.GetResult{Err: err}
+			continue
+		}
+		current, err := s.getAsOfRedirected(entityID, time.Time{})
+		if err != nil {
+			results[i] = &storage.GetResult{Err: err}
+			continue
+		}
+		results[i] = &storage.GetResult{Entity: cloneEntity(current.Entity)}
+	}
+	return results, nil
+}
+
+func (s *storer) ListEntities(
+	et storage.EntityType, pageToken string, pageSize uint,
+) ([]*api.Entity, string, error) {
+	afterEntityID, err := storage.DecodePageToken(et, pageToken)
+	if err != nil {
+		return nil, "", err
+	}
+	if pageSize, err = storage.GetPageSize(pageSize); err != nil {
+		return nil, "", err
+	}
+	es := make([]*api.Entity, 0)
+	s.mu.Lock()
+	for entityID, versions := range s.stored {
+		if entityID <= afterEntityID || storage.GetEntityTypeFromID(entityID) != et {
+			continue
+		}
+		if current, err := getCurrent(versions); err == nil {
+			es = append(es, cloneEntity(current.Entity))
+		}
+	}
+	s.mu.Unlock()
+	sort.Slice(es, func(i, j int) bool { return es[i].EntityId < es[j].EntityId })
+	page, nextPageToken := storage.SplitPage(es, pageSize)
+	s.logger.Debug("listed entities", logListResult(et, pageToken, page)...)
+	return page, nextPageToken, nil
+}
+
+func (s *storer) PatchEntity(patch *api.Entity, paths []string) (*api.Entity, error) {
+	if patch == nil {
+		return nil, api.ErrPatchMissingEntity
+	}
+	if err := s.idGen.Check(patch.EntityId); err != nil {
+		return nil, err
+	}
+	if err := api.ValidatePatchPaths(patch, paths); err != nil {
+		return nil, err
+	}
+	now := ptypes.TimestampNow()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	current, err := getCurrent(s.stored[patch.EntityId])
+	if err != nil {
+		return nil, err
+	}
+	if patch.Version != 0 && patch.Version != current.Entity.Version {
+		return nil, storage.ErrVersionConflict
+	}
+	patched, err := api.PatchEntity(current.Entity, patch, paths)
+	if err != nil {
+		return nil, err
+	}
+	if err = api.ValidateEntity(patched); err != nil {
+		return nil, err
+	}
+	if err = s.put(patched, false, now); err != nil {
+		return nil, err
+	}
+	s.logger.Debug("successfully patched entity", logPatchResult(patched.EntityId, paths)...)
+	return patched, nil
+}
+
+func (s *storer) MergeEntities(survivorID, mergedID string) (*api.Entity, error) {
+	if err := s.idGen.Check(survivorID); err != nil {
+		return nil, err
+	}
+	if err := s.idGen.Check(mergedID); err != nil {
+		return nil, err
+	}
+	if survivorID == mergedID {
+		return nil, storage.ErrSelfMerge
+	}
+	if storage.GetEntityTypeFromID(survivorID) != storage.GetEntityTypeFromID(mergedID) {
+		return nil, storage.ErrMergeTypeMismatch
 	}
-	return result, nil
+	now := ptypes.TimestampNow()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	survivor, err := getCurrent(s.stored[survivorID])
+	if err != nil {
+		return nil, err
+	}
+	merged, err := getCurrent(s.stored[mergedID])
+	if err != nil {
+		return nil, err
+	}
+	merged.ValidTo = now
+	merged.MergedIntoId = survivorID
+	s.logger.Debug("successfully merged entities", logMergeResult(survivorID, mergedID)...)
+	return cloneEntity(survivor.Entity), nil
+}
+
+func (s *storer) RevertEntity(entityID string, revert *api.Revert) (*api.Entity, error) {
+	if err := s.idGen.Check(entityID); err != nil {
+		return nil, err
+	}
+	now := ptypes.TimestampNow()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	versions := s.stored[entityID]
+
+	// a merged entity's last version, closed by the merge, may also be reverted to
+	merged := len(versions) > 0 && versions[len(versions)-1].MergedIntoId != ""
+	current, err := getCurrent(versions)
+	if merged {
+		current = versions[len(versions)-1]
+	} else if err != nil {
+		return nil, err
+	}
+	if revert.ToVersion > current.Entity.Version ||
+		revert.ToVersion == current.Entity.Version && !merged {
+		return nil, storage.ErrInvalidRevertVersion
+	}
+	var reverted *api.Entity
+	for _, v := range versions {
+		if v.Entity.Version == revert.ToVersion {
+			reverted = proto.Clone(v.Entity).(*api.Entity)
+			break
+		}
+	}
+	if reverted == nil {
+		return nil, storage.ErrInvalidRevertVersion
+	}
+	if merged {
+		// undo the merge with a new version, leaving the version closed by the merge in the
+		// history
+		reverted.Version = current.Entity.Version + 1
+		stored := &api.EntityVersion{Entity: cloneEntity(reverted), ValidFrom: now}
+		s.stored[entityID] = append(versions, stored)
+	} else {
+		reverted.Version = 0
+		if err = s.put(reverted, false, now); err != nil {
+			return nil, err
+		}
+	}
+	versions = s.stored[entityID]
+	versions[len(versions)-1].Revert = revert
+	s.logger.Debug("successfully reverted entity", logRevertResult(entityID, revert)...)
+	return reverted, nil
+}
+
+func (s *storer) FindDuplicates(threshold float32, limit uint) ([]*api.DuplicatePair, error) {
+	s.mu.Lock()
+	patients := make([]*api.Entity, 0)
+	for entityID, versions := range s.stored {
+		if storage.GetEntityTypeFromID(entityID) != storage.Patient {
+			continue
+		}
+		if current, err := getCurrent(versions); err == nil {
+			patients = append(patients, current.Entity)
+		}
+	}
+	s.mu.Unlock()
+
+	pairs := make([]*api.DuplicatePair, 0)
+	for i, e1 := range patients {
+		for _, e2 := range patients[i+1:] {
+			p1, p2 := e1.GetPatient(), e2.GetPatient()
+			nameSim := trigramSimilarity(patientName(p1), patientName(p2))
+			if nameSim < storage.DuplicateCandidateSimilarity {
+				continue
+			}
+			birthdateMatch := p1.Birthdate != nil && proto.Equal(p1.Birthdate, p2.Birthdate)
+			pair := storage.NewDuplicatePair(e1, e2, nameSim, birthdateMatch)
+			if pair.Score >= threshold {
+				pairs = append(pairs, pair)
+			}
+		}
+	}
+	storage.SortDuplicatePairs(pairs)
+	if uint(len(pairs)) > limit {
+		pairs = pairs[:limit]
+	}
+	for _, pair := range pairs {
+		pair.Entity1, pair.Entity2 = cloneEntity(pair.Entity1), cloneEntity(pair.Entity2)
+	}
+	s.logger.Debug("found duplicates", logDuplicatesResult(threshold, limit, pairs)...)
+	return pairs, nil
+}
+
+func (s *storer) MatchPatient(p *api.Patient, limit uint) ([]*api.PatientMatch, error) {
+	name := patientName(p)
+	candidates := make([]*api.Entity, 0)
+	nameSims := make(map[string]float32)
+	s.mu.Lock()
+	for entityID, versions := range s.stored {
+		if storage.GetEntityTypeFromID(entityID) != storage.Patient {
+			continue
+		}
+		current, err := getCurrent(versions)
+		if err != nil {
+			continue
+		}
+		candidate := current.Entity.GetPatient()
+		nameSim := trigramSimilarity(name, patientName(candidate))
+		birthdateMatch := p.Birthdate != nil && proto.Equal(p.Birthdate, candidate.Birthdate)
+		if nameSim >= storage.DuplicateCandidateSimilarity || birthdateMatch {
+			candidates = append(candidates, current.Entity)
+			nameSims[entityID] = nameSim
+		}
+	}
+	s.mu.Unlock()
+	candidates = topCandidates(candidates, nameSims)
+	ms := storage.RankPatientMatches(p, candidates, limit, s.params)
+	for _, m := range ms {
+		m.Entity = cloneEntity(m.Entity)
+	}
+	s.logger.Debug("matched patient", logMatchResult(limit, len(candidates), ms)...)
+	return ms, nil
+}
+
+func (s *storer) Autocomplete(
+	prefix string, entityTypes []string, limit uint,
+) ([]*api.Suggestion, error) {
+	ets, err := storage.AutocompleteEntityTypes(entityTypes)
+	if err != nil {
+		return nil, err
+	}
+	allowed := make(map[storage.EntityType]bool, len(ets))
+	for _, et := range ets {
+		allowed[et] = true
+	}
+	foldedPrefix := api.FoldName(prefix)
+	matches := make([]*api.Entity, 0)
+	s.mu.Lock()
+	for entityID, versions := range s.stored {
+		if !allowed[storage.GetEntityTypeFromID(entityID)] {
+			continue
+		}
+		current, err := getCurrent(versions)
+		if err != nil {
+			continue
+		}
+		if storage.MatchesAutocompletePrefix(current.Entity, foldedPrefix) {
+			matches = append(matches, current.Entity)
+		}
+	}
+	s.mu.Unlock()
+	suggestions := storage.TopSuggestions(matches, limit)
+	s.logger.Debug("autocompleted", logAutocompleteResult(prefix, limit, suggestions)...)
+	return suggestions, nil
 }
 
 func (s *storer) Close() error {
 	return nil
 }
 
+// prepPut checks the entity is valid for putting, adding a new entity ID if it is missing, and
+// returns whether the entity should be inserted (vs. updated)
+func (s *storer) prepPut(e *api.Entity) (bool, error) {
+	if e == nil {
+		return false, api.ErrPutMissingEntity
+	}
+	if e.EntityId != "" {
+		if err := s.idGen.Check(e.EntityId); err != nil {
+			return false, err
+		}
+	}
+	if err := api.ValidateEntity(e); err != nil {
+		return false, err
+	}
+	return storage.MaybeAddEntityID(e, s.idGen)
+}
+
+// createsWithID returns whether the entity, whose given ID has no stored versions, should be
+// inserted with that ID rather than fail as missing; s.mu must be held by the caller
+func (s *storer) createsWithID(e *api.Entity, allowCreate bool) bool {
+	_, in := s.stored[e.EntityId]
+	return allowCreate && !in
+}
+
+// put stores a copy of the entity as a new version valid from the given time, setting e.Version to
+// the next version of the entity; s.mu must be held by the caller
+func (s *storer) put(e *api.Entity, insert bool, now *timestamp.Timestamp) error {
+	versions, in := s.stored[e.EntityId]
+	if in && insert {
+		return storage.ErrDupGenEntityID
+	}
+	if insert {
+		e.Version = 1
+	} else {
+		current, err := getCurrent(versions)
+		if err != nil {
+			return err
+		}
+		if e.Version != 0 && e.Version != current.Entity.Version {
+			return storage.ErrVersionConflict
+		}
+		current.ValidTo = now
+		e.Version = current.Entity.Version + 1
+	}
+	stored := &api.EntityVersion{Entity: cloneEntity(e), ValidFrom: now}
+	s.stored[e.EntityId] = append(versions, stored)
+	s.logger.Debug("successfully stored entity", logPutResult(e.EntityId, insert)...)
+	return nil
+}
+
+// cloneEntity returns a deep copy of the entity, so stored versions are never shared with callers
+func cloneEntity(e *api.Entity) *api.Entity {
+	return proto.Clone(e).(*api.Entity)
+}
+
+// getCurrent returns the current version from an entity's versions, or an error if the entity
+// is missing or has been deleted
+func getCurrent(versions []*api.EntityVersion) (*api.EntityVersion, error) {
+	if len(versions) == 0 {
+		return nil, storage.ErrMissingEntity
+	}
+	latest := versions[len(versions)-1]
+	if latest.ValidTo != nil {
+		return nil, storage.ErrDeletedEntity
+	}
+	return latest, nil
+}
+
+// getAsOfRedirected returns the version of the entity valid at the given time like getAsOf, but
+// if the entity had been merged into another by then, it returns the survivor's version instead;
+// s.mu must be held by the caller
+func (s *storer) getAsOfRedirected(entityID string, asOf time.Time) (*api.EntityVersion, error) {
+	redirected := make(map[string]struct{})
+	for {
+		if _, in := redirected[entityID]; in {
+			return nil, storage.ErrMergeCycle
+		}
+		redirected[entityID] = struct{}{}
+		versions := s.stored[entityID]
+		v, err := getAsOf(versions, asOf)
+		if err != storage.ErrDeletedEntity {
+			return v, err
+		}
+
+		if entityID = mergedIntoAsOf(versions, asOf); entityID == "" {
+			return nil, err
+		}
+	}
+}
+
+// mergedIntoAsOf returns the entity ID of the survivor the entity had been merged into at the
+// given time, or now if asOf is zero, which is set on the latest version created by then if it
+// was closed by the merge
+func mergedIntoAsOf(versions []*api.EntityVersion, asOf time.Time) string {
+	for i := len(versions) - 1; i >= 0; i-- {
+		if !asOf.IsZero() {
+			validFrom, err := ptypes.Timestamp(versions[i].ValidFrom)
+			if err != nil {
+				return ""
+			}
+			if validFrom.After(asOf) {
+				continue
+			}
+		}
+		return versions[i].MergedIntoId
+	}
+	return ""
+}
+
+// getAsOf returns the version valid at the given time from an entity's versions, or the current
+// version if asOf is zero
+func getAsOf(versions []*api.EntityVersion, asOf time.Time) (*api.EntityVersion, error) {
+	if asOf.IsZero() {
+		return getCurrent(versions)
+	}
+
+	// find the latest version created at or before asOf
+	for i := len(versions) - 1; i >= 0; i-- {
+		validFrom, err := ptypes.Timestamp(versions[i].ValidFrom)
+		if err != nil {
+			return nil, err
+		}
+		if validFrom.After(asOf) {
+			continue
+		}
+		if versions[i].ValidTo == nil {
+			return versions[i], nil
+		}
+		validTo, err := ptypes.Timestamp(versions[i].ValidTo)
+		if err != nil {
+			return nil, err
+		}
+		if asOf.Before(validTo) {
+			return versions[i], nil
+		}
+		return nil, storage.ErrDeletedEntity
+	}
+	return nil, storage.ErrMissingEntity
+}
+
 // checkMatchesQuery checks whether an entity matches a given query under various searcher,
-// returning the first match it finds, if any
-func checkMatchesQuery(e *api.Entity, query string) (matches bool, searcher string, sim float32) {
-	if matches, sim = matchesUpper(query, e.EntityId); matches {
-		return true, "EntityID", sim
+// returning the first match it finds, if any. The patient name searchers match the name query,
+// which also has the names filtered on, or else the query, since the names filtered on and the
+// query together may not be contained in the patient's names even when each is.
+func checkMatchesQuery(
+	e *api.Entity, query, nameQuery string, nicknames storage.Nicknames,
+) (matches bool, searcher string, sim float32) {
+	if query != "" {
+		if matches, sim = matchesUpper(query, e.EntityId); matches {
+			return true, entityIDSearcher(e), sim
+		}
 	}
 	switch ta := e.TypeAttributes.(type) {
 	case *api.Entity_Patient:
-		p := ta.Patient
-		if matches, sim = matchesUpper(query, p.LastName, p.FirstName); matches {
-			return true, "PatientName", sim
-		}
-		if matches, sim = matchesUpper(query, p.FirstName, p.LastName); matches {
-			return true, "PatientName", sim
+		matches, searcher, sim = checkMatchesPatientName(ta.Patient, nameQuery, nicknames)
+		if !matches && nameQuery != query {
+			matches, searcher, sim = checkMatchesPatientName(ta.Patient, query, nicknames)
 		}
+		return matches, searcher, sim
 	case *api.Entity_Office:
 		f := ta.Office
-		if matches, sim = matchesUpper(query, f.Name); matches {
-			return true, "OfficeName", sim
+		if query == "" {
+			break
+		}
+		if matches, sim = matchesFolded(query, f.Name); matches {
+			return true, storage.OfficeNameSearcher, sim
 		}
 	}
 	return false, "", 0
 }
 
+// entityIDSearcher returns the name of the entity ID searcher of the entity's type
+func entityIDSearcher(e *api.Entity) string {
+	switch storage.GetEntityType(e) {
+	case storage.Patient:
+		return storage.PatientEntityIDSearcher
+	case storage.Office:
+		return storage.OfficeEntityIDSearcher
+	default:
+		panic(storage.ErrUnknownEntityType)
+	}
+}
+
+// checkMatchesPatientName checks whether a patient matches a given query under the patient name
+// searchers, returning the first match it finds, if any
+func checkMatchesPatientName(
+	p *api.Patient, query string, nicknames storage.Nicknames,
+) (matches bool, searcher string, sim float32) {
+	if query == "" {
+		return false, "", 0
+	}
+	if matches, sim = matchesFolded(query, p.LastName, p.FirstName); matches {
+		return true, storage.PatientNameSearcher, sim
+	}
+	if matches, sim = matchesFolded(query, p.FirstName, p.LastName); matches {
+		return true, storage.PatientNameSearcher, sim
+	}
+	if matches, sim = matchesSynonyms(query, nicknames, p.LastName, p.FirstName); matches {
+		return true, storage.PatientNameSynonymsSearcher, sim
+	}
+	if matches, sim = matchesPhonetic(query, p.LastName, p.FirstName); matches {
+		return true, storage.PatientPhoneticNameSearcher, sim
+	}
+	return false, "", 0
+}
+
+// checkMatchesBirthdate checks whether the entity is a patient born on the query date, or on it
+// with its day and month transposed, which has a lower similarity
+func checkMatchesBirthdate(e *api.Entity, d *api.Date) (matches bool, sim float32) {
+	p := e.GetPatient()
+	if p == nil || p.Birthdate == nil {
+		return false, 0
+	}
+	if p.Birthdate.ISO8601() == d.ISO8601() {
+		return true, 1
+	}
+	if t := d.Transposed(); t != nil && p.Birthdate.ISO8601() == t.ISO8601() {
+		return true, storage.TransposedBirthdateSimilarity
+	}
+	return false, 0
+}
+
+// getSearcherQueries returns the preprocessed query for each searcher, where the entity ID
+// searcher matches against the upper-cased query without its date, if any, the name searchers
+// against the folded query without its date, the name synonyms searcher against its nickname
+// variants, and the birthdate searcher against that date. The patient name searchers' queries
+// also have the names of the (possibly nil) filters, per api.PatientNameQuery.
+func getSearcherQueries(
+	query string, filters *api.SearchFilters, nicknames storage.Nicknames,
+) map[string]string {
+	text, birthdate := api.SplitQueryBirthdate(query)
+	nameText, _ := api.SplitQueryBirthdate(api.PatientNameQuery(query, filters))
+	foldedName := api.FoldName(nameText)
+	searcherQueries := map[string]string{
+		storage.PatientEntityIDSearcher:     strings.ToUpper(text),
+		storage.OfficeEntityIDSearcher:      strings.ToUpper(text),
+		storage.PatientNameSearcher:         foldedName,
+		storage.PatientPhoneticNameSearcher: foldedName,
+		storage.OfficeNameSearcher:          api.FoldName(text),
+	}
+	if variants := nicknames.Variants(foldedName); len(variants) > 0 {
+		searcherQueries[storage.PatientNameSynonymsSearcher] = strings.Join(variants, ", ")
+	}
+	if birthdate != nil {
+		searcherQueries[storage.PatientBirthdateSearcher] = birthdate.ISO8601()
+	}
+	return searcherQueries
+}
+
 func matchesUpper(query string, vals ...string) (matches bool, sim float32) {
 	concatVals := strings.ToUpper(strings.Join(vals, " "))
 	if strings.Contains(concatVals, strings.ToUpper(query)) {
@@ -136,3 +741,108 @@ func matchesUpper(query string, vals ...string) (matches bool, sim float32) {
 	}
 	return false, 0
 }
+
+// matchesFolded checks whether the values contain the query when both are folded by
+// api.FoldName, so accents and punctuation are ignored
+func matchesFolded(query string, vals ...string) (matches bool, sim float32) {
+	foldedQuery := api.FoldName(query)
+	if foldedQuery == "" {
+		return false, 0
+	}
+	foldedVals := api.FoldName(strings.Join(vals, " "))
+	if strings.Contains(foldedVals, foldedQuery) {
+		return true, float32(len(foldedQuery)) / float32(len(foldedVals))
+	}
+	return false, 0
+}
+
+// matchesPhonetic checks whether the phonetic codes of any of the values match those of the words
+// in the query, with the similarity being the fraction of the values that match, as the Postgres
+// phonetic searcher does
+func matchesPhonetic(query string, vals ...string) (matches bool, sim float32) {
+	queryCodes := make(map[string]struct{})
+	for _, code := range storage.PhoneticCodes(query) {
+		queryCodes[code] = struct{}{}
+	}
+	nMatched := 0
+	for _, val := range vals {
+		if _, in := queryCodes[storage.PhoneticCode(val)]; in {
+			nMatched++
+		}
+	}
+	return nMatched > 0, float32(nMatched) / float32(len(vals))
+}
+
+// matchesSynonyms checks whether any of the variants of the query with its given names replaced by
+// their synonyms matches the values in either order, with the similarity being that of the best
+// matching variant
+func matchesSynonyms(
+	query string, nicknames storage.Nicknames, vals ...string,
+) (matches bool, sim float32) {
+	reversed := make([]string, len(vals))
+	for i, val := range vals {
+		reversed[len(vals)-1-i] = val
+	}
+	for _, variant := range nicknames.Variants(api.FoldName(query)) {
+		for _, ordered := range [][]string{vals, reversed} {
+			if m, s := matchesFolded(variant, ordered...); m && s > sim {
+				matches, sim = true, s
+			}
+		}
+	}
+	return matches, sim
+}
+
+// topCandidates returns the first storage.MaxMatchCandidates match candidates ordered by their
+// name similarities and then by entity ID, like the Postgres match candidates query
+func topCandidates(candidates []*api.Entity, nameSims map[string]float32) []*api.Entity {
+	sort.Slice(candidates, func(i, j int) bool {
+		si, sj := nameSims[candidates[i].EntityId], nameSims[candidates[j].EntityId]
+		if si != sj {
+			return si > sj
+		}
+		return candidates[i].EntityId < candidates[j].EntityId
+	})
+	if len(candidates) > storage.MaxMatchCandidates {
+		return candidates[:storage.MaxMatchCandidates]
+	}
+	return candidates
+}
+
+// patientName returns the folded name of the patient that is compared when finding duplicates,
+// which mirrors the expression of the Postgres patient name search trigram index
+func patientName(p *api.Patient) string {
+	return api.FoldName(p.LastName) + " " + api.FoldName(p.FirstName)
+}
+
+// trigramSimilarity returns the similarity of two strings as the number of trigrams they share
+// divided by the number of distinct trigrams in either, following the pg_trgm similarity function
+func trigramSimilarity(a, b string) float32 {
+	aTrgms, bTrgms := trigrams(a), trigrams(b)
+	if len(aTrgms) == 0 || len(bTrgms) == 0 {
+		return 0
+	}
+	shared := 0
+	for t := range aTrgms {
+		if _, in := bTrgms[t]; in {
+			shared++
+		}
+	}
+	return float32(shared) / float32(len(aTrgms)+len(bTrgms)-shared)
+}
+
+// trigrams returns the set of trigrams in the lower-cased words of s, where each word is prefixed
+// by two spaces and suffixed by one, as pg_trgm does
+func trigrams(s string) map[string]struct{} {
+	words := strings.FieldsFunc(strings.ToLower(s), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	trgms := make(map[string]struct{})
+	for _, word := range words {
+		padded := []rune("  " + word + " ")
+		for i := 0; i+3 <= len(padded); i++ {
+			trgms[string(padded[i:i+3])] = struct{}{}
+		}
+	}
+	return trgms
+}
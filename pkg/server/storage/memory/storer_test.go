@@ -3,13 +3,16 @@ package memory
 import (
 	"errors"
 	"math/rand"
+	"sort"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/drausin/libri/libri/common/logging"
 	api "github.com/elixirhealth/directory/pkg/directoryapi"
 	"github.com/elixirhealth/directory/pkg/server/storage"
 	"github.com/elixirhealth/directory/pkg/server/storage/id"
+	"github.com/golang/protobuf/ptypes"
 	"github.com/stretchr/testify/assert"
 	"go.uber.org/zap"
 	"go.uber.org/zap/zapcore"
@@ -54,28 +57,58 @@ func TestStorer_PutGetEntity_ok(t *testing.T) {
 		assert.Equal(t, et, storage.GetEntityType(c.original), et.String())
 		assert.NotEqual(t, c.original, c.updated)
 
-		entityID, err := s.PutEntity(c.original)
+		entityID, err := s.PutEntity(c.original, false)
 		assert.Nil(t, err, et.String())
 		assert.Equal(t, entityID, c.original.EntityId, et.String())
+		assert.Equal(t, uint64(1), c.original.Version, et.String())
 	}
 
 	for et, c := range cases {
 		entityID := c.original.EntityId
-		gottenOriginal, err := s.GetEntity(entityID)
+		gottenOriginal, err := s.GetEntity(entityID, time.Time{})
 		assert.Nil(t, err, et.String())
 		assert.Equal(t, c.original, gottenOriginal)
 
 		c.updated.EntityId = c.original.EntityId
-		entityID, err = s.PutEntity(c.updated)
+		c.updated.Version = c.original.Version
+		entityID, err = s.PutEntity(c.updated, false)
 		assert.Nil(t, err)
 		assert.Equal(t, entityID, c.updated.EntityId)
+		assert.Equal(t, uint64(2), c.updated.Version)
 
-		gottenUpdated, err := s.GetEntity(entityID)
+		gottenUpdated, err := s.GetEntity(entityID, time.Time{})
 		assert.Nil(t, err)
 		assert.Equal(t, c.updated, gottenUpdated)
 	}
 }
 
+func TestStorer_PutGetEntity_copies(t *testing.T) {
+	rng := rand.New(rand.NewSource(0))
+	idGen := id.NewNaiveLuhnGenerator(rng, id.DefaultLength)
+	s := New(idGen, storage.NewDefaultParameters(), zap.NewNop())
+	e := api.NewPatient("", &api.Patient{
+		LastName:  "Last Name 1",
+		FirstName: "First Name 1",
+		Birthdate: &api.Date{Year: 2006, Month: 1, Day: 2},
+	})
+	entityID, err := s.PutEntity(e, false)
+	assert.Nil(t, err)
+
+	// changing the put entity doesn't change the stored one
+	e.GetPatient().LastName = "Last Name 2"
+	gotten, err := s.GetEntity(entityID, time.Time{})
+	assert.Nil(t, err)
+	assert.Equal(t, "Last Name 1", gotten.GetPatient().LastName)
+
+	// nor does changing the gotten entity
+	gotten.GetPatient().LastName = "Last Name 3"
+	gotten.Version = 3
+	gottenAgain, err := s.GetEntity(entityID, time.Time{})
+	assert.Nil(t, err)
+	assert.Equal(t, "Last Name 1", gottenAgain.GetPatient().LastName)
+	assert.Equal(t, uint64(1), gottenAgain.Version)
+}
+
 func TestStorer_PutEntity_err(t *testing.T) {
 	rng := rand.New(rand.NewSource(0))
 	lg := zap.NewNop()
@@ -109,7 +142,7 @@ func TestStorer_PutEntity_err(t *testing.T) {
 	}
 
 	for desc, c := range cases {
-		entityID, err2 := c.s.PutEntity(c.e)
+		entityID, err2 := c.s.PutEntity(c.e, false)
 		assert.NotNil(t, err2, desc)
 		assert.Empty(t, entityID, desc)
 	}
@@ -118,11 +151,84 @@ func TestStorer_PutEntity_err(t *testing.T) {
 	s := New(&fixedIDGen{generateID: okID}, storage.NewDefaultParameters(), lg)
 
 	okEntity.EntityId = ""
-	_, err = s.PutEntity(okEntity)
+	_, err = s.PutEntity(okEntity, false)
 	assert.Nil(t, err)
 	okEntity.EntityId = ""
-	_, err = s.PutEntity(okEntity)
+	_, err = s.PutEntity(okEntity, false)
 	assert.Equal(t, storage.ErrDupGenEntityID, err)
+
+	// update with stale version
+	updated1, updated2 := api.NewTestPatient(1, false), api.NewTestPatient(2, false)
+	updated1.EntityId, updated2.EntityId = okID, okID
+	updated1.Version, updated2.Version = 1, 1
+	_, err = s.PutEntity(updated1, false)
+	assert.Nil(t, err)
+	_, err = s.PutEntity(updated2, false)
+	assert.Equal(t, storage.ErrVersionConflict, err)
+
+	// update of missing entity
+	missingID, err := okIDGen.Generate(storage.Patient.IDPrefix())
+	assert.Nil(t, err)
+	okEntity.EntityId = missingID
+	_, err = s.PutEntity(okEntity, false)
+	assert.Equal(t, storage.ErrMissingEntity, err)
+
+	// update of deleted entity
+	okEntity.EntityId = okID
+	err = s.DeleteEntity(okID)
+	assert.Nil(t, err)
+	_, err = s.PutEntity(okEntity, false)
+	assert.Equal(t, storage.ErrDeletedEntity, err)
+}
+
+func TestStorer_PutEntity_allowCreate(t *testing.T) {
+	rng := rand.New(rand.NewSource(0))
+	idGen := id.NewNaiveLuhnGenerator(rng, id.DefaultLength)
+	s := New(idGen, storage.NewDefaultParameters(), zap.NewNop())
+	legacyID, err := idGen.Generate(storage.Patient.IDPrefix())
+	assert.Nil(t, err)
+
+	// unknown entity ID without allowCreate
+	e := api.NewTestPatient(1, false)
+	e.EntityId = legacyID
+	_, err = s.PutEntity(e, false)
+	assert.Equal(t, storage.ErrMissingEntity, err)
+
+	// unknown entity ID with allowCreate
+	entityID, err := s.PutEntity(e, true)
+	assert.Nil(t, err)
+	assert.Equal(t, legacyID, entityID)
+	assert.Equal(t, uint64(1), e.Version)
+	gotten, err := s.GetEntity(legacyID, time.Time{})
+	assert.Nil(t, err)
+	assert.Equal(t, e, gotten)
+
+	// existing entity ID with allowCreate updates
+	updated := api.NewTestPatient(2, false)
+	updated.EntityId = legacyID
+	_, err = s.PutEntity(updated, true)
+	assert.Nil(t, err)
+	assert.Equal(t, uint64(2), updated.Version)
+
+	// deleted entity ID with allowCreate
+	err = s.DeleteEntity(legacyID)
+	assert.Nil(t, err)
+	_, err = s.PutEntity(updated, true)
+	assert.Equal(t, storage.ErrDeletedEntity, err)
+
+	// batch with unknown entity ID
+	legacyID2, err := idGen.Generate(storage.Office.IDPrefix())
+	assert.Nil(t, err)
+	e2 := api.NewTestOffice(1, false)
+	e2.EntityId = legacyID2
+	results, err := s.PutEntities([]*api.Entity{e2}, false)
+	assert.Nil(t, err)
+	assert.Equal(t, storage.ErrMissingEntity, results[0].Err)
+	results, err = s.PutEntities([]*api.Entity{e2}, true)
+	assert.Nil(t, err)
+	assert.Nil(t, results[0].Err)
+	assert.Equal(t, legacyID2, results[0].EntityID)
+	assert.Equal(t, uint64(1), results[0].Version)
 }
 
 func TestStorer_GetEntity_err(t *testing.T) {
@@ -133,16 +239,28 @@ func TestStorer_GetEntity_err(t *testing.T) {
 	assert.NotNil(t, s)
 
 	// bad ID
-	e, err := s.GetEntity("bad ID")
+	e, err := s.GetEntity("bad ID", time.Time{})
 	assert.NotNil(t, err)
 	assert.Nil(t, e)
 
 	// missing ID
 	missingID, err := idGen.Generate(storage.Patient.IDPrefix())
 	assert.Nil(t, err)
-	e, err = s.GetEntity(missingID)
+	e, err = s.GetEntity(missingID, time.Time{})
 	assert.Equal(t, storage.ErrMissingEntity, err)
 	assert.Nil(t, e)
+
+	// merged entities whose survivors lead back to them
+	entityID1, err := s.PutEntity(api.NewTestPatient(1, false), false)
+	assert.Nil(t, err)
+	entityID2, err := s.PutEntity(api.NewTestPatient(2, false), false)
+	assert.Nil(t, err)
+	v1, v2 := s.(*storer).stored[entityID1][0], s.(*storer).stored[entityID2][0]
+	v1.ValidTo, v1.MergedIntoId = ptypes.TimestampNow(), entityID2
+	v2.ValidTo, v2.MergedIntoId = ptypes.TimestampNow(), entityID1
+	e, err = s.GetEntity(entityID1, time.Time{})
+	assert.Equal(t, storage.ErrMergeCycle, err)
+	assert.Nil(t, e)
 }
 
 func TestStorer_SearchEntity_ok(t *testing.T) {
@@ -164,7 +282,7 @@ func TestStorer_SearchEntity_ok(t *testing.T) {
 	}
 	entityIDs := make([]string, len(es))
 	for i, e := range es {
-		entityID, err2 := s.PutEntity(e)
+		entityID, err2 := s.PutEntity(e, false)
 		assert.Nil(t, err2)
 		entityIDs[i] = entityID
 	}
@@ -172,25 +290,274 @@ func TestStorer_SearchEntity_ok(t *testing.T) {
 	limit := uint(3)
 
 	query := "ice name 1" // query unanchored substring with diff case
-	found, err := s.SearchEntity(query, limit)
+	searched, err := s.SearchEntity(query, nil, limit, time.Time{}, "", false)
 	assert.Nil(t, err)
+	found := searched.Results
 	assert.Equal(t, 1, len(found))
 
 	// check that first result is the office with the name that matches the query
-	f, ok := found[0].TypeAttributes.(*api.Entity_Office)
+	f, ok := found[0].Entity.TypeAttributes.(*api.Entity_Office)
 	assert.True(t, ok)
 	assert.True(t, strings.Contains(strings.ToUpper(f.Office.Name), strings.ToUpper(query)))
+	assert.True(t, found[0].Similarity > 0)
+	assert.InDelta(t, found[0].Similarity, found[0].SearcherSimilarities["OfficeName"], 1e-6)
 
 	// query 2nd patient's first 4 chars of entityID diff case
 	query = strings.ToLower(entityIDs[1][:4])
-	found, err = s.SearchEntity(query, limit)
+	searched, err = s.SearchEntity(query, nil, limit, time.Time{}, "", false)
 	assert.Nil(t, err)
+	found = searched.Results
 	assert.Equal(t, 1, len(found))
 
 	// check that first result is the patient with an entityID that matches the query
-	_, ok = found[0].TypeAttributes.(*api.Entity_Patient)
+	_, ok = found[0].Entity.TypeAttributes.(*api.Entity_Patient)
 	assert.True(t, ok)
-	assert.True(t, strings.HasPrefix(found[0].EntityId, strings.ToUpper(query)))
+	assert.True(t, strings.HasPrefix(found[0].Entity.EntityId, strings.ToUpper(query)))
+	assert.Nil(t, found[0].Explanation)
+
+	// check that explaining includes the matching searcher and its preprocessed query
+	searched, err = s.SearchEntity(query, nil, limit, time.Time{}, "", true)
+	assert.Nil(t, err)
+	explanation := searched.Results[0].Explanation
+	assert.Equal(t, 1, len(explanation.Matches))
+	assert.Equal(t, storage.PatientEntityIDSearcher, explanation.Matches[0].Searcher)
+	assert.Equal(t, strings.ToUpper(query), explanation.Matches[0].Query)
+	assert.Empty(t, searched.TimedOutSearchers)
+}
+
+func TestStorer_SearchEntity_filters(t *testing.T) {
+	rng := rand.New(rand.NewSource(0))
+	idGen := id.NewNaiveLuhnGenerator(rng, id.DefaultLength)
+	s := New(idGen, storage.NewDefaultParameters(), zap.NewNop())
+	for i := 1; i <= 4; i++ {
+		_, err := s.PutEntity(api.NewTestPatient(i, false), false)
+		assert.Nil(t, err)
+		_, err = s.PutEntity(api.NewTestOffice(i, false), false)
+		assert.Nil(t, err)
+	}
+	query := "name"
+
+	cases := map[string]struct {
+		filters  *api.SearchFilters
+		expected []string
+	}{
+		"entity types": {
+			filters: &api.SearchFilters{EntityTypes: []string{api.OfficeType}},
+			expected: []string{"Office Name 1", "Office Name 2", "Office Name 3",
+				"Office Name 4"},
+		},
+		"birthdate": {
+			filters:  &api.SearchFilters{Birthdate: &api.Date{Year: 2006, Month: 1, Day: 3}},
+			expected: []string{"First Name 2 Last Name 2"},
+		},
+		"birthdate range": {
+			filters: &api.SearchFilters{
+				BirthdateFrom: &api.Date{Year: 2006, Month: 1, Day: 3},
+				BirthdateTo:   &api.Date{Year: 2006, Month: 1, Day: 4},
+			},
+			expected: []string{"First Name 2 Last Name 2", "First Name 3 Last Name 3"},
+		},
+		"last name prefix": {
+			filters:  &api.SearchFilters{LastNamePrefix: "last name 4"},
+			expected: []string{"First Name 4 Last Name 4"},
+		},
+		"suffix": {
+			filters:  &api.SearchFilters{Suffix: "Jr"},
+			expected: []string{},
+		},
+		"office name": {
+			filters:  &api.SearchFilters{OfficeName: "office name 1"},
+			expected: []string{"Office Name 1"},
+		},
+	}
+	for desc, c := range cases {
+		searched, err := s.SearchEntity(query, c.filters, api.MaxSearchLimit, time.Time{}, "",
+			false)
+		assert.Nil(t, err, desc)
+		names := make([]string, len(searched.Results))
+		for i, r := range searched.Results {
+			names[i] = r.Entity.Name()
+		}
+		sort.Strings(names)
+		assert.Equal(t, c.expected, names, desc)
+	}
+
+	// page tokens are bound to the filters
+	filters := &api.SearchFilters{EntityTypes: []string{api.PatientType}}
+	searched, err := s.SearchEntity(query, filters, 2, time.Time{}, "", false)
+	assert.Nil(t, err)
+	assert.NotEmpty(t, searched.NextPageToken)
+	_, err = s.SearchEntity(query, nil, 2, time.Time{}, searched.NextPageToken, false)
+	assert.Equal(t, storage.ErrInvalidPageToken, err)
+
+	// invalid filters
+	filters = &api.SearchFilters{EntityTypes: []string{"SOME TYPE"}}
+	_, err = s.SearchEntity(query, filters, 2, time.Time{}, "", false)
+	assert.Equal(t, api.ErrSearchUnknownEntityType, err)
+
+	// short query with names filtered on, which the patient name searchers also search
+	filters = &api.SearchFilters{LastNamePrefix: "last name 3"}
+	searched, err = s.SearchEntity("fi", filters, 2, time.Time{}, "", true)
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(searched.Results))
+	assert.Equal(t, "First Name 3 Last Name 3", searched.Results[0].Entity.Name())
+	assert.Equal(t, "LAST NAME 3 FI", searched.Results[0].Explanation.Matches[0].Query)
+}
+
+func TestStorer_SearchEntity_birthdate(t *testing.T) {
+	rng := rand.New(rand.NewSource(0))
+	idGen := id.NewNaiveLuhnGenerator(rng, id.DefaultLength)
+	s := New(idGen, storage.NewDefaultParameters(), zap.NewNop())
+	patients := []*api.Patient{
+		{LastName: "Garcia", FirstName: "Maria", Birthdate: &api.Date{Year: 1975, Month: 3, Day: 4}},
+		{LastName: "Garcia", FirstName: "Jose", Birthdate: &api.Date{Year: 1975, Month: 4, Day: 3}},
+		{LastName: "Garcia", FirstName: "Ana", Birthdate: &api.Date{Year: 1980, Month: 1, Day: 1}},
+		{LastName: "Smith", FirstName: "John", Birthdate: &api.Date{Year: 1975, Month: 3, Day: 4}},
+	}
+	for _, p := range patients {
+		_, err := s.PutEntity(api.NewPatient("", p), false)
+		assert.Nil(t, err)
+	}
+
+	// exact birthdate match ranks above transposed match, which ranks above name-only match,
+	// and the birthdate alone does not match
+	searched, err := s.SearchEntity("garcia 03/04/1975", nil, api.MaxSearchLimit,
+		time.Time{}, "", true)
+	assert.Nil(t, err)
+	names := make([]string, len(searched.Results))
+	for i, r := range searched.Results {
+		names[i] = r.Entity.Name()
+	}
+	assert.Equal(t, []string{"Maria Garcia", "Jose Garcia", "Ana Garcia"}, names)
+	assert.Equal(t, float32(1),
+		searched.Results[0].SearcherSimilarities[storage.PatientBirthdateSearcher])
+	assert.Equal(t, float32(storage.TransposedBirthdateSimilarity),
+		searched.Results[1].SearcherSimilarities[storage.PatientBirthdateSearcher])
+	_, in := searched.Results[2].SearcherSimilarities[storage.PatientBirthdateSearcher]
+	assert.False(t, in)
+	explanation := searched.Results[0].Explanation
+	assert.Equal(t, 2, len(explanation.Matches))
+	assert.Equal(t, "1975-03-04", explanation.Matches[0].Query)
+	assert.Equal(t, "GARCIA", explanation.Matches[1].Query)
+
+	// date-only query
+	searched, err = s.SearchEntity("1975-03-04", nil, api.MaxSearchLimit, time.Time{}, "",
+		false)
+	assert.Nil(t, err)
+	assert.Equal(t, 3, len(searched.Results))
+}
+
+func TestStorer_SearchEntity_phonetic(t *testing.T) {
+	rng := rand.New(rand.NewSource(0))
+	idGen := id.NewNaiveLuhnGenerator(rng, id.DefaultLength)
+	s := New(idGen, storage.NewDefaultParameters(), zap.NewNop())
+	_, err := s.PutEntity(api.NewPatient("", &api.Patient{
+		LastName:  "Thompson",
+		FirstName: "Katherine",
+		Birthdate: &api.Date{Year: 1975, Month: 3, Day: 4},
+	}), false)
+	assert.Nil(t, err)
+	_, err = s.PutEntity(api.NewTestPatient(1, false), false)
+	assert.Nil(t, err)
+
+	searched, err := s.SearchEntity("tomson cathryn", nil, api.MaxSearchLimit, time.Time{},
+		"", false)
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(searched.Results))
+	assert.Equal(t, "Katherine Thompson", searched.Results[0].Entity.Name())
+	assert.Equal(t, float32(1),
+		searched.Results[0].SearcherSimilarities[storage.PatientPhoneticNameSearcher])
+}
+
+func TestStorer_SearchEntity_folded(t *testing.T) {
+	rng := rand.New(rand.NewSource(0))
+	idGen := id.NewNaiveLuhnGenerator(rng, id.DefaultLength)
+	s := New(idGen, storage.NewDefaultParameters(), zap.NewNop())
+	_, err := s.PutEntity(api.NewPatient("", &api.Patient{
+		LastName:  "Muñoz-O'Brien",
+		FirstName: "Zoë",
+		Birthdate: &api.Date{Year: 1975, Month: 3, Day: 4},
+	}), false)
+	assert.Nil(t, err)
+	_, err = s.PutEntity(api.NewTestPatient(1, false), false)
+	assert.Nil(t, err)
+
+	for _, query := range []string{"munoz obrien zoe", "Muñoz-O'Brien Zoë"} {
+		searched, err := s.SearchEntity(query, nil, api.MaxSearchLimit, time.Time{}, "",
+			false)
+		assert.Nil(t, err)
+		assert.Equal(t, 1, len(searched.Results), query)
+		assert.Equal(t, float32(1), searched.Results[0].SearcherSimilarities[storage.PatientNameSearcher],
+			query)
+	}
+
+	filters := &api.SearchFilters{LastNamePrefix: "munoz"}
+	searched, err := s.SearchEntity("zoe obrien", filters, api.MaxSearchLimit, time.Time{}, "",
+		false)
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(searched.Results))
+}
+
+func TestStorer_SearchEntity_nicknames(t *testing.T) {
+	rng := rand.New(rand.NewSource(0))
+	idGen := id.NewNaiveLuhnGenerator(rng, id.DefaultLength)
+	s := New(idGen, storage.NewDefaultParameters(), zap.NewNop())
+	_, err := s.PutEntity(api.NewPatient("", &api.Patient{
+		LastName:  "Jones",
+		FirstName: "Robert",
+		Birthdate: &api.Date{Year: 1975, Month: 3, Day: 4},
+	}), false)
+	assert.Nil(t, err)
+	_, err = s.PutEntity(api.NewTestPatient(1, false), false)
+	assert.Nil(t, err)
+
+	searched, err := s.SearchEntity("bob jones", nil, api.MaxSearchLimit, time.Time{}, "",
+		true)
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(searched.Results))
+	assert.Equal(t, "Robert Jones", searched.Results[0].Entity.Name())
+	assert.Equal(t, float32(1),
+		searched.Results[0].SearcherSimilarities[storage.PatientNameSynonymsSearcher])
+	assert.Contains(t, searched.Results[0].Explanation.Matches[0].Query, "ROBERT JONES")
+}
+
+func TestStorer_SearchEntity_pages(t *testing.T) {
+	rng := rand.New(rand.NewSource(0))
+	idGen := id.NewNaiveLuhnGenerator(rng, id.DefaultLength)
+	lg := zap.NewNop()
+	s := New(idGen, storage.NewDefaultParameters(), lg)
+
+	// all offices have the same similarity to the query, so their order depends on entity ID
+	nOffices := 20
+	for i := 0; i < nOffices; i++ {
+		_, err := s.PutEntity(api.NewOffice("", &api.Office{Name: "Same Office Name"}), false)
+		assert.Nil(t, err)
+	}
+	query, limit := "office name", uint(8)
+	searched, err := s.SearchEntity(query, nil, limit, time.Time{}, "", false)
+	assert.Nil(t, err)
+	first := searched.Results
+
+	found, pageToken := make([]*api.SearchResult, 0), ""
+	for nPages := 1; ; nPages++ {
+		searched, err = s.SearchEntity(query, nil, limit, time.Time{}, pageToken, false)
+		assert.Nil(t, err)
+		found = append(found, searched.Results...)
+		if searched.NextPageToken == "" {
+			assert.Equal(t, 3, nPages)
+			break
+		}
+		pageToken = searched.NextPageToken
+
+		// offices stored after the first page don't shift the later ones
+		_, err = s.PutEntity(api.NewOffice("", &api.Office{Name: "Same Office Name"}), false)
+		assert.Nil(t, err)
+	}
+	assert.Equal(t, nOffices, len(found))
+	assert.Equal(t, first, found[:limit])
+	for i := 1; i < len(found); i++ {
+		assert.True(t, found[i-1].Entity.EntityId < found[i].Entity.EntityId)
+	}
 }
 
 func TestStorer_SearchEntity_err(t *testing.T) {
@@ -208,6 +575,7 @@ func TestStorer_SearchEntity_err(t *testing.T) {
 		getStorer func() storage.Storer
 		query     string
 		limit     uint
+		pageToken string
 		expected  error
 	}{
 		"query too short": {
@@ -234,16 +602,945 @@ func TestStorer_SearchEntity_err(t *testing.T) {
 			limit:     9,
 			expected:  api.ErrSearchLimitTooLarge,
 		},
+		"page token for other query": {
+			getStorer: func() storage.Storer { return okStorer },
+			query:     okQuery,
+			limit:     okLimit,
+			pageToken: storage.EncodeSearchPageToken("other query", time.Now(), okLimit),
+			expected:  storage.ErrInvalidPageToken,
+		},
 	}
 
 	for desc, c := range cases {
 		s := c.getStorer()
-		result, err := s.SearchEntity(c.query, c.limit)
+		result, err := s.SearchEntity(c.query, nil, c.limit, time.Time{}, c.pageToken, false)
 		assert.Equal(t, c.expected, err, desc)
 		assert.Nil(t, result, desc)
 	}
 }
 
+func TestStorer_DeleteEntity_ok(t *testing.T) {
+	rng := rand.New(rand.NewSource(0))
+	idGen := id.NewNaiveLuhnGenerator(rng, id.DefaultLength)
+	lg := logging.NewDevLogger(zapcore.DebugLevel)
+	s := New(idGen, storage.NewDefaultParameters(), lg)
+	assert.NotNil(t, s)
+
+	es := []*api.Entity{
+		api.NewTestPatient(1, false),
+		api.NewTestOffice(1, false),
+	}
+	for _, e := range es {
+		entityID, err := s.PutEntity(e, false)
+		assert.Nil(t, err)
+
+		err = s.DeleteEntity(entityID)
+		assert.Nil(t, err)
+
+		// deleted entity no longer gettable
+		gotten, err := s.GetEntity(entityID, time.Time{})
+		assert.Equal(t, storage.ErrDeletedEntity, err)
+		assert.Nil(t, gotten)
+
+		// deleted entity no longer searchable
+		searched, err := s.SearchEntity(entityID[:4], nil, 8, time.Time{}, "", false)
+		assert.Nil(t, err)
+		found := searched.Results
+		assert.Empty(t, found)
+	}
+}
+
+func TestStorer_DeleteEntity_err(t *testing.T) {
+	rng := rand.New(rand.NewSource(0))
+	idGen := id.NewNaiveLuhnGenerator(rng, id.DefaultLength)
+	lg := zap.NewNop()
+	s := New(idGen, storage.NewDefaultParameters(), lg)
+	assert.NotNil(t, s)
+
+	// bad ID
+	err := s.DeleteEntity("bad ID")
+	assert.NotNil(t, err)
+
+	// missing ID
+	missingID, err := idGen.Generate(storage.Patient.IDPrefix())
+	assert.Nil(t, err)
+	err = s.DeleteEntity(missingID)
+	assert.Equal(t, storage.ErrMissingEntity, err)
+
+	// already deleted
+	entityID, err := s.PutEntity(api.NewTestPatient(0, false), false)
+	assert.Nil(t, err)
+	err = s.DeleteEntity(entityID)
+	assert.Nil(t, err)
+	err = s.DeleteEntity(entityID)
+	assert.Equal(t, storage.ErrDeletedEntity, err)
+}
+
+func TestStorer_PatchEntity_ok(t *testing.T) {
+	rng := rand.New(rand.NewSource(0))
+	idGen := id.NewNaiveLuhnGenerator(rng, id.DefaultLength)
+	lg := zap.NewNop()
+	s := New(idGen, storage.NewDefaultParameters(), lg)
+	assert.NotNil(t, s)
+
+	original := api.NewTestPatient(1, false)
+	entityID, err := s.PutEntity(original, false)
+	assert.Nil(t, err)
+
+	patch := api.NewPatient(entityID, &api.Patient{LastName: "New Last Name"})
+	patched, err := s.PatchEntity(patch, []string{"patient.last_name"})
+	assert.Nil(t, err)
+	assert.Equal(t, entityID, patched.EntityId)
+	pp := patched.TypeAttributes.(*api.Entity_Patient).Patient
+	assert.Equal(t, "New Last Name", pp.LastName)
+	assert.Equal(t, uint64(2), patched.Version)
+
+	// unpatched fields keep their values
+	op := original.TypeAttributes.(*api.Entity_Patient).Patient
+	assert.Equal(t, op.FirstName, pp.FirstName)
+	assert.Equal(t, op.MiddleName, pp.MiddleName)
+	assert.Equal(t, op.Birthdate, pp.Birthdate)
+
+	gotten, err := s.GetEntity(entityID, time.Time{})
+	assert.Nil(t, err)
+	assert.Equal(t, patched, gotten)
+
+	// previous version preserved in history
+	history, err := s.GetEntityHistory(entityID)
+	assert.Nil(t, err)
+	assert.Equal(t, 2, len(history))
+	assert.Equal(t, original, history[0].Entity)
+	assert.Equal(t, patched, history[1].Entity)
+}
+
+func TestStorer_PatchEntity_err(t *testing.T) {
+	rng := rand.New(rand.NewSource(0))
+	idGen := id.NewNaiveLuhnGenerator(rng, id.DefaultLength)
+	lg := zap.NewNop()
+	s := New(idGen, storage.NewDefaultParameters(), lg)
+	assert.NotNil(t, s)
+
+	entityID, err := s.PutEntity(api.NewTestPatient(1, false), false)
+	assert.Nil(t, err)
+	missingID, err := idGen.Generate(storage.Patient.IDPrefix())
+	assert.Nil(t, err)
+	okPaths := []string{"patient.last_name"}
+
+	cases := map[string]struct {
+		patch    *api.Entity
+		paths    []string
+		expected error
+	}{
+		"missing patch": {
+			patch:    nil,
+			paths:    okPaths,
+			expected: api.ErrPatchMissingEntity,
+		},
+		"invalid path": {
+			patch:    api.NewPatient(entityID, &api.Patient{}),
+			paths:    []string{"office.name"},
+			expected: api.ErrPatchInvalidPath,
+		},
+		"missing entity": {
+			patch:    api.NewPatient(missingID, &api.Patient{LastName: "Last Name"}),
+			paths:    okPaths,
+			expected: storage.ErrMissingEntity,
+		},
+		"patched entity invalid": {
+			patch:    api.NewPatient(entityID, &api.Patient{}),
+			paths:    okPaths,
+			expected: api.ErrPatientMissingLastName,
+		},
+		"stale version": {
+			patch: &api.Entity{
+				EntityId: entityID,
+				TypeAttributes: &api.Entity_Patient{
+					Patient: &api.Patient{LastName: "Last Name"},
+				},
+				Version: 2,
+			},
+			paths:    okPaths,
+			expected: storage.ErrVersionConflict,
+		},
+	}
+
+	for desc, c := range cases {
+		patched, err := s.PatchEntity(c.patch, c.paths)
+		assert.Equal(t, c.expected, err, desc)
+		assert.Nil(t, patched, desc)
+	}
+
+	// invalid patch leaves the entity unchanged
+	history, err := s.GetEntityHistory(entityID)
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(history))
+}
+
+func TestStorer_MergeEntities_ok(t *testing.T) {
+	rng := rand.New(rand.NewSource(0))
+	idGen := id.NewNaiveLuhnGenerator(rng, id.DefaultLength)
+	s := New(idGen, storage.NewDefaultParameters(), zap.NewNop())
+
+	survivor, merged := api.NewTestPatient(1, false), api.NewTestPatient(2, false)
+	survivorID, err := s.PutEntity(survivor, false)
+	assert.Nil(t, err)
+	mergedID, err := s.PutEntity(merged, false)
+	assert.Nil(t, err)
+
+	gotten, err := s.MergeEntities(survivorID, mergedID)
+	assert.Nil(t, err)
+	assert.Equal(t, survivor, gotten)
+
+	// merged entity redirects to survivor
+	gotten, err = s.GetEntity(mergedID, time.Time{})
+	assert.Nil(t, err)
+	assert.Equal(t, survivor, gotten)
+
+	// merge is kept in history
+	history, err := s.GetEntityHistory(mergedID)
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(history))
+	assert.Equal(t, merged, history[0].Entity)
+	assert.NotNil(t, history[0].ValidTo)
+	assert.Equal(t, survivorID, history[0].MergedIntoId)
+
+	// merged entity as of before the merge
+	mergedFrom, err := ptypes.Timestamp(history[0].ValidFrom)
+	assert.Nil(t, err)
+	gotten, err = s.GetEntity(mergedID, mergedFrom)
+	assert.Nil(t, err)
+	assert.Equal(t, merged, gotten)
+
+	// merged entity can't be updated
+	_, err = s.PutEntity(merged, false)
+	assert.Equal(t, storage.ErrDeletedEntity, err)
+
+	// redirects follow survivors that are merged in turn
+	survivor2 := api.NewTestPatient(3, false)
+	survivor2ID, err := s.PutEntity(survivor2, false)
+	assert.Nil(t, err)
+	_, err = s.MergeEntities(survivor2ID, survivorID)
+	assert.Nil(t, err)
+	gotten, err = s.GetEntity(mergedID, time.Time{})
+	assert.Nil(t, err)
+	assert.Equal(t, survivor2, gotten)
+
+	// batch gets redirect too
+	results, err := s.GetEntities([]string{mergedID, survivorID, survivor2ID})
+	assert.Nil(t, err)
+	for _, result := range results {
+		assert.Nil(t, result.Err)
+		assert.Equal(t, survivor2, result.Entity)
+	}
+}
+
+func TestStorer_MergeEntities_err(t *testing.T) {
+	rng := rand.New(rand.NewSource(0))
+	idGen := id.NewNaiveLuhnGenerator(rng, id.DefaultLength)
+	s := New(idGen, storage.NewDefaultParameters(), zap.NewNop())
+
+	patientID, err := s.PutEntity(api.NewTestPatient(1, false), false)
+	assert.Nil(t, err)
+	officeID, err := s.PutEntity(api.NewTestOffice(1, false), false)
+	assert.Nil(t, err)
+	deletedID, err := s.PutEntity(api.NewTestPatient(2, false), false)
+	assert.Nil(t, err)
+	err = s.DeleteEntity(deletedID)
+	assert.Nil(t, err)
+	missingID, err := idGen.Generate(storage.Patient.IDPrefix())
+	assert.Nil(t, err)
+
+	cases := map[string]struct {
+		survivorID string
+		mergedID   string
+		expected   error
+	}{
+		"bad survivor ID": {
+			survivorID: "bad ID",
+			mergedID:   patientID,
+			expected:   id.ErrIncorrectChecksum,
+		},
+		"bad merged ID": {
+			survivorID: patientID,
+			mergedID:   "bad ID",
+			expected:   id.ErrIncorrectChecksum,
+		},
+		"self merge": {
+			survivorID: patientID,
+			mergedID:   patientID,
+			expected:   storage.ErrSelfMerge,
+		},
+		"type mismatch": {
+			survivorID: patientID,
+			mergedID:   officeID,
+			expected:   storage.ErrMergeTypeMismatch,
+		},
+		"missing survivor": {
+			survivorID: missingID,
+			mergedID:   patientID,
+			expected:   storage.ErrMissingEntity,
+		},
+		"deleted merged": {
+			survivorID: patientID,
+			mergedID:   deletedID,
+			expected:   storage.ErrDeletedEntity,
+		},
+	}
+
+	for desc, c := range cases {
+		survivor, err := s.MergeEntities(c.survivorID, c.mergedID)
+		assert.Equal(t, c.expected, err, desc)
+		assert.Nil(t, survivor, desc)
+	}
+}
+
+func TestStorer_RevertEntity_ok(t *testing.T) {
+	rng := rand.New(rand.NewSource(0))
+	idGen := id.NewNaiveLuhnGenerator(rng, id.DefaultLength)
+	s := New(idGen, storage.NewDefaultParameters(), zap.NewNop())
+	original := api.NewTestPatient(1, false)
+	entityID, err := s.PutEntity(original, false)
+	assert.Nil(t, err)
+	clobbered := api.NewTestPatient(2, false)
+	clobbered.EntityId = entityID
+	_, err = s.PutEntity(clobbered, false)
+	assert.Nil(t, err)
+
+	revert := &api.Revert{ToVersion: 1, RevertedBy: "some registrar", Reason: "some reason"}
+	reverted, err := s.RevertEntity(entityID, revert)
+	assert.Nil(t, err)
+	assert.Equal(t, uint64(3), reverted.Version)
+	assert.Equal(t, original.TypeAttributes, reverted.TypeAttributes)
+
+	gotten, err := s.GetEntity(entityID, time.Time{})
+	assert.Nil(t, err)
+	assert.Equal(t, reverted, gotten)
+
+	// revert is kept in history with the new version
+	history, err := s.GetEntityHistory(entityID)
+	assert.Nil(t, err)
+	assert.Equal(t, 3, len(history))
+	assert.Nil(t, history[1].Revert)
+	assert.Equal(t, reverted, history[2].Entity)
+	assert.Equal(t, revert, history[2].Revert)
+}
+
+func TestStorer_RevertEntity_merged(t *testing.T) {
+	rng := rand.New(rand.NewSource(0))
+	idGen := id.NewNaiveLuhnGenerator(rng, id.DefaultLength)
+	s := New(idGen, storage.NewDefaultParameters(), zap.NewNop())
+	survivorID, err := s.PutEntity(api.NewTestPatient(1, false), false)
+	assert.Nil(t, err)
+	original := api.NewTestPatient(2, false)
+	mergedID, err := s.PutEntity(original, false)
+	assert.Nil(t, err)
+	_, err = s.MergeEntities(survivorID, mergedID)
+	assert.Nil(t, err)
+
+	// merged entity can't be reverted past the version that was merged
+	reverted, err := s.RevertEntity(mergedID, &api.Revert{ToVersion: 2})
+	assert.Equal(t, storage.ErrInvalidRevertVersion, err)
+	assert.Nil(t, reverted)
+	gotten, err := s.GetEntity(mergedID, time.Time{})
+	assert.Nil(t, err)
+	assert.Equal(t, survivorID, gotten.EntityId)
+
+	// reverting to the merged version undoes the merge
+	revert := &api.Revert{ToVersion: 1, RevertedBy: "some registrar", Reason: "some reason"}
+	reverted, err = s.RevertEntity(mergedID, revert)
+	assert.Nil(t, err)
+	assert.Equal(t, mergedID, reverted.EntityId)
+	assert.Equal(t, uint64(2), reverted.Version)
+	assert.Equal(t, original.TypeAttributes, reverted.TypeAttributes)
+
+	gotten, err = s.GetEntity(mergedID, time.Time{})
+	assert.Nil(t, err)
+	assert.Equal(t, reverted, gotten)
+	results, err := s.GetEntities([]string{mergedID})
+	assert.Nil(t, err)
+	assert.Equal(t, reverted, results[0].Entity)
+
+	// the merge stays in the history
+	history, err := s.GetEntityHistory(mergedID)
+	assert.Nil(t, err)
+	assert.Equal(t, 2, len(history))
+	assert.Equal(t, survivorID, history[0].MergedIntoId)
+	assert.NotNil(t, history[0].ValidTo)
+	assert.Nil(t, history[0].Revert)
+	assert.Empty(t, history[1].MergedIntoId)
+	assert.Nil(t, history[1].ValidTo)
+	assert.Equal(t, revert, history[1].Revert)
+
+	// and reads as of when it was merged still redirect to the survivor
+	mergedAt, err := ptypes.Timestamp(history[0].ValidTo)
+	assert.Nil(t, err)
+	gotten, err = s.GetEntity(mergedID, mergedAt)
+	assert.Nil(t, err)
+	assert.Equal(t, survivorID, gotten.EntityId)
+}
+
+func TestStorer_RevertEntity_err(t *testing.T) {
+	rng := rand.New(rand.NewSource(0))
+	idGen := id.NewNaiveLuhnGenerator(rng, id.DefaultLength)
+	s := New(idGen, storage.NewDefaultParameters(), zap.NewNop())
+	entityID, err := s.PutEntity(api.NewTestPatient(1, false), false)
+	assert.Nil(t, err)
+	updated := api.NewTestPatient(2, false)
+	updated.EntityId = entityID
+	_, err = s.PutEntity(updated, false)
+	assert.Nil(t, err)
+	deletedID, err := s.PutEntity(api.NewTestPatient(3, false), false)
+	assert.Nil(t, err)
+	err = s.DeleteEntity(deletedID)
+	assert.Nil(t, err)
+	missingID, err := idGen.Generate(storage.Patient.IDPrefix())
+	assert.Nil(t, err)
+
+	cases := map[string]struct {
+		entityID  string
+		toVersion uint64
+		expected  error
+	}{
+		"bad entity ID": {
+			entityID:  "bad ID",
+			toVersion: 1,
+			expected:  id.ErrIncorrectChecksum,
+		},
+		"missing entity": {
+			entityID:  missingID,
+			toVersion: 1,
+			expected:  storage.ErrMissingEntity,
+		},
+		"deleted entity": {
+			entityID:  deletedID,
+			toVersion: 1,
+			expected:  storage.ErrDeletedEntity,
+		},
+		"current version": {
+			entityID:  entityID,
+			toVersion: 2,
+			expected:  storage.ErrInvalidRevertVersion,
+		},
+		"future version": {
+			entityID:  entityID,
+			toVersion: 3,
+			expected:  storage.ErrInvalidRevertVersion,
+		},
+	}
+
+	for desc, c := range cases {
+		revert := &api.Revert{ToVersion: c.toVersion, RevertedBy: "some registrar"}
+		reverted, err := s.RevertEntity(c.entityID, revert)
+		assert.Equal(t, c.expected, err, desc)
+		assert.Nil(t, reverted, desc)
+	}
+}
+
+func TestStorer_GetEntityHistory_ok(t *testing.T) {
+	rng := rand.New(rand.NewSource(0))
+	idGen := id.NewNaiveLuhnGenerator(rng, id.DefaultLength)
+	lg := logging.NewDevLogger(zapcore.DebugLevel)
+	s := New(idGen, storage.NewDefaultParameters(), lg)
+	assert.NotNil(t, s)
+
+	original := api.NewTestPatient(1, false)
+	entityID, err := s.PutEntity(original, false)
+	assert.Nil(t, err)
+	updated := api.NewTestPatient(2, false)
+	updated.EntityId = entityID
+	_, err = s.PutEntity(updated, false)
+	assert.Nil(t, err)
+
+	history, err := s.GetEntityHistory(entityID)
+	assert.Nil(t, err)
+	assert.Equal(t, 2, len(history))
+	assert.Equal(t, original, history[0].Entity)
+	assert.NotNil(t, history[0].ValidFrom)
+	assert.Equal(t, history[1].ValidFrom, history[0].ValidTo)
+	assert.Equal(t, updated, history[1].Entity)
+	assert.Nil(t, history[1].ValidTo)
+
+	// deleted entity history still available
+	err = s.DeleteEntity(entityID)
+	assert.Nil(t, err)
+	history, err = s.GetEntityHistory(entityID)
+	assert.Nil(t, err)
+	assert.Equal(t, 2, len(history))
+	assert.NotNil(t, history[1].ValidTo)
+}
+
+func TestStorer_GetEntityHistory_err(t *testing.T) {
+	rng := rand.New(rand.NewSource(0))
+	idGen := id.NewNaiveLuhnGenerator(rng, id.DefaultLength)
+	lg := zap.NewNop()
+	s := New(idGen, storage.NewDefaultParameters(), lg)
+	assert.NotNil(t, s)
+
+	// bad ID
+	history, err := s.GetEntityHistory("bad ID")
+	assert.NotNil(t, err)
+	assert.Nil(t, history)
+
+	// missing ID
+	missingID, err := idGen.Generate(storage.Patient.IDPrefix())
+	assert.Nil(t, err)
+	history, err = s.GetEntityHistory(missingID)
+	assert.Equal(t, storage.ErrMissingEntity, err)
+	assert.Nil(t, history)
+}
+func TestStorer_GetSearchEntity_asOf(t *testing.T) {
+	rng := rand.New(rand.NewSource(0))
+	idGen := id.NewNaiveLuhnGenerator(rng, id.DefaultLength)
+	lg := logging.NewDevLogger(zapcore.DebugLevel)
+	s := New(idGen, storage.NewDefaultParameters(), lg)
+	assert.NotNil(t, s)
+
+	original := api.NewTestPatient(1, false)
+	entityID, err := s.PutEntity(original, false)
+	assert.Nil(t, err)
+	updated := api.NewTestPatient(2, false)
+	updated.EntityId = entityID
+	_, err = s.PutEntity(updated, false)
+	assert.Nil(t, err)
+	err = s.DeleteEntity(entityID)
+	assert.Nil(t, err)
+	history, err := s.GetEntityHistory(entityID)
+	assert.Nil(t, err)
+	assert.Equal(t, 2, len(history))
+	originalFrom, err := ptypes.Timestamp(history[0].ValidFrom)
+	assert.Nil(t, err)
+	updatedFrom, err := ptypes.Timestamp(history[1].ValidFrom)
+	assert.Nil(t, err)
+	deletedAt, err := ptypes.Timestamp(history[1].ValidTo)
+	assert.Nil(t, err)
+	query := "Last Name"
+
+	// before creation
+	gotten, err := s.GetEntity(entityID, originalFrom.Add(-time.Nanosecond))
+	assert.Equal(t, storage.ErrMissingEntity, err)
+	assert.Nil(t, gotten)
+	searched, err := s.SearchEntity(query, nil, 8, originalFrom.Add(-time.Nanosecond), "", false)
+	assert.Nil(t, err)
+	found := searched.Results
+	assert.Empty(t, found)
+
+	// original version
+	gotten, err = s.GetEntity(entityID, originalFrom)
+	assert.Nil(t, err)
+	assert.Equal(t, original, gotten)
+	searched, err = s.SearchEntity(query, nil, 8, originalFrom, "", false)
+	assert.Nil(t, err)
+	found = searched.Results
+	assert.Equal(t, 1, len(found))
+	assert.Equal(t, original, found[0].Entity)
+
+	// updated version
+	gotten, err = s.GetEntity(entityID, updatedFrom)
+	assert.Nil(t, err)
+	assert.Equal(t, updated, gotten)
+	searched, err = s.SearchEntity(query, nil, 8, updatedFrom, "", false)
+	assert.Nil(t, err)
+	found = searched.Results
+	assert.Equal(t, 1, len(found))
+	assert.Equal(t, updated, found[0].Entity)
+
+	// after deletion
+	gotten, err = s.GetEntity(entityID, deletedAt)
+	assert.Equal(t, storage.ErrDeletedEntity, err)
+	assert.Nil(t, gotten)
+	searched, err = s.SearchEntity(query, nil, 8, deletedAt, "", false)
+	assert.Nil(t, err)
+	found = searched.Results
+	assert.Empty(t, found)
+}
+
+func TestStorer_PutGetEntities_ok(t *testing.T) {
+	rng := rand.New(rand.NewSource(0))
+	idGen := id.NewNaiveLuhnGenerator(rng, id.DefaultLength)
+	lg := logging.NewDevLogger(zapcore.DebugLevel)
+	s := New(idGen, storage.NewDefaultParameters(), lg)
+	assert.NotNil(t, s)
+	var err error
+
+	// put existing entities to update and delete
+	toUpdate := api.NewTestPatient(1, false)
+	_, err = s.PutEntity(toUpdate, false)
+	assert.Nil(t, err)
+	toDelete := api.NewTestOffice(1, false)
+	_, err = s.PutEntity(toDelete, false)
+	assert.Nil(t, err)
+	err = s.DeleteEntity(toDelete.EntityId)
+	assert.Nil(t, err)
+
+	updated := api.NewTestPatient(2, false)
+	updated.EntityId = toUpdate.EntityId
+	updated.Version = toUpdate.Version
+	deletedUpdated := api.NewTestOffice(2, false)
+	deletedUpdated.EntityId = toDelete.EntityId
+	es := []*api.Entity{
+		api.NewTestPatient(3, false),
+		api.NewTestOffice(3, false),
+		updated,
+		deletedUpdated,
+		{},
+	}
+	putResults, err := s.PutEntities(es, false)
+	assert.Nil(t, err)
+	assert.Equal(t, len(es), len(putResults))
+	for i := 0; i < 3; i++ {
+		assert.Nil(t, putResults[i].Err)
+		assert.Equal(t, es[i].EntityId, putResults[i].EntityID)
+		assert.Equal(t, es[i].Version, putResults[i].Version)
+	}
+	assert.Equal(t, uint64(2), putResults[2].Version)
+	assert.Equal(t, storage.ErrDeletedEntity, putResults[3].Err)
+	assert.Equal(t, api.ErrMissingTypeAttributes, putResults[4].Err)
+
+	missingID, err := idGen.Generate(storage.Patient.IDPrefix())
+	assert.Nil(t, err)
+	entityIDs := []string{
+		es[0].EntityId,
+		es[1].EntityId,
+		updated.EntityId,
+		toDelete.EntityId,
+		missingID,
+		"bad ID",
+	}
+	getResults, err := s.GetEntities(entityIDs)
+	assert.Nil(t, err)
+	assert.Equal(t, len(entityIDs), len(getResults))
+	assert.Equal(t, es[0], getResults[0].Entity)
+	assert.Equal(t, es[1], getResults[1].Entity)
+	assert.Equal(t, updated, getResults[2].Entity)
+	for i := 0; i < 3; i++ {
+		assert.Nil(t, getResults[i].Err)
+	}
+	assert.Equal(t, storage.ErrDeletedEntity, getResults[3].Err)
+	assert.Equal(t, storage.ErrMissingEntity, getResults[4].Err)
+	assert.NotNil(t, getResults[5].Err)
+	for i := 3; i < len(entityIDs); i++ {
+		assert.Nil(t, getResults[i].Entity)
+	}
+}
+
+func TestStorer_PutGetEntities_err(t *testing.T) {
+	rng := rand.New(rand.NewSource(0))
+	idGen := id.NewNaiveLuhnGenerator(rng, id.DefaultLength)
+	s := New(idGen, storage.NewDefaultParameters(), zap.NewNop())
+	assert.NotNil(t, s)
+	putResults, err := s.PutEntities(nil, false)
+	assert.Equal(t, api.ErrEmptyBatch, err)
+	assert.Nil(t, putResults)
+
+	getResults, err := s.GetEntities(make([]string, api.MaxBatchSize+1))
+	assert.Equal(t, api.ErrBatchTooLarge, err)
+	assert.Nil(t, getResults)
+}
+
+func TestStorer_ListEntities_ok(t *testing.T) {
+	rng := rand.New(rand.NewSource(0))
+	idGen := id.NewNaiveLuhnGenerator(rng, id.DefaultLength)
+	lg := logging.NewDevLogger(zapcore.DebugLevel)
+	s := New(idGen, storage.NewDefaultParameters(), lg)
+	assert.NotNil(t, s)
+	var err error
+
+	entityIDs := make([]string, 0)
+	for i := 0; i < 5; i++ {
+		entityID, err := s.PutEntity(api.NewTestPatient(i, false), false)
+		assert.Nil(t, err)
+		entityIDs = append(entityIDs, entityID)
+	}
+	_, err = s.PutEntity(api.NewTestOffice(0, false), false)
+	assert.Nil(t, err)
+	err = s.DeleteEntity(entityIDs[0])
+	assert.Nil(t, err)
+	entityIDs = entityIDs[1:]
+	sort.Strings(entityIDs)
+
+	// list patients two per page
+	listed := make([]string, 0)
+	pageToken, nPages := "", 0
+	for {
+		page, nextPageToken, err := s.ListEntities(storage.Patient, pageToken, 2)
+		assert.Nil(t, err)
+		assert.True(t, len(page) <= 2)
+		for _, e := range page {
+			listed = append(listed, e.EntityId)
+		}
+		nPages++
+		if nextPageToken == "" {
+			break
+		}
+		pageToken = nextPageToken
+	}
+	assert.Equal(t, entityIDs, listed)
+	assert.Equal(t, 2, nPages)
+
+	// list offices with default page size
+	page, nextPageToken, err := s.ListEntities(storage.Office, "", 0)
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(page))
+	assert.Empty(t, nextPageToken)
+}
+
+func TestStorer_ListEntities_err(t *testing.T) {
+	rng := rand.New(rand.NewSource(0))
+	idGen := id.NewNaiveLuhnGenerator(rng, id.DefaultLength)
+	s := New(idGen, storage.NewDefaultParameters(), zap.NewNop())
+	assert.NotNil(t, s)
+	okPageToken := storage.EncodePageToken(storage.Patient.IDPrefix() + "AAAAAAA")
+
+	page, nextPageToken, err := s.ListEntities(storage.Office, okPageToken, 2)
+	assert.Equal(t, storage.ErrInvalidPageToken, err)
+	assert.Nil(t, page)
+	assert.Empty(t, nextPageToken)
+
+	page, nextPageToken, err = s.ListEntities(storage.Patient, okPageToken,
+		api.MaxListPageSize+1)
+	assert.Equal(t, api.ErrListPageSizeTooLarge, err)
+	assert.Nil(t, page)
+	assert.Empty(t, nextPageToken)
+}
+
+func TestStorer_FindDuplicates(t *testing.T) {
+	rng := rand.New(rand.NewSource(0))
+	idGen := id.NewNaiveLuhnGenerator(rng, id.DefaultLength)
+	s := New(idGen, storage.NewDefaultParameters(), zap.NewNop())
+	birthdate1 := &api.Date{Year: 1980, Month: 1, Day: 1}
+	birthdate2 := &api.Date{Year: 1990, Month: 1, Day: 1}
+	es := []*api.Entity{
+		api.NewPatient("", &api.Patient{LastName: "Smith", FirstName: "John",
+			Birthdate: birthdate1}),
+		api.NewPatient("", &api.Patient{LastName: "Smyth", FirstName: "John",
+			Birthdate: birthdate1}),
+		api.NewPatient("", &api.Patient{LastName: "Smith", FirstName: "John",
+			Birthdate: birthdate2}),
+		api.NewPatient("", &api.Patient{LastName: "Doe", FirstName: "Jane",
+			Birthdate: birthdate1}),
+		api.NewPatient("", &api.Patient{LastName: "Smith", FirstName: "John",
+			Birthdate: birthdate1}),
+		api.NewTestOffice(0, false),
+	}
+	for _, e := range es {
+		_, err := s.PutEntity(e, false)
+		assert.Nil(t, err)
+	}
+	err := s.DeleteEntity(es[4].EntityId)
+	assert.Nil(t, err)
+
+	pairs, err := s.FindDuplicates(0.5, 10)
+	assert.Nil(t, err)
+	assert.Equal(t, 2, len(pairs))
+	assert.Equal(t, float32(8)/14, pairs[0].NameSimilarity)
+	assert.True(t, pairs[0].BirthdateMatch)
+	assert.Equal(t, storage.DuplicateScore(float32(8)/14, true), pairs[0].Score)
+	assert.Equal(t, float32(1), pairs[1].NameSimilarity)
+	assert.False(t, pairs[1].BirthdateMatch)
+	assert.Equal(t, float32(0.5), pairs[1].Score)
+	for _, pair := range pairs {
+		assert.True(t, pair.Entity1.EntityId < pair.Entity2.EntityId)
+	}
+
+	pairs, err = s.FindDuplicates(0.5, 1)
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(pairs))
+	assert.True(t, pairs[0].BirthdateMatch)
+
+	pairs, err = s.FindDuplicates(api.DefaultDuplicateThreshold, 10)
+	assert.Nil(t, err)
+	assert.Empty(t, pairs)
+
+	// names are compared folded, like the names searched
+	s = New(idGen, storage.NewDefaultParameters(), zap.NewNop())
+	for _, lastName := range []string{"Muñoz-García", "MUNOZ GARCIA"} {
+		_, err = s.PutEntity(api.NewPatient("", &api.Patient{LastName: lastName,
+			FirstName: "José", Birthdate: birthdate1}), false)
+		assert.Nil(t, err)
+	}
+	pairs, err = s.FindDuplicates(api.DefaultDuplicateThreshold, 10)
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(pairs))
+	assert.Equal(t, float32(1), pairs[0].NameSimilarity)
+}
+
+func TestStorer_MatchPatient(t *testing.T) {
+	rng := rand.New(rand.NewSource(0))
+	idGen := id.NewNaiveLuhnGenerator(rng, id.DefaultLength)
+	s := New(idGen, storage.NewDefaultParameters(), zap.NewNop())
+	birthdate := &api.Date{Year: 1980, Month: 1, Day: 1}
+	same := api.NewPatient("", &api.Patient{LastName: "Smith", FirstName: "John",
+		Birthdate: birthdate})
+	sameBirthdate := api.NewPatient("", &api.Patient{LastName: "Doe", FirstName: "Jane",
+		Birthdate: birthdate})
+	similarName := api.NewPatient("", &api.Patient{LastName: "Smyth", FirstName: "John",
+		Birthdate: &api.Date{Year: 1990, Month: 1, Day: 1}})
+	unrelated := api.NewPatient("", &api.Patient{LastName: "Roe", FirstName: "Ann",
+		Birthdate: &api.Date{Year: 1970, Month: 1, Day: 1}})
+	for _, e := range []*api.Entity{same, sameBirthdate, similarName, unrelated,
+		api.NewTestOffice(0, false)} {
+		_, err := s.PutEntity(e, false)
+		assert.Nil(t, err)
+	}
+
+	p := &api.Patient{LastName: "SMITH", FirstName: "John", Birthdate: birthdate}
+	ms, err := s.MatchPatient(p, api.DefaultMatchLimit)
+	assert.Nil(t, err)
+	assert.Equal(t, 3, len(ms))
+	assert.Equal(t, same, ms[0].Entity)
+	assert.Equal(t, api.Match, ms[0].Classification)
+	for _, m := range ms[1:] {
+		assert.NotEqual(t, unrelated, m.Entity)
+		assert.NotEqual(t, api.Match, m.Classification)
+	}
+
+	ms, err = s.MatchPatient(p, 1)
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(ms))
+	assert.Equal(t, same, ms[0].Entity)
+}
+
+func TestTopCandidates(t *testing.T) {
+	n := storage.MaxMatchCandidates + 2
+	candidates := make([]*api.Entity, n)
+	nameSims := make(map[string]float32)
+	for i := range candidates {
+		candidates[i] = api.NewTestPatient(i, true)
+		nameSims[candidates[i].EntityId] = float32(i%3) / 2
+	}
+	top := topCandidates(candidates, nameSims)
+	assert.Equal(t, storage.MaxMatchCandidates, len(top))
+	for i := 1; i < len(top); i++ {
+		si, sj := nameSims[top[i-1].EntityId], nameSims[top[i].EntityId]
+		assert.True(t, si > sj || si == sj && top[i-1].EntityId < top[i].EntityId)
+	}
+	assert.Equal(t, float32(0), nameSims[top[len(top)-1].EntityId])
+}
+
+func TestStorer_Autocomplete(t *testing.T) {
+	rng := rand.New(rand.NewSource(0))
+	idGen := id.NewNaiveLuhnGenerator(rng, id.DefaultLength)
+	s := New(idGen, storage.NewDefaultParameters(), zap.NewNop())
+	birthdate := &api.Date{Year: 1980, Month: 1, Day: 1}
+	munoz := api.NewPatient("", &api.Patient{LastName: "Muñoz", FirstName: "José",
+		Birthdate: birthdate})
+	murray := api.NewPatient("", &api.Patient{LastName: "Murray", FirstName: "Ann",
+		Birthdate: birthdate})
+	deleted := api.NewPatient("", &api.Patient{LastName: "Mueller", FirstName: "Eva",
+		Birthdate: birthdate})
+	office := api.NewOffice("", &api.Office{Name: "Mount Sinai Clinic"})
+	for _, e := range []*api.Entity{munoz, murray, deleted, office} {
+		_, err := s.PutEntity(e, false)
+		assert.Nil(t, err)
+	}
+	assert.Nil(t, s.DeleteEntity(deleted.EntityId))
+
+	suggestions, err := s.Autocomplete("mu", nil, api.DefaultAutocompleteLimit)
+	assert.Nil(t, err)
+	assert.Equal(t, []*api.Suggestion{
+		{EntityId: munoz.EntityId, EntityType: api.PatientType, DisplayName: "José Muñoz"},
+		{EntityId: murray.EntityId, EntityType: api.PatientType, DisplayName: "Ann Murray"},
+	}, suggestions)
+
+	// first name followed by last name, ignoring accents
+	suggestions, err = s.Autocomplete("jose mun", nil, api.DefaultAutocompleteLimit)
+	assert.Nil(t, err)
+	assert.Len(t, suggestions, 1)
+	assert.Equal(t, munoz.EntityId, suggestions[0].EntityId)
+
+	suggestions, err = s.Autocomplete("mo", []string{api.OfficeType}, 1)
+	assert.Nil(t, err)
+	assert.Equal(t, []*api.Suggestion{
+		{EntityId: office.EntityId, EntityType: api.OfficeType, DisplayName: "Mount Sinai Clinic"},
+	}, suggestions)
+
+	suggestions, err = s.Autocomplete("mu", []string{api.OfficeType}, 1)
+	assert.Nil(t, err)
+	assert.Empty(t, suggestions)
+
+	suggestions, err = s.Autocomplete("mu", nil, 1)
+	assert.Nil(t, err)
+	assert.Len(t, suggestions, 1)
+
+	suggestions, err = s.Autocomplete("mu", []string{"OTHER"}, 1)
+	assert.Equal(t, storage.ErrUnknownEntityType, err)
+	assert.Nil(t, suggestions)
+}
+
+func TestMatchesPhonetic(t *testing.T) {
+	matches, sim := matchesPhonetic("tomson cathryn", "Thompson", "Katherine")
+	assert.True(t, matches)
+	assert.Equal(t, float32(1), sim)
+
+	matches, sim = matchesPhonetic("cathryn", "Thompson", "Katherine")
+	assert.True(t, matches)
+	assert.Equal(t, float32(0.5), sim)
+
+	matches, sim = matchesPhonetic("jones", "Thompson", "")
+	assert.False(t, matches)
+	assert.Zero(t, sim)
+}
+
+func TestMatchesFolded(t *testing.T) {
+	matches, sim := matchesFolded("munoz obrien", "Muñoz-O'Brien", "Zoë")
+	assert.True(t, matches)
+	assert.Equal(t, float32(12)/16, sim)
+
+	matches, sim = matchesFolded("ZOË", "Muñoz-O'Brien", "Zoe")
+	assert.True(t, matches)
+	assert.Equal(t, float32(3)/16, sim)
+
+	matches, sim = matchesFolded("'", "Muñoz-O'Brien", "Zoë")
+	assert.False(t, matches)
+	assert.Zero(t, sim)
+}
+
+func TestMatchesSynonyms(t *testing.T) {
+	nicknames := make(storage.Nicknames)
+	nicknames.Add("ROBERT", "BOB")
+
+	matches, sim := matchesSynonyms("bob jones", nicknames, "Jones", "Robert")
+	assert.True(t, matches)
+	assert.Equal(t, float32(1), sim)
+
+	matches, sim = matchesSynonyms("bob", nicknames, "Jones", "Robert")
+	assert.True(t, matches)
+	assert.Equal(t, float32(0.5), sim)
+
+	matches, sim = matchesSynonyms("jones", nicknames, "Jones", "Robert")
+	assert.False(t, matches)
+	assert.Zero(t, sim)
+}
+
+func TestGetSearcherQueries(t *testing.T) {
+	searcherQueries := getSearcherQueries("bob jones 1980-02-03", nil,
+		storage.NewDefaultNicknames())
+	assert.Equal(t, len(storage.SearcherNames), len(searcherQueries))
+	for _, name := range storage.SearcherNames {
+		assert.Contains(t, searcherQueries, name)
+	}
+	assert.Equal(t, "BOB JONES", searcherQueries[storage.OfficeEntityIDSearcher])
+}
+
+func TestEntityIDSearcher(t *testing.T) {
+	p := api.NewPatient("P1", &api.Patient{})
+	assert.Equal(t, storage.PatientEntityIDSearcher, entityIDSearcher(p))
+	f := api.NewOffice("F1", &api.Office{})
+	assert.Equal(t, storage.OfficeEntityIDSearcher, entityIDSearcher(f))
+
+	matches, searcher, _ := checkMatchesQuery(f, "f1", "f1", nil)
+	assert.True(t, matches)
+	assert.Equal(t, storage.OfficeEntityIDSearcher, searcher)
+}
+
+func TestTrigramSimilarity(t *testing.T) {
+	assert.Equal(t, float32(1), trigramSimilarity("SMITH JOHN", "SMITH JOHN"))
+	assert.Equal(t, float32(8)/14, trigramSimilarity("SMITH JOHN", "SMYTH JOHN"))
+	assert.Equal(t, float32(1), trigramSimilarity("Smith, John", "SMITH JOHN"))
+	assert.Equal(t, float32(0), trigramSimilarity("SMITH JOHN", "DOE ANN"))
+	assert.Equal(t, float32(0), trigramSimilarity(" ", "SMITH JOHN"))
+}
+
 type fixedIDGen struct {
 	checkErr    error
 	generateID  string
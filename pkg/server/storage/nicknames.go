@@ -0,0 +1,185 @@
+package storage
+
+import (
+	"encoding/csv"
+	"io"
+	"strings"
+)
+
+// MaxNicknameVariants is the maximum number of variants of a query with its given names replaced
+// by their synonyms.
+const MaxNicknameVariants = 8
+
+// defaultNicknameGroups are the bundled groups of given names that are synonyms of each other,
+// usually a formal name and its common nicknames.
+var defaultNicknameGroups = [][]string{
+	{"ABIGAIL", "ABBY"},
+	{"ALBERT", "AL", "BERT"},
+	{"ALEXANDER", "ALEX", "AL", "SANDY"},
+	{"ALEXANDRA", "ALEX", "LEXI", "SANDY"},
+	{"ALFRED", "AL", "ALFIE", "FRED"},
+	{"ALICE", "ALLIE"},
+	{"ALLISON", "ALLIE"},
+	{"ANDREW", "ANDY", "DREW"},
+	{"ANGELA", "ANGIE"},
+	{"ANNE", "ANN", "ANNIE", "NAN", "NANCY"},
+	{"ANTHONY", "TONY"},
+	{"BARBARA", "BARB", "BARBIE"},
+	{"BENJAMIN", "BEN", "BENNY"},
+	{"CATHERINE", "CATHY", "KATE", "KATIE"},
+	{"CHARLES", "CHARLIE", "CHUCK"},
+	{"CHRISTINE", "CHRIS", "CHRISSY", "TINA"},
+	{"CHRISTOPHER", "CHRIS", "KIT"},
+	{"CYNTHIA", "CINDY"},
+	{"DANIEL", "DAN", "DANNY"},
+	{"DAVID", "DAVE", "DAVEY"},
+	{"DEBORAH", "DEB", "DEBBIE"},
+	{"DONALD", "DON", "DONNIE"},
+	{"DOROTHY", "DOT", "DOTTIE"},
+	{"DOUGLAS", "DOUG"},
+	{"EDWARD", "ED", "EDDIE", "NED", "TED"},
+	{"ELIZABETH", "BETH", "BETSY", "BETTY", "ELIZA", "LIBBY", "LIZ", "LIZZIE"},
+	{"EMILY", "EMMY"},
+	{"EUGENE", "GENE"},
+	{"FRANCES", "FRAN", "FRANNIE"},
+	{"FRANCIS", "FRANK", "FRANKIE"},
+	{"FREDERICK", "FRED", "FREDDIE"},
+	{"GERALD", "GERRY", "JERRY"},
+	{"GREGORY", "GREG"},
+	{"HAROLD", "HAL", "HARRY"},
+	{"HENRY", "HANK", "HARRY"},
+	{"JACQUELINE", "JACKIE"},
+	{"JAMES", "JAMIE", "JIM", "JIMMY"},
+	{"JEFFREY", "JEFF"},
+	{"JENNIFER", "JEN", "JENNY"},
+	{"JESSICA", "JESS", "JESSIE"},
+	{"JOHN", "JACK", "JOHNNY"},
+	{"JONATHAN", "JON"},
+	{"JOSEPH", "JOE", "JOEY"},
+	{"JOSEPHINE", "JO", "JOSIE"},
+	{"JOSHUA", "JOSH"},
+	{"JUDITH", "JUDY"},
+	{"KATHERINE", "KAT", "KATE", "KATHY", "KATIE"},
+	{"KENNETH", "KEN", "KENNY"},
+	{"KIMBERLY", "KIM"},
+	{"LAWRENCE", "LARRY"},
+	{"LEONARD", "LEN", "LENNY", "LEO"},
+	{"MARGARET", "MAGGIE", "MARGE", "MEG", "PEGGY"},
+	{"MARY", "MOLLY", "POLLY"},
+	{"MATTHEW", "MATT"},
+	{"MICHAEL", "MICKEY", "MIKE"},
+	{"NATHANIEL", "NAT", "NATE"},
+	{"NICHOLAS", "NICK", "NICKY"},
+	{"PAMELA", "PAM"},
+	{"PATRICIA", "PAT", "PATTY", "TRISH"},
+	{"PATRICK", "PADDY", "PAT"},
+	{"PETER", "PETE"},
+	{"PHILIP", "PHIL"},
+	{"RAYMOND", "RAY"},
+	{"REBECCA", "BECCA", "BECKY"},
+	{"RICHARD", "DICK", "RICH", "RICK", "RICKY"},
+	{"ROBERT", "BOB", "BOBBY", "ROB", "ROBBIE"},
+	{"RONALD", "RON", "RONNIE"},
+	{"SAMANTHA", "SAM", "SAMMY"},
+	{"SAMUEL", "SAM", "SAMMY"},
+	{"SARAH", "SADIE", "SALLY"},
+	{"STEPHANIE", "STEPH"},
+	{"STEPHEN", "STEVE"},
+	{"STEVEN", "STEVE"},
+	{"SUSAN", "SUE", "SUSIE"},
+	{"THERESA", "TERRY", "TESS"},
+	{"THOMAS", "TOM", "TOMMY"},
+	{"TIMOTHY", "TIM", "TIMMY"},
+	{"VALERIE", "VAL"},
+	{"VICTORIA", "TORI", "VICKY"},
+	{"VINCENT", "VINCE", "VINNY"},
+	{"WALTER", "WALLY", "WALT"},
+	{"WILLIAM", "BILL", "BILLY", "WILL", "WILLIE"},
+	{"ZACHARY", "ZACH"},
+}
+
+// Nicknames maps each upper-cased given name to the upper-cased given names that are its synonyms.
+type Nicknames map[string][]string
+
+// NewDefaultNicknames returns Nicknames with the bundled synonym groups.
+func NewDefaultNicknames() Nicknames {
+	n := make(Nicknames)
+	for _, group := range defaultNicknameGroups {
+		n.Add(group...)
+	}
+	return n
+}
+
+// Add adds a group of given names that are all synonyms of each other.
+func (n Nicknames) Add(group ...string) {
+	names := make([]string, 0, len(group))
+	for _, name := range group {
+		if name = strings.ToUpper(strings.TrimSpace(name)); name != "" {
+			names = append(names, name)
+		}
+	}
+	for _, name := range names {
+		for _, synonym := range names {
+			if synonym != name && !n.hasSynonym(name, synonym) {
+				n[name] = append(n[name], synonym)
+			}
+		}
+	}
+}
+
+// Load adds the synonym groups read from the CSV, with one group of given names per record,
+// e.g., "Robert,Bob,Bobby". Lines starting with # are comments.
+func (n Nicknames) Load(r io.Reader) error {
+	cr := csv.NewReader(r)
+	cr.Comment = '#'
+	cr.FieldsPerRecord = -1 // groups vary in size
+	groups, err := cr.ReadAll()
+	if err != nil {
+		return err
+	}
+	for _, group := range groups {
+		n.Add(group...)
+	}
+	return nil
+}
+
+// Variants returns the variants of the query with each of its words that is a given name with
+// synonyms replaced by each of its synonyms, or nil if none of its words have synonyms. The
+// variants are upper-cased and there are at most MaxNicknameVariants of them.
+func (n Nicknames) Variants(query string) []string {
+	words := strings.Fields(strings.ToUpper(query))
+	variants := [][]string{{}}
+	expanded := false
+	for _, word := range words {
+		options := append([]string{word}, n[word]...)
+		expanded = expanded || len(options) > 1
+		next := make([][]string, 0, len(variants)*len(options))
+		for _, variant := range variants {
+			for _, option := range options {
+				if len(next) > MaxNicknameVariants {
+					// the first variant is the query itself, which isn't returned
+					break
+				}
+				next = append(next, append(variant[:len(variant):len(variant)], option))
+			}
+		}
+		variants = next
+	}
+	if !expanded {
+		return nil
+	}
+	joined := make([]string, len(variants)-1)
+	for i, variant := range variants[1:] {
+		joined[i] = strings.Join(variant, " ")
+	}
+	return joined
+}
+
+func (n Nicknames) hasSynonym(name, synonym string) bool {
+	for _, existing := range n[name] {
+		if existing == synonym {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,52 @@
+package storage
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewDefaultNicknames(t *testing.T) {
+	n := NewDefaultNicknames()
+	assert.Contains(t, n["BOB"], "ROBERT")
+	assert.Contains(t, n["ROBERT"], "BOB")
+	assert.Contains(t, n["LIZ"], "ELIZABETH")
+
+	// names in multiple groups have the synonyms from each
+	assert.Contains(t, n["AL"], "ALBERT")
+	assert.Contains(t, n["AL"], "ALFRED")
+	assert.NotContains(t, n["AL"], "AL")
+}
+
+func TestNicknames_Add(t *testing.T) {
+	n := make(Nicknames)
+	n.Add("Robert", " bob ", "", "BOB")
+	assert.Equal(t, Nicknames{"ROBERT": {"BOB"}, "BOB": {"ROBERT"}}, n)
+}
+
+func TestNicknames_Load(t *testing.T) {
+	n := make(Nicknames)
+	err := n.Load(strings.NewReader("# local variants\nGuillermo,Memo\nRoberto,Beto,Tito\n"))
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"MEMO"}, n["GUILLERMO"])
+	assert.Equal(t, []string{"ROBERTO", "TITO"}, n["BETO"])
+
+	err = n.Load(strings.NewReader(`"Unterminated,Quote`))
+	assert.NotNil(t, err)
+}
+
+func TestNicknames_Variants(t *testing.T) {
+	n := make(Nicknames)
+	n.Add("ROBERT", "BOB", "ROB")
+	n.Add("ELIZABETH", "LIZ")
+
+	assert.Nil(t, n.Variants("jones"))
+	assert.Nil(t, n.Variants(""))
+	assert.Equal(t, []string{"ROBERT JONES", "ROB JONES"}, n.Variants("bob  jones"))
+	assert.Equal(t, []string{"BOB ELIZABETH", "ROBERT LIZ", "ROBERT ELIZABETH", "ROB LIZ",
+		"ROB ELIZABETH"}, n.Variants("Bob Liz"))
+
+	// the number of variants is capped
+	assert.Equal(t, MaxNicknameVariants, len(NewDefaultNicknames().Variants("liz bob")))
+}
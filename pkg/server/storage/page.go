@@ -0,0 +1,136 @@
+package storage
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	api "github.com/elixirhealth/directory/pkg/directoryapi"
+	"github.com/golang/protobuf/proto"
+	"github.com/pkg/errors"
+)
+
+// ErrInvalidPageToken indicates when a list or search page token cannot be decoded for the entity
+// type being listed or the query being searched.
+var ErrInvalidPageToken = errors.New("invalid page token")
+
+// EncodePageToken encodes the entity ID of the last entity in a page into an opaque token for
+// the next page.
+func EncodePageToken(lastEntityID string) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(lastEntityID))
+}
+
+// DecodePageToken decodes a page token for the given entity type into the entity ID after which
+// the page starts. An empty token decodes to an empty entity ID, denoting the first page.
+func DecodePageToken(et EntityType, pageToken string) (string, error) {
+	if pageToken == "" {
+		return "", nil
+	}
+	lastEntityID, err := base64.RawURLEncoding.DecodeString(pageToken)
+	if err != nil {
+		return "", ErrInvalidPageToken
+	}
+	if !strings.HasPrefix(string(lastEntityID), et.IDPrefix()) {
+		return "", ErrInvalidPageToken
+	}
+	return string(lastEntityID), nil
+}
+
+// GetPageSize returns the page size to use for the requested one, which is the default if zero.
+func GetPageSize(pageSize uint) (uint, error) {
+	if pageSize == 0 {
+		return api.DefaultListPageSize, nil
+	}
+	if pageSize > api.MaxListPageSize {
+		return 0, api.ErrListPageSizeTooLarge
+	}
+	return pageSize, nil
+}
+
+// SplitPage splits entities ordered by entity ID, which include at least the first entity of the
+// next page if there is one, into the page of at most pageSize entities and the token for the
+// next page.
+func SplitPage(es []*api.Entity, pageSize uint) ([]*api.Entity, string) {
+	if uint(len(es)) <= pageSize {
+		return es, ""
+	}
+	page := es[:pageSize]
+	return page, EncodePageToken(page[pageSize-1].EntityId)
+}
+
+// SearchPageKey returns the key that the page tokens of a search with the given query and
+// (possibly nil) filters are bound to, so a token from one search is invalid for another.
+func SearchPageKey(query string, filters *api.SearchFilters) string {
+	if filters == nil {
+		return query
+	}
+	return query + ":" + proto.CompactTextString(filters)
+}
+
+// EncodeSearchPageToken encodes the offset into the ranked results of a search at which the next
+// page starts, the snapshot time its pages are ranked as of, and a hash of its page key into an
+// opaque token for the next page. Only the hash of the key is encoded, so the query, which may
+// identify a patient, is not exposed in the token.
+func EncodeSearchPageToken(pageKey string, snapshot time.Time, offset uint) string {
+	token := fmt.Sprintf("%d:%d:%s", offset, snapshot.UnixNano(), hashSearchPageKey(pageKey))
+	return base64.RawURLEncoding.EncodeToString([]byte(token))
+}
+
+// DecodeSearchPageToken decodes a page token for the search with the given page key into the
+// offset into its ranked results at which the page starts and the snapshot time its pages are
+// ranked as of. An empty token decodes to a zero offset, denoting the first page, and a snapshot
+// time of asOf, or now if asOf is zero.
+func DecodeSearchPageToken(pageKey, pageToken string, asOf time.Time) (uint, time.Time, error) {
+	if pageToken == "" {
+		if asOf.IsZero() {
+			return 0, time.Now(), nil
+		}
+		return 0, asOf, nil
+	}
+	decoded, err := base64.RawURLEncoding.DecodeString(pageToken)
+	if err != nil {
+		return 0, time.Time{}, ErrInvalidPageToken
+	}
+	parts := strings.SplitN(string(decoded), ":", 3)
+	if len(parts) != 3 || parts[2] != hashSearchPageKey(pageKey) {
+		return 0, time.Time{}, ErrInvalidPageToken
+	}
+	offset, err := strconv.ParseUint(parts[0], 10, 32)
+	if err != nil || offset >= api.MaxSearchDepth {
+		return 0, time.Time{}, ErrInvalidPageToken
+	}
+	snapshotNanos, err := strconv.ParseInt(parts[1], 10, 64)
+	if err != nil || snapshotNanos <= 0 {
+		return 0, time.Time{}, ErrInvalidPageToken
+	}
+	return uint(offset), time.Unix(0, snapshotNanos), nil
+}
+
+func hashSearchPageKey(pageKey string) string {
+	hash := sha256.Sum256([]byte(pageKey))
+	return hex.EncodeToString(hash[:])
+}
+
+// SplitSearchPage splits the results ranked by similarity to the query, which include at least
+// the first result of the next page if there is one, into the page of at most limit results
+// starting at offset and the token for the next page, which is ranked as of the snapshot time. No
+// token is returned for pages that would start beyond api.MaxSearchDepth.
+func SplitSearchPage(
+	ranked []*api.SearchResult, pageKey string, snapshot time.Time, offset, limit uint,
+) ([]*api.SearchResult, string) {
+	if uint(len(ranked)) <= offset {
+		return []*api.SearchResult{}, ""
+	}
+	end := offset + limit
+	if uint(len(ranked)) <= end {
+		return ranked[offset:], ""
+	}
+	if end >= api.MaxSearchDepth {
+		return ranked[offset:end], ""
+	}
+	return ranked[offset:end], EncodeSearchPageToken(pageKey, snapshot, end)
+}
@@ -0,0 +1,158 @@
+package storage
+
+import (
+	"encoding/base64"
+	"testing"
+	"time"
+
+	api "github.com/elixirhealth/directory/pkg/directoryapi"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEncodeDecodePageToken(t *testing.T) {
+	for i := 0; i < NEntityTypes; i++ {
+		et := EntityType(i)
+		lastEntityID := et.IDPrefix() + "AAAAAAA"
+		pageToken := EncodePageToken(lastEntityID)
+		assert.NotEqual(t, lastEntityID, pageToken)
+
+		decoded, err := DecodePageToken(et, pageToken)
+		assert.Nil(t, err)
+		assert.Equal(t, lastEntityID, decoded)
+	}
+
+	// empty token denotes first page
+	decoded, err := DecodePageToken(Patient, "")
+	assert.Nil(t, err)
+	assert.Empty(t, decoded)
+}
+
+func TestDecodePageToken_err(t *testing.T) {
+	cases := map[string]string{
+		"not base64":        "not base64!",
+		"wrong entity type": EncodePageToken(Office.IDPrefix() + "AAAAAAA"),
+	}
+	for desc, pageToken := range cases {
+		decoded, err := DecodePageToken(Patient, pageToken)
+		assert.Equal(t, ErrInvalidPageToken, err, desc)
+		assert.Empty(t, decoded, desc)
+	}
+}
+
+func TestGetPageSize(t *testing.T) {
+	pageSize, err := GetPageSize(0)
+	assert.Nil(t, err)
+	assert.Equal(t, uint(api.DefaultListPageSize), pageSize)
+
+	pageSize, err = GetPageSize(4)
+	assert.Nil(t, err)
+	assert.Equal(t, uint(4), pageSize)
+
+	pageSize, err = GetPageSize(api.MaxListPageSize + 1)
+	assert.Equal(t, api.ErrListPageSizeTooLarge, err)
+	assert.Zero(t, pageSize)
+}
+
+func TestSplitPage(t *testing.T) {
+	es := []*api.Entity{
+		api.NewTestPatient(1, true),
+		api.NewTestPatient(2, true),
+		api.NewTestPatient(3, true),
+	}
+
+	// last page
+	page, nextPageToken := SplitPage(es, 3)
+	assert.Equal(t, es, page)
+	assert.Empty(t, nextPageToken)
+
+	// page with one after
+	page, nextPageToken = SplitPage(es, 2)
+	assert.Equal(t, es[:2], page)
+	assert.Equal(t, EncodePageToken(es[1].EntityId), nextPageToken)
+}
+
+func TestSearchPageKey(t *testing.T) {
+	query := "some query"
+	assert.Equal(t, query, SearchPageKey(query, nil))
+
+	filters1 := &api.SearchFilters{EntityTypes: []string{api.PatientType}}
+	filters2 := &api.SearchFilters{EntityTypes: []string{api.OfficeType}}
+	assert.NotEqual(t, query, SearchPageKey(query, filters1))
+	assert.Equal(t, SearchPageKey(query, filters1), SearchPageKey(query, filters1))
+	assert.NotEqual(t, SearchPageKey(query, filters1), SearchPageKey(query, filters2))
+}
+
+func TestEncodeDecodeSearchPageToken(t *testing.T) {
+	pageKey := "some query"
+	snapshot := time.Date(2006, 1, 2, 15, 4, 5, 6, time.UTC)
+	pageToken := EncodeSearchPageToken(pageKey, snapshot, 8)
+	assert.NotContains(t, pageToken, base64.RawURLEncoding.EncodeToString([]byte(pageKey)))
+	offset, decodedSnapshot, err := DecodeSearchPageToken(pageKey, pageToken, time.Time{})
+	assert.Nil(t, err)
+	assert.Equal(t, uint(8), offset)
+	assert.True(t, snapshot.Equal(decodedSnapshot))
+
+	// as of is ignored for later pages, which are ranked as of the first
+	asOf := snapshot.Add(-time.Hour)
+	offset, decodedSnapshot, err = DecodeSearchPageToken(pageKey, pageToken, asOf)
+	assert.Nil(t, err)
+	assert.Equal(t, uint(8), offset)
+	assert.True(t, snapshot.Equal(decodedSnapshot))
+
+	// empty token denotes first page, ranked as of asOf or now
+	offset, decodedSnapshot, err = DecodeSearchPageToken(pageKey, "", asOf)
+	assert.Nil(t, err)
+	assert.Zero(t, offset)
+	assert.Equal(t, asOf, decodedSnapshot)
+
+	before := time.Now()
+	offset, decodedSnapshot, err = DecodeSearchPageToken(pageKey, "", time.Time{})
+	assert.Nil(t, err)
+	assert.Zero(t, offset)
+	assert.False(t, decodedSnapshot.Before(before))
+}
+
+func TestDecodeSearchPageToken_err(t *testing.T) {
+	pageKey := "some query"
+	snapshot := time.Date(2006, 1, 2, 15, 4, 5, 6, time.UTC)
+	hash := hashSearchPageKey(pageKey)
+	cases := map[string]string{
+		"not base64":     "not base64!",
+		"other query":    EncodeSearchPageToken("other query", snapshot, 8),
+		"raw query":      EncodePageToken("8:1136214245000000006:" + pageKey),
+		"bad offset":     EncodePageToken("eight:1136214245000000006:" + hash),
+		"bad snapshot":   EncodePageToken("8:yesterday:" + hash),
+		"zero snapshot":  EncodePageToken("8:0:" + hash),
+		"offset too big": EncodeSearchPageToken(pageKey, snapshot, api.MaxSearchDepth),
+	}
+	for desc, pageToken := range cases {
+		offset, decodedSnapshot, err := DecodeSearchPageToken(pageKey, pageToken, time.Time{})
+		assert.Equal(t, ErrInvalidPageToken, err, desc)
+		assert.Zero(t, offset, desc)
+		assert.True(t, decodedSnapshot.IsZero(), desc)
+	}
+}
+
+func TestSplitSearchPage(t *testing.T) {
+	pageKey := "some query"
+	snapshot := time.Date(2006, 1, 2, 15, 4, 5, 6, time.UTC)
+	ranked := make([]*api.SearchResult, 5)
+	for i := range ranked {
+		ranked[i] = &api.SearchResult{Entity: api.NewTestPatient(i, true)}
+	}
+
+	// page with one after
+	page, nextPageToken := SplitSearchPage(ranked, pageKey, snapshot, 2, 2)
+	assert.Equal(t, ranked[2:4], page)
+	assert.Equal(t, EncodeSearchPageToken(pageKey, snapshot, 4), nextPageToken)
+
+	// last page
+	page, nextPageToken = SplitSearchPage(ranked, pageKey, snapshot, 4, 2)
+	assert.Equal(t, ranked[4:], page)
+	assert.Empty(t, nextPageToken)
+
+	// page beyond results
+	page, nextPageToken = SplitSearchPage(ranked, pageKey, snapshot, 6, 2)
+	assert.Empty(t, page)
+	assert.Empty(t, nextPageToken)
+}
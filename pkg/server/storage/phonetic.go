@@ -0,0 +1,632 @@
+package storage
+
+import (
+	"regexp"
+	"strings"
+
+	api "github.com/elixirhealth/directory/pkg/directoryapi"
+)
+
+const (
+	// SilentPPattern matches a P between an M and an S or T, as in Thompson or Simpson, where it
+	// is silent. It is replaced by SilentPReplacement before computing a phonetic code, so such
+	// names get the same code as their variants without the P.
+	SilentPPattern = "MP([ST])"
+
+	// SilentPReplacement replaces SilentPPattern matches with just the M and following letter.
+	SilentPReplacement = `M\1`
+
+	// maxPhoneticCodeLen is the length Postgres' fuzzystrmatch dmetaphone function truncates
+	// codes to.
+	maxPhoneticCodeLen = 4
+
+	// phoneticPadding lets the encoder look past the end of the value, as dmetaphone does.
+	phoneticPadding = "     "
+)
+
+var silentP = regexp.MustCompile(SilentPPattern)
+
+// PhoneticCode returns the phonetic code of the value, which is the primary Double Metaphone code
+// of the value folded by api.FoldName with any silent P dropped. It mirrors the Postgres
+// expression dmetaphone(regexp_replace(folded, SilentPPattern, SilentPReplacement, 'g')) over the
+// folded name search columns, and like dmetaphone only codes ASCII letters.
+func PhoneticCode(value string) string {
+	normalized := silentP.ReplaceAllString(api.FoldName(value), "M$1")
+	return doubleMetaphone(normalized)
+}
+
+// PhoneticCodes returns the non-empty phonetic codes of the words in the folded query.
+func PhoneticCodes(query string) []string {
+	words := strings.Fields(api.FoldName(query))
+	codes := make([]string, 0, len(words))
+	for _, word := range words {
+		if code := PhoneticCode(word); code != "" {
+			codes = append(codes, code)
+		}
+	}
+	return codes
+}
+
+// doubleMetaphone returns the primary Double Metaphone code of the upper-cased value, following
+// Lawrence Philips' original algorithm as implemented by Postgres' fuzzystrmatch module
+func doubleMetaphone(value string) string {
+	m := &metaphone{
+		original: value + phoneticPadding,
+		length:   len(value),
+		last:     len(value) - 1,
+	}
+	m.slavoGermanic = strings.ContainsAny(value, "WK") || strings.Contains(value, "CZ") ||
+		strings.Contains(value, "WITZ")
+	return m.encode()
+}
+
+type metaphone struct {
+	original      string
+	length        int
+	last          int
+	current       int
+	slavoGermanic bool
+	primary       strings.Builder
+}
+
+func (m *metaphone) getAt(pos int) byte {
+	if pos < 0 || pos >= len(m.original) {
+		return 0
+	}
+	return m.original[pos]
+}
+
+func (m *metaphone) stringAt(start, length int, options ...string) bool {
+	if start < 0 || start >= len(m.original) || start+length > len(m.original) {
+		return false
+	}
+	sub := m.original[start : start+length]
+	for _, option := range options {
+		if sub == option {
+			return true
+		}
+	}
+	return false
+}
+
+func (m *metaphone) isVowel(pos int) bool {
+	switch m.getAt(pos) {
+	case 'A', 'E', 'I', 'O', 'U', 'Y':
+		return true
+	}
+	return false
+}
+
+func (m *metaphone) add(code string) {
+	m.primary.WriteString(code)
+}
+
+// skip advances past the current letter, and the next one too if it is the same
+func (m *metaphone) skip(letter byte) {
+	if m.getAt(m.current+1) == letter {
+		m.current += 2
+	} else {
+		m.current++
+	}
+}
+
+func (m *metaphone) germanic() bool {
+	return m.stringAt(0, 4, "VAN ", "VON ") || m.stringAt(0, 3, "SCH")
+}
+
+func (m *metaphone) encode() string {
+	// skip these when at start of word
+	if m.stringAt(0, 2, "GN", "KN", "PN", "WR", "PS") {
+		m.current++
+	}
+	// initial 'X' is pronounced 'Z' e.g. 'Xavier'
+	if m.getAt(0) == 'X' {
+		m.add("S")
+		m.current++
+	}
+	for m.primary.Len() < maxPhoneticCodeLen && m.current < m.length {
+		switch m.getAt(m.current) {
+		case 'A', 'E', 'I', 'O', 'U', 'Y':
+			// all initial vowels map to 'A'
+			if m.current == 0 {
+				m.add("A")
+			}
+			m.current++
+		case 'B':
+			// "-mb", e.g., "dumb", already skipped over
+			m.add("P")
+			m.skip('B')
+		case 'C':
+			m.encodeC()
+		case 'D':
+			m.encodeD()
+		case 'F':
+			m.add("F")
+			m.skip('F')
+		case 'G':
+			m.encodeG()
+		case 'H':
+			// only keep if first & before vowel or between 2 vowels
+			if (m.current == 0 || m.isVowel(m.current-1)) && m.isVowel(m.current+1) {
+				m.add("H")
+				m.current += 2
+			} else {
+				m.current++
+			}
+		case 'J':
+			m.encodeJ()
+		case 'K':
+			m.add("K")
+			m.skip('K')
+		case 'L':
+			m.encodeL()
+		case 'M':
+			if (m.stringAt(m.current-1, 3, "UMB") &&
+				(m.current+1 == m.last || m.stringAt(m.current+2, 2, "ER"))) ||
+				m.getAt(m.current+1) == 'M' {
+				m.current += 2
+			} else {
+				m.current++
+			}
+			m.add("M")
+		case 'N':
+			m.add("N")
+			m.skip('N')
+		case 'P':
+			if m.getAt(m.current+1) == 'H' {
+				m.add("F")
+				m.current += 2
+				break
+			}
+			// also account for "campbell", "raspberry"
+			if m.stringAt(m.current+1, 1, "P", "B") {
+				m.current += 2
+			} else {
+				m.current++
+			}
+			m.add("P")
+		case 'Q':
+			m.add("K")
+			m.skip('Q')
+		case 'R':
+			// french e.g. 'rogier', but exclude 'hochmeier', which only differ in the
+			// alternate code
+			if !(m.current == m.last && !m.slavoGermanic && m.stringAt(m.current-2, 2, "IE") &&
+				!m.stringAt(m.current-4, 2, "ME", "MA")) {
+				m.add("R")
+			}
+			m.skip('R')
+		case 'S':
+			m.encodeS()
+		case 'T':
+			m.encodeT()
+		case 'V':
+			m.add("F")
+			m.skip('V')
+		case 'W':
+			m.encodeW()
+		case 'X':
+			// french e.g. breaux
+			if !(m.current == m.last && (m.stringAt(m.current-3, 3, "IAU", "EAU") ||
+				m.stringAt(m.current-2, 2, "AU", "OU"))) {
+				m.add("KS")
+			}
+			if m.stringAt(m.current+1, 1, "C", "X") {
+				m.current += 2
+			} else {
+				m.current++
+			}
+		case 'Z':
+			// chinese pinyin e.g. 'zhao'
+			if m.getAt(m.current+1) == 'H' {
+				m.add("J")
+				m.current += 2
+				break
+			}
+			m.add("S")
+			m.skip('Z')
+		default:
+			m.current++
+		}
+	}
+	code := m.primary.String()
+	if len(code) > maxPhoneticCodeLen {
+		return code[:maxPhoneticCodeLen]
+	}
+	return code
+}
+
+func (m *metaphone) encodeC() {
+	c := m.current
+
+	// various germanic
+	if c > 1 && !m.isVowel(c-2) && m.stringAt(c-1, 3, "ACH") && m.getAt(c+2) != 'I' &&
+		(m.getAt(c+2) != 'E' || m.stringAt(c-2, 6, "BACHER", "MACHER")) {
+		m.add("K")
+		m.current += 2
+		return
+	}
+	// special case 'caesar'
+	if c == 0 && m.stringAt(c, 6, "CAESAR") {
+		m.add("S")
+		m.current += 2
+		return
+	}
+	// italian 'chianti'
+	if m.stringAt(c, 4, "CHIA") {
+		m.add("K")
+		m.current += 2
+		return
+	}
+	if m.stringAt(c, 2, "CH") {
+		m.encodeCH()
+		return
+	}
+	// e.g, 'czerny'
+	if m.stringAt(c, 2, "CZ") && !m.stringAt(c-2, 4, "WICZ") {
+		m.add("S")
+		m.current += 2
+		return
+	}
+	// e.g., 'focaccia'
+	if m.stringAt(c+1, 3, "CIA") {
+		m.add("X")
+		m.current += 3
+		return
+	}
+	// double 'C', but not if e.g. 'McClellan'
+	if m.stringAt(c, 2, "CC") && !(c == 1 && m.getAt(0) == 'M') {
+		// 'bellocchio' but not 'bacchus'
+		if m.stringAt(c+2, 1, "I", "E", "H") && !m.stringAt(c+2, 2, "HU") {
+			// 'accident', 'accede' 'succeed'
+			if (c == 1 && m.getAt(c-1) == 'A') || m.stringAt(c-1, 5, "UCCEE", "UCCES") {
+				m.add("KS")
+			} else {
+				// 'bacci', 'bertucci', other italian
+				m.add("X")
+			}
+			m.current += 3
+			return
+		}
+		// Pierce's rule
+		m.add("K")
+		m.current += 2
+		return
+	}
+	if m.stringAt(c, 2, "CK", "CG", "CQ") {
+		m.add("K")
+		m.current += 2
+		return
+	}
+	if m.stringAt(c, 2, "CI", "CE", "CY") {
+		// italian vs. english, which only differ in the alternate code
+		m.add("S")
+		m.current += 2
+		return
+	}
+	m.add("K")
+	// name sent in 'mac caffrey', 'mac gregor'
+	if m.stringAt(c+1, 2, " C", " Q", " G") {
+		m.current += 3
+	} else if m.stringAt(c+1, 1, "C", "K", "Q") && !m.stringAt(c+1, 2, "CE", "CI") {
+		m.current += 2
+	} else {
+		m.current++
+	}
+}
+
+func (m *metaphone) encodeCH() {
+	c := m.current
+	defer func() { m.current += 2 }()
+
+	// find 'michael'
+	if c > 0 && m.stringAt(c, 4, "CHAE") {
+		m.add("K")
+		return
+	}
+	// greek roots e.g. 'chemistry', 'chorus'
+	if c == 0 && (m.stringAt(c+1, 5, "HARAC", "HARIS") ||
+		m.stringAt(c+1, 3, "HOR", "HYM", "HIA", "HEM")) && !m.stringAt(0, 5, "CHORE") {
+		m.add("K")
+		return
+	}
+	// germanic, greek, or otherwise 'ch' for 'kh' sound
+	if m.germanic() ||
+		// 'architect but not 'arch', 'orchestra', 'orchid'
+		m.stringAt(c-2, 6, "ORCHES", "ARCHIT", "ORCHID") ||
+		m.stringAt(c+2, 1, "T", "S") ||
+		((m.stringAt(c-1, 1, "A", "O", "U", "E") || c == 0) &&
+			// e.g., 'wachtler', 'wechsler', but not 'tichner'
+			m.stringAt(c+2, 1, "L", "R", "N", "M", "B", "H", "F", "V", "W", " ")) {
+		m.add("K")
+		return
+	}
+	if c > 0 && m.stringAt(0, 2, "MC") {
+		// e.g., "McHugh"
+		m.add("K")
+		return
+	}
+	m.add("X")
+}
+
+func (m *metaphone) encodeD() {
+	c := m.current
+	if m.stringAt(c, 2, "DG") {
+		if m.stringAt(c+2, 1, "I", "E", "Y") {
+			// e.g. 'edge'
+			m.add("J")
+			m.current += 3
+			return
+		}
+		// e.g. 'edgar'
+		m.add("TK")
+		m.current += 2
+		return
+	}
+	if m.stringAt(c, 2, "DT", "DD") {
+		m.add("T")
+		m.current += 2
+		return
+	}
+	m.add("T")
+	m.current++
+}
+
+func (m *metaphone) encodeG() {
+	c := m.current
+	if m.getAt(c+1) == 'H' {
+		m.encodeGH()
+		return
+	}
+	if m.getAt(c+1) == 'N' {
+		if c == 1 && m.isVowel(0) && !m.slavoGermanic {
+			m.add("KN")
+		} else if !m.stringAt(c+2, 2, "EY") && m.getAt(c+1) != 'Y' && !m.slavoGermanic {
+			// not e.g. 'cagney'
+			m.add("N")
+		} else {
+			m.add("KN")
+		}
+		m.current += 2
+		return
+	}
+	// 'tagliaro'
+	if m.stringAt(c+1, 2, "LI") && !m.slavoGermanic {
+		m.add("KL")
+		m.current += 2
+		return
+	}
+	// -ges-, -gep-, -gel-, -gie- at beginning
+	if c == 0 && (m.getAt(c+1) == 'Y' || m.stringAt(c+1, 2, "ES", "EP", "EB", "EL", "EY", "IB",
+		"IL", "IN", "IE", "EI", "ER")) {
+		m.add("K")
+		m.current += 2
+		return
+	}
+	// -ger-, -gy-
+	if (m.stringAt(c+1, 2, "ER") || m.getAt(c+1) == 'Y') &&
+		!m.stringAt(0, 6, "DANGER", "RANGER", "MANGER") && !m.stringAt(c-1, 1, "E", "I") &&
+		!m.stringAt(c-1, 3, "RGY", "OGY") {
+		m.add("K")
+		m.current += 2
+		return
+	}
+	// italian e.g, 'biaggi'
+	if m.stringAt(c+1, 1, "E", "I", "Y") || m.stringAt(c-1, 4, "AGGI", "OGGI") {
+		if m.germanic() || m.stringAt(c+1, 2, "ET") {
+			// obvious germanic
+			m.add("K")
+		} else {
+			// always soft if french ending, and otherwise only differ in the alternate code
+			m.add("J")
+		}
+		m.current += 2
+		return
+	}
+	m.add("K")
+	m.skip('G')
+}
+
+func (m *metaphone) encodeGH() {
+	c := m.current
+	defer func() { m.current += 2 }()
+
+	if c > 0 && !m.isVowel(c-1) {
+		m.add("K")
+		return
+	}
+	// 'ghislane', 'ghiradelli'
+	if c == 0 {
+		if m.getAt(c+2) == 'I' {
+			m.add("J")
+		} else {
+			m.add("K")
+		}
+		return
+	}
+	// Parker's rule (with some further refinements) - e.g., 'hugh', 'bough', 'broughton'
+	if (c > 1 && m.stringAt(c-2, 1, "B", "H", "D")) ||
+		(c > 2 && m.stringAt(c-3, 1, "B", "H", "D")) ||
+		(c > 3 && m.stringAt(c-4, 1, "B", "H")) {
+		return
+	}
+	// e.g., 'laugh', 'McLaughlin', 'cough', 'gough', 'rough', 'tough'
+	if c > 2 && m.getAt(c-1) == 'U' && m.stringAt(c-3, 1, "C", "G", "L", "R", "T") {
+		m.add("F")
+	} else if m.getAt(c-1) != 'I' {
+		m.add("K")
+	}
+}
+
+func (m *metaphone) encodeJ() {
+	c := m.current
+	// obvious spanish, 'jose', 'san jacinto'
+	if m.stringAt(c, 4, "JOSE") || m.stringAt(0, 4, "SAN ") {
+		if (c == 0 && m.getAt(c+4) == ' ') || m.stringAt(0, 4, "SAN ") {
+			m.add("H")
+		} else {
+			m.add("J")
+		}
+		m.current++
+		return
+	}
+	if c == 0 {
+		// Yankelovich/Jankelowicz
+		m.add("J")
+	} else if m.isVowel(c-1) && !m.slavoGermanic &&
+		(m.getAt(c+1) == 'A' || m.getAt(c+1) == 'O') {
+		// spanish pron. of e.g. 'bajador'
+		m.add("J")
+	} else if c == m.last {
+		m.add("J")
+	} else if !m.stringAt(c+1, 1, "L", "T", "K", "S", "N", "M", "B", "Z") &&
+		!m.stringAt(c-1, 1, "S", "K", "L") {
+		m.add("J")
+	}
+	m.skip('J')
+}
+
+func (m *metaphone) encodeL() {
+	c := m.current
+	if m.getAt(c+1) == 'L' {
+		// spanish e.g. 'cabrillo', 'gallegos', which only differ in the alternate code
+		m.current += 2
+	} else {
+		m.current++
+	}
+	m.add("L")
+}
+
+func (m *metaphone) encodeS() {
+	c := m.current
+
+	// special cases 'island', 'isle', 'carlisle', 'carlysle'
+	if m.stringAt(c-1, 3, "ISL", "YSL") {
+		m.current++
+		return
+	}
+	// special case 'sugar-'
+	if c == 0 && m.stringAt(c, 5, "SUGAR") {
+		m.add("X")
+		m.current++
+		return
+	}
+	if m.stringAt(c, 2, "SH") {
+		if m.stringAt(c+1, 4, "HEIM", "HOEK", "HOLM", "HOLZ") {
+			// germanic
+			m.add("S")
+		} else {
+			m.add("X")
+		}
+		m.current += 2
+		return
+	}
+	// italian & armenian
+	if m.stringAt(c, 3, "SIO", "SIA") || m.stringAt(c, 4, "SIAN") {
+		m.add("S")
+		m.current += 3
+		return
+	}
+	// german & anglicisations, e.g. 'smith' match 'schmidt', 'snider' match 'schneider'; also,
+	// -sz- in slavic language although in hungarian it is pronounced 's'
+	if (c == 0 && m.stringAt(c+1, 1, "M", "N", "L", "W")) || m.stringAt(c+1, 1, "Z") {
+		m.add("S")
+		if m.stringAt(c+1, 1, "Z") {
+			m.current += 2
+		} else {
+			m.current++
+		}
+		return
+	}
+	if m.stringAt(c, 2, "SC") {
+		m.encodeSC()
+		return
+	}
+	// french e.g. 'resnais', 'artois'
+	if !(c == m.last && m.stringAt(c-2, 2, "AI", "OI")) {
+		m.add("S")
+	}
+	if m.stringAt(c+1, 1, "S", "Z") {
+		m.current += 2
+	} else {
+		m.current++
+	}
+}
+
+func (m *metaphone) encodeSC() {
+	c := m.current
+	defer func() { m.current += 3 }()
+
+	// Schlesinger's rule
+	if m.getAt(c+2) == 'H' {
+		if m.stringAt(c+3, 2, "OO", "ER", "EN", "UY", "ED", "EM") {
+			// dutch origin, e.g. 'school', 'schooner', and 'schermerhorn', 'schenker'
+			if m.stringAt(c+3, 2, "ER", "EN") {
+				m.add("X")
+			} else {
+				m.add("SK")
+			}
+			return
+		}
+		m.add("X")
+		return
+	}
+	if m.stringAt(c+2, 1, "I", "E", "Y") {
+		m.add("S")
+		return
+	}
+	m.add("SK")
+}
+
+func (m *metaphone) encodeT() {
+	c := m.current
+	if m.stringAt(c, 4, "TION") || m.stringAt(c, 3, "TIA", "TCH") {
+		m.add("X")
+		m.current += 3
+		return
+	}
+	if m.stringAt(c, 2, "TH") || m.stringAt(c, 3, "TTH") {
+		// special case 'thomas', 'thames' or germanic
+		if m.stringAt(c+2, 2, "OM", "AM") || m.germanic() {
+			m.add("T")
+		} else {
+			m.add("0")
+		}
+		m.current += 2
+		return
+	}
+	if m.stringAt(c+1, 1, "T", "D") {
+		m.current += 2
+	} else {
+		m.current++
+	}
+	m.add("T")
+}
+
+func (m *metaphone) encodeW() {
+	c := m.current
+
+	// can also be in middle of word
+	if m.stringAt(c, 2, "WR") {
+		m.add("R")
+		m.current += 2
+		return
+	}
+	// Wasserman should match Vasserman, and Uomo should match Womo
+	if c == 0 && (m.isVowel(c+1) || m.stringAt(c, 2, "WH")) {
+		m.add("A")
+	}
+	// Arnow should match Arnoff, which only differ in the alternate code
+	if (c == m.last && m.isVowel(c-1)) ||
+		m.stringAt(c-1, 5, "EWSKI", "EWSKY", "OWSKI", "OWSKY") || m.stringAt(0, 3, "SCH") {
+		m.current++
+		return
+	}
+	// polish e.g. 'filipowicz'
+	if m.stringAt(c, 4, "WICZ", "WITZ") {
+		m.add("TS")
+		m.current += 4
+		return
+	}
+	m.current++
+}
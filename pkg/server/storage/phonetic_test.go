@@ -0,0 +1,47 @@
+package storage
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPhoneticCode(t *testing.T) {
+	cases := map[string]string{
+		"Thompson":  "TMSN",
+		"Tomson":    "TMSN",
+		"Katherine": "K0RN",
+		"Cathryn":   "K0RN",
+		"smith":     "SM0",
+		"Schmidt":   "XMT",
+		"Garcia":    "KRS",
+		"Jose":      "HS",
+		"Xavier":    "SF",
+		"Knight":    "NT",
+		"Caesar":    "SSR",
+		"Michael":   "MKL",
+		"Wasserman": "ASRM",
+		"Bacchus":   "PKS",
+		"Edge":      "AJ",
+		"Laugh":     "LF",
+		"Muñoz":     "MNS",
+		"O'Brien":   "APRN",
+		"":          "",
+		"123":       "",
+	}
+	for value, expected := range cases {
+		assert.Equal(t, expected, PhoneticCode(value), value)
+	}
+}
+
+func TestDoubleMetaphone(t *testing.T) {
+	// without dropping the silent P first
+	assert.Equal(t, "TMPS", doubleMetaphone("THOMPSON"))
+	assert.Equal(t, "ARKT", doubleMetaphone("ARCHITECT"))
+}
+
+func TestPhoneticCodes(t *testing.T) {
+	assert.Equal(t, []string{"TMSN", "K0RN"}, PhoneticCodes("tomson  cathryn 123"))
+	assert.Equal(t, []string{"SM0", "JNS"}, PhoneticCodes("smith-jones"))
+	assert.Empty(t, PhoneticCodes(""))
+}
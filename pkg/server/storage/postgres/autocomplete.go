@@ -0,0 +1,47 @@
+package postgres
+
+import (
+	sq "github.com/Masterminds/squirrel"
+	"github.com/elixirhealth/directory/pkg/server/storage"
+)
+
+// byteOrder collates the autocomplete keys byte-wise, like storage.TopSuggestions orders them, so
+// the autocomplete indexes serve both the LIKE prefix predicates and the ordering
+const byteOrder = ` COLLATE "C"`
+
+// autocompleteKeys returns the expressions of the autocomplete indexes for the entity type,
+// mirroring storage.AutocompleteKeys; results are ordered by the first
+func autocompleteKeys(et storage.EntityType) []string {
+	switch et {
+	case storage.Patient:
+		return []string{
+			"((" + lastNameSearchCol + " || ' ' || " + firstNameSearchCol + ")" + byteOrder + ")",
+			"((" + firstNameSearchCol + " || ' ' || " + lastNameSearchCol + ")" + byteOrder + ")",
+		}
+	case storage.Office:
+		return []string{"(" + nameSearchCol + byteOrder + ")"}
+	default:
+		panic(storage.ErrUnknownEntityType)
+	}
+}
+
+// getAutocompleteSelect returns the SELECT query for the first limit current entities of the
+// given type with an autocomplete key starting with the folded prefix. The selected columns are
+// the entity scan columns.
+func getAutocompleteSelect(
+	et storage.EntityType, foldedPrefix string, limit uint,
+) sq.SelectBuilder {
+	cols, _, _ := prepEntityScan(et, 0)
+	keys := autocompleteKeys(et)
+	pattern := escapeLike(foldedPrefix) + "%"
+	prefixed := make(sq.Or, len(keys))
+	for i, key := range keys {
+		prefixed[i] = sq.Expr(key+" LIKE ?", pattern)
+	}
+	return psql.Select(cols...).
+		From(fullTableName(et)).
+		Where(currentRow).
+		Where(prefixed).
+		OrderBy(keys[0], entityIDCol).
+		Limit(uint64(limit))
+}
@@ -0,0 +1,26 @@
+package postgres
+
+import (
+	"testing"
+
+	"github.com/elixirhealth/directory/pkg/server/storage"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetAutocompleteSelect(t *testing.T) {
+	lastFirst := `((last_name_search || ' ' || first_name_search) COLLATE "C")`
+	firstLast := `((first_name_search || ' ' || last_name_search) COLLATE "C")`
+	qSQL, args, err := getAutocompleteSelect(storage.Patient, "GARCIA J", 4).ToSql()
+	assert.Nil(t, err)
+	assert.Contains(t, qSQL, "FROM entity.patient WHERE upper_inf(transaction_period)")
+	assert.Contains(t, qSQL, "("+lastFirst+" LIKE $1 OR "+firstLast+" LIKE $2)")
+	assert.Contains(t, qSQL, "ORDER BY "+lastFirst+", entity_id LIMIT 4")
+	assert.Equal(t, []interface{}{"GARCIA J%", "GARCIA J%"}, args)
+
+	qSQL, args, err = getAutocompleteSelect(storage.Office, "100%", 8).ToSql()
+	assert.Nil(t, err)
+	assert.Contains(t, qSQL, "FROM entity.office WHERE upper_inf(transaction_period)")
+	assert.Contains(t, qSQL, `((name_search COLLATE "C") LIKE $1)`)
+	assert.Contains(t, qSQL, `ORDER BY (name_search COLLATE "C"), entity_id LIMIT 8`)
+	assert.Equal(t, []interface{}{`100\%%`}, args)
+}
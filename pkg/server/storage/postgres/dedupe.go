@@ -0,0 +1,54 @@
+package postgres
+
+import (
+	"fmt"
+
+	sq "github.com/Masterminds/squirrel"
+	"github.com/elixirhealth/directory/pkg/server/storage"
+)
+
+const (
+	dupAlias1 = "p1"
+	dupAlias2 = "p2"
+)
+
+// patientNameExpr returns the folded patient name expression of the patient name search trigram
+// index for the patient table with the given alias, so candidate pairs can be found via the index
+func patientNameExpr(alias string) string {
+	return fmt.Sprintf("(%[1]s.%[2]s || ' ' || %[1]s.%[3]s)", alias, lastNameSearchCol,
+		firstNameSearchCol)
+}
+
+// qualifiedCols returns the columns qualified by the given table alias
+func qualifiedCols(alias string, cols []string) []string {
+	qCols := make([]string, len(cols))
+	for i, col := range cols {
+		qCols[i] = alias + "." + col
+	}
+	return qCols
+}
+
+// getDuplicatesSelect returns the SELECT query for current patient pairs whose names are
+// trigram-similar and whose storage.DuplicateScore is at least the threshold, with each pair
+// ordered by entity ID. The selected columns are the patient scan columns for each patient of the
+// pair followed by their name similarity and whether their birthdates match.
+func getDuplicatesSelect(threshold float32, limit uint) sq.SelectBuilder {
+	cols, _, _ := prepPatientScan(0)
+	name1, name2 := patientNameExpr(dupAlias1), patientNameExpr(dupAlias2)
+	nameSim := fmt.Sprintf("similarity(%s, %s)", name1, name2)
+	birthdateMatch := fmt.Sprintf("%s.%s = %s.%s", dupAlias1, birthdateCol, dupAlias2,
+		birthdateCol)
+	score := fmt.Sprintf("(%s + CASE WHEN %s THEN 1 ELSE 0 END) / 2", nameSim, birthdateMatch)
+	return psql.Select(qualifiedCols(dupAlias1, cols)...).
+		Columns(qualifiedCols(dupAlias2, cols)...).
+		Columns(nameSim, birthdateMatch).
+		From(fullTableName(storage.Patient)+" "+dupAlias1).
+		Join(fmt.Sprintf("%s %s ON %s.%s < %s.%s AND %s %% %s",
+			fullTableName(storage.Patient), dupAlias2, dupAlias1, entityIDCol, dupAlias2,
+			entityIDCol, name1, name2)).
+		Where(fmt.Sprintf("upper_inf(%s.%s)", dupAlias1, transactionPeriodCol)).
+		Where(fmt.Sprintf("upper_inf(%s.%s)", dupAlias2, transactionPeriodCol)).
+		Where(score+" >= ?", threshold).
+		OrderBy(score+" DESC", dupAlias1+"."+entityIDCol, dupAlias2+"."+entityIDCol).
+		Limit(uint64(limit))
+}
@@ -0,0 +1,28 @@
+package postgres
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestQualifiedCols(t *testing.T) {
+	assert.Equal(t, []string{"p1.entity_id", "p1.name"},
+		qualifiedCols(dupAlias1, []string{entityIDCol, nameCol}))
+}
+
+func TestGetDuplicatesSelect(t *testing.T) {
+	qSQL, args, err := getDuplicatesSelect(0.8, 10).ToSql()
+	assert.Nil(t, err)
+	assert.Equal(t, []interface{}{float32(0.8)}, args)
+
+	// the join uses the patient name trigram index expression
+	name1 := "(p1.last_name_search || ' ' || p1.first_name_search)"
+	name2 := "(p2.last_name_search || ' ' || p2.first_name_search)"
+	assert.Contains(t, qSQL, "JOIN entity.patient p2 ON p1.entity_id < p2.entity_id AND "+
+		name1+" % "+name2)
+	assert.Contains(t, qSQL, "upper_inf(p1.transaction_period)")
+	assert.Contains(t, qSQL, "upper_inf(p2.transaction_period)")
+	assert.Contains(t, qSQL, ">= $1")
+	assert.Contains(t, qSQL, "LIMIT 10")
+}
@@ -1,17 +1,38 @@
 package postgres
 
 import (
+	"sort"
 	"strings"
 	"time"
 
 	api "github.com/elixirhealth/directory/pkg/directoryapi"
 	"github.com/elixirhealth/directory/pkg/server/storage"
+	"github.com/golang/protobuf/ptypes"
 )
 
 const (
-	entitySchema  = "entity"
-	entityIDCol   = "entity_id"
-	similarityCol = "sim"
+	entitySchema         = "entity"
+	rowIDCol             = "row_id"
+	entityIDCol          = "entity_id"
+	versionCol           = "version"
+	mergedIntoIDCol      = "merged_into_id"
+	revertedToVersionCol = "reverted_to_version"
+	revertedByCol        = "reverted_by"
+	revertReasonCol      = "revert_reason"
+	transactionPeriodCol = "transaction_period"
+	similarityCol        = "sim"
+
+	// currentRow is the predicate identifying a row whose transaction period has not been
+	// closed, i.e., one that has not been deleted
+	currentRow = "upper_inf(" + transactionPeriodCol + ")"
+
+	// closedTransactionPeriod is the transaction period value for a row being closed now
+	closedTransactionPeriod = "tstzrange(lower(" + transactionPeriodCol + "), NOW(), '[)')"
+
+	// validFromCol and validToCol are the bounds of a row's transaction period, with the latter
+	// NULL for a current row
+	validFromCol = "lower(" + transactionPeriodCol + ")"
+	validToCol   = "NULLIF(upper(" + transactionPeriodCol + "), 'infinity')"
 
 	// patient attribute indexedValue
 	lastNameCol   = "last_name"
@@ -20,8 +41,16 @@ const (
 	suffixCol     = "suffix"
 	birthdateCol  = "birthdate"
 
+	// lastNameSearchCol and firstNameSearchCol are the patient names folded by api.FoldName,
+	// which the name searchers search
+	lastNameSearchCol  = "last_name_search"
+	firstNameSearchCol = "first_name_search"
+
 	// office attribute indexedValue
 	nameCol = "name"
+
+	// nameSearchCol is the office name folded by api.FoldName, which the name searcher searches
+	nameSearchCol = "name_search"
 )
 
 func fullTableName(et storage.EntityType) string {
@@ -41,9 +70,73 @@ func getPutStmtValues(e *api.Entity) map[string]interface{} {
 		panic(storage.ErrUnknownEntityType)
 	}
 	vals[entityIDCol] = e.EntityId
+	vals[versionCol] = e.Version
 	return vals
 }
 
+// isValidAt returns whether a version with the given transaction period end is valid at the
+// given time, or is current if asOf is zero
+func isValidAt(validTo *time.Time, asOf time.Time) bool {
+	if validTo == nil {
+		return true
+	}
+	return !asOf.IsZero() && asOf.Before(*validTo)
+}
+
+// newEntityVersion creates an *api.EntityVersion for the given entity, the bounds of its
+// transaction period, where a nil validTo denotes the current version, the entity ID of the
+// survivor it was merged into, if any, and the revert that stored it, if any
+func newEntityVersion(
+	e *api.Entity,
+	validFrom time.Time,
+	validTo *time.Time,
+	mergedIntoID *string,
+	revert *api.Revert,
+) (*api.EntityVersion, error) {
+	v := &api.EntityVersion{Entity: e, Revert: revert}
+	if mergedIntoID != nil {
+		v.MergedIntoId = *mergedIntoID
+	}
+	var err error
+	if v.ValidFrom, err = ptypes.TimestampProto(validFrom); err != nil {
+		return nil, err
+	}
+	if validTo != nil {
+		if v.ValidTo, err = ptypes.TimestampProto(*validTo); err != nil {
+			return nil, err
+		}
+	}
+	return v, nil
+}
+
+// newRevert creates an *api.Revert from the nullable revert column values of a row, which are
+// all NULL for a row not stored by a revert
+func newRevert(toVersion *uint64, revertedBy, reason *string) *api.Revert {
+	if toVersion == nil {
+		return nil
+	}
+	r := &api.Revert{ToVersion: *toVersion}
+	if revertedBy != nil {
+		r.RevertedBy = *revertedBy
+	}
+	if reason != nil {
+		r.Reason = *reason
+	}
+	return r
+}
+
+// getPutStmtCols returns the sorted column names of the values from getPutStmtValues for
+// entities of the same type as the given entity
+func getPutStmtCols(e *api.Entity) []string {
+	vals := getPutStmtValues(e)
+	cols := make([]string, 0, len(vals))
+	for col := range vals {
+		cols = append(cols, col)
+	}
+	sort.Strings(cols)
+	return cols
+}
+
 // prepEntityScan returns the table columns, destination slice, and an entity creation function for
 // use in an entity SELECT statement
 func prepEntityScan(
@@ -70,6 +163,7 @@ func prepPatientScan(nExtraDests int) ([]string, []interface{}, func() *api.Enti
 		{middleNameCol, &p.MiddleName},
 		{suffixCol, &p.Suffix},
 		{birthdateCol, &birthdateTime},
+		{versionCol, &e.Version},
 	}
 	cols, dests := splitColDests(cds, nExtraDests)
 	return cols, dests, func() *api.Entity {
@@ -84,6 +178,7 @@ func prepPatientScan(nExtraDests int) ([]string, []interface{}, func() *api.Enti
 			Month: uint32(birthdateTime.Month()),
 			Day:   uint32(birthdateTime.Day()),
 		}
+		e.Version = *dests[6].(*uint64)
 		return e
 	}
 }
@@ -94,11 +189,13 @@ func prepOfficeScan(nExtraDests int) ([]string, []interface{}, func() *api.Entit
 	cds := []*colDest{
 		{entityIDCol, &e.EntityId},
 		{nameCol, &f.Name},
+		{versionCol, &e.Version},
 	}
 	cols, dests := splitColDests(cds, nExtraDests)
 	return cols, dests, func() *api.Entity {
 		e.EntityId = *dests[0].(*string)
 		f.Name = *dests[1].(*string)
+		e.Version = *dests[2].(*uint64)
 		return e
 	}
 }
@@ -110,12 +207,16 @@ func getPutPatientStmtValues(p *api.Patient) map[string]interface{} {
 		middleNameCol: p.MiddleName,
 		suffixCol:     p.Suffix,
 		birthdateCol:  p.Birthdate.ISO8601(),
+
+		lastNameSearchCol:  api.FoldName(p.LastName),
+		firstNameSearchCol: api.FoldName(p.FirstName),
 	}
 }
 
 func getPutOfficeStmtValues(f *api.Office) map[string]interface{} {
 	return map[string]interface{}{
-		nameCol: f.Name,
+		nameCol:       f.Name,
+		nameSearchCol: api.FoldName(f.Name),
 	}
 }
 
@@ -10,6 +10,7 @@ import (
 
 func TestPreparePatientScan(t *testing.T) {
 	e1 := api.NewTestPatient(0, true)
+	e1.Version = 2
 	p1 := e1.TypeAttributes.(*api.Entity_Patient).Patient
 
 	cols, dests, scan := prepPatientScan(0)
@@ -24,6 +25,7 @@ func TestPreparePatientScan(t *testing.T) {
 	birthdateTime, err := time.Parse("2006-01-02", p1.Birthdate.ISO8601())
 	assert.Nil(t, err)
 	dests[5] = &birthdateTime
+	dests[6] = &e1.Version
 
 	e2 := scan()
 	assert.Equal(t, e1, e2)
@@ -37,6 +39,7 @@ func TestPrepareOfficeScan(t *testing.T) {
 				Name: "Name 1",
 			},
 		},
+		Version: 2,
 	}
 	f1 := e1.TypeAttributes.(*api.Entity_Office).Office
 
@@ -46,7 +49,71 @@ func TestPrepareOfficeScan(t *testing.T) {
 	// simulate row.Scan(dest...)
 	dests[0] = &e1.EntityId
 	dests[1] = &f1.Name
+	dests[2] = &e1.Version
 
 	e2 := create()
 	assert.Equal(t, e1, e2)
 }
+
+func TestNewEntityVersion(t *testing.T) {
+	e := api.NewTestPatient(0, true)
+	validFrom := time.Date(2006, 1, 2, 15, 4, 5, 0, time.UTC)
+	validTo := validFrom.Add(time.Hour)
+
+	// current version
+	v, err := newEntityVersion(e, validFrom, nil, nil, nil)
+	assert.Nil(t, err)
+	assert.Equal(t, e, v.Entity)
+	assert.Equal(t, validFrom.Unix(), v.ValidFrom.Seconds)
+	assert.Nil(t, v.ValidTo)
+
+	// superseded version
+	v, err = newEntityVersion(e, validFrom, &validTo, nil, nil)
+	assert.Nil(t, err)
+	assert.Equal(t, validTo.Unix(), v.ValidTo.Seconds)
+	assert.Empty(t, v.MergedIntoId)
+
+	// merged version
+	mergedIntoID := "some survivor ID"
+	v, err = newEntityVersion(e, validFrom, &validTo, &mergedIntoID, nil)
+	assert.Nil(t, err)
+	assert.Equal(t, mergedIntoID, v.MergedIntoId)
+
+	// bad timestamp
+	v, err = newEntityVersion(e, time.Date(10000, 1, 1, 0, 0, 0, 0, time.UTC), nil, nil, nil)
+	assert.NotNil(t, err)
+	assert.Nil(t, v)
+}
+
+func TestNewRevert(t *testing.T) {
+	assert.Nil(t, newRevert(nil, nil, nil))
+
+	toVersion, revertedBy, reason := uint64(1), "some registrar", "some reason"
+	expected := &api.Revert{ToVersion: toVersion, RevertedBy: revertedBy, Reason: reason}
+	assert.Equal(t, expected, newRevert(&toVersion, &revertedBy, &reason))
+}
+
+func TestIsValidAt(t *testing.T) {
+	asOf := time.Date(2006, 1, 2, 15, 4, 5, 0, time.UTC)
+	before, after := asOf.Add(-time.Second), asOf.Add(time.Second)
+
+	assert.True(t, isValidAt(nil, time.Time{}))
+	assert.True(t, isValidAt(nil, asOf))
+	assert.True(t, isValidAt(&after, asOf))
+	assert.False(t, isValidAt(&asOf, asOf))
+	assert.False(t, isValidAt(&before, asOf))
+	assert.False(t, isValidAt(&after, time.Time{}))
+}
+
+func TestGetPutStmtCols(t *testing.T) {
+	p := api.NewTestPatient(0, true)
+	assert.Equal(t,
+		[]string{birthdateCol, entityIDCol, firstNameCol, firstNameSearchCol, lastNameCol,
+			lastNameSearchCol, middleNameCol, suffixCol, versionCol},
+		getPutStmtCols(p),
+	)
+
+	f := api.NewTestOffice(0, true)
+	assert.Equal(t, []string{entityIDCol, nameCol, nameSearchCol, versionCol},
+		getPutStmtCols(f))
+}
@@ -10,17 +10,33 @@ import (
 )
 
 const (
-	logEntityType = "entity_type"
-	logQuery      = "query"
-	logSQL        = "sql"
-	logArgs       = "args"
-	logEntityID   = "entity_id"
-	logInsert     = "insert"
-	logUpdate     = "update"
-	logSearcher   = "searcher"
-	logLimit      = "limit"
-	logResults    = "results"
-	logNFound     = "n_found"
+	logEntityType  = "entity_type"
+	logQuery       = "query"
+	logSQL         = "sql"
+	logArgs        = "args"
+	logEntityID    = "entity_id"
+	logInsert      = "insert"
+	logUpdate      = "update"
+	logSearcher    = "searcher"
+	logLimit       = "limit"
+	logResults     = "results"
+	logNFound      = "n_found"
+	logNVersions   = "n_versions"
+	logNEntities   = "n_entities"
+	logNErrors     = "n_errors"
+	logPageToken   = "page_token"
+	logPaths       = "paths"
+	logSurvivorID  = "survivor_id"
+	logMergedID    = "merged_id"
+	logToVersion   = "to_version"
+	logRevertedBy  = "reverted_by"
+	logThreshold   = "threshold"
+	logNPairs      = "n_pairs"
+	logNCandidates = "n_candidates"
+	logNMatches    = "n_matches"
+	logPrefix      = "prefix"
+	logNSuggested  = "n_suggested"
+	logNRefolded   = "n_refolded"
 )
 
 func logGetSelect(q sq.SelectBuilder, et storage.EntityType, entityID string) []zapcore.Field {
@@ -34,6 +50,17 @@ func logGetSelect(q sq.SelectBuilder, et storage.EntityType, entityID string) []
 	}
 }
 
+func logDeleteUpdate(q sq.UpdateBuilder, et storage.EntityType, entityID string) []zapcore.Field {
+	qSQL, args, err := q.ToSql()
+	errors.MaybePanic(err)
+	return []zapcore.Field{
+		zap.Stringer(logEntityType, et),
+		zap.String(logEntityID, entityID),
+		zap.String(logSQL, qSQL),
+		zap.Array(logArgs, queryArgs(args)),
+	}
+}
+
 func logSearchSelect(q sq.SelectBuilder, s searcher, query string) []zapcore.Field {
 	qSQL, args, err := q.ToSql()
 	errors.MaybePanic(err)
@@ -91,6 +118,178 @@ func logPutUpdate(q sq.UpdateBuilder, e *api.Entity) []zapcore.Field {
 	}
 }
 
+func logHistoryResult(entityID string, history []*api.EntityVersion) []zapcore.Field {
+	return []zapcore.Field{
+		zap.String(logEntityID, entityID),
+		zap.Int(logNVersions, len(history)),
+	}
+}
+
+func logPutsInsert(q sq.InsertBuilder, et storage.EntityType, nEntities int) []zapcore.Field {
+	qSQL, args, err := q.ToSql()
+	errors.MaybePanic(err)
+	return []zapcore.Field{
+		zap.Stringer(logEntityType, et),
+		zap.Int(logNEntities, nEntities),
+		zap.String(logSQL, qSQL),
+		zap.Array(logArgs, queryArgs(args)),
+	}
+}
+
+func logPutsUpdate(q sq.UpdateBuilder, et storage.EntityType, nEntities int) []zapcore.Field {
+	qSQL, args, err := q.ToSql()
+	errors.MaybePanic(err)
+	return []zapcore.Field{
+		zap.Stringer(logEntityType, et),
+		zap.Int(logNEntities, nEntities),
+		zap.String(logSQL, qSQL),
+		zap.Array(logArgs, queryArgs(args)),
+	}
+}
+
+func logPutsResult(results []*storage.PutResult) []zapcore.Field {
+	nErrors := 0
+	for _, r := range results {
+		if r.Err != nil {
+			nErrors++
+		}
+	}
+	return []zapcore.Field{
+		zap.Int(logNEntities, len(results)),
+		zap.Int(logNErrors, nErrors),
+	}
+}
+
+func logGetsSelect(q sq.SelectBuilder, et storage.EntityType, nEntities int) []zapcore.Field {
+	qSQL, args, err := q.ToSql()
+	errors.MaybePanic(err)
+	return []zapcore.Field{
+		zap.Stringer(logEntityType, et),
+		zap.Int(logNEntities, nEntities),
+		zap.String(logSQL, qSQL),
+		zap.Array(logArgs, queryArgs(args)),
+	}
+}
+
+func logGetsResult(results []*storage.GetResult) []zapcore.Field {
+	nErrors := 0
+	for _, r := range results {
+		if r.Err != nil {
+			nErrors++
+		}
+	}
+	return []zapcore.Field{
+		zap.Int(logNEntities, len(results)),
+		zap.Int(logNErrors, nErrors),
+	}
+}
+
+func logListSelect(q sq.SelectBuilder, et storage.EntityType, pageToken string) []zapcore.Field {
+	qSQL, args, err := q.ToSql()
+	errors.MaybePanic(err)
+	return []zapcore.Field{
+		zap.Stringer(logEntityType, et),
+		zap.String(logPageToken, pageToken),
+		zap.String(logSQL, qSQL),
+		zap.Array(logArgs, queryArgs(args)),
+	}
+}
+
+func logListResult(et storage.EntityType, pageToken string, page []*api.Entity) []zapcore.Field {
+	return []zapcore.Field{
+		zap.Stringer(logEntityType, et),
+		zap.String(logPageToken, pageToken),
+		zap.Int(logNEntities, len(page)),
+	}
+}
+
+func logDuplicatesSelect(q sq.SelectBuilder, threshold float32, limit uint) []zapcore.Field {
+	qSQL, args, err := q.ToSql()
+	errors.MaybePanic(err)
+	return []zapcore.Field{
+		zap.Float32(logThreshold, threshold),
+		zap.Uint(logLimit, limit),
+		zap.String(logSQL, qSQL),
+		zap.Array(logArgs, queryArgs(args)),
+	}
+}
+
+func logDuplicatesResult(
+	threshold float32, limit uint, pairs []*api.DuplicatePair,
+) []zapcore.Field {
+	return []zapcore.Field{
+		zap.Float32(logThreshold, threshold),
+		zap.Uint(logLimit, limit),
+		zap.Int(logNPairs, len(pairs)),
+	}
+}
+
+func logMatchSelect(q sq.SelectBuilder, limit uint) []zapcore.Field {
+	qSQL, args, err := q.ToSql()
+	errors.MaybePanic(err)
+	return []zapcore.Field{
+		zap.Uint(logLimit, limit),
+		zap.String(logSQL, qSQL),
+		zap.Array(logArgs, queryArgs(args)),
+	}
+}
+
+func logMatchResult(limit uint, nCandidates int, ms []*api.PatientMatch) []zapcore.Field {
+	nMatches := 0
+	for _, m := range ms {
+		if m.Classification == api.Match {
+			nMatches++
+		}
+	}
+	return []zapcore.Field{
+		zap.Uint(logLimit, limit),
+		zap.Int(logNCandidates, nCandidates),
+		zap.Int(logNMatches, nMatches),
+	}
+}
+
+func logAutocompleteSelect(q sq.SelectBuilder, et storage.EntityType) []zapcore.Field {
+	qSQL, args, err := q.ToSql()
+	errors.MaybePanic(err)
+	return []zapcore.Field{
+		zap.Stringer(logEntityType, et),
+		zap.String(logSQL, qSQL),
+		zap.Array(logArgs, queryArgs(args)),
+	}
+}
+
+func logAutocompleteResult(
+	prefix string, limit uint, suggestions []*api.Suggestion,
+) []zapcore.Field {
+	return []zapcore.Field{
+		zap.String(logPrefix, prefix),
+		zap.Uint(logLimit, limit),
+		zap.Int(logNSuggested, len(suggestions)),
+	}
+}
+
+func logPatchResult(entityID string, paths []string) []zapcore.Field {
+	return []zapcore.Field{
+		zap.String(logEntityID, entityID),
+		zap.Strings(logPaths, paths),
+	}
+}
+
+func logRevertResult(entityID string, revert *api.Revert) []zapcore.Field {
+	return []zapcore.Field{
+		zap.String(logEntityID, entityID),
+		zap.Uint64(logToVersion, revert.ToVersion),
+		zap.String(logRevertedBy, revert.RevertedBy),
+	}
+}
+
+func logMergeResult(survivorID, mergedID string) []zapcore.Field {
+	return []zapcore.Field{
+		zap.String(logSurvivorID, survivorID),
+		zap.String(logMergedID, mergedID),
+	}
+}
+
 type queryArgs []interface{}
 
 func (qas queryArgs) MarshalLogArray(enc zapcore.ArrayEncoder) error {
@@ -106,3 +305,10 @@ func (qas queryArgs) MarshalLogArray(enc zapcore.ArrayEncoder) error {
 	}
 	return nil
 }
+
+func logRefoldResult(et storage.EntityType, nRefolded int) []zapcore.Field {
+	return []zapcore.Field{
+		zap.Stringer(logEntityType, et),
+		zap.Int(logNRefolded, nRefolded),
+	}
+}
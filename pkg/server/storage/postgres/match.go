@@ -0,0 +1,32 @@
+package postgres
+
+import (
+	"fmt"
+
+	sq "github.com/Masterminds/squirrel"
+	api "github.com/elixirhealth/directory/pkg/directoryapi"
+	"github.com/elixirhealth/directory/pkg/server/storage"
+)
+
+const matchAlias = "p"
+
+// getMatchCandidatesSelect returns the SELECT query for the current patients that are candidate
+// matches for the given patient, i.e., those whose names are trigram-similar to its name or whose
+// birthdates agree with its birthdate, preferring those with the most similar names. The selected
+// columns are the patient scan columns.
+func getMatchCandidatesSelect(p *api.Patient) sq.SelectBuilder {
+	cols, _, _ := prepPatientScan(0)
+	name := patientNameExpr(matchAlias)
+	queryName := api.FoldName(p.LastName) + " " + api.FoldName(p.FirstName)
+	blocks := sq.Or{sq.Expr(name+" % ?", queryName)}
+	if p.Birthdate != nil {
+		blocks = append(blocks, sq.Eq{matchAlias + "." + birthdateCol: p.Birthdate.ISO8601()})
+	}
+	return psql.Select(qualifiedCols(matchAlias, cols)...).
+		From(fullTableName(storage.Patient)+" "+matchAlias).
+		Where(fmt.Sprintf("upper_inf(%s.%s)", matchAlias, transactionPeriodCol)).
+		Where(blocks).
+		OrderByClause("similarity("+name+", ?) DESC", queryName).
+		OrderBy(matchAlias + "." + entityIDCol).
+		Limit(storage.MaxMatchCandidates)
+}
@@ -0,0 +1,30 @@
+package postgres
+
+import (
+	"testing"
+
+	api "github.com/elixirhealth/directory/pkg/directoryapi"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetMatchCandidatesSelect(t *testing.T) {
+	name := "(p.last_name_search || ' ' || p.first_name_search)"
+	p := &api.Patient{
+		LastName:  "Muñoz",
+		FirstName: "José",
+		Birthdate: &api.Date{Year: 1980, Month: 1, Day: 2},
+	}
+	qSQL, args, err := getMatchCandidatesSelect(p).ToSql()
+	assert.Nil(t, err)
+	assert.Contains(t, qSQL, "upper_inf(p.transaction_period)")
+	assert.Contains(t, qSQL, "("+name+" % $1 OR p.birthdate = $2)")
+	assert.Contains(t, qSQL, "ORDER BY similarity("+name+", $3) DESC, p.entity_id")
+	assert.Equal(t, []interface{}{"MUNOZ JOSE", "1980-01-02", "MUNOZ JOSE"}, args)
+
+	// without a birthdate, candidates are just those with similar names
+	p.Birthdate = nil
+	qSQL, args, err = getMatchCandidatesSelect(p).ToSql()
+	assert.Nil(t, err)
+	assert.Contains(t, qSQL, "("+name+" % $1)")
+	assert.Equal(t, []interface{}{"MUNOZ JOSE", "MUNOZ JOSE"}, args)
+}
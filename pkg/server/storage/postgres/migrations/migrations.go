@@ -4,6 +4,22 @@
 // pkg/server/storage/postgres/migrations/sql/001_add-initial-tables.up.sql
 // pkg/server/storage/postgres/migrations/sql/002_add-search-idxs.down.sql
 // pkg/server/storage/postgres/migrations/sql/002_add-search-idxs.up.sql
+// pkg/server/storage/postgres/migrations/sql/003_add-entity-versions.down.sql
+// pkg/server/storage/postgres/migrations/sql/003_add-entity-versions.up.sql
+// pkg/server/storage/postgres/migrations/sql/004_add-entity-version-numbers.down.sql
+// pkg/server/storage/postgres/migrations/sql/004_add-entity-version-numbers.up.sql
+// pkg/server/storage/postgres/migrations/sql/005_add-merged-into-ids.down.sql
+// pkg/server/storage/postgres/migrations/sql/005_add-merged-into-ids.up.sql
+// pkg/server/storage/postgres/migrations/sql/006_add-reverts.down.sql
+// pkg/server/storage/postgres/migrations/sql/006_add-reverts.up.sql
+// pkg/server/storage/postgres/migrations/sql/007_add-birthdate-idx.down.sql
+// pkg/server/storage/postgres/migrations/sql/007_add-birthdate-idx.up.sql
+// pkg/server/storage/postgres/migrations/sql/008_add-phonetic-idxs.down.sql
+// pkg/server/storage/postgres/migrations/sql/008_add-phonetic-idxs.up.sql
+// pkg/server/storage/postgres/migrations/sql/009_add-name-search-cols.down.sql
+// pkg/server/storage/postgres/migrations/sql/009_add-name-search-cols.up.sql
+// pkg/server/storage/postgres/migrations/sql/010_add-autocomplete-idxs.down.sql
+// pkg/server/storage/postgres/migrations/sql/010_add-autocomplete-idxs.up.sql
 // DO NOT EDIT!
 
 package migrations
@@ -151,6 +167,326 @@ func _002_addSearchIdxsUpSql() (*asset, error) {
 	return a, nil
 }
 
+var __003_addEntityVersionsDownSql = []byte("\x1f\x8b\x08\x00\x00\x00\x00\x00\x02\xff\x8d\x90\x41\x0b\x82\x30\x14\xc7\xef\xfb\x14\xef\x58\x17\xa1\xae\xd6\x61\xb5\x57\x09\x36\x6b\x4e\xea\x26\x61\x0a\x23\xc8\x21\x46\xf8\xed\x73\x98\xa2\x2b\xa3\xdb\xde\xef\xff\xe7\xf1\xf6\x63\x22\x38\x80\xc7\x19\x9e\x21\xbd\x97\xaa\xac\x9c\x3c\xcb\x54\x92\xc6\xc9\xa3\x28\x6a\x12\x37\x34\x56\x57\x97\xb0\xb1\x6e\xbf\x83\x3e\x4a\x84\x8d\x08\xf6\xc3\x12\xe4\x33\x12\x85\x1e\xdf\xda\x78\x4e\x4e\x3b\x14\x58\xe7\x4e\xb7\x07\x96\x35\xef\x8d\x94\x33\x93\x17\xf9\xd3\x4c\x0b\x13\x36\x6f\x97\x50\x5f\xa2\x00\x49\x57\x3e\x5a\x9b\x29\x63\xb0\x0e\x78\x28\x05\xf5\xb8\x04\xfb\xd6\xf8\x96\x56\x10\x71\xef\x18\x21\x4c\x3a\x3a\x75\xc9\x97\x7f\xea\x4b\xa9\x8c\x8c\xbf\xa4\xb4\xe5\xdf\x56\xde\x2d\xd0\x96\x96\x8e\xb7\x5e\xf4\xd0\x8b\xb6\xbd\xe8\x9e\x17\xfd\xd3\x4b\xbb\xda\x12\xf3\x71\xef\xa8\x99\x17\x0a\x7d\x5d\x4f\x2d\x02\x00\x00")
+
+func _003_addEntityVersionsDownSqlBytes() ([]byte, error) {
+	return bindataRead(
+		__003_addEntityVersionsDownSql,
+		"003_add-entity-versions.down.sql",
+	)
+}
+
+func _003_addEntityVersionsDownSql() (*asset, error) {
+	bytes, err := _003_addEntityVersionsDownSqlBytes()
+	if err != nil {
+		return nil, err
+	}
+
+	info := bindataFileInfo{name: "003_add-entity-versions.down.sql", size: 557, mode: os.FileMode(420), modTime: time.Unix(1792198570, 0)}
+	a := &asset{bytes: bytes, info: info}
+	return a, nil
+}
+
+var __003_addEntityVersionsUpSql = []byte("\x1f\x8b\x08\x00\x00\x00\x00\x00\x02\xff\x8d\x8f\x41\x0a\x83\x30\x10\x45\xf7\x39\xc5\x2c\xed\xa6\x17\xe8\x2a\xd5\x81\x0a\x12\xdb\x34\xd2\xee\x82\xd8\x08\x83\x10\x25\x8d\x0b\x6f\x5f\x8b\x22\xad\x01\xdb\xdd\xf0\xe7\xcf\x1b\x1e\xcf\x14\x4a\x50\xfc\x98\x21\x18\xeb\xc9\x0f\xfb\xae\xf4\x34\x8e\x90\xc8\xfc\x0c\x71\x2e\xae\x4a\xf2\x54\x28\x98\x73\x3d\xd5\x34\x3d\x74\x63\x86\x03\x8b\x25\x72\x85\x90\x8a\x04\xef\x61\x07\x72\xb1\xe6\x46\xcb\x72\xb7\x5c\x17\x22\xbd\x14\x6b\x48\xd5\x3b\xf7\x05\x63\x9b\x30\x76\x3b\xa1\x44\xe8\xbb\xce\x38\x4d\xb6\x8e\xbc\x2b\xed\xb3\xac\x3c\xb5\x56\x8f\x19\xb5\xef\x87\x8c\x87\xc6\x6d\x5d\x53\x65\x02\xe1\x29\xde\xf4\x5d\x57\x3e\x74\x67\xe8\x4f\xdb\x19\xb1\x25\x1b\xa2\xfe\x73\x7d\x01\x14\x47\xc4\x99\xdd\x01\x00\x00")
+
+func _003_addEntityVersionsUpSqlBytes() ([]byte, error) {
+	return bindataRead(
+		__003_addEntityVersionsUpSql,
+		"003_add-entity-versions.up.sql",
+	)
+}
+
+func _003_addEntityVersionsUpSql() (*asset, error) {
+	bytes, err := _003_addEntityVersionsUpSqlBytes()
+	if err != nil {
+		return nil, err
+	}
+
+	info := bindataFileInfo{name: "003_add-entity-versions.up.sql", size: 477, mode: os.FileMode(420), modTime: time.Unix(1792198570, 0)}
+	a := &asset{bytes: bytes, info: info}
+	return a, nil
+}
+
+var __004_addEntityVersionNumbersDownSql = []byte("\x1f\x8b\x08\x00\x00\x00\x00\x00\x02\xff\x73\xf4\x09\x71\x0d\x52\x08\x71\x74\xf2\x71\x55\x48\xcd\x2b\xc9\x2c\xa9\xd4\xcb\x4f\x4b\xcb\x4c\x4e\x55\x70\x09\xf2\x0f\x50\x70\xf6\xf7\x09\xf5\xf5\x53\x28\x4b\x2d\x2a\xce\xcc\xcf\xb3\xe6\x72\xc4\x54\x5e\x90\x58\x92\x09\x64\x62\x57\x0f\x00\xd3\xa6\xf4\x3a\x5f\x00\x00\x00")
+
+func _004_addEntityVersionNumbersDownSqlBytes() ([]byte, error) {
+	return bindataRead(
+		__004_addEntityVersionNumbersDownSql,
+		"004_add-entity-version-numbers.down.sql",
+	)
+}
+
+func _004_addEntityVersionNumbersDownSql() (*asset, error) {
+	bytes, err := _004_addEntityVersionNumbersDownSqlBytes()
+	if err != nil {
+		return nil, err
+	}
+
+	info := bindataFileInfo{name: "004_add-entity-version-numbers.down.sql", size: 95, mode: os.FileMode(420), modTime: time.Unix(1792200154, 0)}
+	a := &asset{bytes: bytes, info: info}
+	return a, nil
+}
+
+var __004_addEntityVersionNumbersUpSql = []byte("\x1f\x8b\x08\x00\x00\x00\x00\x00\x02\xff\xcd\x90\x31\x6b\xc3\x30\x14\x84\x77\xfd\x8a\x1b\x6d\x08\x86\xce\x21\x83\x1c\xc9\x8d\x41\xb1\x82\xfc\x9c\xd2\xa9\xb4\x8d\x03\x1a\x62\x19\xd7\x75\xe8\xbf\xaf\xb0\x9c\x90\xe2\x6c\x59\x3a\x08\x1d\x4f\xba\x7b\xc7\xc7\x15\x49\x03\xe2\xa9\x92\xa8\x9b\xde\xf6\x3f\x49\xfb\xde\x5b\x2f\xc1\x85\xc0\x5a\xab\x6a\x5b\x60\xa8\xbb\x2f\xeb\x1a\xa4\xf9\x73\x5e\x10\x0a\xed\x4f\xa5\x14\x84\xcc\x78\xa5\x08\x4f\x4b\x56\xed\x04\xa7\x59\x46\xcb\x4a\x49\x57\xfb\x0a\x43\x32\x69\x96\x19\xbd\x45\xc4\x80\x52\x2a\xb9\x26\x74\xee\xfc\x66\x0f\x8b\xf1\x6e\xbe\x4f\x1f\x75\x17\xc5\xd0\x7b\x5f\x2e\xda\x71\x43\x39\xe5\xba\x40\xfa\x3a\x2d\xf0\x5f\xa1\x8d\xf0\xaf\x7e\x14\xac\x31\x78\x79\xd9\xe4\x63\xc7\xfc\xbf\x6d\x58\x8c\x81\xbd\x6c\xa4\x91\x68\x93\x60\x1a\x2b\x05\xb9\x64\x8c\xcf\x61\xb8\xe3\xd1\x7e\xd6\x0f\xb1\x98\x22\xdc\xbf\x40\x11\xca\xdc\x90\x70\xf7\x48\xfc\x02\x61\xcb\x77\xa0\x16\x02\x00\x00")
+
+func _004_addEntityVersionNumbersUpSqlBytes() ([]byte, error) {
+	return bindataRead(
+		__004_addEntityVersionNumbersUpSql,
+		"004_add-entity-version-numbers.up.sql",
+	)
+}
+
+func _004_addEntityVersionNumbersUpSql() (*asset, error) {
+	bytes, err := _004_addEntityVersionNumbersUpSqlBytes()
+	if err != nil {
+		return nil, err
+	}
+
+	info := bindataFileInfo{name: "004_add-entity-version-numbers.up.sql", size: 534, mode: os.FileMode(420), modTime: time.Unix(1792200154, 0)}
+	a := &asset{bytes: bytes, info: info}
+	return a, nil
+}
+
+var __005_addMergedIntoIdsDownSql = []byte("\x1f\x8b\x08\x00\x00\x00\x00\x00\x02\xff\x73\xf4\x09\x71\x0d\x52\x08\x71\x74\xf2\x71\x55\x48\xcd\x2b\xc9\x2c\xa9\xd4\xcb\x4f\x4b\xcb\x4c\x4e\x55\x70\x09\xf2\x0f\x50\x70\xf6\xf7\x09\xf5\xf5\x53\xc8\x4d\x2d\x4a\x4f\x4d\x89\xcf\xcc\x2b\xc9\x8f\xcf\x4c\xb1\xe6\x72\xc4\xd4\x55\x90\x58\x92\x09\x64\xe2\xd5\x06\x00\xe2\x8e\x2c\x91\x6d\x00\x00\x00")
+
+func _005_addMergedIntoIdsDownSqlBytes() ([]byte, error) {
+	return bindataRead(
+		__005_addMergedIntoIdsDownSql,
+		"005_add-merged-into-ids.down.sql",
+	)
+}
+
+func _005_addMergedIntoIdsDownSql() (*asset, error) {
+	bytes, err := _005_addMergedIntoIdsDownSqlBytes()
+	if err != nil {
+		return nil, err
+	}
+
+	info := bindataFileInfo{name: "005_add-merged-into-ids.down.sql", size: 109, mode: os.FileMode(420), modTime: time.Unix(1792200442, 0)}
+	a := &asset{bytes: bytes, info: info}
+	return a, nil
+}
+
+var __005_addMergedIntoIdsUpSql = []byte("\x1f\x8b\x08\x00\x00\x00\x00\x00\x02\xff\x73\xf4\x09\x71\x0d\x52\x08\x71\x74\xf2\x71\x55\x48\xcd\x2b\xc9\x2c\xa9\xd4\x2b\x48\x2c\xc9\x04\x32\x15\x1c\x5d\x5c\x14\x9c\xfd\x7d\x42\x7d\xfd\x14\x72\x53\x8b\xd2\x53\x53\xe2\x33\xf3\x4a\xf2\xe3\x33\x53\x14\xc2\x1c\x83\x9c\x3d\x1c\x83\xac\xb9\x1c\x31\x75\xe7\xa7\xa5\x65\x26\xa7\x12\xa3\x19\x00\x8c\xec\x70\xba\x7b\x00\x00\x00")
+
+func _005_addMergedIntoIdsUpSqlBytes() ([]byte, error) {
+	return bindataRead(
+		__005_addMergedIntoIdsUpSql,
+		"005_add-merged-into-ids.up.sql",
+	)
+}
+
+func _005_addMergedIntoIdsUpSql() (*asset, error) {
+	bytes, err := _005_addMergedIntoIdsUpSqlBytes()
+	if err != nil {
+		return nil, err
+	}
+
+	info := bindataFileInfo{name: "005_add-merged-into-ids.up.sql", size: 123, mode: os.FileMode(420), modTime: time.Unix(1792200442, 0)}
+	a := &asset{bytes: bytes, info: info}
+	return a, nil
+}
+
+var __006_addRevertsDownSql = []byte("\x1f\x8b\x08\x00\x00\x00\x00\x00\x02\xff\x73\xf4\x09\x71\x0d\x52\x08\x71\x74\xf2\x71\x55\x48\xcd\x2b\xc9\x2c\xa9\xd4\xcb\x4f\x4b\xcb\x4c\x4e\xe5\x52\x50\x70\x09\xf2\x0f\x50\x70\xf6\xf7\x09\xf5\xf5\x53\x28\x4a\x2d\x4b\x2d\x2a\x89\x2f\x4a\x4d\x2c\xce\xcf\xd3\xc1\x2a\x99\x9a\x12\x9f\x54\x89\x53\xaa\x24\x3f\x1e\xc8\x2a\xce\xcc\xcf\xb3\xe6\xe2\x72\xc4\xb4\xb5\x20\xb1\x24\x13\xc8\xa4\xa5\xb5\x00\x1c\xb8\x5c\xa6\xec\x00\x00\x00")
+
+func _006_addRevertsDownSqlBytes() ([]byte, error) {
+	return bindataRead(
+		__006_addRevertsDownSql,
+		"006_add-reverts.down.sql",
+	)
+}
+
+func _006_addRevertsDownSql() (*asset, error) {
+	bytes, err := _006_addRevertsDownSqlBytes()
+	if err != nil {
+		return nil, err
+	}
+
+	info := bindataFileInfo{name: "006_add-reverts.down.sql", size: 236, mode: os.FileMode(420), modTime: time.Unix(1792200610, 0)}
+	a := &asset{bytes: bytes, info: info}
+	return a, nil
+}
+
+var __006_addRevertsUpSql = []byte("\x1f\x8b\x08\x00\x00\x00\x00\x00\x02\xff\x73\xf4\x09\x71\x0d\x52\x08\x71\x74\xf2\x71\x55\x48\xcd\x2b\xc9\x2c\xa9\xd4\x2b\x48\x2c\xc9\x04\x32\xb9\x14\x14\x1c\x5d\x5c\x14\x9c\xfd\x7d\x42\x7d\xfd\x14\x8a\x52\xcb\x52\x8b\x4a\x52\x53\xe2\x4b\xf2\xe3\x81\xac\xe2\xcc\xfc\x3c\x05\x27\x4f\x77\x4f\xbf\x10\x1d\x1c\x0a\x93\x2a\x15\xc2\x1c\x83\x9c\x3d\x1c\x83\xb0\xaa\x88\x2f\x4a\x4d\x2c\x06\x1a\x02\x55\x63\xcd\xc5\xe5\x88\xe9\x94\xfc\xb4\xb4\xcc\xe4\x54\x7a\xbb\x04\x00\xc8\xda\x39\xa1\x14\x01\x00\x00")
+
+func _006_addRevertsUpSqlBytes() ([]byte, error) {
+	return bindataRead(
+		__006_addRevertsUpSql,
+		"006_add-reverts.up.sql",
+	)
+}
+
+func _006_addRevertsUpSql() (*asset, error) {
+	bytes, err := _006_addRevertsUpSqlBytes()
+	if err != nil {
+		return nil, err
+	}
+
+	info := bindataFileInfo{name: "006_add-reverts.up.sql", size: 276, mode: os.FileMode(420), modTime: time.Unix(1792200610, 0)}
+	a := &asset{bytes: bytes, info: info}
+	return a, nil
+}
+
+var __007_addBirthdateIdxDownSql = []byte("\x1f\x8b\x08\x00\x00\x00\x00\x00\x02\xff\x73\x09\xf2\x0f\x50\xf0\xf4\x73\x71\x8d\x50\x48\xcd\x2b\xc9\x2c\xa9\xd4\x2b\x48\x2c\xc9\x04\x32\xe3\x93\x32\x8b\x4a\x32\x52\x12\x4b\x52\xad\xb9\x00\xa2\xec\x79\x8c\x25\x00\x00\x00")
+
+func _007_addBirthdateIdxDownSqlBytes() ([]byte, error) {
+	return bindataRead(
+		__007_addBirthdateIdxDownSql,
+		"007_add-birthdate-idx.down.sql",
+	)
+}
+
+func _007_addBirthdateIdxDownSql() (*asset, error) {
+	bytes, err := _007_addBirthdateIdxDownSqlBytes()
+	if err != nil {
+		return nil, err
+	}
+
+	info := bindataFileInfo{name: "007_add-birthdate-idx.down.sql", size: 37, mode: os.FileMode(420), modTime: time.Unix(1792201803, 0)}
+	a := &asset{bytes: bytes, info: info}
+	return a, nil
+}
+
+var __007_addBirthdateIdxUpSql = []byte("\x1f\x8b\x08\x00\x00\x00\x00\x00\x02\xff\x73\x0e\x72\x75\x0c\x71\x55\xf0\xf4\x73\x71\x8d\x50\x28\x48\x2c\xc9\x4c\xcd\x2b\x89\x4f\xca\x2c\x2a\xc9\x48\x49\x2c\x49\x55\xf0\xf7\x53\x00\x0a\x64\x96\x54\xea\x41\xe5\x14\x34\xe0\x92\x9a\xd6\x5c\x00\x44\x2a\x6d\x19\x3e\x00\x00\x00")
+
+func _007_addBirthdateIdxUpSqlBytes() ([]byte, error) {
+	return bindataRead(
+		__007_addBirthdateIdxUpSql,
+		"007_add-birthdate-idx.up.sql",
+	)
+}
+
+func _007_addBirthdateIdxUpSql() (*asset, error) {
+	bytes, err := _007_addBirthdateIdxUpSqlBytes()
+	if err != nil {
+		return nil, err
+	}
+
+	info := bindataFileInfo{name: "007_add-birthdate-idx.up.sql", size: 62, mode: os.FileMode(420), modTime: time.Unix(1792201803, 0)}
+	a := &asset{bytes: bytes, info: info}
+	return a, nil
+}
+
+var __008_addPhoneticIdxsDownSql = []byte("\x1f\x8b\x08\x00\x00\x00\x00\x00\x02\xff\x73\x09\xf2\x0f\x50\xf0\xf4\x73\x71\x8d\x50\x48\xcd\x2b\xc9\x2c\xa9\xd4\x2b\x48\x2c\xc9\x04\x32\xe3\xd3\x32\x8b\x8a\x4b\xe2\xf3\x12\x73\x53\xe3\x0b\x32\xf2\xf3\x52\x4b\x32\x93\xad\xb9\x5c\x70\x2a\xcf\x49\xc4\xa1\xda\x35\x22\xc4\xd5\x2f\xd8\xd3\xdf\x4f\x21\xad\xb4\xaa\xaa\xb2\xb8\xa4\x28\x37\xb1\x24\x39\xc3\x9a\x0b\x00\xf0\x3d\x7e\x72\x7b\x00\x00\x00")
+
+func _008_addPhoneticIdxsDownSqlBytes() ([]byte, error) {
+	return bindataRead(
+		__008_addPhoneticIdxsDownSql,
+		"008_add-phonetic-idxs.down.sql",
+	)
+}
+
+func _008_addPhoneticIdxsDownSql() (*asset, error) {
+	bytes, err := _008_addPhoneticIdxsDownSqlBytes()
+	if err != nil {
+		return nil, err
+	}
+
+	info := bindataFileInfo{name: "008_add-phonetic-idxs.down.sql", size: 123, mode: os.FileMode(420), modTime: time.Unix(1792202215, 0)}
+	a := &asset{bytes: bytes, info: info}
+	return a, nil
+}
+
+var __008_addPhoneticIdxsUpSql = []byte("\x1f\x8b\x08\x00\x00\x00\x00\x00\x02\xff\x73\x0e\x72\x75\x0c\x71\x55\x70\x8d\x08\x71\xf5\x0b\xf6\xf4\xf7\x53\x48\x2b\xad\xaa\xaa\x2c\x2e\x29\xca\x4d\x2c\x49\xce\xb0\xe6\xe2\x72\x86\x28\xf0\xf4\x73\x71\x8d\x50\x28\x48\x2c\xc9\x4c\xcd\x2b\x89\xcf\x49\x2c\x2e\x89\xcf\x4b\xcc\x4d\x8d\x2f\xc8\xc8\xcf\x4b\x2d\xc9\x4c\xe6\x02\x6a\x05\xca\x64\x96\x54\xea\x41\x15\x29\x68\xa4\xe4\xa6\x96\x24\x82\x15\x68\x14\xa5\xa6\xa7\x56\x14\xc4\x17\xa5\x16\xe4\x24\x26\xa7\x6a\x38\xfb\x3b\xfa\xb8\x06\x3b\xbb\x6a\x84\x06\x04\xb8\x06\x69\xc0\x8d\xd3\xd4\x51\x50\x57\x07\x11\xbe\x01\x1a\xd1\xc1\x21\xb1\x9a\xea\x20\x76\x8c\x21\x88\x4a\x57\xd7\xd4\xd4\xc4\xe5\xa0\xb4\xcc\x22\xaa\xba\x08\x61\x1e\x11\x4e\x02\x00\xcb\xb6\xf7\xa5\x44\x01\x00\x00")
+
+func _008_addPhoneticIdxsUpSqlBytes() ([]byte, error) {
+	return bindataRead(
+		__008_addPhoneticIdxsUpSql,
+		"008_add-phonetic-idxs.up.sql",
+	)
+}
+
+func _008_addPhoneticIdxsUpSql() (*asset, error) {
+	bytes, err := _008_addPhoneticIdxsUpSqlBytes()
+	if err != nil {
+		return nil, err
+	}
+
+	info := bindataFileInfo{name: "008_add-phonetic-idxs.up.sql", size: 324, mode: os.FileMode(420), modTime: time.Unix(1792202215, 0)}
+	a := &asset{bytes: bytes, info: info}
+	return a, nil
+}
+
+var __009_addNameSearchColsDownSql = []byte("\x1f\x8b\x08\x00\x00\x00\x00\x00\x02\xff\xad\x52\x4b\x6b\x83\x30\x1c\xbf\xe7\x53\xe4\x66\x84\x32\xd8\xb9\xa7\x4c\xb3\x4e\xb0\x51\x62\x1c\x85\xad\x84\x60\xa3\x15\x7c\xa1\x19\x6c\xdf\x7e\x6a\x6d\xeb\x03\x37\x06\xbb\x24\x21\xfc\x5e\xff\x87\xcd\x3c\x1f\x3a\xd4\x26\x07\xa8\x0a\x9d\xea\xaf\x87\x4a\xea\xb4\x7d\x8a\x38\xad\x1b\x2d\x0a\x99\x2b\xd1\x28\x59\x47\x67\x51\x9d\xcb\x42\xe9\x34\xda\x02\x7b\x95\x95\xc9\x55\x12\xb0\x18\xc1\x9c\x0c\xbc\x25\xe1\x8a\x04\x1e\x9d\xa9\x42\x74\xca\x95\x96\x3d\x00\xd5\x2a\x51\x9f\x95\xa8\x55\x95\xc9\x48\x21\xcb\xc3\x2e\x09\x2c\x82\x42\xdf\x27\x0c\xdd\xe4\xcc\x0d\x34\x8c\xee\xd8\xfb\xe8\x2d\xe0\x47\xd3\xe8\xde\xef\x8f\xdd\x95\x18\xa6\x69\xae\x05\x1a\xd5\xfd\x2f\x89\xee\x7a\x7f\x8e\x54\xc6\x71\x1a\xa9\x9e\x3b\x8a\x70\xf9\x05\x61\xe0\xd0\x1d\xdc\x39\x14\xce\x1d\x2f\x5e\xad\x15\x4c\xd2\x42\xe8\x3a\xc9\x45\x59\x35\x9d\xfa\x72\x6e\x23\x8b\x61\x62\x3f\x4d\x77\x02\x03\xd8\xe5\x84\x41\x8e\x9f\x5c\x32\x95\x83\xbd\x82\xe5\xb9\xe1\x9e\xc2\x5f\x39\x83\x38\x80\x13\xda\x62\xfd\x36\x33\xc0\x7c\xd3\xae\xe5\x3d\x87\xd4\xe2\xce\xbd\x5d\x71\x99\x9d\x7a\x1c\x7a\xc5\xcc\x7a\xc1\xec\xd6\x08\x72\xe0\x84\x06\x1d\xf4\xa3\x90\x51\xd4\xe2\xb7\xe0\x1b\x26\xd8\x38\xa4\x10\x03\x00\x00")
+
+func _009_addNameSearchColsDownSqlBytes() ([]byte, error) {
+	return bindataRead(
+		__009_addNameSearchColsDownSql,
+		"009_add-name-search-cols.down.sql",
+	)
+}
+
+func _009_addNameSearchColsDownSql() (*asset, error) {
+	bytes, err := _009_addNameSearchColsDownSqlBytes()
+	if err != nil {
+		return nil, err
+	}
+
+	info := bindataFileInfo{name: "009_add-name-search-cols.down.sql", size: 784, mode: os.FileMode(420), modTime: time.Unix(1792207796, 0)}
+	a := &asset{bytes: bytes, info: info}
+	return a, nil
+}
+
+var __009_addNameSearchColsUpSql = []byte("\x1f\x8b\x08\x00\x00\x00\x00\x00\x02\xff\x9d\x53\x5b\x8f\x9a\x40\x14\x7e\xe7\x57\x9c\x07\x13\x21\x55\x93\xbe\xba\xe9\x03\x85\xd1\x92\x20\x5a\x2e\xcd\x26\xae\x35\x04\x07\x25\x05\x86\xcc\x8c\xdd\xdd\x64\x1f\xf8\x09\xbd\xfe\x41\x7e\x49\x07\x44\x44\x71\x2f\xe9\x0b\xb7\xf9\x2e\xe7\x7c\xe7\xa0\xd9\x48\x75\x11\xa0\x5b\x17\x59\x8e\x31\xb7\x60\x9f\xfa\x41\x80\x53\x7e\x23\x49\xaa\xe9\x22\x1b\x5c\xf5\xa3\x89\x40\x7c\x89\xf8\xe3\x28\xf3\x79\x24\x1e\x25\x00\x55\xd7\x41\x9b\x9b\xde\xcc\x82\xd8\x67\x7c\x9d\xfa\x09\x5e\x33\xec\xd3\x60\x07\x5f\x54\x5b\xfb\xa4\xda\x60\xcd\x5d\xb0\x3c\xd3\x04\x1d\x4d\x54\xcf\x74\xa1\xdf\x1f\x9c\x53\xc3\x88\xbe\x9d\x7b\xbd\x24\x12\x86\x51\x80\xdb\xa2\x6f\x97\x1b\x0e\x21\x24\xf1\x86\x55\x14\x06\x71\xf4\x0d\xc3\x26\xa2\x38\xe0\x84\x3e\xfa\x59\x34\x9a\x88\x53\x4b\x9c\x0d\xe0\x7e\x17\x09\xb9\x03\x9a\xef\x70\xcd\x20\xe1\xa1\x8c\x48\x3c\x33\x41\xc2\x1b\x08\x29\x49\x20\x25\xf7\x40\xd2\x41\x69\x80\x1f\x02\x9c\x71\xc1\xa4\xc0\x48\x22\x88\x24\x1d\xaa\x8e\x66\x18\x10\x63\xce\x31\x2d\xe5\x7c\xde\xe4\x5e\x5b\x6c\xa2\x30\xc4\x54\xbc\xc7\x8f\xb5\x77\xa9\x95\x11\xc6\xb7\x14\xb3\x91\x8d\xc3\xba\x32\xa7\xea\x53\x23\x31\x03\x5a\x7d\x64\x92\x76\x98\xe9\xc4\xb3\x34\xb7\x1c\x69\x1d\x54\x79\x58\x45\x2d\x7f\xf7\xe3\x3d\x3e\x46\xa3\x80\x8d\x5c\xcf\xb6\x9c\x26\x2b\xd5\x81\x5e\x4f\xcc\xc9\x41\x26\xd2\x5c\xf0\x16\x0b\x64\xcb\xae\x6d\xcc\x64\x8a\xb7\xf8\x21\x5b\x53\x9c\xc5\x7e\x80\x5f\x79\x15\x0a\xd0\xb4\x25\x6b\x73\xd5\x44\x8e\x86\x0e\xe6\x03\x91\xbf\xa2\x0c\x2a\x48\x7f\x39\x2c\xf2\x1f\x45\xfe\xb3\xc8\x7f\x15\xf9\xef\x22\xff\x53\xe4\x7f\x57\x7d\x01\x81\xf2\xb2\xed\x37\xb8\xaf\xcb\xb1\x1f\xa7\xfb\x64\xbc\x5a\x8e\x59\x26\x3c\xc6\xab\x0a\x77\x0e\xbb\x63\xef\xda\x64\x45\x91\x7a\x3d\x30\x55\x6b\xea\xa9\x53\x04\xce\x67\x13\x9c\x6a\x81\xc4\xfc\xbd\x85\x5e\x26\x75\xb1\xdc\x0e\x72\xbb\x3b\xfd\xa1\x1b\x63\x83\xa9\x9c\xbb\xab\x7c\x85\x72\x02\x29\x1d\xfb\x7a\x91\x4b\xf7\x57\x54\x8e\xfc\x7a\xd0\x86\xa5\xa3\x5b\xa8\xab\x6f\x57\x20\x9d\x86\x7f\xec\xcd\x73\x0c\x6b\x0a\x53\xc3\x02\x59\xee\xf4\xf8\xf4\x54\xc6\x56\xde\x3a\xcd\x28\xb0\x8d\xd2\x35\xa7\xdb\x64\x4d\x32\xd6\x31\x3f\xd4\xfe\x8c\xf7\xe1\xb0\x6d\xdd\x36\xbd\xd4\xd5\xed\xf9\xa2\x56\x3d\xe3\x57\xe2\x57\xcf\x8f\x9d\xb7\x8a\xce\x76\x24\xc5\x3c\x0a\x6e\x5e\x80\x9f\xda\x3f\xa1\xaf\x47\x7a\x19\x54\x43\xe8\x06\x0c\xf2\x26\xc1\xdc\xaf\x00\x97\x3f\xc4\xa5\x8c\xd8\xcf\xd9\x42\x5e\x3a\xee\x4a\x29\x77\x75\x76\xf7\xbe\x59\xd9\xe7\x2a\xe9\xcc\xe5\x3f\x4b\xe9\xe8\xbc\x58\xcb\x3f\xae\xfb\xd2\xed\x25\x06\x00\x00")
+
+func _009_addNameSearchColsUpSqlBytes() ([]byte, error) {
+	return bindataRead(
+		__009_addNameSearchColsUpSql,
+		"009_add-name-search-cols.up.sql",
+	)
+}
+
+func _009_addNameSearchColsUpSql() (*asset, error) {
+	bytes, err := _009_addNameSearchColsUpSqlBytes()
+	if err != nil {
+		return nil, err
+	}
+
+	info := bindataFileInfo{name: "009_add-name-search-cols.up.sql", size: 1573, mode: os.FileMode(420), modTime: time.Unix(1792207796, 0)}
+	a := &asset{bytes: bytes, info: info}
+	return a, nil
+}
+
+var __010_addAutocompleteIdxsDownSql = []byte("\x1f\x8b\x08\x00\x00\x00\x00\x00\x02\xff\x73\x09\xf2\x0f\x50\xf0\xf4\x73\x71\x8d\x50\x48\xcd\x2b\xc9\x2c\xa9\xd4\xcb\x4f\x4b\xcb\x4c\x4e\x8d\xcf\x4b\xcc\x4d\x8d\x4f\x2c\x2d\xc9\x4f\xce\xcf\x2d\xc8\x49\x2d\x49\xb5\xe6\x72\xc1\x50\x5b\x90\x58\x92\x09\x64\xc6\xa7\x65\x16\x15\x97\xc4\xe7\x24\x02\x09\x92\xf4\x81\x75\x40\x34\x63\xd1\x07\x00\x5e\xb4\x0e\xcb\x9c\x00\x00\x00")
+
+func _010_addAutocompleteIdxsDownSqlBytes() ([]byte, error) {
+	return bindataRead(
+		__010_addAutocompleteIdxsDownSql,
+		"010_add-autocomplete-idxs.down.sql",
+	)
+}
+
+func _010_addAutocompleteIdxsDownSql() (*asset, error) {
+	bytes, err := _010_addAutocompleteIdxsDownSqlBytes()
+	if err != nil {
+		return nil, err
+	}
+
+	info := bindataFileInfo{name: "010_add-autocomplete-idxs.down.sql", size: 156, mode: os.FileMode(420), modTime: time.Unix(1792203078, 0)}
+	a := &asset{bytes: bytes, info: info}
+	return a, nil
+}
+
+var __010_addAutocompleteIdxsUpSql = []byte("\x1f\x8b\x08\x00\x00\x00\x00\x00\x02\xff\xad\x8f\x51\x4b\xc3\x30\x14\x85\xdf\xfb\x2b\x0e\x7b\x59\x0b\xd6\x3f\xe0\x93\xcc\x80\xc3\xb1\xc1\x10\xf4\xad\x64\xc9\x8d\x0b\x74\xb9\x35\x49\xb7\x15\xf6\xe3\x4d\x57\xc5\xa2\xa2\x20\x23\x90\xcb\xbd\x39\xe7\x9e\x2f\x65\x89\xc6\x93\xb1\x47\x58\xa7\xe9\x48\x01\xbc\x27\x8f\xb8\x25\xa8\xd6\x7b\x72\x11\x9e\x0f\x61\x0a\xc3\xb5\x26\x0d\x27\x77\x14\xae\xa0\xb8\xae\x65\x4c\xfd\xa6\x8b\x54\x1e\x6c\x20\x04\xee\x5d\x1d\x02\xf9\x3d\x61\xc3\x71\xdb\xf7\x59\x59\x62\x31\x7f\x10\x1f\x29\xa9\x68\xab\x92\x35\x40\x3a\x7d\xce\x61\xaf\xc9\x5b\xf7\x02\x36\x90\x6d\x64\xc5\xbb\xa6\xa6\x48\x78\x6d\xd3\x9c\x42\x36\x5b\x8b\xdb\x47\x81\xf9\xf2\x4e\x3c\xa3\x91\xd1\x26\xaa\xaa\x96\x21\x56\xc6\xfa\x74\xf7\x4c\xd5\xd8\x99\xad\x96\x48\x1a\x1b\xbb\xeb\x77\x39\xf2\x3c\x3f\x3b\xce\xda\x40\xd2\xab\x2d\x4e\x27\x4c\xd3\x49\x65\xb4\x67\x78\x2b\x30\x5b\x2d\x16\x7d\xea\x64\x36\x29\x8a\xec\xe9\x5e\xac\x05\xda\xa6\x21\x5f\x59\x67\xf2\xe8\xa5\x0b\x52\x45\xcb\xae\x4a\x33\xcb\xba\xb8\xc9\x7e\x06\x1d\x76\x7f\x86\xff\x0d\xfa\x8d\x66\x44\xfa\xf5\x13\x17\x00\x65\x63\xac\xa2\x5f\xe1\x06\x49\x62\x1b\x43\xfd\x23\xf8\x0d\x42\x47\x19\x17\x6e\x02\x00\x00")
+
+func _010_addAutocompleteIdxsUpSqlBytes() ([]byte, error) {
+	return bindataRead(
+		__010_addAutocompleteIdxsUpSql,
+		"010_add-autocomplete-idxs.up.sql",
+	)
+}
+
+func _010_addAutocompleteIdxsUpSql() (*asset, error) {
+	bytes, err := _010_addAutocompleteIdxsUpSqlBytes()
+	if err != nil {
+		return nil, err
+	}
+
+	info := bindataFileInfo{name: "010_add-autocomplete-idxs.up.sql", size: 622, mode: os.FileMode(420), modTime: time.Unix(1792203078, 0)}
+	a := &asset{bytes: bytes, info: info}
+	return a, nil
+}
+
 // Asset loads and returns the asset for the given name.
 // It returns an error if the asset could not be found or
 // could not be loaded.
@@ -203,10 +539,26 @@ func AssetNames() []string {
 
 // _bindata is a table, holding each asset generator, mapped to its name.
 var _bindata = map[string]func() (*asset, error){
-	"001_add-initial-tables.down.sql": _001_addInitialTablesDownSql,
-	"001_add-initial-tables.up.sql":   _001_addInitialTablesUpSql,
-	"002_add-search-idxs.down.sql":    _002_addSearchIdxsDownSql,
-	"002_add-search-idxs.up.sql":      _002_addSearchIdxsUpSql,
+	"001_add-initial-tables.down.sql":         _001_addInitialTablesDownSql,
+	"001_add-initial-tables.up.sql":           _001_addInitialTablesUpSql,
+	"002_add-search-idxs.down.sql":            _002_addSearchIdxsDownSql,
+	"002_add-search-idxs.up.sql":              _002_addSearchIdxsUpSql,
+	"003_add-entity-versions.down.sql":        _003_addEntityVersionsDownSql,
+	"003_add-entity-versions.up.sql":          _003_addEntityVersionsUpSql,
+	"004_add-entity-version-numbers.down.sql": _004_addEntityVersionNumbersDownSql,
+	"004_add-entity-version-numbers.up.sql":   _004_addEntityVersionNumbersUpSql,
+	"005_add-merged-into-ids.down.sql":        _005_addMergedIntoIdsDownSql,
+	"005_add-merged-into-ids.up.sql":          _005_addMergedIntoIdsUpSql,
+	"006_add-reverts.down.sql":                _006_addRevertsDownSql,
+	"006_add-reverts.up.sql":                  _006_addRevertsUpSql,
+	"007_add-birthdate-idx.down.sql":          _007_addBirthdateIdxDownSql,
+	"007_add-birthdate-idx.up.sql":            _007_addBirthdateIdxUpSql,
+	"008_add-phonetic-idxs.down.sql":          _008_addPhoneticIdxsDownSql,
+	"008_add-phonetic-idxs.up.sql":            _008_addPhoneticIdxsUpSql,
+	"009_add-name-search-cols.down.sql":       _009_addNameSearchColsDownSql,
+	"009_add-name-search-cols.up.sql":         _009_addNameSearchColsUpSql,
+	"010_add-autocomplete-idxs.down.sql":      _010_addAutocompleteIdxsDownSql,
+	"010_add-autocomplete-idxs.up.sql":        _010_addAutocompleteIdxsUpSql,
 }
 
 // AssetDir returns the file names below a certain
@@ -250,10 +602,26 @@ type bintree struct {
 }
 
 var _bintree = &bintree{nil, map[string]*bintree{
-	"001_add-initial-tables.down.sql": &bintree{_001_addInitialTablesDownSql, map[string]*bintree{}},
-	"001_add-initial-tables.up.sql":   &bintree{_001_addInitialTablesUpSql, map[string]*bintree{}},
-	"002_add-search-idxs.down.sql":    &bintree{_002_addSearchIdxsDownSql, map[string]*bintree{}},
-	"002_add-search-idxs.up.sql":      &bintree{_002_addSearchIdxsUpSql, map[string]*bintree{}},
+	"001_add-initial-tables.down.sql":         &bintree{_001_addInitialTablesDownSql, map[string]*bintree{}},
+	"001_add-initial-tables.up.sql":           &bintree{_001_addInitialTablesUpSql, map[string]*bintree{}},
+	"002_add-search-idxs.down.sql":            &bintree{_002_addSearchIdxsDownSql, map[string]*bintree{}},
+	"002_add-search-idxs.up.sql":              &bintree{_002_addSearchIdxsUpSql, map[string]*bintree{}},
+	"003_add-entity-versions.down.sql":        &bintree{_003_addEntityVersionsDownSql, map[string]*bintree{}},
+	"003_add-entity-versions.up.sql":          &bintree{_003_addEntityVersionsUpSql, map[string]*bintree{}},
+	"004_add-entity-version-numbers.down.sql": &bintree{_004_addEntityVersionNumbersDownSql, map[string]*bintree{}},
+	"004_add-entity-version-numbers.up.sql":   &bintree{_004_addEntityVersionNumbersUpSql, map[string]*bintree{}},
+	"005_add-merged-into-ids.down.sql":        &bintree{_005_addMergedIntoIdsDownSql, map[string]*bintree{}},
+	"005_add-merged-into-ids.up.sql":          &bintree{_005_addMergedIntoIdsUpSql, map[string]*bintree{}},
+	"006_add-reverts.down.sql":                &bintree{_006_addRevertsDownSql, map[string]*bintree{}},
+	"006_add-reverts.up.sql":                  &bintree{_006_addRevertsUpSql, map[string]*bintree{}},
+	"007_add-birthdate-idx.down.sql":          &bintree{_007_addBirthdateIdxDownSql, map[string]*bintree{}},
+	"007_add-birthdate-idx.up.sql":            &bintree{_007_addBirthdateIdxUpSql, map[string]*bintree{}},
+	"008_add-phonetic-idxs.down.sql":          &bintree{_008_addPhoneticIdxsDownSql, map[string]*bintree{}},
+	"008_add-phonetic-idxs.up.sql":            &bintree{_008_addPhoneticIdxsUpSql, map[string]*bintree{}},
+	"009_add-name-search-cols.down.sql":       &bintree{_009_addNameSearchColsDownSql, map[string]*bintree{}},
+	"009_add-name-search-cols.up.sql":         &bintree{_009_addNameSearchColsUpSql, map[string]*bintree{}},
+	"010_add-autocomplete-idxs.down.sql":      &bintree{_010_addAutocompleteIdxsDownSql, map[string]*bintree{}},
+	"010_add-autocomplete-idxs.up.sql":        &bintree{_010_addAutocompleteIdxsUpSql, map[string]*bintree{}},
 }}
 
 // RestoreAsset restores an asset under the given directory
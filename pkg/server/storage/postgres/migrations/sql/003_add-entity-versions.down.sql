@@ -0,0 +1,13 @@
+DROP INDEX entity.office_current_entity_id;
+DROP INDEX entity.office_entity_id;
+DELETE FROM entity.office o1
+USING entity.office o2
+WHERE o1.entity_id = o2.entity_id AND o1.row_id < o2.row_id;
+ALTER TABLE entity.office ADD CONSTRAINT office_entity_id_key UNIQUE (entity_id);
+
+DROP INDEX entity.patient_current_entity_id;
+DROP INDEX entity.patient_entity_id;
+DELETE FROM entity.patient p1
+USING entity.patient p2
+WHERE p1.entity_id = p2.entity_id AND p1.row_id < p2.row_id;
+ALTER TABLE entity.patient ADD CONSTRAINT patient_entity_id_key UNIQUE (entity_id);
@@ -0,0 +1,11 @@
+ALTER TABLE entity.patient DROP CONSTRAINT patient_entity_id_key;
+CREATE INDEX patient_entity_id ON entity.patient (entity_id);
+CREATE UNIQUE INDEX patient_current_entity_id
+ON entity.patient (entity_id)
+WHERE upper_inf(transaction_period);
+
+ALTER TABLE entity.office DROP CONSTRAINT office_entity_id_key;
+CREATE INDEX office_entity_id ON entity.office (entity_id);
+CREATE UNIQUE INDEX office_current_entity_id
+ON entity.office (entity_id)
+WHERE upper_inf(transaction_period);
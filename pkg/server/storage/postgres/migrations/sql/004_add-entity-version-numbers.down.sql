@@ -0,0 +1,2 @@
+ALTER TABLE entity.office DROP COLUMN version;
+ALTER TABLE entity.patient DROP COLUMN version;
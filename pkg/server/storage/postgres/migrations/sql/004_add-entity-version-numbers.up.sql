@@ -0,0 +1,17 @@
+ALTER TABLE entity.patient ADD COLUMN version BIGINT NOT NULL DEFAULT 1;
+UPDATE entity.patient p
+SET version = v.version
+FROM (
+  SELECT row_id, row_number() OVER (PARTITION BY entity_id ORDER BY row_id) AS version
+  FROM entity.patient
+) v
+WHERE p.row_id = v.row_id;
+
+ALTER TABLE entity.office ADD COLUMN version BIGINT NOT NULL DEFAULT 1;
+UPDATE entity.office o
+SET version = v.version
+FROM (
+  SELECT row_id, row_number() OVER (PARTITION BY entity_id ORDER BY row_id) AS version
+  FROM entity.office
+) v
+WHERE o.row_id = v.row_id;
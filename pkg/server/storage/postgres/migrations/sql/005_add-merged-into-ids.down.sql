@@ -0,0 +1,2 @@
+ALTER TABLE entity.office DROP COLUMN merged_into_id;
+ALTER TABLE entity.patient DROP COLUMN merged_into_id;
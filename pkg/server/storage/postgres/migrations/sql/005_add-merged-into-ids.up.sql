@@ -0,0 +1,2 @@
+ALTER TABLE entity.patient ADD COLUMN merged_into_id VARCHAR;
+ALTER TABLE entity.office ADD COLUMN merged_into_id VARCHAR;
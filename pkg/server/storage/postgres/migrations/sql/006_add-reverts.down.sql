@@ -0,0 +1,9 @@
+ALTER TABLE entity.office
+  DROP COLUMN revert_reason,
+  DROP COLUMN reverted_by,
+  DROP COLUMN reverted_to_version;
+
+ALTER TABLE entity.patient
+  DROP COLUMN revert_reason,
+  DROP COLUMN reverted_by,
+  DROP COLUMN reverted_to_version;
@@ -0,0 +1,9 @@
+ALTER TABLE entity.patient
+  ADD COLUMN reverted_to_version BIGINT,
+  ADD COLUMN reverted_by VARCHAR,
+  ADD COLUMN revert_reason VARCHAR;
+
+ALTER TABLE entity.office
+  ADD COLUMN reverted_to_version BIGINT,
+  ADD COLUMN reverted_by VARCHAR,
+  ADD COLUMN revert_reason VARCHAR;
@@ -0,0 +1 @@
+DROP INDEX entity.patient_birthdate;
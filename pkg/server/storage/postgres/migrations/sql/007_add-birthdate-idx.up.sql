@@ -0,0 +1 @@
+CREATE INDEX patient_birthdate ON entity.patient (birthdate);
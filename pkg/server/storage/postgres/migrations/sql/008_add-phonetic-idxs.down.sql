@@ -0,0 +1,3 @@
+DROP INDEX entity.patient_first_name_phonetic;
+DROP INDEX entity.patient_last_name_phonetic;
+DROP EXTENSION fuzzystrmatch;
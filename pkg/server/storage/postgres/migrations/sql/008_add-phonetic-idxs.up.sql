@@ -0,0 +1,7 @@
+CREATE EXTENSION fuzzystrmatch;
+
+CREATE INDEX patient_last_name_phonetic
+ON entity.patient (dmetaphone(regexp_replace(COALESCE(UPPER(last_name), ''), 'MP([ST])', 'M\1', 'g')));
+
+CREATE INDEX patient_first_name_phonetic
+ON entity.patient (dmetaphone(regexp_replace(COALESCE(UPPER(first_name), ''), 'MP([ST])', 'M\1', 'g')));
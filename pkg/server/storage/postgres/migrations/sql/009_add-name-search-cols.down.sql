@@ -0,0 +1,25 @@
+DROP INDEX entity.patient_first_name_search_phonetic;
+DROP INDEX entity.patient_last_name_search_phonetic;
+
+CREATE INDEX patient_last_name_phonetic
+ON entity.patient (dmetaphone(regexp_replace(COALESCE(UPPER(last_name), ''), 'MP([ST])', 'M\1', 'g')));
+
+CREATE INDEX patient_first_name_phonetic
+ON entity.patient (dmetaphone(regexp_replace(COALESCE(UPPER(first_name), ''), 'MP([ST])', 'M\1', 'g')));
+
+CREATE INDEX office_name
+ON entity.office
+USING GIN (COALESCE(UPPER(name),'') gin_trgm_ops);
+
+DROP INDEX entity.office_name_search;
+DROP INDEX entity.patient_name_search;
+
+ALTER TABLE entity.office DROP COLUMN name_search;
+
+ALTER TABLE entity.patient
+  DROP COLUMN first_name_search,
+  DROP COLUMN last_name_search;
+
+DROP FUNCTION entity.fold_name(VARCHAR);
+
+DROP EXTENSION unaccent;
@@ -0,0 +1,43 @@
+CREATE EXTENSION unaccent;
+
+ALTER TABLE entity.patient
+  ADD COLUMN last_name_search VARCHAR NOT NULL DEFAULT '',
+  ADD COLUMN first_name_search VARCHAR NOT NULL DEFAULT '';
+
+ALTER TABLE entity.office ADD COLUMN name_search VARCHAR NOT NULL DEFAULT '';
+
+-- folds names like directoryapi.FoldName, which folds the names of entities stored from now on,
+-- except for some non-ASCII letters that unaccent folds differently, which
+-- postgres.RefoldNameSearchCols refolds
+CREATE FUNCTION entity.fold_name(value VARCHAR) RETURNS VARCHAR AS $$
+  SELECT UPPER(TRIM(regexp_replace(regexp_replace(regexp_replace(
+    unaccent(COALESCE(value, '')),
+    '[-‐‑‒–—―]', ' ', 'g'),
+    '[^[:alnum:][:space:]]', '', 'g'),
+    '\s+', ' ', 'g')))
+$$ LANGUAGE SQL STABLE;
+
+UPDATE entity.patient
+SET last_name_search = entity.fold_name(last_name),
+  first_name_search = entity.fold_name(first_name);
+
+UPDATE entity.office SET name_search = entity.fold_name(name);
+
+CREATE INDEX patient_name_search
+ON entity.patient
+USING GIN ((last_name_search || ' ' || first_name_search) gin_trgm_ops);
+
+CREATE INDEX office_name_search
+ON entity.office
+USING GIN (name_search gin_trgm_ops);
+
+DROP INDEX entity.office_name;
+
+DROP INDEX entity.patient_first_name_phonetic;
+DROP INDEX entity.patient_last_name_phonetic;
+
+CREATE INDEX patient_last_name_search_phonetic
+ON entity.patient (dmetaphone(regexp_replace(last_name_search, 'MP([ST])', 'M\1', 'g')));
+
+CREATE INDEX patient_first_name_search_phonetic
+ON entity.patient (dmetaphone(regexp_replace(first_name_search, 'MP([ST])', 'M\1', 'g')));
@@ -0,0 +1,3 @@
+DROP INDEX entity.office_name_autocomplete;
+DROP INDEX entity.patient_first_last_name_autocomplete;
+DROP INDEX entity.patient_last_first_name_autocomplete;
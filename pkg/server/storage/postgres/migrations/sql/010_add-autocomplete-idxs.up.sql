@@ -0,0 +1,13 @@
+-- prefix indexes over the current rows' folded names, collated byte-wise so they serve both the
+-- LIKE prefix predicates and the ordering of autocomplete queries
+CREATE INDEX patient_last_first_name_autocomplete
+ON entity.patient (((last_name_search || ' ' || first_name_search) COLLATE "C"))
+WHERE upper_inf(transaction_period);
+
+CREATE INDEX patient_first_last_name_autocomplete
+ON entity.patient (((first_name_search || ' ' || last_name_search) COLLATE "C"))
+WHERE upper_inf(transaction_period);
+
+CREATE INDEX office_name_autocomplete
+ON entity.office ((name_search COLLATE "C"))
+WHERE upper_inf(transaction_period);
@@ -0,0 +1,158 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+
+	sq "github.com/Masterminds/squirrel"
+	api "github.com/elixirhealth/directory/pkg/directoryapi"
+	"github.com/elixirhealth/directory/pkg/server/storage"
+	"go.uber.org/zap"
+)
+
+// refoldBatchSize is the max number of rows whose name search columns are checked per batch
+const refoldBatchSize = 1000
+
+// RefoldNameSearchCols sets the name search columns of the rows folded by the migration adding
+// them to the names folded by api.FoldName, like those of the entities stored since, where they
+// differ. Only rows with non-ASCII names are checked, since the migration folds ASCII names the
+// same way. Rows are refolded in batches of one transaction each, so it may run while the
+// entities are being searched.
+func RefoldNameSearchCols(dbURL string, logger *zap.Logger) error {
+	if dbURL == "" {
+		return errEmptyDBUrl
+	}
+	db, err := sql.Open("postgres", dbURL)
+	if err != nil {
+		return err
+	}
+	for i := 0; i < storage.NEntityTypes; i++ {
+		et := storage.EntityType(i)
+		n, err := refoldNameSearchCols(db, et)
+		if err != nil {
+			_ = db.Close()
+			return err
+		}
+		if n > 0 {
+			logger.Info("refolded name search columns", logRefoldResult(et, n)...)
+		}
+	}
+	return db.Close()
+}
+
+// nameSearchCols returns the name columns of the entity type and the name search columns holding
+// them folded, in the same order.
+func nameSearchCols(et storage.EntityType) (nameCols []string, searchCols []string) {
+	switch et {
+	case storage.Patient:
+		return []string{lastNameCol, firstNameCol},
+			[]string{lastNameSearchCol, firstNameSearchCol}
+	case storage.Office:
+		return []string{nameCol}, []string{nameSearchCol}
+	default:
+		panic(storage.ErrUnknownEntityType)
+	}
+}
+
+// nonASCIINames returns the predicate for rows with non-ASCII characters in any of the name
+// columns, which take more bytes than characters in UTF-8
+func nonASCIINames(nameCols []string) string {
+	names := make([]string, len(nameCols))
+	for i, col := range nameCols {
+		names[i] = "COALESCE(" + col + ", '')"
+	}
+	joined := strings.Join(names, " || ")
+	return "octet_length(" + joined + ") > char_length(" + joined + ")"
+}
+
+// refoldNameSearchCols refolds the name search columns of the rows of the entity type in batches
+// ordered by row ID, returning the number of rows refolded.
+func refoldNameSearchCols(db *sql.DB, et storage.EntityType) (int, error) {
+	n := 0
+	afterRowID := int64(0)
+	for {
+		refolded, lastRowID, err := refoldBatch(db, et, afterRowID)
+		n += refolded
+		if err != nil || lastRowID == afterRowID {
+			return n, err
+		}
+		afterRowID = lastRowID
+	}
+}
+
+// refoldBatch refolds the name search columns of the next batch of rows with non-ASCII names
+// after the given row ID in one transaction, returning the number of rows refolded and the last
+// row ID of the batch, which is afterRowID if there are no more rows.
+func refoldBatch(db *sql.DB, et storage.EntityType, afterRowID int64) (int, int64, error) {
+	fqTbl := fullTableName(et)
+	nameCols, searchCols := nameSearchCols(et)
+	tx, err := db.BeginTx(context.Background(), nil)
+	if err != nil {
+		return 0, afterRowID, err
+	}
+	rows, err := psql.RunWith(tx).
+		Select(append(append([]string{rowIDCol}, nameCols...), searchCols...)...).
+		From(fqTbl).
+		Where(sq.Gt{rowIDCol: afterRowID}).
+		Where(nonASCIINames(nameCols)).
+		OrderBy(rowIDCol).
+		Limit(refoldBatchSize).
+		Query()
+	if err != nil {
+		return 0, afterRowID, abortBatch(tx, err)
+	}
+	lastRowID := afterRowID
+	refolds := make(map[int64]map[string]interface{})
+	for rows.Next() {
+		names := make([]sql.NullString, len(nameCols))
+		folded := make([]string, len(searchCols))
+		dest := []interface{}{&lastRowID}
+		for i := range names {
+			dest = append(dest, &names[i])
+		}
+		for i := range folded {
+			dest = append(dest, &folded[i])
+		}
+		if err = rows.Scan(dest...); err != nil {
+			_ = rows.Close()
+			return 0, afterRowID, abortBatch(tx, err)
+		}
+		vals := make(map[string]interface{})
+		for i, col := range searchCols {
+			if refolded := api.FoldName(names[i].String); refolded != folded[i] {
+				vals[col] = refolded
+			}
+		}
+		if len(vals) > 0 {
+			refolds[lastRowID] = vals
+		}
+	}
+	if err = rows.Err(); err != nil {
+		_ = rows.Close()
+		return 0, afterRowID, abortBatch(tx, err)
+	}
+	if err = rows.Close(); err != nil {
+		return 0, afterRowID, abortBatch(tx, err)
+	}
+	for rowID, vals := range refolds {
+		_, err = psql.RunWith(tx).
+			Update(fqTbl).
+			SetMap(vals).
+			Where(sq.Eq{rowIDCol: rowID}).
+			Exec()
+		if err != nil {
+			return 0, afterRowID, abortBatch(tx, err)
+		}
+	}
+	if err = tx.Commit(); err != nil {
+		return 0, afterRowID, err
+	}
+	return len(refolds), lastRowID, nil
+}
+
+// abortBatch rolls back the transaction of a batch, returning the error that aborted it
+func abortBatch(tx *sql.Tx, err error) error {
+	_ = tx.Rollback()
+	return err
+}
@@ -0,0 +1,102 @@
+package postgres
+
+import (
+	"database/sql"
+	"testing"
+
+	sq "github.com/Masterminds/squirrel"
+	api "github.com/elixirhealth/directory/pkg/directoryapi"
+	"github.com/elixirhealth/directory/pkg/server/storage"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+)
+
+func TestRefoldNameSearchCols(t *testing.T) {
+	dbURL, tearDown := setUpPostgresTest(t)
+	defer func() {
+		err := tearDown()
+		assert.Nil(t, err)
+	}()
+	db, err := sql.Open("postgres", dbURL)
+	assert.Nil(t, err)
+	defer func() {
+		err = db.Close()
+		assert.Nil(t, err)
+	}()
+
+	// rows folded by the migration adding the name search columns
+	names := map[storage.EntityType]map[string][]string{
+		storage.Patient: {
+			"P1": {"Muñoz-O'Brien", "Zoë"},
+			"P2": {"Straße ẞtraße", "Łukasz"},
+			"P3": {"Ærøskøbing  Œdipe", "Þór Đorđe Ħaġar"},
+			"P4": {"Јовановић", "émile"},
+			"P5": {"Ｍｕｌｌｅｒ", ""},
+			"P6": {"O'Brien-Smith", "John"},
+		},
+		storage.Office: {
+			"F1": {"St. Mary's — Clinic"},
+			"F2": {"Clínica São João №2"},
+		},
+	}
+	for et, etNames := range names {
+		nameCols, searchCols := nameSearchCols(et)
+		for entityID, entityNames := range etNames {
+			vals := map[string]interface{}{entityIDCol: entityID}
+			for i, col := range nameCols {
+				vals[col] = entityNames[i]
+				vals[searchCols[i]] = sq.Expr("entity.fold_name(?)", entityNames[i])
+			}
+			_, err = psql.RunWith(db).Insert(fullTableName(et)).SetMap(vals).Exec()
+			assert.Nil(t, err)
+		}
+	}
+
+	err = RefoldNameSearchCols(dbURL, zap.NewNop())
+	assert.Nil(t, err)
+
+	for et, etNames := range names {
+		_, searchCols := nameSearchCols(et)
+		for entityID, entityNames := range etNames {
+			folded := make([]string, len(searchCols))
+			dest := make([]interface{}, len(searchCols))
+			for i := range folded {
+				dest[i] = &folded[i]
+			}
+			err = psql.RunWith(db).
+				Select(searchCols...).
+				From(fullTableName(et)).
+				Where(sq.Eq{entityIDCol: entityID}).
+				QueryRow().
+				Scan(dest...)
+			assert.Nil(t, err)
+			for i, name := range entityNames {
+				assert.Equal(t, api.FoldName(name), folded[i], entityID)
+			}
+		}
+	}
+
+	// refolded rows aren't refolded again
+	for et := range names {
+		n, err := refoldNameSearchCols(db, et)
+		assert.Nil(t, err)
+		assert.Zero(t, n)
+	}
+}
+
+func TestNameSearchCols(t *testing.T) {
+	for i := 0; i < storage.NEntityTypes; i++ {
+		nameCols, searchCols := nameSearchCols(storage.EntityType(i))
+		assert.NotEmpty(t, nameCols)
+		assert.Equal(t, len(nameCols), len(searchCols))
+	}
+	assert.Panics(t, func() { nameSearchCols(storage.EntityType(storage.NEntityTypes)) })
+}
+
+func TestNonASCIINames(t *testing.T) {
+	assert.Equal(t,
+		"octet_length(COALESCE(a, '') || COALESCE(b, '')) > "+
+			"char_length(COALESCE(a, '') || COALESCE(b, ''))",
+		nonASCIINames([]string{"a", "b"}),
+	)
+}
@@ -6,35 +6,194 @@ import (
 	"sort"
 	"strings"
 	"sync"
+	"time"
 
+	sq "github.com/Masterminds/squirrel"
+	api "github.com/elixirhealth/directory/pkg/directoryapi"
 	"github.com/elixirhealth/directory/pkg/server/storage"
 )
 
+var patientNameSearcher = &trigramSearcher{
+	searcherName: storage.PatientNameSearcher,
+	et:           storage.Patient,
+	indexedValue: joinedNames(lastNameSearchCol, firstNameSearchCol),
+}
+
 var searchers = []searcher{
 	&btreeSearcher{
-		searcherName: "PatientEntityID",
+		searcherName: storage.PatientEntityIDSearcher,
 		et:           storage.Patient,
 		indexedValue: entityIDCol,
 	},
-	&trigramSearcher{
-		searcherName: "PatientName",
+	patientNameSearcher,
+	&phoneticSearcher{
+		searcherName: storage.PatientPhoneticNameSearcher,
+		et:           storage.Patient,
+		indexedValues: []string{
+			phoneticCode(lastNameSearchCol),
+			phoneticCode(firstNameSearchCol),
+		},
+	},
+	&synonymSearcher{
+		searcherName: storage.PatientNameSynonymsSearcher,
+		et:           storage.Patient,
+		indexedValue: joinedNames(lastNameSearchCol, firstNameSearchCol),
+	},
+	&birthdateSearcher{
+		searcherName: storage.PatientBirthdateSearcher,
 		et:           storage.Patient,
-		indexedValue: nonEmptyUpper(lastNameCol, firstNameCol),
+		indexedValue: birthdateCol,
+		boosted:      patientNameSearcher,
 	},
 
 	&btreeSearcher{
-		searcherName: "OfficeEntityID",
+		searcherName: storage.OfficeEntityIDSearcher,
 		et:           storage.Office,
 		indexedValue: entityIDCol,
 	},
 	&trigramSearcher{
-		searcherName: "OfficeName",
+		searcherName: storage.OfficeNameSearcher,
 		et:           storage.Office,
-		indexedValue: nonEmptyUpper(nameCol),
+		indexedValue: joinedNames(nameSearchCol),
 	},
 }
 
-func nonEmptyUpper(cols ...string) string {
+// validAt returns the search predicate for rows valid at the given time, or for current rows if
+// asOf is zero. Since searcher predicates refer to the query as $1, asOf is referred to as $2.
+func validAt(asOf time.Time) sq.Sqlizer {
+	if asOf.IsZero() {
+		return sq.Expr(currentRow)
+	}
+	return sq.Expr(transactionPeriodCol+" @> $2::timestamptz", asOf)
+}
+
+// firstFiltersArg returns the number of the first arg of the filters predicate, which follows the
+// searcher query arg ($1) and the validAt arg ($2), if any.
+func firstFiltersArg(asOf time.Time) int {
+	if asOf.IsZero() {
+		return 2
+	}
+	return 3
+}
+
+// filtersPredicate returns the search predicate for rows of the given entity type satisfying the
+// filters, which is empty if there are none, along with its args. Since the predicate follows the
+// searcher and validAt predicates, its args are referred to as $firstArg, $firstArg+1, etc.
+func filtersPredicate(
+	et storage.EntityType, f *api.SearchFilters, firstArg int,
+) (string, []interface{}) {
+	if f == nil {
+		return "", nil
+	}
+	var preds []string
+	var args []interface{}
+	add := func(format string, arg interface{}) {
+		preds = append(preds, fmt.Sprintf(format, firstArg+len(args)))
+		args = append(args, arg)
+	}
+	switch et {
+	case storage.Patient:
+		if f.Birthdate != nil {
+			add(birthdateCol+" = $%d", f.Birthdate.ISO8601())
+		}
+		if f.BirthdateFrom != nil {
+			add(birthdateCol+" >= $%d", f.BirthdateFrom.ISO8601())
+		}
+		if f.BirthdateTo != nil {
+			add(birthdateCol+" <= $%d", f.BirthdateTo.ISO8601())
+		}
+		if f.LastNamePrefix != "" {
+			add(lastNameSearchCol+" LIKE $%d", escapeLike(api.FoldName(f.LastNamePrefix))+"%")
+		}
+		if f.FirstNamePrefix != "" {
+			add(firstNameSearchCol+" LIKE $%d", escapeLike(api.FoldName(f.FirstNamePrefix))+"%")
+		}
+		if f.Suffix != "" {
+			add("UPPER("+suffixCol+") = $%d", strings.ToUpper(f.Suffix))
+		}
+	case storage.Office:
+		if f.OfficeName != "" {
+			add(nameSearchCol+" = $%d", api.FoldName(f.OfficeName))
+		}
+	}
+	return strings.Join(preds, " AND "), args
+}
+
+// boostPredicate returns the search predicate restricting a birthdate searcher to the entities
+// its boosted searcher matches when the query has text besides the date, which is empty
+// otherwise, along with its arg, which is referred to as $arg.
+func boostPredicate(s searcher, query string, arg int) (string, []interface{}) {
+	bs, ok := s.(*birthdateSearcher)
+	if !ok {
+		return "", nil
+	}
+	boostedQuery := bs.boosted.preprocQuery(query, nil)
+	if boostedQuery == "" {
+		return "", nil
+	}
+	return fmt.Sprintf("%s %% $%d", bs.boosted.indexedValue, arg), []interface{}{boostedQuery}
+}
+
+// escapeLike upper-cases the value and escapes the LIKE wildcards in it so it is matched
+// literally
+func escapeLike(value string) string {
+	return likeEscaper.Replace(strings.ToUpper(value))
+}
+
+var likeEscaper = strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`)
+
+// addZeroSimilarities adds a zero similarity for each searcher of the entity's type with a query
+// (keyed by searcher name) that did not match it, so results have a similarity for every searcher
+// of their type that ran
+func addZeroSimilarities(es *storage.EntitySim, searcherQueries map[string]string) {
+	et := storage.GetEntityType(es.E)
+	for _, s := range searchers {
+		_, ran := searcherQueries[s.name()]
+		if _, in := es.Similarities[s.name()]; !in && ran && s.entityType() == et {
+			es.Add(s.name(), 0)
+		}
+	}
+}
+
+// getSearcherQueries returns the preprocessed query for each searcher, keyed by searcher name,
+// omitting searchers with nothing in the query to search for. The patient name searchers search
+// the api.PatientNameQuery with the names of the (possibly nil) filters.
+func getSearcherQueries(
+	query string, filters *api.SearchFilters, nicknames storage.Nicknames,
+) map[string]string {
+	nameQuery := api.PatientNameQuery(query, filters)
+	searcherQueries := make(map[string]string, len(searchers))
+	for _, s := range searchers {
+		raw := query
+		if searchesPatientNames(s) {
+			raw = nameQuery
+		}
+		if searcherQuery := s.preprocQuery(raw, nicknames); searcherQuery != "" {
+			searcherQueries[s.name()] = searcherQuery
+		}
+	}
+	return searcherQueries
+}
+
+// phoneticCode returns the expression for the phonetic code of the folded value, which mirrors
+// storage.PhoneticCode and the patient phonetic name indexes
+func phoneticCode(value string) string {
+	return fmt.Sprintf("dmetaphone(regexp_replace(%s, '%s', '%s', 'g'))", value,
+		storage.SilentPPattern, storage.SilentPReplacement)
+}
+
+// searchesPatientNames returns whether the searcher searches patient names
+func searchesPatientNames(s searcher) bool {
+	switch s.(type) {
+	case *trigramSearcher, *phoneticSearcher, *synonymSearcher:
+		return s.entityType() == storage.Patient
+	}
+	return false
+}
+
+// joinedNames returns the expression for the values of the (non-null) name search columns joined
+// by spaces, which matches the name search indexes
+func joinedNames(cols ...string) string {
 	return "(" + strings.Join(cols, " || ' ' || ") + ")"
 }
 
@@ -43,7 +202,10 @@ type searcher interface {
 	name() string
 	predicate() string
 	similarity() string
-	preprocQuery(raw string) string
+
+	// preprocQuery returns the query arg for the searcher's predicate and similarity, which is
+	// empty if the raw query has nothing for the searcher to search for
+	preprocQuery(raw string, nicknames storage.Nicknames) string
 }
 
 type btreeSearcher struct {
@@ -72,11 +234,15 @@ func (ps *btreeSearcher) similarity() string {
 		similarityCol)
 }
 
-func (ps *btreeSearcher) preprocQuery(raw string) string {
+func (ps *btreeSearcher) preprocQuery(raw string, _ storage.Nicknames) string {
+	text, _ := api.SplitQueryBirthdate(raw)
+	if text == "" {
+		return ""
+	}
 	if !ps.caseSensitive {
-		return strings.ToUpper(raw) + "%"
+		return strings.ToUpper(text) + "%"
 	}
-	return raw + "%"
+	return text + "%"
 }
 
 type trigramSearcher struct {
@@ -101,11 +267,155 @@ func (ts *trigramSearcher) similarity() string {
 	return fmt.Sprintf("similarity(%s, $1) AS %s", ts.indexedValue, similarityCol)
 }
 
-func (ts *trigramSearcher) preprocQuery(raw string) string {
+func (ts *trigramSearcher) preprocQuery(raw string, _ storage.Nicknames) string {
+	text, _ := api.SplitQueryBirthdate(raw)
 	if !ts.caseSensitive {
-		return strings.ToUpper(raw)
+		// fold the query like the indexed name search columns
+		return api.FoldName(text)
+	}
+	return text
+}
+
+// phoneticSearcher matches the phonetic codes of the words in the query against those of each of
+// its indexed values, with a similarity of the fraction of the values matched.
+type phoneticSearcher struct {
+	et            storage.EntityType
+	searcherName  string
+	indexedValues []string
+}
+
+// queryCodes is the array of the non-empty phonetic codes of the space-separated words in the
+// query
+var queryCodes = fmt.Sprintf(
+	"ARRAY(SELECT code FROM (SELECT %s AS code FROM regexp_split_to_table($1, ' ') AS word) "+
+		"AS codes WHERE code <> '')", phoneticCode("word"))
+
+func (ps *phoneticSearcher) entityType() storage.EntityType {
+	return ps.et
+}
+
+func (ps *phoneticSearcher) name() string {
+	return ps.searcherName
+}
+
+func (ps *phoneticSearcher) predicate() string {
+	matches := make([]string, len(ps.indexedValues))
+	for i, indexedValue := range ps.indexedValues {
+		matches[i] = indexedValue + " = ANY(" + queryCodes + ")"
+	}
+	return "(" + strings.Join(matches, " OR ") + ")"
+}
+
+func (ps *phoneticSearcher) similarity() string {
+	matches := make([]string, len(ps.indexedValues))
+	for i, indexedValue := range ps.indexedValues {
+		matches[i] = "(" + indexedValue + " = ANY(" + queryCodes + "))::int"
+	}
+	return fmt.Sprintf("(%s)::real / %d AS %s", strings.Join(matches, " + "),
+		len(ps.indexedValues), similarityCol)
+}
+
+func (ps *phoneticSearcher) preprocQuery(raw string, _ storage.Nicknames) string {
+	text, _ := api.SplitQueryBirthdate(raw)
+	return api.FoldName(text)
+}
+
+// synonymSearcher matches the variants of the query with its given names replaced by their
+// nicknames or other synonyms, e.g., ROBERT JONES for the query bob jones, against its indexed
+// value, with a similarity of the highest trigram similarity of any variant.
+type synonymSearcher struct {
+	et           storage.EntityType
+	searcherName string
+	indexedValue string
+}
+
+func (ss *synonymSearcher) entityType() storage.EntityType {
+	return ss.et
+}
+
+func (ss *synonymSearcher) name() string {
+	return ss.searcherName
+}
+
+func (ss *synonymSearcher) predicate() string {
+	// separate conditions for each variant rather than % ANY($1::text[]) so the trigram index
+	// can be used; conditions past the last variant are NULL and so never match
+	matches := make([]string, storage.MaxNicknameVariants)
+	for i := range matches {
+		matches[i] = fmt.Sprintf("%s %% ($1::text[])[%d]", ss.indexedValue, i+1)
+	}
+	return "(" + strings.Join(matches, " OR ") + ")"
+}
+
+func (ss *synonymSearcher) similarity() string {
+	// GREATEST ignores the NULL similarities past the last variant
+	sims := make([]string, storage.MaxNicknameVariants)
+	for i := range sims {
+		sims[i] = fmt.Sprintf("similarity(%s, ($1::text[])[%d])", ss.indexedValue, i+1)
+	}
+	return fmt.Sprintf("GREATEST(%s) AS %s", strings.Join(sims, ", "), similarityCol)
+}
+
+func (ss *synonymSearcher) preprocQuery(raw string, nicknames storage.Nicknames) string {
+	text, _ := api.SplitQueryBirthdate(raw)
+	variants := nicknames.Variants(api.FoldName(text))
+	if len(variants) == 0 {
+		return ""
+	}
+	return textArray(variants)
+}
+
+// textArray returns the Postgres array literal of the values, each quoted so commas, braces, and
+// spaces in them are literal
+func textArray(values []string) string {
+	quoted := make([]string, len(values))
+	for i, value := range values {
+		quoted[i] = `"` + arrayElemEscaper.Replace(value) + `"`
+	}
+	return "{" + strings.Join(quoted, ",") + "}"
+}
+
+var arrayElemEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`)
+
+// birthdateSearcher matches the date in the query exactly or with its day and month transposed,
+// the latter with a lower similarity. When the query has text besides the date, it only boosts the
+// entities matched by the boosted searcher.
+type birthdateSearcher struct {
+	et           storage.EntityType
+	searcherName string
+	indexedValue string
+	boosted      *trigramSearcher
+}
+
+func (bs *birthdateSearcher) entityType() storage.EntityType {
+	return bs.et
+}
+
+func (bs *birthdateSearcher) name() string {
+	return bs.searcherName
+}
+
+func (bs *birthdateSearcher) predicate() string {
+	return bs.indexedValue + " = ANY($1::date[])"
+}
+
+func (bs *birthdateSearcher) similarity() string {
+	// the exact date is first in the query array, followed by the transposed date, if any
+	return fmt.Sprintf("(CASE WHEN %s = ($1::date[])[1] THEN 1 ELSE %g END)::real AS %s",
+		bs.indexedValue, storage.TransposedBirthdateSimilarity, similarityCol)
+}
+
+func (bs *birthdateSearcher) preprocQuery(raw string, _ storage.Nicknames) string {
+	_, d := api.SplitQueryBirthdate(raw)
+	if d == nil {
+		return ""
+	}
+	dates := []string{d.ISO8601()}
+	if transposed := d.Transposed(); transposed != nil {
+		dates = append(dates, transposed.ISO8601())
 	}
-	return raw
+	// Postgres array literal
+	return "{" + strings.Join(dates, ",") + "}"
 }
 
 type searchResultMerger interface {
@@ -114,13 +424,15 @@ type searchResultMerger interface {
 }
 
 type searchResultMergerImpl struct {
-	sims map[string]*storage.EntitySim
-	mu   sync.Mutex
+	sims    map[string]*storage.EntitySim
+	ranking *storage.Ranking
+	mu      sync.Mutex
 }
 
-func newSearchResultMerger() searchResultMerger {
+func newSearchResultMerger(ranking *storage.Ranking) searchResultMerger {
 	return &searchResultMergerImpl{
-		sims: make(map[string]*storage.EntitySim),
+		sims:    make(map[string]*storage.EntitySim),
+		ranking: ranking,
 	}
 }
 
@@ -141,7 +453,7 @@ func (srm *searchResultMergerImpl) merge(
 		e := createEntity()
 		srm.mu.Lock()
 		if _, in := srm.sims[e.EntityId]; !in {
-			srm.sims[e.EntityId] = storage.NewEntitySim(e)
+			srm.sims[e.EntityId] = storage.NewEntitySim(e, srm.ranking)
 		}
 		srm.sims[e.EntityId].Add(searchName, simDest)
 		n++
@@ -155,7 +467,7 @@ func (srm *searchResultMergerImpl) top(n uint) storage.EntitySims {
 	heap.Init(ess)
 	srm.mu.Lock()
 	for _, es := range srm.sims {
-		if ess.Len() < int(n) || es.Similarity() > ess.Peak().Similarity() {
+		if ess.Len() < int(n) || ess.Peak().Less(es) {
 			heap.Push(ess, es)
 		}
 		if ess.Len() > int(n) {
@@ -1,7 +1,9 @@
 package postgres
 
 import (
+	"strings"
 	"testing"
+	"time"
 
 	api "github.com/elixirhealth/directory/pkg/directoryapi"
 	"github.com/elixirhealth/directory/pkg/server/storage"
@@ -14,7 +16,7 @@ func TestSearchResultMergerImpl_merge(t *testing.T) {
 	rows1 := &fixedOfficeRows{ess: testEntitySims(0.1, search1, n)}
 	rows2 := &fixedOfficeRows{ess: testEntitySims(0.2, search2, n)}
 
-	srm := newSearchResultMerger()
+	srm := newSearchResultMerger(storage.NewDefaultRanking())
 	nMerged, err := srm.merge(rows1, search1, storage.Office)
 	assert.Nil(t, err)
 	assert.Equal(t, n, nMerged)
@@ -33,7 +35,7 @@ func testEntitySims(simMult float32, search string, n int) storage.EntitySims {
 	ess := make(storage.EntitySims, n)
 	for i := range ess {
 		sim := simMult * float32(i)
-		es := storage.NewEntitySim(api.NewTestOffice(i, true))
+		es := storage.NewEntitySim(api.NewTestOffice(i, true), nil)
 		es.Add(search, sim)
 		ess[i] = es
 
@@ -43,13 +45,13 @@ func testEntitySims(simMult float32, search string, n int) storage.EntitySims {
 
 func TestSearchResultMergerImpl_top(t *testing.T) {
 	searchName := "Search1"
-	es1 := storage.NewEntitySim(&api.Entity{EntityId: "entity1"})
+	es1 := storage.NewEntitySim(&api.Entity{EntityId: "entity1"}, nil)
 	es1.Add(searchName, 0.1)
-	es2 := storage.NewEntitySim(&api.Entity{EntityId: "entity2"})
+	es2 := storage.NewEntitySim(&api.Entity{EntityId: "entity2"}, nil)
 	es2.Add(searchName, 0.3)
-	es3 := storage.NewEntitySim(&api.Entity{EntityId: "entity3"})
+	es3 := storage.NewEntitySim(&api.Entity{EntityId: "entity3"}, nil)
 	es3.Add(searchName, 0.2)
-	es4 := storage.NewEntitySim(&api.Entity{EntityId: "entity4"})
+	es4 := storage.NewEntitySim(&api.Entity{EntityId: "entity4"}, nil)
 	es4.Add(searchName, 0.4)
 	srm := &searchResultMergerImpl{
 		sims: map[string]*storage.EntitySim{
@@ -67,14 +69,182 @@ func TestSearchResultMergerImpl_top(t *testing.T) {
 	assert.Equal(t, 4, len(top))
 }
 
+func TestValidAt(t *testing.T) {
+	qSQL, args, err := validAt(time.Time{}).ToSql()
+	assert.Nil(t, err)
+	assert.Equal(t, currentRow, qSQL)
+	assert.Empty(t, args)
+
+	asOf := time.Date(2006, 1, 2, 15, 4, 5, 0, time.UTC)
+	qSQL, args, err = validAt(asOf).ToSql()
+	assert.Nil(t, err)
+	assert.Equal(t, "transaction_period @> $2::timestamptz", qSQL)
+	assert.Equal(t, []interface{}{asOf}, args)
+}
+
+func TestFirstFiltersArg(t *testing.T) {
+	assert.Equal(t, 2, firstFiltersArg(time.Time{}))
+	assert.Equal(t, 3, firstFiltersArg(time.Now()))
+}
+
+func TestFiltersPredicate(t *testing.T) {
+	pred, args := filtersPredicate(storage.Patient, nil, 2)
+	assert.Empty(t, pred)
+	assert.Empty(t, args)
+
+	f := &api.SearchFilters{
+		BirthdateFrom:   &api.Date{Year: 2006, Month: 1, Day: 2},
+		BirthdateTo:     &api.Date{Year: 2006, Month: 2, Day: 1},
+		LastNamePrefix:  "Ó'd",
+		FirstNamePrefix: "jo",
+		Suffix:          "Jr",
+		OfficeName:      "Office Name",
+	}
+	pred, args = filtersPredicate(storage.Patient, f, 3)
+	assert.Equal(t, "birthdate >= $3 AND birthdate <= $4 AND last_name_search LIKE $5 AND "+
+		"first_name_search LIKE $6 AND UPPER(suffix) = $7", pred)
+	assert.Equal(t, []interface{}{"2006-01-02", "2006-02-01", "OD%", "JO%", "JR"}, args)
+
+	pred, args = filtersPredicate(storage.Office, f, 2)
+	assert.Equal(t, "name_search = $2", pred)
+	assert.Equal(t, []interface{}{"OFFICE NAME"}, args)
+}
+
+func TestBoostPredicate(t *testing.T) {
+	pred, args := boostPredicate(searchers[4], "garcia 03/04/1975", 3)
+	assert.Equal(t, "(last_name_search || ' ' || first_name_search) % $3", pred)
+	assert.Equal(t, []interface{}{"GARCIA"}, args)
+
+	// date-only query
+	pred, args = boostPredicate(searchers[4], "03/04/1975", 3)
+	assert.Empty(t, pred)
+	assert.Empty(t, args)
+
+	// not a birthdate searcher
+	pred, args = boostPredicate(searchers[1], "garcia 03/04/1975", 3)
+	assert.Empty(t, pred)
+	assert.Empty(t, args)
+}
+
+func TestEscapeLike(t *testing.T) {
+	assert.Equal(t, "SMITH", escapeLike("smith"))
+	assert.Equal(t, `100\%\_`, escapeLike(`100%_`))
+	assert.Equal(t, `A\\B`, escapeLike(`a\b`))
+}
+
+func TestAddZeroSimilarities(t *testing.T) {
+	es := storage.NewEntitySim(api.NewTestOffice(1, true), nil)
+	es.Add("OfficeName", 0.5)
+	addZeroSimilarities(es, getSearcherQueries("some query", nil, storage.NewDefaultNicknames()))
+	assert.Equal(t, float32(0.5), es.Similarity())
+	assert.Equal(t, 2, len(es.Similarities))
+	assert.Equal(t, float32(0.5), es.Similarities["OfficeName"])
+	assert.Zero(t, es.Similarities["OfficeEntityID"])
+
+	es = storage.NewEntitySim(api.NewTestPatient(1, true), nil)
+	es.Add("PatientName", 0.5)
+	addZeroSimilarities(es, getSearcherQueries("some query", nil, storage.NewDefaultNicknames()))
+	assert.Equal(t, 3, len(es.Similarities))
+	_, in := es.Similarities["PatientBirthdate"]
+	assert.False(t, in)
+}
+
+func TestGetSearcherQueries(t *testing.T) {
+	searcherQueries := getSearcherQueries("some query", nil, storage.NewDefaultNicknames())
+	assert.Equal(t, len(searchers)-2, len(searcherQueries))
+	assert.Equal(t, "SOME QUERY%", searcherQueries["PatientEntityID"])
+	assert.Equal(t, "SOME QUERY", searcherQueries["PatientName"])
+
+	searcherQueries = getSearcherQueries("garcia 03/04/1975", nil, nil)
+	assert.Equal(t, len(searchers)-1, len(searcherQueries))
+	assert.Equal(t, "GARCIA%", searcherQueries["PatientEntityID"])
+	assert.Equal(t, "GARCIA", searcherQueries["PatientName"])
+	assert.Equal(t, "{1975-03-04,1975-04-03}", searcherQueries["PatientBirthdate"])
+
+	// names are folded, except in entity IDs
+	searcherQueries = getSearcherQueries("Muñoz-O'Brien", nil, nil)
+	assert.Equal(t, "MUÑOZ-O'BRIEN%", searcherQueries["PatientEntityID"])
+	assert.Equal(t, "MUNOZ OBRIEN", searcherQueries["PatientName"])
+	assert.Equal(t, "MUNOZ OBRIEN", searcherQueries["OfficeName"])
+
+	searcherQueries = getSearcherQueries("1975-03-14", nil, nil)
+	assert.Equal(t, map[string]string{"PatientBirthdate": "{1975-03-14}"}, searcherQueries)
+
+	// patient name searchers also search the name filters
+	filters := &api.SearchFilters{LastNamePrefix: "garcia"}
+	searcherQueries = getSearcherQueries("maria", filters, nil)
+	assert.Equal(t, "MARIA%", searcherQueries["PatientEntityID"])
+	assert.Equal(t, "GARCIA MARIA", searcherQueries["PatientName"])
+	assert.Equal(t, "MARIA", searcherQueries["OfficeName"])
+
+	searcherQueries = getSearcherQueries("bob jones", nil, storage.NewDefaultNicknames())
+	assert.Equal(t, len(searchers)-1, len(searcherQueries))
+	assert.Equal(t, `{"ROBERT JONES","BOBBY JONES","ROB JONES","ROBBIE JONES"}`,
+		searcherQueries["PatientNameSynonyms"])
+}
+
+func TestSearcherNames(t *testing.T) {
+	names := make([]string, len(searchers))
+	for i, s := range searchers {
+		names[i] = s.name()
+	}
+	assert.Equal(t, storage.SearcherNames, names)
+}
+
+func TestPhoneticSearcher(t *testing.T) {
+	ps := searchers[2].(*phoneticSearcher)
+	codes := "ARRAY(SELECT code FROM (SELECT dmetaphone(regexp_replace(word, 'MP([ST])', " +
+		"'M\\1', 'g')) AS code FROM regexp_split_to_table($1, ' ') AS word) AS codes " +
+		"WHERE code <> '')"
+	lastCode := "dmetaphone(regexp_replace(last_name_search, 'MP([ST])', 'M\\1', 'g'))"
+	firstCode := "dmetaphone(regexp_replace(first_name_search, 'MP([ST])', 'M\\1', 'g'))"
+	assert.Equal(t, "("+lastCode+" = ANY("+codes+") OR "+firstCode+" = ANY("+codes+"))",
+		ps.predicate())
+	assert.Equal(t, "(("+lastCode+" = ANY("+codes+"))::int + ("+firstCode+" = ANY("+codes+
+		"))::int)::real / 2 AS sim", ps.similarity())
+	assert.Equal(t, "TOMSON CATHRYN", ps.preprocQuery(" tomson  cathryn 03/04/1975", nil))
+	assert.Equal(t, "MUNOZ OBRIEN", ps.preprocQuery("Muñoz O'Brien", nil))
+}
+
+func TestSynonymSearcher(t *testing.T) {
+	ss := searchers[3].(*synonymSearcher)
+	name := "(last_name_search || ' ' || first_name_search)"
+	assert.True(t, strings.HasPrefix(ss.predicate(),
+		"("+name+" % ($1::text[])[1] OR "+name+" % ($1::text[])[2] OR "))
+	assert.True(t, strings.HasSuffix(ss.predicate(), name+" % ($1::text[])[8])"))
+	assert.True(t, strings.HasPrefix(ss.similarity(),
+		"GREATEST(similarity("+name+", ($1::text[])[1]), similarity("))
+	assert.True(t, strings.HasSuffix(ss.similarity(), "($1::text[])[8])) AS sim"))
+
+	nicknames := make(storage.Nicknames)
+	nicknames.Add("ELIZABETH", "LIZ")
+	assert.Equal(t, `{"ELIZABETH JONES"}`, ss.preprocQuery("liz jones 03/04/1975", nicknames))
+	assert.Empty(t, ss.preprocQuery("mary jones", nicknames))
+}
+
+func TestTextArray(t *testing.T) {
+	assert.Equal(t, `{"A B","C,D"}`, textArray([]string{"A B", "C,D"}))
+	assert.Equal(t, `{"\"A\\"}`, textArray([]string{`"A\`}))
+}
+
+func TestBirthdateSearcher(t *testing.T) {
+	bs := searchers[4].(*birthdateSearcher)
+	assert.Equal(t, "birthdate = ANY($1::date[])", bs.predicate())
+	assert.Equal(t, "(CASE WHEN birthdate = ($1::date[])[1] THEN 1 ELSE 0.5 END)::real AS sim",
+		bs.similarity())
+	assert.Empty(t, bs.preprocQuery("garcia", nil))
+}
+
 type fixedOfficeRows struct {
 	ess      storage.EntitySims
 	cursor   int
+	errDelay time.Duration
 	errErr   error
 	closeErr error
 }
 
 func (fr *fixedOfficeRows) Err() error {
+	time.Sleep(fr.errDelay)
 	return fr.errErr
 }
 
@@ -83,8 +253,9 @@ func (fr *fixedOfficeRows) Scan(dest ...interface{}) error {
 	f := e.TypeAttributes.(*api.Entity_Office).Office
 	dest[0] = &e.EntityId
 	dest[1] = &f.Name
+	dest[2] = &e.Version
 	sim := fr.ess[fr.cursor].Similarity()
-	dest[2] = &sim
+	dest[3] = &sim
 	fr.cursor++
 	return nil
 }
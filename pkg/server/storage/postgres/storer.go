@@ -3,7 +3,10 @@ package postgres
 import (
 	"context"
 	"database/sql"
+	"sort"
+	"strings"
 	"sync"
+	"time"
 
 	sq "github.com/Masterminds/squirrel"
 	errors2 "github.com/drausin/libri/libri/common/errors"
@@ -25,6 +28,7 @@ var (
 
 	errEmptyDBUrl            = errors.New("empty DB URL")
 	errUnexpectedStorageType = errors.New("unexpected storage type")
+	errConcurrentUpdate      = errors.New("entity concurrently updated")
 )
 
 type storer struct {
@@ -56,40 +60,27 @@ func New(
 		db:      db,
 		dbCache: sq.NewStmtCacher(db),
 		qr:      &querierImpl{},
-		newSRM:  func() searchResultMerger { return newSearchResultMerger() },
+		newSRM:  func() searchResultMerger { return newSearchResultMerger(params.Ranking) },
 		logger:  logger,
 	}, nil
 }
 
-func (s *storer) PutEntity(e *api.Entity) (string, error) {
-	if e.EntityId != "" {
-		if err := s.idGen.Check(e.EntityId); err != nil {
-			return "", err
-		}
-	}
-	if err := api.ValidateEntity(e); err != nil {
-		return "", err
-	}
-	insert, err := storage.MaybeAddEntityID(e, s.idGen)
+func (s *storer) PutEntity(e *api.Entity, allowCreate bool) (string, error) {
+	insert, err := s.prepPut(e)
 	if err != nil {
 		return "", err
 	}
 	fqTbl := fullTableName(storage.GetEntityType(e))
-	vals := getPutStmtValues(e)
 	ctx, cancel := context.WithTimeout(context.Background(), s.params.PutQueryTimeout)
 	if insert {
+		e.Version = 1
 		q := psql.RunWith(s.dbCache).
 			Insert(fqTbl).
-			SetMap(vals)
+			SetMap(getPutStmtValues(e))
 		s.logger.Debug("inserting entity", logPutInsert(q, e)...)
 		_, err = s.qr.InsertExecContext(ctx, q)
 	} else {
-		q := psql.RunWith(s.dbCache).
-			Update(fqTbl).
-			SetMap(vals).
-			Where(sq.Eq{entityIDCol: e.EntityId})
-		s.logger.Debug("updating entity", logPutUpdate(q, e)...)
-		_, err = s.qr.UpdateExecContext(ctx, q)
+		err = s.updateEntity(ctx, e, fqTbl, allowCreate)
 	}
 	cancel()
 	if err != nil {
@@ -104,37 +95,116 @@ func (s *storer) PutEntity(e *api.Entity) (string, error) {
 	return e.EntityId, nil
 }
 
-func (s *storer) GetEntity(entityID string) (*api.Entity, error) {
+func (s *storer) GetEntity(entityID string, asOf time.Time) (*api.Entity, error) {
 	if err := s.idGen.Check(entityID); err != nil {
 		return nil, err
 	}
+	e, err := s.getEntityRedirected(entityID, asOf)
+	if err != nil {
+		return nil, err
+	}
+	s.logger.Debug("successfully found entity", zap.String(logEntityID, entityID))
+	return e, nil
+}
+
+// getEntityRedirected gets the version of the entity valid at the given time like getEntity, but
+// if the entity had been merged into another by then, it gets the survivor's version instead.
+func (s *storer) getEntityRedirected(entityID string, asOf time.Time) (*api.Entity, error) {
+	redirected := make(map[string]struct{})
+	for {
+		e, mergedIntoID, err := s.getEntity(entityID, asOf)
+		if err != storage.ErrDeletedEntity || mergedIntoID == "" {
+			return e, err
+		}
+		if _, in := redirected[entityID]; in {
+			return nil, storage.ErrMergeCycle
+		}
+		redirected[entityID] = struct{}{}
+		s.logger.Debug("redirecting to merged entity survivor",
+			logMergeResult(mergedIntoID, entityID)...)
+		entityID = mergedIntoID
+	}
+}
+
+// getEntity gets the version of the entity valid at the given time, or the current version if
+// asOf is zero. If that version has been deleted, it returns ErrDeletedEntity along with the entity
+// ID of the survivor the entity was merged into, if any.
+func (s *storer) getEntity(entityID string, asOf time.Time) (*api.Entity, string, error) {
 	et := storage.GetEntityTypeFromID(entityID)
-	cols, dest, create := prepEntityScan(et, 0)
+
+	// prepare the destination slice for the entity with extra slots for the end of its
+	// transaction period, which determines whether the latest version is still valid, and the
+	// survivor it was merged into, if any
+	cols, dest, create := prepEntityScan(et, 2)
+	var validTo *time.Time
+	var mergedIntoID *string
 	q := psql.RunWith(s.dbCache).
-		Select(cols...).
+		Select(append(cols, validToCol, mergedIntoIDCol)...).
 		From(fullTableName(et)).
-		Where(sq.Eq{entityIDCol: entityID})
+		Where(sq.Eq{entityIDCol: entityID}).
+		OrderBy(rowIDCol + " DESC").
+		Limit(1)
+	if !asOf.IsZero() {
+		q = q.Where(validFromCol+" <= ?", asOf)
+	}
 	s.logger.Debug("getting entity", logGetSelect(q, et, entityID)...)
 	ctx, cancel := context.WithTimeout(context.Background(), s.params.GetQueryTimeout)
 	defer cancel()
 	row := s.qr.SelectQueryRowContext(ctx, q)
-	if err := row.Scan(dest...); err == sql.ErrNoRows {
-		return nil, storage.ErrMissingEntity
+	if err := row.Scan(append(dest, &validTo, &mergedIntoID)...); err == sql.ErrNoRows {
+		return nil, "", storage.ErrMissingEntity
 	} else if err != nil {
-		return nil, err
+		return nil, "", err
 	}
-	s.logger.Debug("successfully found entity", zap.String(logEntityID, entityID))
-	return create(), nil
+	if !isValidAt(validTo, asOf) {
+		if mergedIntoID != nil {
+			return nil, *mergedIntoID, storage.ErrDeletedEntity
+		}
+		return nil, "", storage.ErrDeletedEntity
+	}
+	return create(), "", nil
 }
 
-func (s *storer) SearchEntity(query string, limit uint) ([]*api.Entity, error) {
-	if err := api.ValidateSearchQuery(query, uint32(limit)); err != nil {
+func (s *storer) SearchEntity(
+	query string,
+	filters *api.SearchFilters,
+	limit uint,
+	asOf time.Time,
+	pageToken string,
+	explain bool,
+) (*storage.SearchPage, error) {
+	if err := api.ValidateSearchQuery(query, filters, uint32(limit)); err != nil {
+		return nil, err
+	}
+	if err := api.ValidateSearchFilters(filters); err != nil {
+		return nil, err
+	}
+	pageKey := storage.SearchPageKey(query, filters)
+	offset, snapshot, err := storage.DecodeSearchPageToken(pageKey, pageToken, asOf)
+	if err != nil {
 		return nil, err
 	}
+	if pageToken != "" {
+		// later pages are ranked as of the first, so writes in between don't shift them
+		asOf = snapshot
+	}
+
+	// each searcher needs enough results for this page plus one more to know if there is a
+	// next page
+	depth := offset + limit + 1
 	errs := make(chan error, len(searchers))
+	timeouts := make(chan string, len(searchers))
 	wg1 := new(sync.WaitGroup)
 	srm := s.newSRM()
+	searcherQueries := getSearcherQueries(query, filters, s.params.Nicknames)
 	for _, s1 := range searchers {
+		if !filters.AllowsType(strings.ToUpper(s1.entityType().String())) {
+			continue
+		}
+		if _, in := searcherQueries[s1.name()]; !in {
+			// e.g., the birthdate searcher for a query without a date
+			continue
+		}
 		wg1.Add(1)
 		go func(s2 searcher, wg2 *sync.WaitGroup) {
 			defer wg2.Done()
@@ -143,16 +213,35 @@ func (s *storer) SearchEntity(query string, limit uint) ([]*api.Entity, error) {
 			q := psql.RunWith(s.dbCache).
 				Select(selectCols...).
 				From(fullTableName(s2.entityType())).
-				Where(s2.predicate(), s2.preprocQuery(query)).
-				OrderBy(similarityCol + " DESC").
-				Limit(uint64(limit))
+				Where(s2.predicate(), searcherQueries[s2.name()]).
+				Where(validAt(asOf)).
+				OrderBy(similarityCol+" DESC", entityIDCol).
+				Limit(uint64(depth))
+			filtersPred, filtersArgs := filtersPredicate(s2.entityType(), filters,
+				firstFiltersArg(asOf))
+			if filtersPred != "" {
+				q = q.Where(filtersPred, filtersArgs...)
+			}
+			boostPred, boostArgs := boostPredicate(s2, api.PatientNameQuery(query, filters),
+				firstFiltersArg(asOf)+len(filtersArgs))
+			if boostPred != "" {
+				q = q.Where(boostPred, boostArgs...)
+			}
 			s.logger.Debug("searching for entity", logSearchSelect(q, s2, query)...)
 			ctx, cancel := context.WithTimeout(context.Background(),
 				s.params.SearchQueryTimeout)
 			defer cancel()
 			rows, err := s.qr.SelectQueryContext(ctx, q)
 			n, err := s.processSearchQuery(srm, rows, err, s2)
-			if err != nil {
+			if err != nil && ctx.Err() == context.DeadlineExceeded {
+				// the deadline may pass while running the query or reading its rows, and
+				// the driver may return its own cancellation error instead
+				err = context.DeadlineExceeded
+			}
+			if err == context.DeadlineExceeded {
+				s.logger.Warn("searcher timed out", logSearcherFinished(s2, query, n)...)
+				timeouts <- s2.name()
+			} else if err != nil {
 				errs <- err
 			}
 			s.logger.Debug("searcher finished", logSearcherFinished(s2, query, n)...)
@@ -165,26 +254,561 @@ func (s *storer) SearchEntity(query string, limit uint) ([]*api.Entity, error) {
 		return nil, err
 	default:
 	}
+	close(timeouts)
+	var timedOutSearchers []string
+	for searcherName := range timeouts {
+		timedOutSearchers = append(timedOutSearchers, searcherName)
+	}
+	sort.Strings(timedOutSearchers)
 
-	// return just the entities, without their granular or norm'd similarity scores
-	es := make([]*api.Entity, 0, limit)
-	ess := srm.top(limit)
-	s.logger.Debug("ranked search results", logSearchRanked(query, limit, ess)...)
+	ranked := make([]*api.SearchResult, 0, depth)
+	ess := srm.top(depth)
+	s.logger.Debug("ranked search results", logSearchRanked(query, depth, ess)...)
 	for _, eSim := range ess {
-		es = append(es, eSim.E)
+		var explanation *api.SearchExplanation
+		if explain {
+			// explain before adding zero similarities so only the matches are included
+			explanation = eSim.Explain(searcherQueries)
+		}
+		addZeroSimilarities(eSim, searcherQueries)
+		r := eSim.SearchResult()
+		r.Explanation = explanation
+		ranked = append(ranked, r)
+	}
+	results, nextPageToken := storage.SplitSearchPage(ranked, pageKey, snapshot, offset, limit)
+	return &storage.SearchPage{
+		Results:           results,
+		NextPageToken:     nextPageToken,
+		TimedOutSearchers: timedOutSearchers,
+	}, nil
+}
+
+func (s *storer) DeleteEntity(entityID string) error {
+	if err := s.idGen.Check(entityID); err != nil {
+		return err
+	}
+	et := storage.GetEntityTypeFromID(entityID)
+	q := psql.RunWith(s.dbCache).
+		Update(fullTableName(et)).
+		Set(transactionPeriodCol, sq.Expr(closedTransactionPeriod)).
+		Where(sq.Eq{entityIDCol: entityID}).
+		Where(currentRow)
+	s.logger.Debug("deleting entity", logDeleteUpdate(q, et, entityID)...)
+	ctx, cancel := context.WithTimeout(context.Background(), s.params.DeleteQueryTimeout)
+	result, err := s.qr.UpdateExecContext(ctx, q)
+	cancel()
+	if err != nil {
+		return err
+	}
+	nDeleted, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if nDeleted == 0 {
+		return s.noCurrentRowErr(entityID, 0)
+	}
+	s.logger.Debug("successfully deleted entity", zap.String(logEntityID, entityID))
+	return nil
+}
+
+func (s *storer) GetEntityHistory(entityID string) ([]*api.EntityVersion, error) {
+	if err := s.idGen.Check(entityID); err != nil {
+		return nil, err
+	}
+	et := storage.GetEntityTypeFromID(entityID)
+	cols, _, _ := prepEntityScan(et, 0)
+	q := psql.RunWith(s.dbCache).
+		Select(append(cols, validFromCol, validToCol, mergedIntoIDCol, revertedToVersionCol,
+			revertedByCol, revertReasonCol)...).
+		From(fullTableName(et)).
+		Where(sq.Eq{entityIDCol: entityID}).
+		OrderBy(rowIDCol)
+	s.logger.Debug("getting entity history", logGetSelect(q, et, entityID)...)
+	ctx, cancel := context.WithTimeout(context.Background(), s.params.GetQueryTimeout)
+	defer cancel()
+	rows, err := s.qr.SelectQueryContext(ctx, q)
+	if err != nil {
+		return nil, err
+	}
+	history := make([]*api.EntityVersion, 0)
+	for rows.Next() {
+
+		// prepare the destination slice for the entity with extra slots for the bounds of
+		// its transaction period, the survivor it was merged into, if any, and the revert
+		// that stored it, if any
+		_, dest, create := prepEntityScan(et, 6)
+		var validFrom time.Time
+		var validTo *time.Time
+		var mergedIntoID, revertedBy, revertReason *string
+		var revertedToVersion *uint64
+		dest = append(dest, &validFrom, &validTo, &mergedIntoID, &revertedToVersion,
+			&revertedBy, &revertReason)
+		if err := rows.Scan(dest...); err != nil {
+			return nil, err
+		}
+		revert := newRevert(revertedToVersion, revertedBy, revertReason)
+		v, err := newEntityVersion(create(), validFrom, validTo, mergedIntoID, revert)
+		if err != nil {
+			return nil, err
+		}
+		history = append(history, v)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if len(history) == 0 {
+		return nil, storage.ErrMissingEntity
+	}
+	s.logger.Debug("successfully found entity history", logHistoryResult(entityID, history)...)
+	return history, nil
+}
+
+func (s *storer) PutEntities(
+	es []*api.Entity, allowCreate bool,
+) ([]*storage.PutResult, error) {
+	if err := api.ValidateEntityBatch(es); err != nil {
+		return nil, err
+	}
+	results := make([]*storage.PutResult, len(es))
+	inserts := make(map[storage.EntityType][]*api.Entity)
+	updates := make(map[storage.EntityType][]*api.Entity)
+	for i, e := range es {
+		insert, err := s.prepPut(e)
+		results[i] = &storage.PutResult{Err: err}
+		if err != nil {
+			continue
+		}
+		et := storage.GetEntityType(e)
+		if insert {
+			e.Version = 1
+			inserts[et] = append(inserts[et], e)
+		} else {
+			updates[et] = append(updates[et], e)
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), s.params.PutQueryTimeout)
+	defer cancel()
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	updateErrs := make(map[*api.Entity]error)
+	for et, ues := range updates {
+		closed, err := s.closeCurrentRows(ctx, tx, et, ues)
+		if err != nil {
+			return nil, s.rollback(tx, err)
+		}
+		for _, e := range ues {
+			if closedVersion, in := closed[e.EntityId]; in {
+				e.Version = closedVersion + 1
+				inserts[et] = append(inserts[et], e)
+				continue
+			}
+			err := s.noCurrentRowErr(e.EntityId, e.Version)
+			if err == storage.ErrMissingEntity && allowCreate {
+				e.Version = 1
+				inserts[et] = append(inserts[et], e)
+			} else {
+				updateErrs[e] = err
+			}
+		}
+	}
+	for et, ies := range inserts {
+		if err := s.insertEntities(ctx, tx, et, ies); err != nil {
+			err = s.rollback(tx, err)
+			if pqErr, ok := err.(*pq.Error); ok {
+				if pqErr.Code == pqUniqueViolationErrCode {
+					return nil, storage.ErrDupGenEntityID
+				}
+			}
+			return nil, err
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	for i, e := range es {
+		if results[i].Err != nil {
+			continue
+		}
+		if results[i].Err = updateErrs[e]; results[i].Err == nil {
+			results[i].EntityID = e.EntityId
+			results[i].Version = e.Version
+		}
+	}
+	s.logger.Debug("successfully stored entities", logPutsResult(results)...)
+	return results, nil
+}
+
+func (s *storer) GetEntities(entityIDs []string) ([]*storage.GetResult, error) {
+	if err := api.ValidateEntityIDBatch(entityIDs); err != nil {
+		return nil, err
+	}
+	results := make([]*storage.GetResult, len(entityIDs))
+	typeEntityIDs := make(map[storage.EntityType][]string)
+	for i, entityID := range entityIDs {
+		if err := s.idGen.Check(entityID); err != nil {
+			results[i] = &storage.GetResult{Err: err}
+			continue
+		}
+		et := storage.GetEntityTypeFromID(entityID)
+		typeEntityIDs[et] = append(typeEntityIDs[et], entityID)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), s.params.GetQueryTimeout)
+	defer cancel()
+	tx, err := s.db.BeginTx(ctx, &sql.TxOptions{ReadOnly: true})
+	if err != nil {
+		return nil, err
+	}
+	found := make(map[string]*storage.GetResult)
+	mergedInto := make(map[string]string)
+	for len(typeEntityIDs) > 0 {
+		for et, etEntityIDs := range typeEntityIDs {
+			err := s.selectLatestRows(ctx, tx, et, etEntityIDs, found, mergedInto)
+			if err != nil {
+				return nil, s.rollback(tx, err)
+			}
+		}
+
+		// get the survivors of merged entities in the same transaction, which may themselves
+		// have been merged
+		typeEntityIDs = make(map[storage.EntityType][]string)
+		for _, survivorID := range mergedInto {
+			if _, in := found[survivorID]; !in {
+				found[survivorID] = &storage.GetResult{Err: storage.ErrMissingEntity}
+				et := storage.GetEntityTypeFromID(survivorID)
+				typeEntityIDs[et] = append(typeEntityIDs[et], survivorID)
+			}
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	for i, entityID := range entityIDs {
+		if results[i] == nil {
+			results[i] = getRedirectedResult(entityID, found, mergedInto)
+		}
+	}
+	s.logger.Debug("successfully got entities", logGetsResult(results)...)
+	return results, nil
+}
+
+func (s *storer) ListEntities(
+	et storage.EntityType, pageToken string, pageSize uint,
+) ([]*api.Entity, string, error) {
+	afterEntityID, err := storage.DecodePageToken(et, pageToken)
+	if err != nil {
+		return nil, "", err
+	}
+	if pageSize, err = storage.GetPageSize(pageSize); err != nil {
+		return nil, "", err
+	}
+
+	// select one more than the page size to know whether there's a next page
+	cols, _, _ := prepEntityScan(et, 0)
+	q := psql.RunWith(s.dbCache).
+		Select(cols...).
+		From(fullTableName(et)).
+		Where(currentRow).
+		Where(entityIDCol+" > ?", afterEntityID).
+		OrderBy(entityIDCol).
+		Limit(uint64(pageSize + 1))
+	s.logger.Debug("listing entities", logListSelect(q, et, pageToken)...)
+	ctx, cancel := context.WithTimeout(context.Background(), s.params.ListQueryTimeout)
+	defer cancel()
+	rows, err := s.qr.SelectQueryContext(ctx, q)
+	if err != nil {
+		return nil, "", err
+	}
+	es := make([]*api.Entity, 0, pageSize+1)
+	for rows.Next() {
+		_, dest, create := prepEntityScan(et, 0)
+		if err := rows.Scan(dest...); err != nil {
+			return nil, "", err
+		}
+		es = append(es, create())
+	}
+	if err := rows.Err(); err != nil {
+		return nil, "", err
+	}
+	if err := rows.Close(); err != nil {
+		return nil, "", err
+	}
+	page, nextPageToken := storage.SplitPage(es, pageSize)
+	s.logger.Debug("listed entities", logListResult(et, pageToken, page)...)
+	return page, nextPageToken, nil
+}
+
+// prepPut checks the entity is valid for putting, adding a new entity ID if it is missing, and
+// returns whether the entity should be inserted (vs. updated)
+func (s *storer) prepPut(e *api.Entity) (bool, error) {
+	if e == nil {
+		return false, api.ErrPutMissingEntity
+	}
+	if e.EntityId != "" {
+		if err := s.idGen.Check(e.EntityId); err != nil {
+			return false, err
+		}
+	}
+	if err := api.ValidateEntity(e); err != nil {
+		return false, err
+	}
+	return storage.MaybeAddEntityID(e, s.idGen)
+}
+
+// closeCurrentRows closes the transaction periods of the current rows of the given entities,
+// all of the given type, returning the versions of the closed rows keyed by entity ID. The row of
+// an entity with a non-zero version is only closed if it has that version.
+func (s *storer) closeCurrentRows(
+	ctx context.Context, tx *sql.Tx, et storage.EntityType, es []*api.Entity,
+) (map[string]uint64, error) {
+	unversionedIDs := make([]string, 0, len(es))
+	matches := sq.Or{}
+	for _, e := range es {
+		if e.Version == 0 {
+			unversionedIDs = append(unversionedIDs, e.EntityId)
+		} else {
+			matches = append(matches, sq.Eq{entityIDCol: e.EntityId, versionCol: e.Version})
+		}
+	}
+	if len(unversionedIDs) > 0 {
+		matches = append(matches, sq.Eq{entityIDCol: unversionedIDs})
+	}
+	q := psql.RunWith(tx).
+		Update(fullTableName(et)).
+		Set(transactionPeriodCol, sq.Expr(closedTransactionPeriod)).
+		Where(matches).
+		Where(currentRow).
+		Suffix("RETURNING " + entityIDCol + ", " + versionCol)
+	s.logger.Debug("closing current entity versions", logPutsUpdate(q, et, len(es))...)
+	rows, err := s.qr.UpdateQueryContext(ctx, q)
+	if err != nil {
+		return nil, err
 	}
-	return es, nil
+	closed := make(map[string]uint64)
+	for rows.Next() {
+		var entityID string
+		var version uint64
+		if err := rows.Scan(&entityID, &version); err != nil {
+			return nil, err
+		}
+		closed[entityID] = version
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	return closed, nil
+}
+
+// insertEntities inserts new current rows for the given entities, all of the given type, in a
+// single multi-row INSERT
+func (s *storer) insertEntities(
+	ctx context.Context, tx *sql.Tx, et storage.EntityType, es []*api.Entity,
+) error {
+	cols := getPutStmtCols(es[0])
+	q := psql.RunWith(tx).
+		Insert(fullTableName(et)).
+		Columns(cols...)
+	for _, e := range es {
+		vals := getPutStmtValues(e)
+		row := make([]interface{}, len(cols))
+		for i, col := range cols {
+			row[i] = vals[col]
+		}
+		q = q.Values(row...)
+	}
+	s.logger.Debug("inserting entities", logPutsInsert(q, et, len(es))...)
+	_, err := s.qr.InsertExecContext(ctx, q)
+	return err
+}
+
+// selectLatestRows selects the latest rows of the given entities, all of the given type, adding
+// a result for each one found to the found map and the survivor of each merged one to mergedInto
+func (s *storer) selectLatestRows(
+	ctx context.Context,
+	tx *sql.Tx,
+	et storage.EntityType,
+	entityIDs []string,
+	found map[string]*storage.GetResult,
+	mergedInto map[string]string,
+) error {
+	cols, _, _ := prepEntityScan(et, 0)
+	q := psql.RunWith(tx).
+		Select(append(cols, validToCol, mergedIntoIDCol)...).
+		Options("DISTINCT ON ("+entityIDCol+")").
+		From(fullTableName(et)).
+		Where(sq.Eq{entityIDCol: entityIDs}).
+		OrderBy(entityIDCol, rowIDCol+" DESC")
+	s.logger.Debug("getting entities", logGetsSelect(q, et, len(entityIDs))...)
+	rows, err := s.qr.SelectQueryContext(ctx, q)
+	if err != nil {
+		return err
+	}
+	for rows.Next() {
+
+		// prepare the destination slice for the entity with extra slots for the end of its
+		// transaction period, which determines whether it has been deleted, and the
+		// survivor it was merged into, if any
+		_, dest, create := prepEntityScan(et, 2)
+		var validTo *time.Time
+		var mergedIntoID *string
+		if err := rows.Scan(append(dest, &validTo, &mergedIntoID)...); err != nil {
+			return err
+		}
+		e := create()
+		if validTo != nil {
+			found[e.EntityId] = &storage.GetResult{Err: storage.ErrDeletedEntity}
+			if mergedIntoID != nil {
+				mergedInto[e.EntityId] = *mergedIntoID
+			}
+		} else {
+			found[e.EntityId] = &storage.GetResult{Entity: e}
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	return rows.Close()
+}
+
+// getRedirectedResult returns the found result for the entity ID, or for its survivor if it has
+// been merged into another.
+func getRedirectedResult(
+	entityID string, found map[string]*storage.GetResult, mergedInto map[string]string,
+) *storage.GetResult {
+	redirected := make(map[string]struct{})
+	for {
+		survivorID, merged := mergedInto[entityID]
+		if !merged {
+			break
+		}
+		if _, in := redirected[entityID]; in {
+			return &storage.GetResult{Err: storage.ErrMergeCycle}
+		}
+		redirected[entityID] = struct{}{}
+		entityID = survivorID
+	}
+	if result, in := found[entityID]; in {
+		return result
+	}
+	return &storage.GetResult{Err: storage.ErrMissingEntity}
+}
+
+// updateEntity closes the transaction period of the entity's current row and inserts a new
+// current row with the entity's values and the next version, both within a single DB transaction.
+// If allowCreate is true and the entity is missing, it just inserts the entity's first row.
+func (s *storer) updateEntity(
+	ctx context.Context, e *api.Entity, fqTbl string, allowCreate bool,
+) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	q1 := psql.RunWith(tx).
+		Update(fqTbl).
+		Set(transactionPeriodCol, sq.Expr(closedTransactionPeriod)).
+		Where(sq.Eq{entityIDCol: e.EntityId}).
+		Where(currentRow).
+		Where(versionMatches(e.Version)).
+		Suffix("RETURNING " + versionCol)
+	s.logger.Debug("closing current entity version", logPutUpdate(q1, e)...)
+	rows, err := s.qr.UpdateQueryContext(ctx, q1)
+	if err != nil {
+		return s.rollback(tx, err)
+	}
+	var closedVersion uint64
+	closed, err := scanOne(rows, []interface{}{&closedVersion})
+	if err != nil {
+		return s.rollback(tx, err)
+	}
+	if !closed {
+		err = s.noCurrentRowErr(e.EntityId, e.Version)
+		if err != storage.ErrMissingEntity || !allowCreate {
+			return s.rollback(tx, err)
+		}
+	}
+
+	// closedVersion is still zero when creating the entity, so its first version is 1
+	e.Version = closedVersion + 1
+	q2 := psql.RunWith(tx).
+		Insert(fqTbl).
+		SetMap(getPutStmtValues(e))
+	s.logger.Debug("inserting new entity version", logPutInsert(q2, e)...)
+	if _, err = s.qr.InsertExecContext(ctx, q2); err != nil {
+		return s.rollback(tx, err)
+	}
+	return tx.Commit()
+}
+
+// rollback rolls back the transaction and returns the error that caused it
+func (s *storer) rollback(tx *sql.Tx, err error) error {
+	if err2 := tx.Rollback(); err2 != nil {
+		s.logger.Error("failed to roll back transaction", zap.Error(err2))
+	}
+	return err
+}
+
+// noCurrentRowErr returns the error for an entity without a current row (with the given version,
+// if non-zero), which means it either doesn't exist, has been deleted, or has a different version
+func (s *storer) noCurrentRowErr(entityID string, version uint64) error {
+	if _, _, err := s.getEntity(entityID, time.Time{}); err != nil {
+		return err
+	}
+	if version != 0 {
+		return storage.ErrVersionConflict
+	}
+
+	// entity has a current row again, so it must have been concurrently updated
+	return errConcurrentUpdate
+}
+
+// versionMatches returns the predicate for rows with the given version, which matches all rows
+// if the version is zero
+func versionMatches(version uint64) sq.Sqlizer {
+	if version == 0 {
+		return sq.And{}
+	}
+	return sq.Eq{versionCol: version}
+}
+
+// scanOne scans the first of the rows, if there is one, into the given destinations and returns
+// whether there was one
+func scanOne(rows queryRows, dests []interface{}) (bool, error) {
+	found := false
+	if rows.Next() {
+		if err := rows.Scan(dests...); err != nil {
+			return false, err
+		}
+		found = true
+	}
+	if err := rows.Err(); err != nil {
+		return false, err
+	}
+	if err := rows.Close(); err != nil {
+		return false, err
+	}
+	return found, nil
 }
 
 func (s *storer) processSearchQuery(
 	srm searchResultMerger, rows queryRows, err error, sch searcher,
 ) (int, error) {
-	if err != nil {
-		if err != context.DeadlineExceeded && err != sql.ErrNoRows {
-			return 0, err
-		}
+	if err == sql.ErrNoRows {
 		return 0, nil
 	}
+	if err != nil {
+		return 0, err
+	}
 	n, err := srm.merge(rows, sch.name(), sch.entityType())
 	if err != nil {
 		return 0, err
@@ -198,6 +822,342 @@ func (s *storer) processSearchQuery(
 	return n, nil
 }
 
+func (s *storer) PatchEntity(patch *api.Entity, paths []string) (*api.Entity, error) {
+	if patch == nil {
+		return nil, api.ErrPatchMissingEntity
+	}
+	if err := s.idGen.Check(patch.EntityId); err != nil {
+		return nil, err
+	}
+	if err := api.ValidatePatchPaths(patch, paths); err != nil {
+		return nil, err
+	}
+	et := storage.GetEntityTypeFromID(patch.EntityId)
+	fqTbl := fullTableName(et)
+	ctx, cancel := context.WithTimeout(context.Background(), s.params.PutQueryTimeout)
+	defer cancel()
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	// close the current row and patch the values it returns, so the fields not being patched
+	// keep their values from the row closed within this transaction
+	cols, dests, create := prepEntityScan(et, 0)
+	q1 := psql.RunWith(tx).
+		Update(fqTbl).
+		Set(transactionPeriodCol, sq.Expr(closedTransactionPeriod)).
+		Where(sq.Eq{entityIDCol: patch.EntityId}).
+		Where(currentRow).
+		Where(versionMatches(patch.Version)).
+		Suffix("RETURNING " + strings.Join(cols, ", "))
+	s.logger.Debug("closing current entity version", logPutUpdate(q1, patch)...)
+	rows, err := s.qr.UpdateQueryContext(ctx, q1)
+	if err != nil {
+		return nil, s.rollback(tx, err)
+	}
+	closed, err := scanOne(rows, dests)
+	if err != nil {
+		return nil, s.rollback(tx, err)
+	}
+	if !closed {
+		return nil, s.rollback(tx, s.noCurrentRowErr(patch.EntityId, patch.Version))
+	}
+	patched, err := api.PatchEntity(create(), patch, paths)
+	if err != nil {
+		return nil, s.rollback(tx, err)
+	}
+	patched.Version++
+	if err = api.ValidateEntity(patched); err != nil {
+		return nil, s.rollback(tx, err)
+	}
+	q2 := psql.RunWith(tx).
+		Insert(fqTbl).
+		SetMap(getPutStmtValues(patched))
+	s.logger.Debug("inserting patched entity version", logPutInsert(q2, patched)...)
+	if _, err = s.qr.InsertExecContext(ctx, q2); err != nil {
+		return nil, s.rollback(tx, err)
+	}
+	if err = tx.Commit(); err != nil {
+		return nil, err
+	}
+	s.logger.Debug("successfully patched entity", logPatchResult(patched.EntityId, paths)...)
+	return patched, nil
+}
+
+func (s *storer) MergeEntities(survivorID, mergedID string) (*api.Entity, error) {
+	if err := s.idGen.Check(survivorID); err != nil {
+		return nil, err
+	}
+	if err := s.idGen.Check(mergedID); err != nil {
+		return nil, err
+	}
+	if survivorID == mergedID {
+		return nil, storage.ErrSelfMerge
+	}
+	et := storage.GetEntityTypeFromID(survivorID)
+	if et != storage.GetEntityTypeFromID(mergedID) {
+		return nil, storage.ErrMergeTypeMismatch
+	}
+	fqTbl := fullTableName(et)
+	ctx, cancel := context.WithTimeout(context.Background(), s.params.PutQueryTimeout)
+	defer cancel()
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	// lock the survivor's current row so it can't be updated or deleted during the merge
+	cols, dests, create := prepEntityScan(et, 0)
+	q1 := psql.RunWith(tx).
+		Select(cols...).
+		From(fqTbl).
+		Where(sq.Eq{entityIDCol: survivorID}).
+		Where(currentRow).
+		Suffix("FOR SHARE")
+	s.logger.Debug("getting survivor entity", logGetSelect(q1, et, survivorID)...)
+	rows, err := s.qr.SelectQueryContext(ctx, q1)
+	if err != nil {
+		return nil, s.rollback(tx, err)
+	}
+	found, err := scanOne(rows, dests)
+	if err != nil {
+		return nil, s.rollback(tx, err)
+	}
+	if !found {
+		return nil, s.rollback(tx, s.noCurrentRowErr(survivorID, 0))
+	}
+
+	q2 := psql.RunWith(tx).
+		Update(fqTbl).
+		Set(transactionPeriodCol, sq.Expr(closedTransactionPeriod)).
+		Set(mergedIntoIDCol, survivorID).
+		Where(sq.Eq{entityIDCol: mergedID}).
+		Where(currentRow).
+		Suffix("RETURNING " + entityIDCol)
+	s.logger.Debug("closing merged entity version", logDeleteUpdate(q2, et, mergedID)...)
+	rows, err = s.qr.UpdateQueryContext(ctx, q2)
+	if err != nil {
+		return nil, s.rollback(tx, err)
+	}
+	var closedID string
+	closed, err := scanOne(rows, []interface{}{&closedID})
+	if err != nil {
+		return nil, s.rollback(tx, err)
+	}
+	if !closed {
+		return nil, s.rollback(tx, s.noCurrentRowErr(mergedID, 0))
+	}
+	if err = tx.Commit(); err != nil {
+		return nil, err
+	}
+	s.logger.Debug("successfully merged entities", logMergeResult(survivorID, mergedID)...)
+	return create(), nil
+}
+
+func (s *storer) RevertEntity(entityID string, revert *api.Revert) (*api.Entity, error) {
+	if err := s.idGen.Check(entityID); err != nil {
+		return nil, err
+	}
+	et := storage.GetEntityTypeFromID(entityID)
+	fqTbl := fullTableName(et)
+	ctx, cancel := context.WithTimeout(context.Background(), s.params.PutQueryTimeout)
+	defer cancel()
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	q1 := psql.RunWith(tx).
+		Update(fqTbl).
+		Set(transactionPeriodCol, sq.Expr(closedTransactionPeriod)).
+		Where(sq.Eq{entityIDCol: entityID}).
+		Where(currentRow).
+		Suffix("RETURNING " + versionCol)
+	s.logger.Debug("closing current entity version", logDeleteUpdate(q1, et, entityID)...)
+	rows, err := s.qr.UpdateQueryContext(ctx, q1)
+	if err != nil {
+		return nil, s.rollback(tx, err)
+	}
+	var latestVersion uint64
+	closed, err := scanOne(rows, []interface{}{&latestVersion})
+	if err != nil {
+		return nil, s.rollback(tx, err)
+	}
+	merged := false
+	if !closed {
+		// a merged entity has no current row, and reverting it undoes the merge while leaving
+		// the row closed by the merge in its history
+		merged, latestVersion, err = s.selectMergedVersion(ctx, tx, et, entityID)
+		if err != nil {
+			return nil, s.rollback(tx, err)
+		}
+		if !merged {
+			return nil, s.rollback(tx, s.noCurrentRowErr(entityID, 0))
+		}
+	}
+	if revert.ToVersion > latestVersion || revert.ToVersion == latestVersion && !merged {
+		return nil, s.rollback(tx, storage.ErrInvalidRevertVersion)
+	}
+
+	cols, dests, create := prepEntityScan(et, 0)
+	q2 := psql.RunWith(tx).
+		Select(cols...).
+		From(fqTbl).
+		Where(sq.Eq{entityIDCol: entityID, versionCol: revert.ToVersion})
+	s.logger.Debug("getting entity version to revert to", logGetSelect(q2, et, entityID)...)
+	rows, err = s.qr.SelectQueryContext(ctx, q2)
+	if err != nil {
+		return nil, s.rollback(tx, err)
+	}
+	found, err := scanOne(rows, dests)
+	if err != nil {
+		return nil, s.rollback(tx, err)
+	}
+	if !found {
+		return nil, s.rollback(tx, storage.ErrInvalidRevertVersion)
+	}
+	reverted := create()
+	reverted.Version = latestVersion + 1
+	vals := getPutStmtValues(reverted)
+	vals[revertedToVersionCol] = revert.ToVersion
+	vals[revertedByCol] = revert.RevertedBy
+	vals[revertReasonCol] = revert.Reason
+	q3 := psql.RunWith(tx).
+		Insert(fqTbl).
+		SetMap(vals)
+	s.logger.Debug("inserting reverted entity version", logPutInsert(q3, reverted)...)
+	if _, err = s.qr.InsertExecContext(ctx, q3); err != nil {
+		return nil, s.rollback(tx, err)
+	}
+	if err = tx.Commit(); err != nil {
+		return nil, err
+	}
+	s.logger.Debug("successfully reverted entity", logRevertResult(entityID, revert)...)
+	return reverted, nil
+}
+
+// selectMergedVersion returns whether the latest row of the entity was closed by merging it into
+// a survivor, and if so, its version
+func (s *storer) selectMergedVersion(
+	ctx context.Context, tx *sql.Tx, et storage.EntityType, entityID string,
+) (bool, uint64, error) {
+	q := psql.RunWith(tx).
+		Select(versionCol, mergedIntoIDCol).
+		From(fullTableName(et)).
+		Where(sq.Eq{entityIDCol: entityID}).
+		OrderBy(rowIDCol + " DESC").
+		Limit(1)
+	s.logger.Debug("getting latest entity version", logGetSelect(q, et, entityID)...)
+	rows, err := s.qr.SelectQueryContext(ctx, q)
+	if err != nil {
+		return false, 0, err
+	}
+	var version uint64
+	var mergedIntoID sql.NullString
+	if _, err := scanOne(rows, []interface{}{&version, &mergedIntoID}); err != nil {
+		return false, 0, err
+	}
+	return mergedIntoID.Valid, version, nil
+}
+
+func (s *storer) FindDuplicates(threshold float32, limit uint) ([]*api.DuplicatePair, error) {
+	q := getDuplicatesSelect(threshold, limit).RunWith(s.dbCache)
+	s.logger.Debug("finding duplicates", logDuplicatesSelect(q, threshold, limit)...)
+	ctx, cancel := context.WithTimeout(context.Background(), s.params.DedupeQueryTimeout)
+	defer cancel()
+	rows, err := s.qr.SelectQueryContext(ctx, q)
+	if err != nil {
+		return nil, err
+	}
+	pairs := make([]*api.DuplicatePair, 0)
+	for rows.Next() {
+		_, dest1, create1 := prepPatientScan(0)
+		_, dest2, create2 := prepPatientScan(2)
+		var nameSim float32
+		var birthdateMatch bool
+		dest := append(dest1, append(dest2, &nameSim, &birthdateMatch)...)
+		if err := rows.Scan(dest...); err != nil {
+			return nil, err
+		}
+		pair := storage.NewDuplicatePair(create1(), create2(), nameSim, birthdateMatch)
+		pairs = append(pairs, pair)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	s.logger.Debug("found duplicates", logDuplicatesResult(threshold, limit, pairs)...)
+	return pairs, nil
+}
+
+func (s *storer) MatchPatient(p *api.Patient, limit uint) ([]*api.PatientMatch, error) {
+	q := getMatchCandidatesSelect(p).RunWith(s.dbCache)
+	s.logger.Debug("selecting match candidates", logMatchSelect(q, limit)...)
+	ctx, cancel := context.WithTimeout(context.Background(), s.params.MatchQueryTimeout)
+	defer cancel()
+	rows, err := s.qr.SelectQueryContext(ctx, q)
+	if err != nil {
+		return nil, err
+	}
+	candidates := make([]*api.Entity, 0)
+	for rows.Next() {
+		_, dest, create := prepPatientScan(0)
+		if err := rows.Scan(dest...); err != nil {
+			return nil, err
+		}
+		candidates = append(candidates, create())
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	ms := storage.RankPatientMatches(p, candidates, limit, s.params)
+	s.logger.Debug("matched patient", logMatchResult(limit, len(candidates), ms)...)
+	return ms, nil
+}
+
+func (s *storer) Autocomplete(
+	prefix string, entityTypes []string, limit uint,
+) ([]*api.Suggestion, error) {
+	ets, err := storage.AutocompleteEntityTypes(entityTypes)
+	if err != nil {
+		return nil, err
+	}
+	foldedPrefix := api.FoldName(prefix)
+	ctx, cancel := context.WithTimeout(context.Background(), s.params.AutocompleteQueryTimeout)
+	defer cancel()
+	matches := make([]*api.Entity, 0)
+	for _, et := range ets {
+		q := getAutocompleteSelect(et, foldedPrefix, limit).RunWith(s.dbCache)
+		s.logger.Debug("selecting autocomplete suggestions", logAutocompleteSelect(q, et)...)
+		rows, err := s.qr.SelectQueryContext(ctx, q)
+		if err != nil {
+			return nil, err
+		}
+		for rows.Next() {
+			_, dest, create := prepEntityScan(et, 0)
+			if err := rows.Scan(dest...); err != nil {
+				return nil, err
+			}
+			matches = append(matches, create())
+		}
+		if err := rows.Err(); err != nil {
+			return nil, err
+		}
+		if err := rows.Close(); err != nil {
+			return nil, err
+		}
+	}
+	suggestions := storage.TopSuggestions(matches, limit)
+	s.logger.Debug("autocompleted", logAutocompleteResult(prefix, limit, suggestions)...)
+	return suggestions, nil
+}
+
 func (s *storer) Close() error {
 	return s.db.Close()
 }
@@ -214,6 +1174,7 @@ type querier interface {
 	SelectQueryRowContext(ctx context.Context, b sq.SelectBuilder) sq.RowScanner
 	InsertExecContext(ctx context.Context, b sq.InsertBuilder) (sql.Result, error)
 	UpdateExecContext(ctx context.Context, b sq.UpdateBuilder) (sql.Result, error)
+	UpdateQueryContext(ctx context.Context, b sq.UpdateBuilder) (queryRows, error)
 }
 
 type querierImpl struct {
@@ -242,3 +1203,9 @@ func (q *querierImpl) UpdateExecContext(
 ) (sql.Result, error) {
 	return b.ExecContext(ctx)
 }
+
+func (q *querierImpl) UpdateQueryContext(
+	ctx context.Context, b sq.UpdateBuilder,
+) (queryRows, error) {
+	return b.QueryContext(ctx)
+}
@@ -7,8 +7,11 @@ import (
 	"log"
 	"math/rand"
 	"os"
+	"sort"
 	"strings"
+	"sync"
 	"testing"
+	"time"
 
 	sq "github.com/Masterminds/squirrel"
 	"github.com/drausin/libri/libri/common/logging"
@@ -17,6 +20,7 @@ import (
 	"github.com/elixirhealth/directory/pkg/server/storage/id"
 	"github.com/elixirhealth/directory/pkg/server/storage/postgres/migrations"
 	bstorage "github.com/elixirhealth/service-base/pkg/server/storage"
+	"github.com/golang/protobuf/ptypes"
 	"github.com/mattes/migrate/source/go-bindata"
 	"github.com/stretchr/testify/assert"
 	"go.uber.org/zap"
@@ -135,23 +139,26 @@ func TestStorer_PutGetEntity_ok(t *testing.T) {
 		assert.Equal(t, et, storage.GetEntityType(c.original), et.String())
 		assert.NotEqual(t, c.original, c.updated)
 
-		entityID, err := s.PutEntity(c.original)
+		entityID, err := s.PutEntity(c.original, false)
 		assert.Nil(t, err, et.String())
 		assert.Equal(t, entityID, c.original.EntityId, et.String())
+		assert.Equal(t, uint64(1), c.original.Version, et.String())
 	}
 
 	for et, c := range cases {
 		entityID := c.original.EntityId
-		gottenOriginal, err := s.GetEntity(entityID)
+		gottenOriginal, err := s.GetEntity(entityID, time.Time{})
 		assert.Nil(t, err, et.String())
 		assert.Equal(t, c.original, gottenOriginal)
 
 		c.updated.EntityId = c.original.EntityId
-		entityID, err = s.PutEntity(c.updated)
+		c.updated.Version = c.original.Version
+		entityID, err = s.PutEntity(c.updated, false)
 		assert.Nil(t, err)
 		assert.Equal(t, entityID, c.updated.EntityId)
+		assert.Equal(t, uint64(2), c.updated.Version)
 
-		gottenUpdated, err := s.GetEntity(entityID)
+		gottenUpdated, err := s.GetEntity(entityID, time.Time{})
 		assert.Nil(t, err)
 		assert.Equal(t, c.updated, gottenUpdated)
 	}
@@ -174,14 +181,14 @@ func TestStorer_GetEntity_err(t *testing.T) {
 	assert.NotNil(t, s)
 
 	// bad ID
-	e, err := s.GetEntity("bad ID")
+	e, err := s.GetEntity("bad ID", time.Time{})
 	assert.NotNil(t, err)
 	assert.Nil(t, e)
 
 	// missing ID
 	missingID, err := idGen.Generate(storage.Patient.IDPrefix())
 	assert.Nil(t, err)
-	e, err = s.GetEntity(missingID)
+	e, err = s.GetEntity(missingID, time.Time{})
 	assert.Equal(t, storage.ErrMissingEntity, err)
 	assert.Nil(t, e)
 }
@@ -227,7 +234,7 @@ func TestStorer_PutEntity_err(t *testing.T) {
 	}
 
 	for desc, c := range cases {
-		entityID, err2 := c.s.PutEntity(c.e)
+		entityID, err2 := c.s.PutEntity(c.e, false)
 		assert.NotNil(t, err2, desc)
 		assert.Empty(t, entityID, desc)
 	}
@@ -236,11 +243,93 @@ func TestStorer_PutEntity_err(t *testing.T) {
 	s, err := New(dbURL, &fixedIDGen{generateID: okID}, params, lg)
 	assert.Nil(t, err)
 	okEntity.EntityId = ""
-	_, err = s.PutEntity(okEntity)
+	_, err = s.PutEntity(okEntity, false)
 	assert.Nil(t, err)
 	okEntity.EntityId = ""
-	_, err = s.PutEntity(okEntity)
+	_, err = s.PutEntity(okEntity, false)
 	assert.Equal(t, storage.ErrDupGenEntityID, err)
+
+	// update with stale version
+	updated1, updated2 := api.NewTestPatient(1, false), api.NewTestPatient(2, false)
+	updated1.EntityId, updated2.EntityId = okID, okID
+	updated1.Version, updated2.Version = 1, 1
+	_, err = s.PutEntity(updated1, false)
+	assert.Nil(t, err)
+	_, err = s.PutEntity(updated2, false)
+	assert.Equal(t, storage.ErrVersionConflict, err)
+
+	// update of missing entity
+	missingID, err := okIDGen.Generate(storage.Patient.IDPrefix())
+	assert.Nil(t, err)
+	okEntity.EntityId = missingID
+	_, err = s.PutEntity(okEntity, false)
+	assert.Equal(t, storage.ErrMissingEntity, err)
+
+	// update of deleted entity
+	okEntity.EntityId = okID
+	err = s.DeleteEntity(okID)
+	assert.Nil(t, err)
+	_, err = s.PutEntity(okEntity, false)
+	assert.Equal(t, storage.ErrDeletedEntity, err)
+}
+
+func TestStorer_PutEntity_allowCreate(t *testing.T) {
+	dbURL, tearDown := setUpPostgresTest(t)
+	defer func() {
+		err := tearDown()
+		assert.Nil(t, err)
+	}()
+
+	rng := rand.New(rand.NewSource(0))
+	idGen := id.NewNaiveLuhnGenerator(rng, id.DefaultLength)
+	params := storage.NewDefaultParameters()
+	params.Type = bstorage.Postgres
+	s, err := New(dbURL, idGen, params, zap.NewNop())
+	assert.Nil(t, err)
+	legacyID, err := idGen.Generate(storage.Patient.IDPrefix())
+	assert.Nil(t, err)
+
+	// unknown entity ID without allowCreate
+	e := api.NewTestPatient(1, false)
+	e.EntityId = legacyID
+	_, err = s.PutEntity(e, false)
+	assert.Equal(t, storage.ErrMissingEntity, err)
+
+	// unknown entity ID with allowCreate
+	entityID, err := s.PutEntity(e, true)
+	assert.Nil(t, err)
+	assert.Equal(t, legacyID, entityID)
+	assert.Equal(t, uint64(1), e.Version)
+	gotten, err := s.GetEntity(legacyID, time.Time{})
+	assert.Nil(t, err)
+	assert.Equal(t, e, gotten)
+
+	// existing entity ID with allowCreate updates
+	updated := api.NewTestPatient(2, false)
+	updated.EntityId = legacyID
+	_, err = s.PutEntity(updated, true)
+	assert.Nil(t, err)
+	assert.Equal(t, uint64(2), updated.Version)
+
+	// deleted entity ID with allowCreate
+	err = s.DeleteEntity(legacyID)
+	assert.Nil(t, err)
+	_, err = s.PutEntity(updated, true)
+	assert.Equal(t, storage.ErrDeletedEntity, err)
+
+	// batch with unknown entity ID
+	legacyID2, err := idGen.Generate(storage.Office.IDPrefix())
+	assert.Nil(t, err)
+	e2 := api.NewTestOffice(1, false)
+	e2.EntityId = legacyID2
+	results, err := s.PutEntities([]*api.Entity{e2}, false)
+	assert.Nil(t, err)
+	assert.Equal(t, storage.ErrMissingEntity, results[0].Err)
+	results, err = s.PutEntities([]*api.Entity{e2}, true)
+	assert.Nil(t, err)
+	assert.Nil(t, results[0].Err)
+	assert.Equal(t, legacyID2, results[0].EntityID)
+	assert.Equal(t, uint64(1), results[0].Version)
 }
 
 func TestStorer_SearchEntity_ok(t *testing.T) {
@@ -271,7 +360,7 @@ func TestStorer_SearchEntity_ok(t *testing.T) {
 	}
 	entityIDs := make([]string, len(es))
 	for i, e := range es {
-		entityID, err2 := s.PutEntity(e)
+		entityID, err2 := s.PutEntity(e, false)
 		assert.Nil(t, err2)
 		entityIDs[i] = entityID
 	}
@@ -279,30 +368,408 @@ func TestStorer_SearchEntity_ok(t *testing.T) {
 	limit := uint(3)
 
 	query := "ice name 1" // query unanchored substring with diff case
-	found, err := s.SearchEntity(query, limit)
+	searched, err := s.SearchEntity(query, nil, limit, time.Time{}, "", false)
 	assert.Nil(t, err)
+	found := searched.Results
 	assert.Equal(t, limit, uint(len(found)))
 
 	// check that first result is the office with the name that matches the query
-	f, ok := found[0].TypeAttributes.(*api.Entity_Office)
+	f, ok := found[0].Entity.TypeAttributes.(*api.Entity_Office)
 	assert.True(t, ok)
 	assert.True(t, strings.Contains(strings.ToUpper(f.Office.Name), strings.ToUpper(query)))
 
+	// check that first result has a similarity for each office searcher
+	assert.True(t, found[0].Similarity > 0)
+	assert.True(t, found[0].SearcherSimilarities["OfficeName"] > 0)
+	assert.Zero(t, found[0].SearcherSimilarities["OfficeEntityID"])
+	assert.Equal(t, 2, len(found[0].SearcherSimilarities))
+
 	// check that second and third results are also offices
-	_, ok = found[1].TypeAttributes.(*api.Entity_Office)
+	_, ok = found[1].Entity.TypeAttributes.(*api.Entity_Office)
 	assert.True(t, ok)
-	_, ok = found[2].TypeAttributes.(*api.Entity_Office)
+	_, ok = found[2].Entity.TypeAttributes.(*api.Entity_Office)
 	assert.True(t, ok)
 
 	query = strings.ToLower(entityIDs[1][:4]) // 2nd patient's first 4 chars of entityID diff case
-	found, err = s.SearchEntity(query, limit)
+	searched, err = s.SearchEntity(query, nil, limit, time.Time{}, "", false)
 	assert.Nil(t, err)
+	found = searched.Results
 	assert.Equal(t, 1, len(found))
 
 	// check that first result is the patient with an entityID that matches the query
-	_, ok = found[0].TypeAttributes.(*api.Entity_Patient)
+	_, ok = found[0].Entity.TypeAttributes.(*api.Entity_Patient)
 	assert.True(t, ok)
-	assert.True(t, strings.HasPrefix(found[0].EntityId, strings.ToUpper(query)))
+	assert.True(t, strings.HasPrefix(found[0].Entity.EntityId, strings.ToUpper(query)))
+}
+
+func TestStorer_SearchEntity_pages(t *testing.T) {
+	dbURL, tearDown := setUpPostgresTest(t)
+	defer func() {
+		err := tearDown()
+		assert.Nil(t, err)
+	}()
+
+	rng := rand.New(rand.NewSource(0))
+	lg := logging.NewDevLogger(zapcore.DebugLevel)
+	idGen := id.NewNaiveLuhnGenerator(rng, id.DefaultLength)
+	params := storage.NewDefaultParameters()
+	params.Type = bstorage.Postgres
+	s, err := New(dbURL, idGen, params, lg)
+	assert.Nil(t, err)
+	assert.NotNil(t, s)
+
+	// all offices have the same similarity to the query, so their order depends on entity ID
+	nOffices := 20
+	for i := 0; i < nOffices; i++ {
+		_, err = s.PutEntity(api.NewOffice("", &api.Office{Name: "Same Office Name"}), false)
+		assert.Nil(t, err)
+	}
+	query, limit := "Same Office Name", uint(8)
+	searched, err := s.SearchEntity(query, nil, limit, time.Time{}, "", false)
+	assert.Nil(t, err)
+	first := searched.Results
+
+	found, pageToken := make([]*api.SearchResult, 0), ""
+	for nPages := 1; ; nPages++ {
+		searched, err = s.SearchEntity(query, nil, limit, time.Time{}, pageToken, false)
+		assert.Nil(t, err)
+		found = append(found, searched.Results...)
+		if searched.NextPageToken == "" {
+			assert.Equal(t, 3, nPages)
+			break
+		}
+		pageToken = searched.NextPageToken
+
+		// offices stored after the first page don't shift the later ones
+		_, err = s.PutEntity(api.NewOffice("", &api.Office{Name: "Same Office Name"}), false)
+		assert.Nil(t, err)
+	}
+	assert.Equal(t, nOffices, len(found))
+	assert.Equal(t, first, found[:limit])
+	for i := 1; i < len(found); i++ {
+		assert.True(t, found[i-1].Entity.EntityId < found[i].Entity.EntityId)
+	}
+}
+
+func TestStorer_SearchEntity_timeout(t *testing.T) {
+	rng := rand.New(rand.NewSource(0))
+	idGen := id.NewNaiveLuhnGenerator(rng, id.DefaultLength)
+	params := storage.NewDefaultParameters()
+	params.Type = bstorage.Postgres
+	params.SearchQueryTimeout = 10 * time.Millisecond
+	filters := &api.SearchFilters{EntityTypes: []string{api.OfficeType}}
+
+	cases := map[string]struct {
+		rows queryRows
+		srm  searchResultMerger
+	}{
+		"while merging rows": {
+			rows: &fixedOfficeRows{},
+			srm:  &fixedSearchResultsMerger{mergeDelay: 20 * time.Millisecond, mergeErr: errTest},
+		},
+		"while checking rows": {
+			rows: &fixedOfficeRows{errDelay: 20 * time.Millisecond, errErr: errTest},
+			srm:  &fixedSearchResultsMerger{},
+		},
+	}
+
+	// the driver's error after the deadline passes while reading rows means the searcher timed
+	// out rather than failed
+	for desc, c := range cases {
+		s, err := New("some DB URL", idGen, params, zap.NewNop())
+		assert.Nil(t, err, desc)
+		s.(*storer).qr = &fixedQuerier{selectQueryRows: c.rows}
+		s.(*storer).newSRM = func() searchResultMerger { return c.srm }
+		searched, err := s.SearchEntity("some query", filters, 3, time.Time{}, "", false)
+		assert.Nil(t, err, desc)
+		assert.Equal(t, []string{"OfficeEntityID", "OfficeName"}, searched.TimedOutSearchers,
+			desc)
+	}
+}
+
+func TestStorer_SearchEntity_filters(t *testing.T) {
+	dbURL, tearDown := setUpPostgresTest(t)
+	defer func() {
+		err := tearDown()
+		assert.Nil(t, err)
+	}()
+
+	rng := rand.New(rand.NewSource(0))
+	idGen := id.NewNaiveLuhnGenerator(rng, id.DefaultLength)
+	lg := logging.NewDevLogger(zapcore.DebugLevel)
+	params := storage.NewDefaultParameters()
+	params.Type = bstorage.Postgres
+	s, err := New(dbURL, idGen, params, lg)
+	assert.Nil(t, err)
+	for i := 1; i <= 4; i++ {
+		_, err = s.PutEntity(api.NewTestPatient(i, false), false)
+		assert.Nil(t, err)
+		_, err = s.PutEntity(api.NewTestOffice(i, false), false)
+		assert.Nil(t, err)
+	}
+	patientQuery, officeQuery := "Last Name 1 First Name 1", "Office Name 1"
+
+	cases := map[string]struct {
+		query    string
+		filters  *api.SearchFilters
+		expected []string
+	}{
+		"birthdate": {
+			query:    patientQuery,
+			filters:  &api.SearchFilters{Birthdate: &api.Date{Year: 2006, Month: 1, Day: 3}},
+			expected: []string{"First Name 2 Last Name 2"},
+		},
+		"birthdate range": {
+			query: patientQuery,
+			filters: &api.SearchFilters{
+				BirthdateFrom: &api.Date{Year: 2006, Month: 1, Day: 3},
+				BirthdateTo:   &api.Date{Year: 2006, Month: 1, Day: 4},
+			},
+			expected: []string{"First Name 2 Last Name 2", "First Name 3 Last Name 3"},
+		},
+		"last name prefix": {
+			query:    patientQuery,
+			filters:  &api.SearchFilters{LastNamePrefix: "last name 4"},
+			expected: []string{"First Name 4 Last Name 4"},
+		},
+		"suffix": {
+			query:    patientQuery,
+			filters:  &api.SearchFilters{Suffix: "Jr"},
+			expected: []string{},
+		},
+		"office name": {
+			query:    officeQuery,
+			filters:  &api.SearchFilters{OfficeName: "office name 1"},
+			expected: []string{"Office Name 1"},
+		},
+		"entity types": {
+			query:    officeQuery,
+			filters:  &api.SearchFilters{EntityTypes: []string{api.PatientType}},
+			expected: []string{},
+		},
+		"short query with names filtered on": {
+			query:    "fi",
+			filters:  &api.SearchFilters{LastNamePrefix: "last name 3"},
+			expected: []string{"First Name 3 Last Name 3"},
+		},
+	}
+	for desc, c := range cases {
+		searched, err := s.SearchEntity(c.query, c.filters, api.MaxSearchLimit, time.Time{},
+			"", false)
+		assert.Nil(t, err, desc)
+		names := make([]string, len(searched.Results))
+		for i, r := range searched.Results {
+			names[i] = r.Entity.Name()
+		}
+		sort.Strings(names)
+		assert.Equal(t, c.expected, names, desc)
+	}
+}
+
+func TestStorer_SearchEntity_birthdate(t *testing.T) {
+	dbURL, tearDown := setUpPostgresTest(t)
+	defer func() {
+		err := tearDown()
+		assert.Nil(t, err)
+	}()
+
+	rng := rand.New(rand.NewSource(0))
+	idGen := id.NewNaiveLuhnGenerator(rng, id.DefaultLength)
+	lg := logging.NewDevLogger(zapcore.DebugLevel)
+	params := storage.NewDefaultParameters()
+	params.Type = bstorage.Postgres
+	s, err := New(dbURL, idGen, params, lg)
+	assert.Nil(t, err)
+	patients := []*api.Patient{
+		{LastName: "Garcia", FirstName: "Maria", Birthdate: &api.Date{Year: 1975, Month: 3, Day: 4}},
+		{LastName: "Garcia", FirstName: "Jose", Birthdate: &api.Date{Year: 1975, Month: 4, Day: 3}},
+		{LastName: "Smith", FirstName: "John", Birthdate: &api.Date{Year: 1975, Month: 3, Day: 4}},
+	}
+	for _, p := range patients {
+		_, err = s.PutEntity(api.NewPatient("", p), false)
+		assert.Nil(t, err)
+	}
+
+	// exact birthdate match ranks above transposed match, and the birthdate alone does not
+	// match
+	searched, err := s.SearchEntity("garcia 03/04/1975", nil, api.MaxSearchLimit, time.Time{},
+		"", false)
+	assert.Nil(t, err)
+	assert.Equal(t, 2, len(searched.Results))
+	assert.Equal(t, "Maria Garcia", searched.Results[0].Entity.Name())
+	assert.Equal(t, float32(1), searched.Results[0].SearcherSimilarities["PatientBirthdate"])
+	assert.Equal(t, "Jose Garcia", searched.Results[1].Entity.Name())
+	assert.Equal(t, float32(storage.TransposedBirthdateSimilarity),
+		searched.Results[1].SearcherSimilarities["PatientBirthdate"])
+
+	// date-only query
+	searched, err = s.SearchEntity("1975-03-04", nil, api.MaxSearchLimit, time.Time{}, "",
+		false)
+	assert.Nil(t, err)
+	assert.Equal(t, 3, len(searched.Results))
+}
+
+func TestStorer_SearchEntity_phonetic(t *testing.T) {
+	dbURL, tearDown := setUpPostgresTest(t)
+	defer func() {
+		err := tearDown()
+		assert.Nil(t, err)
+	}()
+
+	rng := rand.New(rand.NewSource(0))
+	idGen := id.NewNaiveLuhnGenerator(rng, id.DefaultLength)
+	lg := logging.NewDevLogger(zapcore.DebugLevel)
+	params := storage.NewDefaultParameters()
+	params.Type = bstorage.Postgres
+	s, err := New(dbURL, idGen, params, lg)
+	assert.Nil(t, err)
+	_, err = s.PutEntity(api.NewPatient("", &api.Patient{
+		LastName:  "Thompson",
+		FirstName: "Katherine",
+		Birthdate: &api.Date{Year: 1975, Month: 3, Day: 4},
+	}), false)
+	assert.Nil(t, err)
+	_, err = s.PutEntity(api.NewTestPatient(1, false), false)
+	assert.Nil(t, err)
+
+	searched, err := s.SearchEntity("tomson cathryn", nil, api.MaxSearchLimit, time.Time{},
+		"", false)
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(searched.Results))
+	assert.Equal(t, "Katherine Thompson", searched.Results[0].Entity.Name())
+	assert.Equal(t, float32(1), searched.Results[0].SearcherSimilarities["PatientPhoneticName"])
+}
+
+func TestStorer_SearchEntity_nicknames(t *testing.T) {
+	dbURL, tearDown := setUpPostgresTest(t)
+	defer func() {
+		err := tearDown()
+		assert.Nil(t, err)
+	}()
+
+	rng := rand.New(rand.NewSource(0))
+	idGen := id.NewNaiveLuhnGenerator(rng, id.DefaultLength)
+	lg := logging.NewDevLogger(zapcore.DebugLevel)
+	params := storage.NewDefaultParameters()
+	params.Type = bstorage.Postgres
+	s, err := New(dbURL, idGen, params, lg)
+	assert.Nil(t, err)
+	_, err = s.PutEntity(api.NewPatient("", &api.Patient{
+		LastName:  "Jones",
+		FirstName: "Robert",
+		Birthdate: &api.Date{Year: 1975, Month: 3, Day: 4},
+	}), false)
+	assert.Nil(t, err)
+	_, err = s.PutEntity(api.NewTestPatient(1, false), false)
+	assert.Nil(t, err)
+
+	searched, err := s.SearchEntity("jones bob", nil, api.MaxSearchLimit, time.Time{}, "",
+		false)
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(searched.Results))
+	assert.Equal(t, "Robert Jones", searched.Results[0].Entity.Name())
+	assert.Equal(t, float32(1), searched.Results[0].SearcherSimilarities["PatientNameSynonyms"])
+}
+
+func TestStorer_SearchEntity_folded(t *testing.T) {
+	dbURL, tearDown := setUpPostgresTest(t)
+	defer func() {
+		err := tearDown()
+		assert.Nil(t, err)
+	}()
+
+	rng := rand.New(rand.NewSource(0))
+	idGen := id.NewNaiveLuhnGenerator(rng, id.DefaultLength)
+	lg := logging.NewDevLogger(zapcore.DebugLevel)
+	params := storage.NewDefaultParameters()
+	params.Type = bstorage.Postgres
+	s, err := New(dbURL, idGen, params, lg)
+	assert.Nil(t, err)
+	_, err = s.PutEntity(api.NewPatient("", &api.Patient{
+		LastName:  "Muñoz-O'Brien",
+		FirstName: "Zoë",
+		Birthdate: &api.Date{Year: 1975, Month: 3, Day: 4},
+	}), false)
+	assert.Nil(t, err)
+	_, err = s.PutEntity(api.NewTestPatient(1, false), false)
+	assert.Nil(t, err)
+
+	for _, query := range []string{"munoz obrien zoe", "Muñoz-O'Brien Zoë"} {
+		searched, err := s.SearchEntity(query, nil, api.MaxSearchLimit, time.Time{}, "",
+			false)
+		assert.Nil(t, err)
+		assert.Equal(t, 1, len(searched.Results), query)
+		assert.Equal(t, float32(1), searched.Results[0].SearcherSimilarities["PatientName"],
+			query)
+	}
+
+	filters := &api.SearchFilters{LastNamePrefix: "munoz"}
+	searched, err := s.SearchEntity("zoe obrien", filters, api.MaxSearchLimit, time.Time{}, "",
+		false)
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(searched.Results))
+}
+
+func TestStorer_SearchEntity_entityTypes(t *testing.T) {
+	rng := rand.New(rand.NewSource(0))
+	idGen := id.NewNaiveLuhnGenerator(rng, id.DefaultLength)
+	params := storage.NewDefaultParameters()
+	params.Type = bstorage.Postgres
+	s, err := New("some DB URL", idGen, params, zap.NewNop())
+	assert.Nil(t, err)
+	qr := &fixedQuerier{selectQueryRows: &fixedOfficeRows{}}
+	s.(*storer).qr = qr
+	s.(*storer).newSRM = func() searchResultMerger {
+		return &fixedSearchResultsMerger{}
+	}
+
+	// only the office searchers query the DB
+	filters := &api.SearchFilters{EntityTypes: []string{api.OfficeType}}
+	searched, err := s.SearchEntity("some query", filters, 3, time.Time{}, "", false)
+	assert.Nil(t, err)
+	assert.Empty(t, searched.Results)
+	assert.Equal(t, 2, len(qr.selectedSQLs))
+	for _, qSQL := range qr.selectedSQLs {
+		assert.Contains(t, qSQL, "FROM entity.office")
+	}
+}
+
+func TestStorer_SearchEntity_explain(t *testing.T) {
+	dbURL, tearDown := setUpPostgresTest(t)
+	defer func() {
+		err := tearDown()
+		assert.Nil(t, err)
+	}()
+
+	rng := rand.New(rand.NewSource(0))
+	idGen := id.NewNaiveLuhnGenerator(rng, id.DefaultLength)
+	lg := zap.NewNop()
+	params := storage.NewDefaultParameters()
+	params.Type = bstorage.Postgres
+	s, err := New(dbURL, idGen, params, lg)
+	assert.Nil(t, err)
+
+	// all searchers time out, leaving just the fixed merged result
+	es := storage.NewEntitySim(api.NewTestOffice(1, true), nil)
+	es.Add("OfficeName", 0.5)
+	s.(*storer).qr = &fixedQuerier{selectQueryErr: context.DeadlineExceeded}
+	s.(*storer).newSRM = func() searchResultMerger {
+		return &fixedSearchResultsMerger{topEntitySims: storage.EntitySims{es}}
+	}
+	searched, err := s.SearchEntity("some query", nil, 3, time.Time{}, "", true)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"OfficeEntityID", "OfficeName", "PatientEntityID", "PatientName",
+		"PatientPhoneticName"}, searched.TimedOutSearchers)
+	assert.Equal(t, 1, len(searched.Results))
+
+	// explanation only includes the searcher that matched
+	explanation := searched.Results[0].Explanation
+	assert.Equal(t, 1, len(explanation.Matches))
+	assert.Equal(t, "OfficeName", explanation.Matches[0].Searcher)
+	assert.Equal(t, "SOME QUERY", explanation.Matches[0].Query)
+	assert.Equal(t, float32(0.5), explanation.Matches[0].Similarity)
+	assert.Equal(t, "sqrt(0.5^2) = 0.5", explanation.Combination)
+	assert.Equal(t, 2, len(searched.Results[0].SearcherSimilarities))
 }
 
 func TestStorer_SearchEntity_err(t *testing.T) {
@@ -426,34 +893,1109 @@ func TestStorer_SearchEntity_err(t *testing.T) {
 
 	for desc, c := range cases {
 		s := c.getStorer()
-		result, err := s.SearchEntity(c.query, c.limit)
+		result, err := s.SearchEntity(c.query, nil, c.limit, time.Time{}, "", false)
 		assert.Equal(t, c.expected, err, desc)
 		assert.Nil(t, result, desc)
 	}
 }
 
-type fixedIDGen struct {
-	checkErr    error
-	generateID  string
-	generateErr error
-}
+func TestStorer_DeleteEntity_ok(t *testing.T) {
+	dbURL, tearDown := setUpPostgresTest(t)
+	defer func() {
+		err := tearDown()
+		assert.Nil(t, err)
+	}()
 
-func (f *fixedIDGen) Check(id string) error {
-	return f.checkErr
-}
+	rng := rand.New(rand.NewSource(0))
+	idGen := id.NewNaiveLuhnGenerator(rng, id.DefaultLength)
+	lg := logging.NewDevLogger(zapcore.DebugLevel)
+	params := storage.NewDefaultParameters()
+	params.Type = bstorage.Postgres
+	s, err := New(dbURL, idGen, params, lg)
+	assert.Nil(t, err)
+	assert.NotNil(t, s)
 
-func (f *fixedIDGen) Generate(prefix string) (string, error) {
-	return f.generateID, f.generateErr
-}
+	es := []*api.Entity{
+		api.NewTestPatient(1, false),
+		api.NewTestOffice(1, false),
+	}
+	for _, e := range es {
+		entityID, err := s.PutEntity(e, false)
+		assert.Nil(t, err)
 
-type fixedQuerier struct {
-	selectQueryRows queryRows
-	selectQueryErr  error
-}
+		err = s.DeleteEntity(entityID)
+		assert.Nil(t, err)
+
+		// deleted entity no longer gettable
+		gotten, err := s.GetEntity(entityID, time.Time{})
+		assert.Equal(t, storage.ErrDeletedEntity, err)
+		assert.Nil(t, gotten)
+
+		// deleted entity no longer searchable
+		searched, err := s.SearchEntity(entityID[:4], nil, 8, time.Time{}, "", false)
+		assert.Nil(t, err)
+		found := searched.Results
+		assert.Empty(t, found)
+	}
+}
+
+func TestStorer_DeleteEntity_err(t *testing.T) {
+	dbURL, tearDown := setUpPostgresTest(t)
+	defer func() {
+		err := tearDown()
+		assert.Nil(t, err)
+	}()
+
+	rng := rand.New(rand.NewSource(0))
+	idGen := id.NewNaiveLuhnGenerator(rng, id.DefaultLength)
+	params := storage.NewDefaultParameters()
+	params.Type = bstorage.Postgres
+	lg := zap.NewNop()
+	s, err := New(dbURL, idGen, params, lg)
+	assert.Nil(t, err)
+	assert.NotNil(t, s)
+
+	// bad ID
+	err = s.DeleteEntity("bad ID")
+	assert.NotNil(t, err)
+
+	// missing ID
+	missingID, err := idGen.Generate(storage.Patient.IDPrefix())
+	assert.Nil(t, err)
+	err = s.DeleteEntity(missingID)
+	assert.Equal(t, storage.ErrMissingEntity, err)
+
+	// already deleted
+	entityID, err := s.PutEntity(api.NewTestPatient(0, false), false)
+	assert.Nil(t, err)
+	err = s.DeleteEntity(entityID)
+	assert.Nil(t, err)
+	err = s.DeleteEntity(entityID)
+	assert.Equal(t, storage.ErrDeletedEntity, err)
+
+	// unexpected update error
+	s.(*storer).qr = &fixedQuerier{updateErr: errTest}
+	err = s.DeleteEntity(entityID)
+	assert.Equal(t, errTest, err)
+}
+
+func TestStorer_PatchEntity_ok(t *testing.T) {
+	dbURL, tearDown := setUpPostgresTest(t)
+	defer func() {
+		err := tearDown()
+		assert.Nil(t, err)
+	}()
+
+	rng := rand.New(rand.NewSource(0))
+	idGen := id.NewNaiveLuhnGenerator(rng, id.DefaultLength)
+	lg := zap.NewNop()
+	params := storage.NewDefaultParameters()
+	params.Type = bstorage.Postgres
+	s, err := New(dbURL, idGen, params, lg)
+	assert.Nil(t, err)
+	assert.NotNil(t, s)
+
+	original := api.NewTestPatient(1, false)
+	entityID, err := s.PutEntity(original, false)
+	assert.Nil(t, err)
+
+	patch := api.NewPatient(entityID, &api.Patient{LastName: "New Last Name"})
+	patched, err := s.PatchEntity(patch, []string{"patient.last_name"})
+	assert.Nil(t, err)
+	assert.Equal(t, entityID, patched.EntityId)
+	pp := patched.TypeAttributes.(*api.Entity_Patient).Patient
+	assert.Equal(t, "New Last Name", pp.LastName)
+	assert.Equal(t, uint64(2), patched.Version)
+
+	// unpatched fields keep their values
+	op := original.TypeAttributes.(*api.Entity_Patient).Patient
+	assert.Equal(t, op.FirstName, pp.FirstName)
+	assert.Equal(t, op.MiddleName, pp.MiddleName)
+	assert.Equal(t, op.Birthdate, pp.Birthdate)
+
+	gotten, err := s.GetEntity(entityID, time.Time{})
+	assert.Nil(t, err)
+	assert.Equal(t, patched, gotten)
+
+	// previous version preserved in history
+	history, err := s.GetEntityHistory(entityID)
+	assert.Nil(t, err)
+	assert.Equal(t, 2, len(history))
+	assert.Equal(t, original, history[0].Entity)
+	assert.Equal(t, patched, history[1].Entity)
+}
+
+func TestStorer_PatchEntity_err(t *testing.T) {
+	dbURL, tearDown := setUpPostgresTest(t)
+	defer func() {
+		err := tearDown()
+		assert.Nil(t, err)
+	}()
+
+	rng := rand.New(rand.NewSource(0))
+	idGen := id.NewNaiveLuhnGenerator(rng, id.DefaultLength)
+	lg := zap.NewNop()
+	params := storage.NewDefaultParameters()
+	params.Type = bstorage.Postgres
+	s, err := New(dbURL, idGen, params, lg)
+	assert.Nil(t, err)
+	assert.NotNil(t, s)
+
+	entityID, err := s.PutEntity(api.NewTestPatient(1, false), false)
+	assert.Nil(t, err)
+	missingID, err := idGen.Generate(storage.Patient.IDPrefix())
+	assert.Nil(t, err)
+	okPaths := []string{"patient.last_name"}
+
+	cases := map[string]struct {
+		patch    *api.Entity
+		paths    []string
+		expected error
+	}{
+		"missing patch": {
+			patch:    nil,
+			paths:    okPaths,
+			expected: api.ErrPatchMissingEntity,
+		},
+		"invalid path": {
+			patch:    api.NewPatient(entityID, &api.Patient{}),
+			paths:    []string{"office.name"},
+			expected: api.ErrPatchInvalidPath,
+		},
+		"missing entity": {
+			patch:    api.NewPatient(missingID, &api.Patient{LastName: "Last Name"}),
+			paths:    okPaths,
+			expected: storage.ErrMissingEntity,
+		},
+		"patched entity invalid": {
+			patch:    api.NewPatient(entityID, &api.Patient{}),
+			paths:    okPaths,
+			expected: api.ErrPatientMissingLastName,
+		},
+		"stale version": {
+			patch: &api.Entity{
+				EntityId: entityID,
+				TypeAttributes: &api.Entity_Patient{
+					Patient: &api.Patient{LastName: "Last Name"},
+				},
+				Version: 2,
+			},
+			paths:    okPaths,
+			expected: storage.ErrVersionConflict,
+		},
+	}
+
+	for desc, c := range cases {
+		patched, err := s.PatchEntity(c.patch, c.paths)
+		assert.Equal(t, c.expected, err, desc)
+		assert.Nil(t, patched, desc)
+	}
+
+	// invalid patch leaves the entity unchanged
+	history, err := s.GetEntityHistory(entityID)
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(history))
+}
+
+func TestStorer_MergeEntities_ok(t *testing.T) {
+	dbURL, tearDown := setUpPostgresTest(t)
+	defer func() {
+		err := tearDown()
+		assert.Nil(t, err)
+	}()
+
+	rng := rand.New(rand.NewSource(0))
+	idGen := id.NewNaiveLuhnGenerator(rng, id.DefaultLength)
+	params := storage.NewDefaultParameters()
+	params.Type = bstorage.Postgres
+	s, err := New(dbURL, idGen, params, zap.NewNop())
+	assert.Nil(t, err)
+
+	survivor, merged := api.NewTestPatient(1, false), api.NewTestPatient(2, false)
+	survivorID, err := s.PutEntity(survivor, false)
+	assert.Nil(t, err)
+	mergedID, err := s.PutEntity(merged, false)
+	assert.Nil(t, err)
+
+	gotten, err := s.MergeEntities(survivorID, mergedID)
+	assert.Nil(t, err)
+	assert.Equal(t, survivor, gotten)
+
+	// merged entity redirects to survivor
+	gotten, err = s.GetEntity(mergedID, time.Time{})
+	assert.Nil(t, err)
+	assert.Equal(t, survivor, gotten)
+
+	// merge is kept in history
+	history, err := s.GetEntityHistory(mergedID)
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(history))
+	assert.Equal(t, merged, history[0].Entity)
+	assert.NotNil(t, history[0].ValidTo)
+	assert.Equal(t, survivorID, history[0].MergedIntoId)
+
+	// merged entity as of before the merge
+	mergedFrom, err := ptypes.Timestamp(history[0].ValidFrom)
+	assert.Nil(t, err)
+	gotten, err = s.GetEntity(mergedID, mergedFrom)
+	assert.Nil(t, err)
+	assert.Equal(t, merged, gotten)
+
+	// merged entity can't be updated
+	_, err = s.PutEntity(merged, false)
+	assert.Equal(t, storage.ErrDeletedEntity, err)
+
+	// redirects follow survivors that are merged in turn
+	survivor2 := api.NewTestPatient(3, false)
+	survivor2ID, err := s.PutEntity(survivor2, false)
+	assert.Nil(t, err)
+	_, err = s.MergeEntities(survivor2ID, survivorID)
+	assert.Nil(t, err)
+	gotten, err = s.GetEntity(mergedID, time.Time{})
+	assert.Nil(t, err)
+	assert.Equal(t, survivor2, gotten)
+
+	// batch gets redirect too
+	results, err := s.GetEntities([]string{mergedID, survivorID, survivor2ID})
+	assert.Nil(t, err)
+	for _, result := range results {
+		assert.Nil(t, result.Err)
+		assert.Equal(t, survivor2, result.Entity)
+	}
+}
+
+func TestGetRedirectedResult(t *testing.T) {
+	e := api.NewTestPatient(1, true)
+	found := map[string]*storage.GetResult{
+		"A": {Err: storage.ErrDeletedEntity},
+		"B": {Err: storage.ErrDeletedEntity},
+		"C": {Entity: e},
+		"D": {Err: storage.ErrDeletedEntity},
+		"E": {Err: storage.ErrDeletedEntity},
+	}
+	mergedInto := map[string]string{"A": "B", "B": "C", "D": "E", "E": "D"}
+
+	assert.Equal(t, found["C"], getRedirectedResult("A", found, mergedInto))
+	assert.Equal(t, found["C"], getRedirectedResult("C", found, mergedInto))
+	assert.Equal(t, storage.ErrMergeCycle, getRedirectedResult("D", found, mergedInto).Err)
+	assert.Equal(t, storage.ErrMissingEntity, getRedirectedResult("F", found, mergedInto).Err)
+}
+
+func TestStorer_MergeEntities_err(t *testing.T) {
+	dbURL, tearDown := setUpPostgresTest(t)
+	defer func() {
+		err := tearDown()
+		assert.Nil(t, err)
+	}()
+
+	rng := rand.New(rand.NewSource(0))
+	idGen := id.NewNaiveLuhnGenerator(rng, id.DefaultLength)
+	params := storage.NewDefaultParameters()
+	params.Type = bstorage.Postgres
+	s, err := New(dbURL, idGen, params, zap.NewNop())
+	assert.Nil(t, err)
+
+	patientID, err := s.PutEntity(api.NewTestPatient(1, false), false)
+	assert.Nil(t, err)
+	officeID, err := s.PutEntity(api.NewTestOffice(1, false), false)
+	assert.Nil(t, err)
+	deletedID, err := s.PutEntity(api.NewTestPatient(2, false), false)
+	assert.Nil(t, err)
+	err = s.DeleteEntity(deletedID)
+	assert.Nil(t, err)
+	missingID, err := idGen.Generate(storage.Patient.IDPrefix())
+	assert.Nil(t, err)
+
+	cases := map[string]struct {
+		survivorID string
+		mergedID   string
+		expected   error
+	}{
+		"bad survivor ID": {
+			survivorID: "bad ID",
+			mergedID:   patientID,
+			expected:   id.ErrIncorrectChecksum,
+		},
+		"bad merged ID": {
+			survivorID: patientID,
+			mergedID:   "bad ID",
+			expected:   id.ErrIncorrectChecksum,
+		},
+		"self merge": {
+			survivorID: patientID,
+			mergedID:   patientID,
+			expected:   storage.ErrSelfMerge,
+		},
+		"type mismatch": {
+			survivorID: patientID,
+			mergedID:   officeID,
+			expected:   storage.ErrMergeTypeMismatch,
+		},
+		"missing survivor": {
+			survivorID: missingID,
+			mergedID:   patientID,
+			expected:   storage.ErrMissingEntity,
+		},
+		"deleted merged": {
+			survivorID: patientID,
+			mergedID:   deletedID,
+			expected:   storage.ErrDeletedEntity,
+		},
+	}
+
+	for desc, c := range cases {
+		survivor, err := s.MergeEntities(c.survivorID, c.mergedID)
+		assert.Equal(t, c.expected, err, desc)
+		assert.Nil(t, survivor, desc)
+	}
+}
+
+func TestStorer_RevertEntity_ok(t *testing.T) {
+	dbURL, tearDown := setUpPostgresTest(t)
+	defer func() {
+		err := tearDown()
+		assert.Nil(t, err)
+	}()
+
+	rng := rand.New(rand.NewSource(0))
+	idGen := id.NewNaiveLuhnGenerator(rng, id.DefaultLength)
+	params := storage.NewDefaultParameters()
+	params.Type = bstorage.Postgres
+	s, err := New(dbURL, idGen, params, zap.NewNop())
+	assert.Nil(t, err)
+	original := api.NewTestPatient(1, false)
+	entityID, err := s.PutEntity(original, false)
+	assert.Nil(t, err)
+	clobbered := api.NewTestPatient(2, false)
+	clobbered.EntityId = entityID
+	_, err = s.PutEntity(clobbered, false)
+	assert.Nil(t, err)
+
+	revert := &api.Revert{ToVersion: 1, RevertedBy: "some registrar", Reason: "some reason"}
+	reverted, err := s.RevertEntity(entityID, revert)
+	assert.Nil(t, err)
+	assert.Equal(t, uint64(3), reverted.Version)
+	assert.Equal(t, original.TypeAttributes, reverted.TypeAttributes)
+
+	gotten, err := s.GetEntity(entityID, time.Time{})
+	assert.Nil(t, err)
+	assert.Equal(t, reverted, gotten)
+
+	// revert is kept in history with the new version
+	history, err := s.GetEntityHistory(entityID)
+	assert.Nil(t, err)
+	assert.Equal(t, 3, len(history))
+	assert.Nil(t, history[1].Revert)
+	assert.Equal(t, reverted, history[2].Entity)
+	assert.Equal(t, revert, history[2].Revert)
+}
+
+func TestStorer_RevertEntity_merged(t *testing.T) {
+	dbURL, tearDown := setUpPostgresTest(t)
+	defer func() {
+		err := tearDown()
+		assert.Nil(t, err)
+	}()
+
+	rng := rand.New(rand.NewSource(0))
+	idGen := id.NewNaiveLuhnGenerator(rng, id.DefaultLength)
+	params := storage.NewDefaultParameters()
+	params.Type = bstorage.Postgres
+	s, err := New(dbURL, idGen, params, zap.NewNop())
+	assert.Nil(t, err)
+	survivorID, err := s.PutEntity(api.NewTestPatient(1, false), false)
+	assert.Nil(t, err)
+	original := api.NewTestPatient(2, false)
+	mergedID, err := s.PutEntity(original, false)
+	assert.Nil(t, err)
+	_, err = s.MergeEntities(survivorID, mergedID)
+	assert.Nil(t, err)
+
+	// merged entity can't be reverted past the version that was merged
+	reverted, err := s.RevertEntity(mergedID, &api.Revert{ToVersion: 2})
+	assert.Equal(t, storage.ErrInvalidRevertVersion, err)
+	assert.Nil(t, reverted)
+	gotten, err := s.GetEntity(mergedID, time.Time{})
+	assert.Nil(t, err)
+	assert.Equal(t, survivorID, gotten.EntityId)
+
+	// reverting to the merged version undoes the merge
+	revert := &api.Revert{ToVersion: 1, RevertedBy: "some registrar", Reason: "some reason"}
+	reverted, err = s.RevertEntity(mergedID, revert)
+	assert.Nil(t, err)
+	assert.Equal(t, mergedID, reverted.EntityId)
+	assert.Equal(t, uint64(2), reverted.Version)
+	assert.Equal(t, original.TypeAttributes, reverted.TypeAttributes)
+
+	gotten, err = s.GetEntity(mergedID, time.Time{})
+	assert.Nil(t, err)
+	assert.Equal(t, reverted, gotten)
+	results, err := s.GetEntities([]string{mergedID})
+	assert.Nil(t, err)
+	assert.Equal(t, reverted, results[0].Entity)
+
+	// the merge stays in the history
+	history, err := s.GetEntityHistory(mergedID)
+	assert.Nil(t, err)
+	assert.Equal(t, 2, len(history))
+	assert.Equal(t, survivorID, history[0].MergedIntoId)
+	assert.NotNil(t, history[0].ValidTo)
+	assert.Nil(t, history[0].Revert)
+	assert.Empty(t, history[1].MergedIntoId)
+	assert.Nil(t, history[1].ValidTo)
+	assert.Equal(t, revert, history[1].Revert)
+
+	// and reads as of when it was merged still redirect to the survivor
+	mergedAt, err := ptypes.Timestamp(history[0].ValidTo)
+	assert.Nil(t, err)
+	gotten, err = s.GetEntity(mergedID, mergedAt)
+	assert.Nil(t, err)
+	assert.Equal(t, survivorID, gotten.EntityId)
+}
+
+func TestStorer_RevertEntity_err(t *testing.T) {
+	dbURL, tearDown := setUpPostgresTest(t)
+	defer func() {
+		err := tearDown()
+		assert.Nil(t, err)
+	}()
+
+	rng := rand.New(rand.NewSource(0))
+	idGen := id.NewNaiveLuhnGenerator(rng, id.DefaultLength)
+	params := storage.NewDefaultParameters()
+	params.Type = bstorage.Postgres
+	s, err := New(dbURL, idGen, params, zap.NewNop())
+	assert.Nil(t, err)
+	entityID, err := s.PutEntity(api.NewTestPatient(1, false), false)
+	assert.Nil(t, err)
+	updated := api.NewTestPatient(2, false)
+	updated.EntityId = entityID
+	_, err = s.PutEntity(updated, false)
+	assert.Nil(t, err)
+	deletedID, err := s.PutEntity(api.NewTestPatient(3, false), false)
+	assert.Nil(t, err)
+	err = s.DeleteEntity(deletedID)
+	assert.Nil(t, err)
+	missingID, err := idGen.Generate(storage.Patient.IDPrefix())
+	assert.Nil(t, err)
+
+	cases := map[string]struct {
+		entityID  string
+		toVersion uint64
+		expected  error
+	}{
+		"bad entity ID": {
+			entityID:  "bad ID",
+			toVersion: 1,
+			expected:  id.ErrIncorrectChecksum,
+		},
+		"missing entity": {
+			entityID:  missingID,
+			toVersion: 1,
+			expected:  storage.ErrMissingEntity,
+		},
+		"deleted entity": {
+			entityID:  deletedID,
+			toVersion: 1,
+			expected:  storage.ErrDeletedEntity,
+		},
+		"current version": {
+			entityID:  entityID,
+			toVersion: 2,
+			expected:  storage.ErrInvalidRevertVersion,
+		},
+		"future version": {
+			entityID:  entityID,
+			toVersion: 3,
+			expected:  storage.ErrInvalidRevertVersion,
+		},
+	}
+
+	for desc, c := range cases {
+		revert := &api.Revert{ToVersion: c.toVersion, RevertedBy: "some registrar"}
+		reverted, err := s.RevertEntity(c.entityID, revert)
+		assert.Equal(t, c.expected, err, desc)
+		assert.Nil(t, reverted, desc)
+	}
+}
+
+func TestStorer_GetEntityHistory_ok(t *testing.T) {
+	dbURL, tearDown := setUpPostgresTest(t)
+	defer func() {
+		err := tearDown()
+		assert.Nil(t, err)
+	}()
+
+	rng := rand.New(rand.NewSource(0))
+	idGen := id.NewNaiveLuhnGenerator(rng, id.DefaultLength)
+	lg := logging.NewDevLogger(zapcore.DebugLevel)
+	params := storage.NewDefaultParameters()
+	params.Type = bstorage.Postgres
+	s, err := New(dbURL, idGen, params, lg)
+	assert.Nil(t, err)
+	assert.NotNil(t, s)
+
+	original := api.NewTestOffice(1, false)
+	entityID, err := s.PutEntity(original, false)
+	assert.Nil(t, err)
+	updated := api.NewTestOffice(2, false)
+	updated.EntityId = entityID
+	_, err = s.PutEntity(updated, false)
+	assert.Nil(t, err)
+
+	history, err := s.GetEntityHistory(entityID)
+	assert.Nil(t, err)
+	assert.Equal(t, 2, len(history))
+	assert.Equal(t, original, history[0].Entity)
+	assert.NotNil(t, history[0].ValidFrom)
+	assert.Equal(t, history[1].ValidFrom, history[0].ValidTo)
+	assert.Equal(t, updated, history[1].Entity)
+	assert.Nil(t, history[1].ValidTo)
+
+	// deleted entity history still available
+	err = s.DeleteEntity(entityID)
+	assert.Nil(t, err)
+	history, err = s.GetEntityHistory(entityID)
+	assert.Nil(t, err)
+	assert.Equal(t, 2, len(history))
+	assert.NotNil(t, history[1].ValidTo)
+}
+
+func TestStorer_GetEntityHistory_err(t *testing.T) {
+	dbURL, tearDown := setUpPostgresTest(t)
+	defer func() {
+		err := tearDown()
+		assert.Nil(t, err)
+	}()
+
+	rng := rand.New(rand.NewSource(0))
+	idGen := id.NewNaiveLuhnGenerator(rng, id.DefaultLength)
+	params := storage.NewDefaultParameters()
+	params.Type = bstorage.Postgres
+	lg := zap.NewNop()
+	s, err := New(dbURL, idGen, params, lg)
+	assert.Nil(t, err)
+	assert.NotNil(t, s)
+
+	// bad ID
+	history, err := s.GetEntityHistory("bad ID")
+	assert.NotNil(t, err)
+	assert.Nil(t, history)
+
+	// missing ID
+	missingID, err := idGen.Generate(storage.Patient.IDPrefix())
+	assert.Nil(t, err)
+	history, err = s.GetEntityHistory(missingID)
+	assert.Equal(t, storage.ErrMissingEntity, err)
+	assert.Nil(t, history)
+
+	// unexpected query error
+	s.(*storer).qr = &fixedQuerier{selectQueryErr: errTest}
+	history, err = s.GetEntityHistory(missingID)
+	assert.Equal(t, errTest, err)
+	assert.Nil(t, history)
+}
+func TestStorer_GetSearchEntity_asOf(t *testing.T) {
+	dbURL, tearDown := setUpPostgresTest(t)
+	defer func() {
+		err := tearDown()
+		assert.Nil(t, err)
+	}()
+
+	rng := rand.New(rand.NewSource(0))
+	idGen := id.NewNaiveLuhnGenerator(rng, id.DefaultLength)
+	lg := logging.NewDevLogger(zapcore.DebugLevel)
+	params := storage.NewDefaultParameters()
+	params.Type = bstorage.Postgres
+	s, err := New(dbURL, idGen, params, lg)
+	assert.Nil(t, err)
+	assert.NotNil(t, s)
+
+	original := api.NewTestPatient(1, false)
+	entityID, err := s.PutEntity(original, false)
+	assert.Nil(t, err)
+	updated := api.NewTestPatient(2, false)
+	updated.EntityId = entityID
+	_, err = s.PutEntity(updated, false)
+	assert.Nil(t, err)
+	err = s.DeleteEntity(entityID)
+	assert.Nil(t, err)
+	history, err := s.GetEntityHistory(entityID)
+	assert.Nil(t, err)
+	assert.Equal(t, 2, len(history))
+	originalFrom, err := ptypes.Timestamp(history[0].ValidFrom)
+	assert.Nil(t, err)
+	updatedFrom, err := ptypes.Timestamp(history[1].ValidFrom)
+	assert.Nil(t, err)
+	deletedAt, err := ptypes.Timestamp(history[1].ValidTo)
+	assert.Nil(t, err)
+	query := "Last Name"
+
+	// before creation
+	gotten, err := s.GetEntity(entityID, originalFrom.Add(-time.Nanosecond))
+	assert.Equal(t, storage.ErrMissingEntity, err)
+	assert.Nil(t, gotten)
+	searched, err := s.SearchEntity(query, nil, 8, originalFrom.Add(-time.Nanosecond), "", false)
+	assert.Nil(t, err)
+	found := searched.Results
+	assert.Empty(t, found)
+
+	// original version
+	gotten, err = s.GetEntity(entityID, originalFrom)
+	assert.Nil(t, err)
+	assert.Equal(t, original, gotten)
+	searched, err = s.SearchEntity(query, nil, 8, originalFrom, "", false)
+	assert.Nil(t, err)
+	found = searched.Results
+	assert.Equal(t, 1, len(found))
+	assert.Equal(t, original, found[0].Entity)
+
+	// updated version
+	gotten, err = s.GetEntity(entityID, updatedFrom)
+	assert.Nil(t, err)
+	assert.Equal(t, updated, gotten)
+	searched, err = s.SearchEntity(query, nil, 8, updatedFrom, "", false)
+	assert.Nil(t, err)
+	found = searched.Results
+	assert.Equal(t, 1, len(found))
+	assert.Equal(t, updated, found[0].Entity)
+
+	// after deletion
+	gotten, err = s.GetEntity(entityID, deletedAt)
+	assert.Equal(t, storage.ErrDeletedEntity, err)
+	assert.Nil(t, gotten)
+	searched, err = s.SearchEntity(query, nil, 8, deletedAt, "", false)
+	assert.Nil(t, err)
+	found = searched.Results
+	assert.Empty(t, found)
+}
+
+func TestStorer_PutGetEntities_ok(t *testing.T) {
+	dbURL, tearDown := setUpPostgresTest(t)
+	defer func() {
+		err := tearDown()
+		assert.Nil(t, err)
+	}()
+
+	rng := rand.New(rand.NewSource(0))
+	idGen := id.NewNaiveLuhnGenerator(rng, id.DefaultLength)
+	lg := logging.NewDevLogger(zapcore.DebugLevel)
+	params := storage.NewDefaultParameters()
+	params.Type = bstorage.Postgres
+	s, err := New(dbURL, idGen, params, lg)
+	assert.Nil(t, err)
+	assert.NotNil(t, s)
+
+	// put existing entities to update and delete
+	toUpdate := api.NewTestPatient(1, false)
+	_, err = s.PutEntity(toUpdate, false)
+	assert.Nil(t, err)
+	toDelete := api.NewTestOffice(1, false)
+	_, err = s.PutEntity(toDelete, false)
+	assert.Nil(t, err)
+	err = s.DeleteEntity(toDelete.EntityId)
+	assert.Nil(t, err)
+
+	updated := api.NewTestPatient(2, false)
+	updated.EntityId = toUpdate.EntityId
+	updated.Version = toUpdate.Version
+	deletedUpdated := api.NewTestOffice(2, false)
+	deletedUpdated.EntityId = toDelete.EntityId
+	es := []*api.Entity{
+		api.NewTestPatient(3, false),
+		api.NewTestOffice(3, false),
+		updated,
+		deletedUpdated,
+		{},
+	}
+	putResults, err := s.PutEntities(es, false)
+	assert.Nil(t, err)
+	assert.Equal(t, len(es), len(putResults))
+	for i := 0; i < 3; i++ {
+		assert.Nil(t, putResults[i].Err)
+		assert.Equal(t, es[i].EntityId, putResults[i].EntityID)
+		assert.Equal(t, es[i].Version, putResults[i].Version)
+	}
+	assert.Equal(t, uint64(2), putResults[2].Version)
+	assert.Equal(t, storage.ErrDeletedEntity, putResults[3].Err)
+	assert.Equal(t, api.ErrMissingTypeAttributes, putResults[4].Err)
+
+	missingID, err := idGen.Generate(storage.Patient.IDPrefix())
+	assert.Nil(t, err)
+	entityIDs := []string{
+		es[0].EntityId,
+		es[1].EntityId,
+		updated.EntityId,
+		toDelete.EntityId,
+		missingID,
+		"bad ID",
+	}
+	getResults, err := s.GetEntities(entityIDs)
+	assert.Nil(t, err)
+	assert.Equal(t, len(entityIDs), len(getResults))
+	assert.Equal(t, es[0], getResults[0].Entity)
+	assert.Equal(t, es[1], getResults[1].Entity)
+	assert.Equal(t, updated, getResults[2].Entity)
+	for i := 0; i < 3; i++ {
+		assert.Nil(t, getResults[i].Err)
+	}
+	assert.Equal(t, storage.ErrDeletedEntity, getResults[3].Err)
+	assert.Equal(t, storage.ErrMissingEntity, getResults[4].Err)
+	assert.NotNil(t, getResults[5].Err)
+	for i := 3; i < len(entityIDs); i++ {
+		assert.Nil(t, getResults[i].Entity)
+	}
+}
+
+func TestStorer_PutGetEntities_err(t *testing.T) {
+	dbURL, tearDown := setUpPostgresTest(t)
+	defer func() {
+		err := tearDown()
+		assert.Nil(t, err)
+	}()
+
+	rng := rand.New(rand.NewSource(0))
+	idGen := id.NewNaiveLuhnGenerator(rng, id.DefaultLength)
+	params := storage.NewDefaultParameters()
+	params.Type = bstorage.Postgres
+	s, err := New(dbURL, idGen, params, zap.NewNop())
+	assert.Nil(t, err)
+	assert.NotNil(t, s)
+	putResults, err := s.PutEntities(nil, false)
+	assert.Equal(t, api.ErrEmptyBatch, err)
+	assert.Nil(t, putResults)
+
+	getResults, err := s.GetEntities(make([]string, api.MaxBatchSize+1))
+	assert.Equal(t, api.ErrBatchTooLarge, err)
+	assert.Nil(t, getResults)
+}
+
+func TestStorer_ListEntities_ok(t *testing.T) {
+	dbURL, tearDown := setUpPostgresTest(t)
+	defer func() {
+		err := tearDown()
+		assert.Nil(t, err)
+	}()
+
+	rng := rand.New(rand.NewSource(0))
+	idGen := id.NewNaiveLuhnGenerator(rng, id.DefaultLength)
+	lg := logging.NewDevLogger(zapcore.DebugLevel)
+	params := storage.NewDefaultParameters()
+	params.Type = bstorage.Postgres
+	s, err := New(dbURL, idGen, params, lg)
+	assert.Nil(t, err)
+	assert.NotNil(t, s)
+
+	entityIDs := make([]string, 0)
+	for i := 0; i < 5; i++ {
+		entityID, err := s.PutEntity(api.NewTestPatient(i, false), false)
+		assert.Nil(t, err)
+		entityIDs = append(entityIDs, entityID)
+	}
+	_, err = s.PutEntity(api.NewTestOffice(0, false), false)
+	assert.Nil(t, err)
+	err = s.DeleteEntity(entityIDs[0])
+	assert.Nil(t, err)
+	entityIDs = entityIDs[1:]
+	sort.Strings(entityIDs)
+
+	// list patients two per page
+	listed := make([]string, 0)
+	pageToken, nPages := "", 0
+	for {
+		page, nextPageToken, err := s.ListEntities(storage.Patient, pageToken, 2)
+		assert.Nil(t, err)
+		assert.True(t, len(page) <= 2)
+		for _, e := range page {
+			listed = append(listed, e.EntityId)
+		}
+		nPages++
+		if nextPageToken == "" {
+			break
+		}
+		pageToken = nextPageToken
+	}
+	assert.Equal(t, entityIDs, listed)
+	assert.Equal(t, 2, nPages)
+
+	// list offices with default page size
+	page, nextPageToken, err := s.ListEntities(storage.Office, "", 0)
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(page))
+	assert.Empty(t, nextPageToken)
+}
+
+func TestStorer_ListEntities_err(t *testing.T) {
+	dbURL, tearDown := setUpPostgresTest(t)
+	defer func() {
+		err := tearDown()
+		assert.Nil(t, err)
+	}()
+
+	rng := rand.New(rand.NewSource(0))
+	idGen := id.NewNaiveLuhnGenerator(rng, id.DefaultLength)
+	params := storage.NewDefaultParameters()
+	params.Type = bstorage.Postgres
+	s, err := New(dbURL, idGen, params, zap.NewNop())
+	assert.Nil(t, err)
+	assert.NotNil(t, s)
+	okPageToken := storage.EncodePageToken(storage.Patient.IDPrefix() + "AAAAAAA")
+
+	page, nextPageToken, err := s.ListEntities(storage.Office, okPageToken, 2)
+	assert.Equal(t, storage.ErrInvalidPageToken, err)
+	assert.Nil(t, page)
+	assert.Empty(t, nextPageToken)
+
+	page, nextPageToken, err = s.ListEntities(storage.Patient, okPageToken,
+		api.MaxListPageSize+1)
+	assert.Equal(t, api.ErrListPageSizeTooLarge, err)
+	assert.Nil(t, page)
+	assert.Empty(t, nextPageToken)
+}
+
+func TestStorer_FindDuplicates_ok(t *testing.T) {
+	dbURL, tearDown := setUpPostgresTest(t)
+	defer func() {
+		err := tearDown()
+		assert.Nil(t, err)
+	}()
+
+	rng := rand.New(rand.NewSource(0))
+	idGen := id.NewNaiveLuhnGenerator(rng, id.DefaultLength)
+	lg := logging.NewDevLogger(zapcore.DebugLevel)
+	params := storage.NewDefaultParameters()
+	params.Type = bstorage.Postgres
+	s, err := New(dbURL, idGen, params, lg)
+	assert.Nil(t, err)
+	assert.NotNil(t, s)
+
+	birthdate1 := &api.Date{Year: 1980, Month: 1, Day: 1}
+	birthdate2 := &api.Date{Year: 1990, Month: 1, Day: 1}
+	es := []*api.Entity{
+		api.NewPatient("", &api.Patient{LastName: "Smith", FirstName: "John",
+			Birthdate: birthdate1}),
+		api.NewPatient("", &api.Patient{LastName: "Smyth", FirstName: "John",
+			Birthdate: birthdate1}),
+		api.NewPatient("", &api.Patient{LastName: "Smith", FirstName: "John",
+			Birthdate: birthdate2}),
+		api.NewPatient("", &api.Patient{LastName: "Doe", FirstName: "Jane",
+			Birthdate: birthdate1}),
+		api.NewPatient("", &api.Patient{LastName: "Smith", FirstName: "John",
+			Birthdate: birthdate1}),
+		api.NewTestOffice(0, false),
+	}
+	for _, e := range es {
+		_, err = s.PutEntity(e, false)
+		assert.Nil(t, err)
+	}
+	err = s.DeleteEntity(es[4].EntityId)
+	assert.Nil(t, err)
+
+	pairs, err := s.FindDuplicates(0.5, 10)
+	assert.Nil(t, err)
+	assert.Equal(t, 2, len(pairs))
+	assert.InDelta(t, float32(8)/14, pairs[0].NameSimilarity, 1e-6)
+	assert.True(t, pairs[0].BirthdateMatch)
+	assert.InDelta(t, float32(1), pairs[1].NameSimilarity, 1e-6)
+	assert.False(t, pairs[1].BirthdateMatch)
+	for _, pair := range pairs {
+		assert.True(t, pair.Entity1.EntityId < pair.Entity2.EntityId)
+	}
+
+	pairs, err = s.FindDuplicates(0.5, 1)
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(pairs))
+	assert.True(t, pairs[0].BirthdateMatch)
+
+	pairs, err = s.FindDuplicates(api.DefaultDuplicateThreshold, 10)
+	assert.Nil(t, err)
+	assert.Empty(t, pairs)
+}
+
+func TestStorer_FindDuplicates_err(t *testing.T) {
+	rng := rand.New(rand.NewSource(0))
+	idGen := id.NewNaiveLuhnGenerator(rng, id.DefaultLength)
+	params := storage.NewDefaultParameters()
+	params.Type = bstorage.Postgres
+	s, err := New("some DB URL", idGen, params, zap.NewNop())
+	assert.Nil(t, err)
+	s.(*storer).qr = &fixedQuerier{selectQueryErr: errTest}
+
+	pairs, err := s.FindDuplicates(api.DefaultDuplicateThreshold, api.DefaultDuplicatesLimit)
+	assert.Equal(t, errTest, err)
+	assert.Nil(t, pairs)
+}
+
+func TestStorer_MatchPatient_ok(t *testing.T) {
+	dbURL, tearDown := setUpPostgresTest(t)
+	defer func() {
+		err := tearDown()
+		assert.Nil(t, err)
+	}()
+
+	rng := rand.New(rand.NewSource(0))
+	idGen := id.NewNaiveLuhnGenerator(rng, id.DefaultLength)
+	lg := logging.NewDevLogger(zapcore.DebugLevel)
+	params := storage.NewDefaultParameters()
+	params.Type = bstorage.Postgres
+	s, err := New(dbURL, idGen, params, lg)
+	assert.Nil(t, err)
+	assert.NotNil(t, s)
+
+	birthdate := &api.Date{Year: 1980, Month: 1, Day: 1}
+	same := api.NewPatient("", &api.Patient{LastName: "Smith", FirstName: "John",
+		Birthdate: birthdate})
+	sameBirthdate := api.NewPatient("", &api.Patient{LastName: "Doe", FirstName: "Jane",
+		Birthdate: birthdate})
+	similarName := api.NewPatient("", &api.Patient{LastName: "Smyth", FirstName: "John",
+		Birthdate: &api.Date{Year: 1990, Month: 1, Day: 1}})
+	unrelated := api.NewPatient("", &api.Patient{LastName: "Roe", FirstName: "Ann",
+		Birthdate: &api.Date{Year: 1970, Month: 1, Day: 1}})
+	for _, e := range []*api.Entity{same, sameBirthdate, similarName, unrelated,
+		api.NewTestOffice(0, false)} {
+		_, err = s.PutEntity(e, false)
+		assert.Nil(t, err)
+	}
+
+	p := &api.Patient{LastName: "SMITH", FirstName: "John", Birthdate: birthdate}
+	ms, err := s.MatchPatient(p, api.DefaultMatchLimit)
+	assert.Nil(t, err)
+	assert.Equal(t, 3, len(ms))
+	assert.Equal(t, same.EntityId, ms[0].Entity.EntityId)
+	assert.Equal(t, api.Match, ms[0].Classification)
+	for _, m := range ms[1:] {
+		assert.NotEqual(t, unrelated.EntityId, m.Entity.EntityId)
+		assert.NotEqual(t, api.Match, m.Classification)
+	}
+
+	ms, err = s.MatchPatient(p, 1)
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(ms))
+	assert.Equal(t, same.EntityId, ms[0].Entity.EntityId)
+}
+
+func TestStorer_MatchPatient_err(t *testing.T) {
+	rng := rand.New(rand.NewSource(0))
+	idGen := id.NewNaiveLuhnGenerator(rng, id.DefaultLength)
+	params := storage.NewDefaultParameters()
+	params.Type = bstorage.Postgres
+	s, err := New("some DB URL", idGen, params, zap.NewNop())
+	assert.Nil(t, err)
+	s.(*storer).qr = &fixedQuerier{selectQueryErr: errTest}
+
+	ms, err := s.MatchPatient(api.NewTestPatient(1, false).GetPatient(), api.DefaultMatchLimit)
+	assert.Equal(t, errTest, err)
+	assert.Nil(t, ms)
+}
+
+func TestStorer_Autocomplete_ok(t *testing.T) {
+	dbURL, tearDown := setUpPostgresTest(t)
+	defer func() {
+		err := tearDown()
+		assert.Nil(t, err)
+	}()
+
+	rng := rand.New(rand.NewSource(0))
+	idGen := id.NewNaiveLuhnGenerator(rng, id.DefaultLength)
+	lg := logging.NewDevLogger(zapcore.DebugLevel)
+	params := storage.NewDefaultParameters()
+	params.Type = bstorage.Postgres
+	s, err := New(dbURL, idGen, params, lg)
+	assert.Nil(t, err)
+	assert.NotNil(t, s)
+
+	birthdate := &api.Date{Year: 1980, Month: 1, Day: 1}
+	munoz := api.NewPatient("", &api.Patient{LastName: "Muñoz", FirstName: "José",
+		Birthdate: birthdate})
+	murray := api.NewPatient("", &api.Patient{LastName: "Murray", FirstName: "Ann",
+		Birthdate: birthdate})
+	deleted := api.NewPatient("", &api.Patient{LastName: "Mueller", FirstName: "Eva",
+		Birthdate: birthdate})
+	office := api.NewOffice("", &api.Office{Name: "Mount Sinai Clinic"})
+	for _, e := range []*api.Entity{munoz, murray, deleted, office} {
+		_, err = s.PutEntity(e, false)
+		assert.Nil(t, err)
+	}
+	assert.Nil(t, s.DeleteEntity(deleted.EntityId))
+
+	suggestions, err := s.Autocomplete("mu", nil, api.DefaultAutocompleteLimit)
+	assert.Nil(t, err)
+	assert.Equal(t, []*api.Suggestion{
+		{EntityId: munoz.EntityId, EntityType: api.PatientType, DisplayName: "José Muñoz"},
+		{EntityId: murray.EntityId, EntityType: api.PatientType, DisplayName: "Ann Murray"},
+	}, suggestions)
+
+	// first name followed by last name, ignoring accents
+	suggestions, err = s.Autocomplete("jose mun", nil, api.DefaultAutocompleteLimit)
+	assert.Nil(t, err)
+	assert.Len(t, suggestions, 1)
+	assert.Equal(t, munoz.EntityId, suggestions[0].EntityId)
+
+	suggestions, err = s.Autocomplete("mo", []string{api.OfficeType}, 1)
+	assert.Nil(t, err)
+	assert.Equal(t, []*api.Suggestion{
+		{EntityId: office.EntityId, EntityType: api.OfficeType, DisplayName: "Mount Sinai Clinic"},
+	}, suggestions)
+
+	suggestions, err = s.Autocomplete("mu", nil, 1)
+	assert.Nil(t, err)
+	assert.Len(t, suggestions, 1)
+	assert.Equal(t, munoz.EntityId, suggestions[0].EntityId)
+}
+
+func TestStorer_Autocomplete_err(t *testing.T) {
+	rng := rand.New(rand.NewSource(0))
+	idGen := id.NewNaiveLuhnGenerator(rng, id.DefaultLength)
+	params := storage.NewDefaultParameters()
+	params.Type = bstorage.Postgres
+	s, err := New("some DB URL", idGen, params, zap.NewNop())
+	assert.Nil(t, err)
+
+	suggestions, err := s.Autocomplete("mu", []string{"OTHER"}, 1)
+	assert.Equal(t, storage.ErrUnknownEntityType, err)
+	assert.Nil(t, suggestions)
+
+	s.(*storer).qr = &fixedQuerier{selectQueryErr: errTest}
+	suggestions, err = s.Autocomplete("mu", nil, 1)
+	assert.Equal(t, errTest, err)
+	assert.Nil(t, suggestions)
+}
+
+type fixedIDGen struct {
+	checkErr    error
+	generateID  string
+	generateErr error
+}
+
+func (f *fixedIDGen) Check(id string) error {
+	return f.checkErr
+}
+
+func (f *fixedIDGen) Generate(prefix string) (string, error) {
+	return f.generateID, f.generateErr
+}
+
+type fixedQuerier struct {
+	selectQueryRows queryRows
+	selectQueryErr  error
+	selectedSQLs    []string
+	mu              sync.Mutex
+	updateResult    sql.Result
+	updateErr       error
+	updateQueryRows queryRows
+	updateQueryErr  error
+}
 
 func (f *fixedQuerier) SelectQueryContext(
 	ctx context.Context, b sq.SelectBuilder,
 ) (queryRows, error) {
+	qSQL, _, err := b.ToSql()
+	if err != nil {
+		return nil, err
+	}
+	f.mu.Lock()
+	f.selectedSQLs = append(f.selectedSQLs, qSQL)
+	f.mu.Unlock()
 	return f.selectQueryRows, f.selectQueryErr
 }
 
@@ -472,11 +2014,18 @@ func (f *fixedQuerier) InsertExecContext(
 func (f *fixedQuerier) UpdateExecContext(
 	ctx context.Context, b sq.UpdateBuilder,
 ) (sql.Result, error) {
-	panic("implement me")
+	return f.updateResult, f.updateErr
+}
+
+func (f *fixedQuerier) UpdateQueryContext(
+	ctx context.Context, b sq.UpdateBuilder,
+) (queryRows, error) {
+	return f.updateQueryRows, f.updateQueryErr
 }
 
 type fixedSearchResultsMerger struct {
 	mergeN        int
+	mergeDelay    time.Duration
 	mergeErr      error
 	topEntitySims storage.EntitySims
 }
@@ -484,6 +2033,7 @@ type fixedSearchResultsMerger struct {
 func (srm *fixedSearchResultsMerger) merge(
 	rows queryRows, searchName string, et storage.EntityType,
 ) (int, error) {
+	time.Sleep(srm.mergeDelay)
 	return srm.mergeN, srm.mergeErr
 }
 
@@ -0,0 +1,218 @@
+package storage
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+	"go.uber.org/zap/zapcore"
+)
+
+const (
+	searcherWeightsSep     = ","
+	searcherWeightValueSep = "="
+)
+
+var (
+	// ErrUnknownCombination indicates when a similarity combination name is not one of the
+	// known combinations.
+	ErrUnknownCombination = errors.New("unknown similarity combination")
+
+	// ErrInvalidSearcherWeight indicates when a searcher weight is not a non-negative number
+	// for a named searcher.
+	ErrInvalidSearcherWeight = errors.New("invalid searcher weight")
+
+	// ErrUnknownSearcher indicates when a searcher weight is for a searcher name that is not
+	// one of the SearcherNames.
+	ErrUnknownSearcher = errors.New("unknown searcher")
+)
+
+// names of the searchers, which both storers search with
+const (
+	PatientEntityIDSearcher     = "PatientEntityID"
+	PatientNameSearcher         = "PatientName"
+	PatientPhoneticNameSearcher = "PatientPhoneticName"
+	PatientNameSynonymsSearcher = "PatientNameSynonyms"
+	PatientBirthdateSearcher    = "PatientBirthdate"
+	OfficeEntityIDSearcher      = "OfficeEntityID"
+	OfficeNameSearcher          = "OfficeName"
+)
+
+// SearcherNames are the names of the searchers that searcher weights may be given for.
+var SearcherNames = []string{
+	PatientEntityIDSearcher,
+	PatientNameSearcher,
+	PatientPhoneticNameSearcher,
+	PatientNameSynonymsSearcher,
+	PatientBirthdateSearcher,
+	OfficeEntityIDSearcher,
+	OfficeNameSearcher,
+}
+
+// Combination is a way of combining the weighted similarities of an entity to the query from
+// each searcher into its overall similarity.
+type Combination int
+
+const (
+	// L2 combines the weighted similarities with their L2 norm, i.e., the square root of the
+	// sum of their squares.
+	L2 Combination = iota
+
+	// WeightedSum combines the weighted similarities with their sum.
+	WeightedSum
+
+	// Max combines the weighted similarities with their max.
+	Max
+)
+
+var combinationNames = map[Combination]string{
+	L2:          "L2",
+	WeightedSum: "SUM",
+	Max:         "MAX",
+}
+
+// String returns the name of the combination.
+func (c Combination) String() string {
+	return combinationNames[c]
+}
+
+// ParseCombination returns the combination with the given name, ignoring case.
+func ParseCombination(name string) (Combination, error) {
+	for c, cName := range combinationNames {
+		if strings.EqualFold(name, cName) {
+			return c, nil
+		}
+	}
+	return 0, errors.Wrap(ErrUnknownCombination, name)
+}
+
+// SearcherWeights are the weights of the similarities from each searcher, keyed by searcher name.
+type SearcherWeights map[string]float32
+
+// ParseSearcherWeights parses comma-separated searcher weights of the form name=weight, e.g.,
+// "PatientEntityID=0.25,OfficeEntityID=0.25". Each name must be one of the SearcherNames.
+func ParseSearcherWeights(s string) (SearcherWeights, error) {
+	weights := make(SearcherWeights)
+	if strings.TrimSpace(s) == "" {
+		return weights, nil
+	}
+	for _, term := range strings.Split(s, searcherWeightsSep) {
+		nameWeight := strings.SplitN(term, searcherWeightValueSep, 2)
+		if len(nameWeight) != 2 || strings.TrimSpace(nameWeight[0]) == "" {
+			return nil, errors.Wrap(ErrInvalidSearcherWeight, term)
+		}
+		weight, err := strconv.ParseFloat(strings.TrimSpace(nameWeight[1]), 32)
+		if err != nil || weight < 0 {
+			return nil, errors.Wrap(ErrInvalidSearcherWeight, term)
+		}
+		name := strings.TrimSpace(nameWeight[0])
+		if !isSearcherName(name) {
+			return nil, errors.Wrap(ErrUnknownSearcher, name)
+		}
+		weights[name] = float32(weight)
+	}
+	return weights, nil
+}
+
+func isSearcherName(name string) bool {
+	for _, searcherName := range SearcherNames {
+		if name == searcherName {
+			return true
+		}
+	}
+	return false
+}
+
+// MarshalLogObject writes the searcher weights to the given object encoder.
+func (sw SearcherWeights) MarshalLogObject(enc zapcore.ObjectEncoder) error {
+	for searcher, weight := range sw {
+		enc.AddFloat32(searcher, weight)
+	}
+	return nil
+}
+
+// Ranking defines how the similarities of an entity to the query from each searcher combine into
+// its overall similarity.
+type Ranking struct {
+	// Combination combines the weighted similarities.
+	Combination Combination
+
+	// SearcherWeights are the weights the similarities are multiplied by before combining
+	// them. Searchers without a weight have weight 1.
+	SearcherWeights SearcherWeights
+}
+
+// NewDefaultRanking returns a *Ranking that combines the unweighted similarities with their L2
+// norm.
+func NewDefaultRanking() *Ranking {
+	return &Ranking{
+		Combination:     L2,
+		SearcherWeights: make(SearcherWeights),
+	}
+}
+
+func (r *Ranking) weight(searcher string) float32 {
+	if weight, in := r.SearcherWeights[searcher]; in {
+		return weight
+	}
+	return 1
+}
+
+// combine returns the combined similarity of the similarities keyed by searcher name.
+func (r *Ranking) combine(sims searcherSimilarities) float32 {
+	var combined float32
+	for searcher, sim := range sims {
+		weighted := r.weight(searcher) * sim
+		switch r.Combination {
+		case L2:
+			combined += weighted * weighted
+		case WeightedSum:
+			combined += weighted
+		case Max:
+			if weighted > combined {
+				combined = weighted
+			}
+		}
+	}
+	if r.Combination == L2 {
+		return float32(math.Sqrt(float64(combined)))
+	}
+	return combined
+}
+
+// explain returns how the similarities keyed by searcher name combine into the combined
+// similarity, with the searchers ordered by name.
+func (r *Ranking) explain(sims searcherSimilarities, combined float32) string {
+	searcherNames := make([]string, 0, len(sims))
+	for searcherName := range sims {
+		searcherNames = append(searcherNames, searcherName)
+	}
+	sort.Strings(searcherNames)
+	terms := make([]string, len(searcherNames))
+	for i, searcherName := range searcherNames {
+		weighted := fmt.Sprintf("%g", sims[searcherName])
+		if weight := r.weight(searcherName); weight != 1 {
+			weighted = fmt.Sprintf("%g*%g", weight, sims[searcherName])
+		}
+		switch r.Combination {
+		case L2:
+			if strings.Contains(weighted, "*") {
+				weighted = "(" + weighted + ")"
+			}
+			terms[i] = weighted + "^2"
+		default:
+			terms[i] = weighted
+		}
+	}
+	switch r.Combination {
+	case L2:
+		return fmt.Sprintf("sqrt(%s) = %g", strings.Join(terms, " + "), combined)
+	case WeightedSum:
+		return fmt.Sprintf("%s = %g", strings.Join(terms, " + "), combined)
+	default:
+		return fmt.Sprintf("max(%s) = %g", strings.Join(terms, ", "), combined)
+	}
+}
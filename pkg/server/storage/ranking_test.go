@@ -0,0 +1,85 @@
+package storage
+
+import (
+	"math"
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseCombination(t *testing.T) {
+	for _, c := range []Combination{L2, WeightedSum, Max} {
+		parsed, err := ParseCombination(c.String())
+		assert.Nil(t, err)
+		assert.Equal(t, c, parsed)
+	}
+	parsed, err := ParseCombination("sum")
+	assert.Nil(t, err)
+	assert.Equal(t, WeightedSum, parsed)
+
+	_, err = ParseCombination("mean")
+	assert.Equal(t, ErrUnknownCombination, errors.Cause(err))
+}
+
+func TestParseSearcherWeights(t *testing.T) {
+	weights, err := ParseSearcherWeights("")
+	assert.Nil(t, err)
+	assert.Empty(t, weights)
+
+	weights, err = ParseSearcherWeights("PatientEntityID=0.25, PatientName = 2")
+	assert.Nil(t, err)
+	assert.Equal(t, SearcherWeights{"PatientEntityID": 0.25, "PatientName": 2}, weights)
+
+	for _, s := range []string{"PatientName", "=1", "PatientName=high", "PatientName=-1"} {
+		weights, err = ParseSearcherWeights(s)
+		assert.Equal(t, ErrInvalidSearcherWeight, errors.Cause(err), s)
+		assert.Nil(t, weights, s)
+	}
+
+	for _, s := range []string{"EntityID=1", "PatientName=1,patientname=2"} {
+		weights, err = ParseSearcherWeights(s)
+		assert.Equal(t, ErrUnknownSearcher, errors.Cause(err), s)
+		assert.Nil(t, weights, s)
+	}
+
+	for _, name := range SearcherNames {
+		weights, err = ParseSearcherWeights(name + "=1")
+		assert.Nil(t, err, name)
+		assert.Equal(t, SearcherWeights{name: 1}, weights)
+	}
+}
+
+func TestRanking(t *testing.T) {
+	cases := map[Combination]struct {
+		sims        searcherSimilarities
+		combined    float32
+		explanation string
+	}{
+		L2: {
+			sims:        searcherSimilarities{"Search1": 0.3, "Search2": 0.8},
+			combined:    1,
+			explanation: "sqrt((2*0.3)^2 + 0.8^2) = 1",
+		},
+		WeightedSum: {
+			sims:        searcherSimilarities{"Search1": 0.25, "Search2": 0.5},
+			combined:    1,
+			explanation: "2*0.25 + 0.5 = 1",
+		},
+		Max: {
+			sims:        searcherSimilarities{"Search1": 0.3, "Search2": 0.8},
+			combined:    0.8,
+			explanation: "max(2*0.3, 0.8) = 0.8",
+		},
+	}
+	for c, expected := range cases {
+		r := &Ranking{Combination: c, SearcherWeights: SearcherWeights{"Search1": 2}}
+		combined := r.combine(expected.sims)
+		assert.InDelta(t, expected.combined, combined, 1e-6, c.String())
+		assert.Equal(t, expected.explanation, r.explain(expected.sims, combined), c.String())
+	}
+
+	// searchers without weights have weight 1
+	sims := searcherSimilarities{"Search1": 0.3, "Search2": 0.8}
+	assert.InDelta(t, math.Sqrt(0.3*0.3+0.8*0.8), NewDefaultRanking().combine(sims), 1e-6)
+}
@@ -1,7 +1,7 @@
 package storage
 
 import (
-	"math"
+	"sort"
 	"time"
 
 	errors2 "github.com/drausin/libri/libri/common/errors"
@@ -16,12 +16,41 @@ var (
 	// ErrMissingEntity indicates when an entity is requested with an ID that does not exist.
 	ErrMissingEntity = errors.New("no entity with given ID")
 
+	// ErrDeletedEntity indicates when an entity is requested with an ID that has been deleted.
+	ErrDeletedEntity = errors.New("entity with given ID has been deleted")
+
 	// ErrDupGenEntityID indicates when a newly generated entity ID already exists.
 	ErrDupGenEntityID = errors.New("duplicate entity ID generated")
 
 	// ErrUnknownEntityType indicates when the entity type is unknown (usually used in default
 	// case of switch statement).
 	ErrUnknownEntityType = errors.New("unknown entity type")
+
+	// ErrVersionConflict indicates when an update's entity version does not match the version
+	// of the stored entity.
+	ErrVersionConflict = errors.New("entity version does not match stored version")
+
+	// ErrMergeTypeMismatch indicates when the entity to merge has a different type than the
+	// survivor.
+	ErrMergeTypeMismatch = errors.New("merged entity type does not match survivor entity type")
+
+	// ErrSelfMerge indicates when an entity is merged into itself.
+	ErrSelfMerge = errors.New("entity cannot be merged into itself")
+
+	// ErrMergeCycle indicates when following the survivors of merged entities leads back to an
+	// entity already followed.
+	ErrMergeCycle = errors.New("merged entity survivors form a cycle")
+
+	// ErrInvalidRevertVersion indicates when an entity is reverted to a version that is not one
+	// of its earlier versions.
+	ErrInvalidRevertVersion = errors.New("no earlier version of entity with given version")
+)
+
+const (
+	// TransposedBirthdateSimilarity is the birthdate searcher similarity of a patient whose
+	// birthdate matches the query date with its day and month transposed. An exact match has
+	// similarity 1.
+	TransposedBirthdateSimilarity = 0.5
 )
 
 var (
@@ -39,32 +68,179 @@ var (
 	// DefaultSearchQueryTimeout is the default timeout for DB SELECT queries used to in
 	// a Storer's SearchEntity method.
 	DefaultSearchQueryTimeout = 2 * time.Second
+
+	// DefaultDeleteQueryTimeout is the default timeout for DB UPDATE queries used to in a
+	// Storer's DeleteEntity method.
+	DefaultDeleteQueryTimeout = 2 * time.Second
+
+	// DefaultListQueryTimeout is the default timeout for DB SELECT queries used to in a
+	// Storer's ListEntities method.
+	DefaultListQueryTimeout = 5 * time.Second
+
+	// DefaultDedupeQueryTimeout is the default timeout for DB SELECT queries used to in a
+	// Storer's FindDuplicates method.
+	DefaultDedupeQueryTimeout = 30 * time.Second
+
+	// DefaultMatchQueryTimeout is the default timeout for DB SELECT queries used to in a
+	// Storer's MatchPatient method.
+	DefaultMatchQueryTimeout = 2 * time.Second
+
+	// DefaultAutocompleteQueryTimeout is the default timeout for DB SELECT queries used to in a
+	// Storer's Autocomplete method, which is kept short since suggestions are requested as users
+	// type.
+	DefaultAutocompleteQueryTimeout = 250 * time.Millisecond
 )
 
 // Storer stores and retrieves entities.
 type Storer interface {
 
 	// PutEntity inserts a new or updates an existing entity (based on E.EntityId) and returns
-	// the entity ID.
-	PutEntity(e *api.Entity) (string, error)
-
-	// GetEntity retrives the entity with the given entityID.
-	GetEntity(entityID string) (*api.Entity, error)
-
-	// SearchEntity finds {{ limiit }} entities matching the given query, ordered most similar
-	// to least.
-	SearchEntity(query string, limit uint) ([]*api.Entity, error)
+	// the entity ID, setting E.Version to the stored version. Updates preserve the previous
+	// version of the entity in its history and, if E.Version is non-zero, must match the version
+	// of the stored entity. Putting an entity with an unknown E.EntityId returns
+	// ErrMissingEntity unless allowCreate is true, in which case it is inserted with that ID.
+	PutEntity(e *api.Entity, allowCreate bool) (string, error)
+
+	// GetEntity retrives the entity with the given entityID. If asOf is non-zero, it
+	// retrieves the version of the entity valid at that time instead of the current one. If the
+	// entity has been merged into another, it retrieves the survivor instead, whose entity ID
+	// differs from entityID.
+	GetEntity(entityID string, asOf time.Time) (*api.Entity, error)
+
+	// SearchEntity finds {{ limiit }} entities matching the given query and (possibly nil)
+	// filters with their similarities, ordered most similar to least and starting at the given
+	// pageToken. The returned page includes the token for the next page, which is empty when
+	// there are no more results. If asOf is non-zero, it searches the versions of entities valid
+	// at that time instead of the current ones. Pages after the first search the versions valid
+	// when the first was searched, so writes in between don't shift them. If explain is true,
+	// each result includes an explanation of how it was ranked.
+	SearchEntity(
+		query string,
+		filters *api.SearchFilters,
+		limit uint,
+		asOf time.Time,
+		pageToken string,
+		explain bool,
+	) (*SearchPage, error)
+
+	// DeleteEntity marks the entity with the given entityID as deleted, after which it is no
+	// longer returned by GetEntity or SearchEntity.
+	DeleteEntity(entityID string) error
+
+	// GetEntityHistory retrieves every version of the entity with the given entityID, ordered
+	// oldest to newest.
+	GetEntityHistory(entityID string) ([]*api.EntityVersion, error)
+
+	// PutEntities inserts new or updates existing entities together, returning a result for
+	// each entity in the same order. Entities that fail validation get an error in their
+	// result, while the rest are stored atomically. Entities with unknown entity IDs are handled
+	// per allowCreate as in PutEntity.
+	PutEntities(es []*api.Entity, allowCreate bool) ([]*PutResult, error)
+
+	// GetEntities retrieves the entities with the given entityIDs together, returning a result
+	// for each entity ID in the same order. Like GetEntity, the result for an entity merged into
+	// another is the survivor.
+	GetEntities(entityIDs []string) ([]*GetResult, error)
+
+	// ListEntities returns a page of at most pageSize current entities of the given type,
+	// ordered by entity ID and starting after the given pageToken, along with the token for
+	// the next page, which is empty when there are no more entities.
+	ListEntities(et EntityType, pageToken string, pageSize uint) ([]*api.Entity, string, error)
+
+	// PatchEntity updates just the fields at the given update mask paths of the entity with
+	// patch.EntityId to their values in patch and returns the patched entity, which must still be
+	// valid. The previous version of the entity is preserved in its history. If patch.Version is
+	// non-zero, it must match the version of the stored entity.
+	PatchEntity(patch *api.Entity, paths []string) (*api.Entity, error)
+
+	// MergeEntities merges the entity with mergedID into the entity of the same type with
+	// survivorID and returns the survivor. The merged entity's current version is closed and
+	// kept in its history with the survivor's entity ID. Merging an entity into itself returns
+	// ErrSelfMerge.
+	MergeEntities(survivorID, mergedID string) (*api.Entity, error)
+
+	// RevertEntity stores a new version of the entity with the given entityID equal to its
+	// earlier version revert.ToVersion and returns it. The revert is kept in the entity's
+	// history with the new version. Reverting an entity merged into another undoes the merge,
+	// so it may also be reverted to the version that was merged, while the merge stays in its
+	// history.
+	RevertEntity(entityID string, revert *api.Revert) (*api.Entity, error)
+
+	// FindDuplicates finds at most limit pairs of current patients that are probably the same
+	// person, with scores of at least threshold, ordered from highest score to lowest.
+	FindDuplicates(threshold float32, limit uint) ([]*api.DuplicatePair, error)
+
+	// MatchPatient finds at most limit current patients that are candidate matches for the
+	// given patient, weighted and classified with the Parameters' match weights and thresholds,
+	// and ordered from highest weight to lowest.
+	MatchPatient(p *api.Patient, limit uint) ([]*api.PatientMatch, error)
+
+	// Autocomplete suggests at most limit current entities of the given types (or of all types
+	// if none are given) whose folded names start with the folded prefix, ordered by their
+	// folded names.
+	Autocomplete(prefix string, entityTypes []string, limit uint) ([]*api.Suggestion, error)
 
 	// Close handles any necessary cleanup.
 	Close() error
 }
 
+// PutResult is the outcome of putting a single entity in a batch.
+type PutResult struct {
+	EntityID string
+	Version  uint64
+	Err      error
+}
+
+// GetResult is the outcome of getting a single entity in a batch.
+type GetResult struct {
+	Entity *api.Entity
+	Err    error
+}
+
+// SearchPage is a page of results from SearchEntity.
+type SearchPage struct {
+	Results       []*api.SearchResult
+	NextPageToken string
+
+	// TimedOutSearchers are the names of the searchers that timed out before returning all of
+	// their results, making the page partial.
+	TimedOutSearchers []string
+}
+
+// Partial returns whether some searchers timed out, so the results may be missing matches.
+func (p *SearchPage) Partial() bool {
+	return len(p.TimedOutSearchers) > 0
+}
+
 // Parameters defines the parameters of the Storer.
 type Parameters struct {
 	Type               bstorage.Type
 	PutQueryTimeout    time.Duration
 	GetQueryTimeout    time.Duration
 	SearchQueryTimeout time.Duration
+	DeleteQueryTimeout time.Duration
+	ListQueryTimeout   time.Duration
+	DedupeQueryTimeout time.Duration
+	MatchQueryTimeout  time.Duration
+
+	// AutocompleteQueryTimeout is the latency budget of Autocomplete, which is usually much
+	// shorter than the other timeouts.
+	AutocompleteQueryTimeout time.Duration
+
+	// MatchWeights are the weights of each compared patient field when matching a patient.
+	MatchWeights *MatchWeights
+
+	// MatchThreshold and PossibleMatchThreshold are the minimum match weights of candidates
+	// classified as matches and possible matches, respectively.
+	MatchThreshold         float64
+	PossibleMatchThreshold float64
+
+	// Nicknames are the given-name synonyms that patient name searches are expanded with.
+	Nicknames Nicknames
+
+	// Ranking defines how the similarities from each searcher combine into the similarities
+	// search results are ranked by.
+	Ranking *Ranking
 }
 
 // NewDefaultParameters returns a *Parameters object with default values.
@@ -74,6 +250,18 @@ func NewDefaultParameters() *Parameters {
 		PutQueryTimeout:    DefaultPutQueryTimeout,
 		GetQueryTimeout:    DefaultGetQueryTimeout,
 		SearchQueryTimeout: DefaultSearchQueryTimeout,
+		DeleteQueryTimeout: DefaultDeleteQueryTimeout,
+		ListQueryTimeout:   DefaultListQueryTimeout,
+		DedupeQueryTimeout: DefaultDedupeQueryTimeout,
+		MatchQueryTimeout:  DefaultMatchQueryTimeout,
+
+		AutocompleteQueryTimeout: DefaultAutocompleteQueryTimeout,
+
+		MatchWeights:           NewDefaultMatchWeights(),
+		MatchThreshold:         DefaultMatchThreshold,
+		PossibleMatchThreshold: DefaultPossibleMatchThreshold,
+		Nicknames:              NewDefaultNicknames(),
+		Ranking:                NewDefaultRanking(),
 	}
 }
 
@@ -89,16 +277,22 @@ func (ss searcherSimilarities) MarshalLogObject(enc zapcore.ObjectEncoder) error
 // EntitySim contains an *api.Entity and its Similarities to the query for a number of different
 // Searches
 type EntitySim struct {
-	E                  *api.Entity
-	Similarities       searcherSimilarities
-	similaritySuffStat float32
+	E            *api.Entity
+	Similarities searcherSimilarities
+	ranking      *Ranking
+	similarity   float32
 }
 
-// NewEntitySim creates a new *EntitySim for the given *Entity.
-func NewEntitySim(e *api.Entity) *EntitySim {
+// NewEntitySim creates a new *EntitySim for the given *Entity whose similarities combine under
+// the given *Ranking, or the default ranking if it is nil.
+func NewEntitySim(e *api.Entity, r *Ranking) *EntitySim {
+	if r == nil {
+		r = NewDefaultRanking()
+	}
 	return &EntitySim{
 		E:            e,
 		Similarities: make(map[string]float32),
+		ranking:      r,
 	}
 }
 
@@ -114,13 +308,53 @@ func (e *EntitySim) MarshalLogObject(enc zapcore.ObjectEncoder) error {
 // Add adds a new [0, 1] similarity score for the given search name.
 func (e *EntitySim) Add(search string, similarity float32) {
 	e.Similarities[search] = similarity
-	// L-2 suff stat is sum of squares
-	e.similaritySuffStat += similarity * similarity
+	e.similarity = e.ranking.combine(e.Similarities)
 }
 
 // Similarity returns the combined similarity over all the searches.
 func (e *EntitySim) Similarity() float32 {
-	return float32(math.Sqrt(float64(e.similaritySuffStat)))
+	return e.similarity
+}
+
+// SearchResult returns the *api.SearchResult with the entity and its similarities.
+func (e *EntitySim) SearchResult() *api.SearchResult {
+	return &api.SearchResult{
+		Entity:               e.E,
+		Similarity:           e.Similarity(),
+		SearcherSimilarities: e.Similarities,
+	}
+}
+
+// Explain returns the *api.SearchExplanation of how the similarities from the searchers that
+// matched, using the given preprocessed queries (keyed by searcher name), combine into the
+// overall similarity.
+func (e *EntitySim) Explain(searcherQueries map[string]string) *api.SearchExplanation {
+	searcherNames := make([]string, 0, len(e.Similarities))
+	for searcherName := range e.Similarities {
+		searcherNames = append(searcherNames, searcherName)
+	}
+	sort.Strings(searcherNames)
+	matches := make([]*api.SearcherMatch, len(searcherNames))
+	for i, searcherName := range searcherNames {
+		matches[i] = &api.SearcherMatch{
+			Searcher:   searcherName,
+			Query:      searcherQueries[searcherName],
+			Similarity: e.Similarities[searcherName],
+		}
+	}
+	return &api.SearchExplanation{
+		Matches:     matches,
+		Combination: e.ranking.explain(e.Similarities, e.Similarity()),
+	}
+}
+
+// Less returns whether e ranks below the other EntitySim, i.e., whether it has a lower
+// similarity or the same similarity and a larger entity ID, which keeps rankings stable.
+func (e *EntitySim) Less(other *EntitySim) bool {
+	if e.Similarity() == other.Similarity() {
+		return e.E.EntityId > other.E.EntityId
+	}
+	return e.Similarity() < other.Similarity()
 }
 
 // EntitySims is a min-heap of entity Similarities
@@ -140,9 +374,9 @@ func (ess EntitySims) Len() int {
 	return len(ess)
 }
 
-// Less returns whether entity sim i has a similarity less than that of j.
+// Less returns whether entity sim i ranks below entity sim j.
 func (ess EntitySims) Less(i, j int) bool {
-	return ess[i].Similarity() < ess[j].Similarity()
+	return ess[i].Less(ess[j])
 }
 
 // Swap swaps the entity sim i and j.
@@ -14,13 +14,13 @@ import (
 
 func TestEntitySims(t *testing.T) {
 	searchName := "Search1"
-	es1 := NewEntitySim(&api.Entity{EntityId: "entity1"})
+	es1 := NewEntitySim(&api.Entity{EntityId: "entity1"}, nil)
 	es1.Add(searchName, 0.1)
-	es2 := NewEntitySim(&api.Entity{EntityId: "entity2"})
+	es2 := NewEntitySim(&api.Entity{EntityId: "entity2"}, nil)
 	es2.Add(searchName, 0.3)
-	es3 := NewEntitySim(&api.Entity{EntityId: "entity3"})
+	es3 := NewEntitySim(&api.Entity{EntityId: "entity3"}, nil)
 	es3.Add(searchName, 0.2)
-	es4 := NewEntitySim(&api.Entity{EntityId: "entity4"})
+	es4 := NewEntitySim(&api.Entity{EntityId: "entity4"}, nil)
 	es4.Add(searchName, 0.4)
 
 	ess := &EntitySims{}
@@ -38,13 +38,73 @@ func TestEntitySims(t *testing.T) {
 }
 
 func TestEntitySim(t *testing.T) {
-	es := NewEntitySim(&api.Entity{})
+	es := NewEntitySim(&api.Entity{}, nil)
 	es.Add("Search1", 0.2)
 	es.Add("Search2", 0.3)
 	assert.Equal(t, searcherSimilarities{"Search1": 0.2, "Search2": 0.3}, es.Similarities)
 	assert.Equal(t, float32(math.Sqrt(0.2*0.2+0.3*0.3)), es.Similarity())
 }
 
+func TestEntitySim_Less(t *testing.T) {
+	es1 := NewEntitySim(&api.Entity{EntityId: "entity1"}, nil)
+	es1.Add("Search1", 0.2)
+	es2 := NewEntitySim(&api.Entity{EntityId: "entity2"}, nil)
+	es2.Add("Search1", 0.3)
+	es3 := NewEntitySim(&api.Entity{EntityId: "entity3"}, nil)
+	es3.Add("Search1", 0.3)
+
+	// lower similarity ranks below
+	assert.True(t, es1.Less(es2))
+	assert.False(t, es2.Less(es1))
+
+	// same similarity ranks larger entity ID below
+	assert.True(t, es3.Less(es2))
+	assert.False(t, es2.Less(es3))
+}
+
+func TestEntitySim_SearchResult(t *testing.T) {
+	e := api.NewTestPatient(1, true)
+	es := NewEntitySim(e, nil)
+	es.Add("Search1", 0.6)
+	es.Add("Search2", 0.8)
+
+	r := es.SearchResult()
+	assert.Equal(t, e, r.Entity)
+	assert.InDelta(t, 1.0, r.Similarity, 1e-6)
+	assert.Equal(t, map[string]float32{"Search1": 0.6, "Search2": 0.8}, r.SearcherSimilarities)
+}
+
+func TestEntitySim_Explain(t *testing.T) {
+	es := NewEntitySim(api.NewTestPatient(1, true), nil)
+	es.Add("Search2", 0.8)
+	es.Add("Search1", 0.6)
+
+	explanation := es.Explain(map[string]string{
+		"Search1": "QUERY 1",
+		"Search2": "QUERY 2",
+		"Search3": "QUERY 3",
+	})
+	assert.Equal(t, []*api.SearcherMatch{
+		{Searcher: "Search1", Query: "QUERY 1", Similarity: 0.6},
+		{Searcher: "Search2", Query: "QUERY 2", Similarity: 0.8},
+	}, explanation.Matches)
+	assert.Equal(t, "sqrt(0.6^2 + 0.8^2) = 1", explanation.Combination)
+}
+
+func TestEntitySim_ranking(t *testing.T) {
+	r := &Ranking{Combination: Max, SearcherWeights: SearcherWeights{"Search1": 0.5}}
+	es := NewEntitySim(api.NewTestPatient(1, true), r)
+	es.Add("Search1", 0.8)
+	es.Add("Search2", 0.6)
+	assert.Equal(t, float32(0.6), es.Similarity())
+	assert.Equal(t, "max(0.5*0.8, 0.6) = 0.6", es.Explain(nil).Combination)
+}
+
+func TestSearchPage_Partial(t *testing.T) {
+	assert.False(t, (&SearchPage{}).Partial())
+	assert.True(t, (&SearchPage{TimedOutSearchers: []string{"Search1"}}).Partial())
+}
+
 func TestMaybeAddEntityID(t *testing.T) {
 	rng := rand.New(rand.NewSource(0))
 	okIDGen := id.NewNaiveLuhnGenerator(rng, id.DefaultLength)